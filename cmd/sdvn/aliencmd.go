@@ -0,0 +1,381 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of sdvn.
+//
+// sdvn is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// sdvn is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with sdvn. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/seaskycheng/sdvn/cmd/utils"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	alienFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.SyncModeFlag,
+		utils.MainnetFlag,
+		utils.TestnetFlag,
+	}
+	alienCommand = cli.Command{
+		Name:      "alien",
+		Usage:     "Offline inspection of the alien consensus state",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			alienDumpCmd,
+			alienSignersCmd,
+			alienCandidatesCmd,
+			alienTallyCmd,
+			alienPunishedCmd,
+			alienPledgesCmd,
+			alienLocksCmd,
+			alienFulCmd,
+			alienKeysCmd,
+		},
+		Description: `
+The alien commands open the chain database read-only and print the voting
+snapshot of the alien engine. The snapshot is selected by a block number or
+hash, the current head is used if none is given.`,
+	}
+	alienDumpCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienDump),
+		Name:      "dump",
+		Usage:     "Dump the whole voting snapshot as JSON",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienSignersCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienSigners),
+		Name:      "signers",
+		Usage:     "List the signer queue of the snapshot",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienCandidatesCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienCandidates),
+		Name:      "candidates",
+		Usage:     "List the candidates and their state",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienTallyCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienTally),
+		Name:      "tally",
+		Usage:     "List the stake of every candidate",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienPunishedCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienPunished),
+		Name:      "punished",
+		Usage:     "List the punishment of the signers",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienPledgesCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienPledges),
+		Name:      "pledges",
+		Usage:     "List the candidate and flow miner pledges",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienLocksCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienLocks),
+		Name:      "locks",
+		Usage:     "List the reward, flow and bandwidth lock balances",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienFulCmd = cli.Command{
+		Action:    utils.MigrateFlags(alienFul),
+		Name:      "ful",
+		Usage:     "Print the balances of the FUL trie",
+		ArgsUsage: "[<number|hash>]",
+		Flags:     alienFlags,
+	}
+	alienKeysCmd = cli.Command{
+		Action: utils.MigrateFlags(alienKeys),
+		Name:   "keys",
+		Usage:  "List the alien database keys and their sizes",
+		Flags:  alienFlags,
+		Description: `
+This command iterates the snapshots, snapshot diff layers, lock reward caches
+(-l1-/-l2-) and flow miner caches of the alien engine and prints their sizes.`,
+	}
+)
+
+// alienSnapshot opens the snapshot selected by the optional command argument.
+func alienSnapshot(ctx *cli.Context, db ethdb.Database) (*alien.Snapshot, error) {
+	if ctx.NArg() > 1 {
+		return nil, fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil || config.Alien == nil {
+		return nil, errors.New("database does not hold an alien chain")
+	}
+	hash := rawdb.ReadHeadBlockHash(db)
+	if arg := ctx.Args().First(); arg != "" {
+		if len(arg) == 2+2*common.HashLength {
+			hash = common.HexToHash(arg)
+		} else {
+			number, err := strconv.ParseUint(arg, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid block number or hash %q", arg)
+			}
+			if hash = rawdb.ReadCanonicalHash(db, number); hash == (common.Hash{}) {
+				return nil, fmt.Errorf("block %d not found", number)
+			}
+		}
+	}
+	snap, err := alien.LoadSnapshot(config.Alien, db, hash)
+	if err != nil {
+		return nil, fmt.Errorf("no snapshot stored for block %#x: %v", hash, err)
+	}
+	return snap, nil
+}
+
+// withAlienSnapshot runs fn on the snapshot selected by the command argument.
+func withAlienSnapshot(ctx *cli.Context, fn func(snap *alien.Snapshot) error) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	snap, err := alienSnapshot(ctx, db)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Snapshot at block %d (%#x)\n", snap.Number, snap.Hash)
+	return fn(snap)
+}
+
+// sortedAddresses returns the keys of an address map in ascending order.
+func sortedAddresses(m interface{}) []common.Address {
+	var addrs []common.Address
+	switch m := m.(type) {
+	case map[common.Address]*big.Int:
+		for addr := range m {
+			addrs = append(addrs, addr)
+		}
+	case map[common.Address]uint64:
+		for addr := range m {
+			addrs = append(addrs, addr)
+		}
+	case map[common.Address]*alien.PledgeItem:
+		for addr := range m {
+			addrs = append(addrs, addr)
+		}
+	case map[common.Address]*alien.LockBalanceData:
+		for addr := range m {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+func alienDump(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		out, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	})
+}
+
+func alienSigners(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		for i, signer := range snap.Signers {
+			fmt.Printf("  %d. %s\n", i, signer.Hex())
+		}
+		return nil
+	})
+}
+
+func alienCandidates(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		for _, addr := range sortedAddresses(snap.Candidates) {
+			fmt.Printf("  %s state %d\n", addr.Hex(), snap.Candidates[addr])
+		}
+		return nil
+	})
+}
+
+func alienTally(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		for _, addr := range sortedAddresses(snap.Tally) {
+			fmt.Printf("  %s %v\n", addr.Hex(), snap.Tally[addr])
+		}
+		return nil
+	})
+}
+
+func alienPunished(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		for _, addr := range sortedAddresses(snap.Punished) {
+			fmt.Printf("  %s %d\n", addr.Hex(), snap.Punished[addr])
+		}
+		return nil
+	})
+}
+
+func alienPledges(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		for _, pledges := range []struct {
+			name  string
+			items map[common.Address]*alien.PledgeItem
+		}{
+			{"Candidate pledges", snap.CandidatePledge},
+			{"Flow miner pledges", snap.FlowPledge},
+		} {
+			fmt.Printf("%s:\n", pledges.name)
+			for _, addr := range sortedAddresses(pledges.items) {
+				item := pledges.items[addr]
+				fmt.Printf("  %s amount %v paid %v start %d revenue %s\n", addr.Hex(), item.Amount, item.Playment, item.StartHigh, item.RevenueAddress.Hex())
+			}
+		}
+		return nil
+	})
+}
+
+func alienLocks(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		if snap.FlowRevenue == nil {
+			return nil
+		}
+		for _, lock := range []*alien.LockData{snap.FlowRevenue.RewardLock, snap.FlowRevenue.FlowLock, snap.FlowRevenue.BandwidthLock} {
+			if lock == nil {
+				continue
+			}
+			fmt.Printf("Lock %s: %d cached L1, L2 %#x\n", lock.Locktype, len(lock.CacheL1), lock.CacheL2)
+			for _, addr := range sortedAddresses(lock.FlowRevenue) {
+				var (
+					data   = lock.FlowRevenue[addr]
+					reward = new(big.Int)
+					locked = new(big.Int)
+					paid   = new(big.Int)
+				)
+				for _, balance := range data.RewardBalance {
+					reward.Add(reward, balance)
+				}
+				for _, items := range data.LockBalance {
+					for _, item := range items {
+						locked.Add(locked, item.Amount)
+						paid.Add(paid, item.Playment)
+					}
+				}
+				fmt.Printf("  %s reward %v locked %v paid %v\n", addr.Hex(), reward, locked, paid)
+			}
+		}
+		return nil
+	})
+}
+
+func alienFul(ctx *cli.Context) error {
+	return withAlienSnapshot(ctx, func(snap *alien.Snapshot) error {
+		if snap.Ful == nil {
+			fmt.Println("No FUL trie stored")
+			return nil
+		}
+		fmt.Printf("FUL trie root %#x\n", snap.FulHash)
+		balances := snap.Ful.GetAll()
+		total := new(big.Int)
+		for _, addr := range sortedAddresses(balances) {
+			fmt.Printf("  %s %v\n", addr.Hex(), balances[addr])
+			total.Add(total, balances[addr])
+		}
+		fmt.Printf("%d accounts, total %v\n", len(balances), total)
+		return nil
+	})
+}
+
+// alienKeyType names the kind of data stored under an alien key.
+func alienKeyType(key []byte) string {
+	switch {
+	case bytes.HasPrefix(key, []byte("flow-")):
+		return "Flow miner cache"
+	case bytes.HasPrefix(key, []byte("alien-layer-")):
+		return "Snapshot layer"
+	case len(key) == len("alien-")+common.HashLength:
+		return "Snapshot"
+	case bytes.Contains(key, []byte("-l1-")):
+		return "Lock cache L1"
+	case bytes.Contains(key, []byte("-l2-")):
+		return "Lock cache L2"
+	}
+	return "Other"
+}
+
+// alienKeyString prints the textual prefix of a key followed by the hex of
+// its binary suffix.
+func alienKeyString(key []byte) string {
+	if len(key) > common.HashLength && alienKeyType(key) != "Flow miner cache" {
+		return fmt.Sprintf("%s%x", key[:len(key)-common.HashLength], key[len(key)-common.HashLength:])
+	}
+	return string(key)
+}
+
+func alienKeys(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var (
+		types  []string
+		counts = make(map[string]int)
+		sizes  = make(map[string]common.StorageSize)
+	)
+	for _, prefix := range []string{"alien-", "flow-"} {
+		it := db.NewIterator([]byte(prefix), nil)
+		for it.Next() {
+			key, size := it.Key(), common.StorageSize(len(it.Key())+len(it.Value()))
+			typ := alienKeyType(key)
+			if _, ok := counts[typ]; !ok {
+				types = append(types, typ)
+			}
+			counts[typ]++
+			sizes[typ] += size
+			fmt.Printf("  %s %v\n", alienKeyString(key), size)
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		fmt.Printf("%-18s %8d keys %v\n", typ, counts[typ], sizes[typ])
+	}
+	return nil
+}
@@ -21,10 +21,12 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/seaskycheng/sdvn/cmd/utils"
 	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien"
 	"github.com/seaskycheng/sdvn/core/rawdb"
 	"github.com/seaskycheng/sdvn/core/state"
 	"github.com/seaskycheng/sdvn/core/state/pruner"
@@ -77,6 +79,47 @@ WARNING: It's necessary to delete the trie clean cache after the pruning.
 If you specify another directory for the trie clean cache via "--cache.trie.journal"
 during the use of sdvn, please also specify it here for correct deletion. Otherwise
 the trie clean cache with default directory will be deleted.
+`,
+			},
+			{
+				Name:     "prune-alien",
+				Usage:    "Prune stale alien consensus snapshots and caches",
+				Action:   utils.MigrateFlags(pruneAlien),
+				Category: "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.TestnetFlag,
+					utils.AlienGCWindowFlag,
+				},
+				Description: `
+sdvn snapshot prune-alien
+will delete the alien voting snapshots, snapshot diff layers, lock reward
+caches, flow miner caches and FUL trie nodes which are not reachable from
+the canonical chain within the window below the head block. Entries written
+for blocks which were reorged away are deleted too.
+
+The window is set by "--alien.gcwindow", the default is 3600 blocks.
+`,
+			},
+			{
+				Name:      "verify-alien",
+				Usage:     "Replay the alien consensus snapshots from genesis for verification",
+				ArgsUsage: "<number>",
+				Action:    utils.MigrateFlags(verifyAlien),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.TestnetFlag,
+				},
+				Description: `
+sdvn snapshot verify-alien <number>
+will rebuild the alien voting snapshots from genesis over the canonical headers
+up to the given block, the head block by default. Every stored checkpoint
+snapshot is compared with the replayed one and every FUL trie root with the
+FulDataRoot of its header. The first differing field is reported with both
+values.
 `,
 			},
 			{
@@ -194,6 +237,71 @@ func pruneState(ctx *cli.Context) error {
 	return nil
 }
 
+func pruneAlien(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	window := uint64(alien.DefaultSnapshotGCWindow)
+	if ctx.GlobalIsSet(utils.AlienGCWindowFlag.Name) {
+		window = ctx.GlobalUint64(utils.AlienGCWindowFlag.Name)
+	}
+	if err := alien.PruneSnapshots(chaindb, headBlock.NumberU64(), window); err != nil {
+		log.Error("Failed to prune alien snapshots", "err", err)
+		return err
+	}
+	return nil
+}
+
+func verifyAlien(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	config := rawdb.ReadChainConfig(chaindb, rawdb.ReadCanonicalHash(chaindb, 0))
+	if config == nil || config.Alien == nil {
+		log.Error("Database does not hold an alien chain")
+		return errors.New("no alien chain")
+	}
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	number := headBlock.NumberU64()
+	if ctx.NArg() > 1 {
+		log.Error("Too many arguments given")
+		return errors.New("too many arguments")
+	}
+	if ctx.NArg() == 1 {
+		var err error
+		if number, err = strconv.ParseUint(ctx.Args()[0], 0, 64); err != nil {
+			log.Error("Failed to resolve block number", "err", err)
+			return err
+		}
+	}
+	result, err := alien.VerifySnapshots(config.Alien, chaindb, number)
+	if err != nil {
+		log.Error("Failed to verify alien snapshots", "err", err)
+		return err
+	}
+	if mismatch := result.Mismatch; mismatch != nil {
+		log.Error("Alien snapshot diverged", "number", mismatch.Number, "hash", mismatch.Hash, "field", mismatch.Field, "stored", mismatch.Stored, "replayed", mismatch.Replayed)
+		return errors.New("alien snapshot diverged")
+	}
+	log.Info("Alien snapshots are consistent", "head", result.Head, "checkpoints", result.Checkpoints)
+	return nil
+}
+
 func verifyState(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -47,6 +47,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
+			utils.AlienGCWindowFlag,
+			utils.AlienFulHistoryFlag,
+			utils.AlienVoteHistoryFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
@@ -72,6 +75,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: []cli.Flag{
 			utils.DeveloperFlag,
 			utils.DeveloperPeriodFlag,
+			utils.DeveloperEngineFlag,
+			utils.DeveloperFastRewardsFlag,
 		},
 	},
 	{
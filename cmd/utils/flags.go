@@ -160,6 +160,15 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperEngineFlag = cli.StringFlag{
+		Name:  "dev.engine",
+		Usage: `Consensus engine to use in developer mode ("clique" or "alien")`,
+		Value: "clique",
+	}
+	DeveloperFastRewardsFlag = cli.BoolFlag{
+		Name:  "dev.fastrewards",
+		Usage: "Pay alien rewards and release locks within minutes instead of days in developer mode",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -219,6 +228,18 @@ var (
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value: ethconfig.Defaults.TxLookupLimit,
 	}
+	AlienGCWindowFlag = cli.Uint64Flag{
+		Name:  "alien.gcwindow",
+		Usage: "Number of recent blocks to keep the alien snapshots for, older ones are garbage collected (0 = keep all)",
+	}
+	AlienFulHistoryFlag = cli.BoolFlag{
+		Name:  "alien.fulhistory",
+		Usage: "Index the FUL debits and credits of every address for alien_getFulHistory",
+	}
+	AlienVoteHistoryFlag = cli.BoolFlag{
+		Name:  "alien.votehistory",
+		Usage: "Index the vote changes of every voter for alien_getVoteHistory",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -768,7 +789,7 @@ var (
 	// PBFT settings
 	PBFTEnableFlag = cli.BoolFlag{
 		Name:  "pbft",
-		Usage: "PBFT miner coinbase send confirm transaction",
+		Usage: "Sign BFT finality votes for the chain heads with the miner coinbase",
 	}
 
 	// Data side chain settings
@@ -1558,6 +1579,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(AlienGCWindowFlag.Name) {
+		cfg.AlienGCWindow = ctx.GlobalUint64(AlienGCWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(AlienFulHistoryFlag.Name) {
+		cfg.AlienFulHistory = ctx.GlobalBool(AlienFulHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(AlienVoteHistoryFlag.Name) {
+		cfg.AlienVoteHistory = ctx.GlobalBool(AlienVoteHistoryFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1666,7 +1696,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		log.Info("Using developer account", "address", developer.Address)
 
 		// Create a new developer genesis block or reuse existing one
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		period := uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name))
+		switch engine := ctx.GlobalString(DeveloperEngineFlag.Name); engine {
+		case "clique":
+			cfg.Genesis = core.DeveloperGenesisBlock(period, developer.Address)
+		case "alien":
+			var economics *params.AlienEconomics
+			if ctx.GlobalBool(DeveloperFastRewardsFlag.Name) {
+				economics = &params.DeveloperAlienEconomics
+			}
+			cfg.Genesis = core.DeveloperAlienGenesisBlock(period, developer.Address, economics)
+		default:
+			Fatalf("Unknown developer engine %q", engine)
+		}
 		if ctx.GlobalIsSet(DataDirFlag.Name) {
 			// Check if we have an already initialized chain and fall back to
 			// that if so. Otherwise we need to generate a new genesis spec.
@@ -60,6 +60,7 @@ var (
 	totalFlowReward                  = new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(1155000000)) // Block reward in wei
 	defaultEpochLength               = uint64(60480)                                               // Default number of blocks after which vote's period of validity, About one week if period is 10
 	defaultBlockPeriod               = uint64(10)                                                  // Default minimum difference between two consecutive block's timestamps
+	onDemandBlockPeriod              = uint64(1)                                                   // Minimum difference between the timestamps of 0-period developer chains sealing on demand
 	defaultMaxSignerCount            = uint64(21)                                                  //
 	minVoterBalance                  = new(big.Int).Mul(big.NewInt(100), big.NewInt(1e+18))
 	extraVanity                      = 32                                                                // Fixed number of extra-data prefix bytes reserved for signer vanity
@@ -157,12 +158,25 @@ type Alien struct {
 	config     *params.AlienConfig // Consensus engine configuration parameters
 	db         ethdb.Database      // Database to store and retrieve snapshot checkpoints
 	recents    *lru.ARCCache       // Snapshots for recent block to speed up reorgs
+	layers     *lru.ARCCache       // Flattened recent snapshots to compute the next diff layer
 	signatures *lru.ARCCache       // Signatures of recent blocks to speed up mining
+	slots      *lru.ARCCache       // Headers of recently verified seals by slot to detect double signs
 	signer     common.Address      // Ethereum address of the signing key
 	signFn     SignerFn            // Signer function to authorize hashes with
 	signTxFn   SignTxFn            // Sign transaction function to sign tx
 	lock       sync.RWMutex        // Protects the signer fields
 	lcsc       uint64              // Last confirmed side chain
+
+	quit      chan struct{} // Stops the background snapshot migration and collection
+	closeOnce sync.Once     // Ensures quit is only closed once
+	gcWindow  uint64        // Blocks below the head whose snapshots are kept, 0 disables the collection
+	gcRunning int32         // Whether a snapshot collection is running (atomic)
+
+	sealOnDemand bool              // Whether empty blocks are left unsealed, set for 0-period chains sealing on demand
+	now          func() time.Time  // Clock blocks are timed and verified against
+	finality     *finality         // Votes of the finality gadget and the last finalized block
+	fulHistory   *fulHistoryIndex  // Index of the FUL debits and credits, nil unless enabled
+	voteHistory  *voteHistoryIndex // Index of the vote changes, nil unless enabled
 }
 
 // SignerFn hashes and signs the data to be signed by a backing account.
@@ -236,31 +250,53 @@ func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, er
 // New creates a Alien delegated-proof-of-stake consensus engine with the initial
 // signers set to the ones provided by the user.
 func New(config *params.AlienConfig, db ethdb.Database) *Alien {
-	// Set any missing consensus parameters to their defaults
-	conf := *config
-	if conf.Epoch == 0 {
-		conf.Epoch = defaultEpochLength
-	}
-	if conf.Period == 0 {
-		conf.Period = defaultBlockPeriod
-	}
-	if conf.MaxSignerCount == 0 {
-		conf.MaxSignerCount = defaultMaxSignerCount
-	}
+	conf := configWithDefaults(config)
 	if conf.MinVoterBalance.Uint64() > 0 {
 		minVoterBalance = conf.MinVoterBalance
 	}
 
 	// Allocate the snapshot caches and create the engine
 	recents, _ := lru.NewARC(inMemorySnapshots)
+	layers, _ := lru.NewARC(inMemoryLayers)
 	signatures, _ := lru.NewARC(inMemorySignatures)
+	slots, _ := lru.NewARC(inMemorySlots)
 
-	return &Alien{
+	alien := &Alien{
 		config:     &conf,
 		db:         db,
 		recents:    recents,
+		layers:     layers,
 		signatures: signatures,
+		slots:      slots,
+		quit:       make(chan struct{}),
+
+		sealOnDemand: config.Period == 0 && config.SealOnDemand,
+		now:          time.Now,
+		finality:     loadFinality(db),
 	}
+	go migrateSnapshots(db, alien.quit)
+	return alien
+}
+
+// configWithDefaults returns a copy of config with any missing consensus
+// parameters set to their defaults.
+func configWithDefaults(config *params.AlienConfig) params.AlienConfig {
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = defaultEpochLength
+	}
+	if conf.Period == 0 {
+		if conf.SealOnDemand {
+			// 0-period developer chains seal on demand, but still need a period to time the rewards
+			conf.Period = onDemandBlockPeriod
+		} else {
+			conf.Period = defaultBlockPeriod
+		}
+	}
+	if conf.MaxSignerCount == 0 {
+		conf.MaxSignerCount = defaultMaxSignerCount
+	}
+	return conf
 }
 
 // Author implements consensus.Engine, returning the Ethereum address recovered
@@ -305,7 +341,7 @@ func (a *Alien) verifyHeader(chain consensus.ChainHeaderReader, header *types.He
 	}
 
 	// Don't waste time checking blocks from the future
-	if header.Time > uint64(time.Now().Unix()) {
+	if header.Time > uint64(a.now().Unix()) {
 		return consensus.ErrFutureBlock
 	}
 
@@ -354,10 +390,15 @@ func (a *Alien) verifyCascadingFields(chain consensus.ChainHeaderReader, header
 		return ErrInvalidTimestamp
 	}
 	// Retrieve the snapshot needed to verify this header and cache it
-	_, err := a.snapshot(chain, number-1, header.ParentHash, parents, nil, defaultLoopCntRecalculateSigners)
+	snap, err := a.snapshot(chain, number-1, header.ParentHash, parents, nil, defaultLoopCntRecalculateSigners)
 	if err != nil {
 		return err
 	}
+	if !a.config.SideChain {
+		if err := a.verifyRandom(header, snap); err != nil {
+			return err
+		}
+	}
 
 	// All basic checks passed, verify the seal and return
 	return a.verifySeal(chain, header, parents)
@@ -381,13 +422,11 @@ func (a *Alien) snapshot(chain consensus.ChainHeaderReader, number uint64, hash
 			snap = s.(*Snapshot)
 			break
 		}
-		// If an on-disk checkpoint snapshot can be found, use that
-		if number%checkpointInterval == 0 {
-			if s, err := loadSnapshot(a.config, a.signatures, a.db, hash); err == nil {
-				log.Trace("Loaded voting snapshot from disk", "number", number, "hash", hash)
-				snap = s
-				break
-			}
+		// If an on-disk snapshot or diff layer can be found, use that
+		if s, err := loadSnapshot(a.config, a.signatures, a.db, hash); err == nil {
+			log.Trace("Loaded voting snapshot from disk", "number", number, "hash", hash, "layers", s.layers)
+			snap = s
+			break
 		}
 		// If we're at block zero, make a snapshot
 		if number == 0 {
@@ -395,8 +434,16 @@ func (a *Alien) snapshot(chain consensus.ChainHeaderReader, number uint64, hash
 			if err := a.VerifyHeader(chain, genesis, false); err != nil {
 				return nil, err
 			}
-			a.config.Period = chain.Config().Alien.Period
+			if period := chain.Config().Alien.Period; period > 0 {
+				a.config.Period = period
+			}
 			snap = newSnapshot(a.config, a.signatures, genesis.Hash(), genesisVotes, lcrs)
+			if isGeFulTrieNumber(a.config, 1) {
+				// Headers before the first FUL trie block are never applied
+				if err := snap.initFulTrie(a.db); err != nil {
+					return nil, err
+				}
+			}
 			if err := snap.store(a.db); err != nil {
 				return nil, err
 			}
@@ -427,19 +474,37 @@ func (a *Alien) snapshot(chain consensus.ChainHeaderReader, number uint64, hash
 		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
 	}
 
-	snap, err := snap.apply(headers, a.db)
+	parent := snap
+	var votes *voteHistory
+	if a.voteHistory != nil {
+		votes = new(voteHistory)
+	}
+	snap, err := snap.apply(headers, a.db, votes)
 	if err != nil {
 		return nil, err
 	}
+	if votes != nil {
+		if err := a.voteHistory.store(headers, votes); err != nil {
+			log.Warn("Failed to store vote history", "number", snap.Number, "err", err)
+		}
+	}
 
 	a.recents.Add(snap.Hash, snap)
 
-	// If we've generated a new checkpoint snapshot, save to disk
+	// If we've generated a new checkpoint snapshot, save to disk, otherwise
+	// save the changes against the snapshot it was applied on
 	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
 		if err = snap.store(a.db); err != nil {
 			return nil, err
 		}
 		log.Trace("Stored voting snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+		if a.gcWindow > 0 {
+			a.gcSnapshots(snap.Number)
+		}
+	} else if len(headers) > 0 {
+		if err := a.storeLayer(parent, snap); err != nil {
+			log.Warn("Failed to store voting snapshot layer", "number", snap.Number, "hash", snap.Hash, "err", err)
+		}
 	}
 	return snap, err
 }
@@ -482,7 +547,7 @@ func (a *Alien) verifySeal(chain consensus.ChainHeaderReader, header *types.Head
 	}
 
 	// check the coinbase == signer
-	if header.Number.Cmp(big.NewInt(bugFixBlockNumber)) > 0 {
+	if isAfterForkBlock(a.config.IsBugFix, header.Number.Uint64()) {
 		if signer != header.Coinbase {
 			return errUnauthorized
 		}
@@ -522,7 +587,7 @@ func (a *Alien) verifySeal(chain consensus.ChainHeaderReader, header *types.Head
 						return errInvalidSignerQueue
 					}
 				}
-				if signer == parent.Coinbase && header.Time-parent.Time < chain.Config().Alien.Period {
+				if signer == parent.Coinbase && header.Time-parent.Time < a.config.Period {
 					return errInvalidNeighborSigner
 				}
 
@@ -578,6 +643,7 @@ func (a *Alien) verifySeal(chain consensus.ChainHeaderReader, header *types.Head
 		if !snap.inturn(signer, header.Time) {
 			return errUnauthorized
 		}
+		a.detectDoubleSign(header, signer)
 	} else {
 		if notice, loopStartTime, period, signerLength, _, err := a.mcSnapshot(chain, signer, header.Time); err != nil {
 			return err
@@ -659,13 +725,13 @@ func (a *Alien) Prepare(chain consensus.ChainHeaderReader, header *types.Header)
 	// Set the correct difficulty
 	header.Difficulty = new(big.Int).Set(defaultDifficulty)
 	// If now is later than genesis timestamp, skip prepare
-	if a.config.GenesisTimestamp < uint64(time.Now().Unix()) {
+	if a.config.GenesisTimestamp < uint64(a.now().Unix()) {
 		return nil
 	}
 	// Count down for start
 	if header.Number.Uint64() == 1 {
 		for {
-			delay := time.Unix(int64(a.config.GenesisTimestamp-2), 0).Sub(time.Now())
+			delay := time.Unix(int64(a.config.GenesisTimestamp-2), 0).Sub(a.now())
 			if delay <= time.Duration(0) {
 				log.Info("Ready for seal block", "time", time.Now())
 				break
@@ -829,7 +895,11 @@ func (a *Alien) GrantProfit(chain consensus.ChainHeaderReader, header *types.Hea
 	timeNow := time.Now()
 	var playGrantProfit []consensus.GrantProfitRecord
 	var currentGrantProfit []consensus.GrantProfitRecord
-	payAddressAll:= make(map[common.Address]*big.Int)
+	// Before the one-time grant the payments go straight into the state
+	var payAddressAll map[common.Address]*big.Int
+	if isAfterForkBlock(a.config.IsGrantProfitOneTime, number) {
+		payAddressAll = make(map[common.Address]*big.Int)
+	}
 	for address, item := range snap.CandidatePledge {
 		result, amount := paymentPledge(false, item, state, header,payAddressAll)
 		if 0 == result {
@@ -878,7 +948,7 @@ func (a *Alien) GrantProfit(chain consensus.ChainHeaderReader, header *types.Hea
 			})
 		}
 	}
-	currentGrantProfit, playGrantProfit, err = snap.FlowRevenue.payProfit(a.db, chain.Config().Alien.Period, number, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
+	currentGrantProfit, playGrantProfit, err = snap.FlowRevenue.payProfit(a.db, a.config, number, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
 	if err != nil {
 		log.Warn("worker GrantProfit payProfit", "err", err)
 	}
@@ -901,8 +971,8 @@ func (a *Alien) Finalize(chain consensus.ChainHeaderReader, header *types.Header
 		return consensus.ErrPrunedAncestor
 	}
 	header.Time = parent.Time + a.config.Period
-	if int64(header.Time) < time.Now().Unix() {
-		header.Time = uint64(time.Now().Unix())
+	if now := a.now().Unix(); int64(header.Time) < now {
+		header.Time = uint64(now)
 	}
 
 	// Ensure the extra data has all it's components
@@ -974,11 +1044,18 @@ func (a *Alien) Finalize(chain consensus.ChainHeaderReader, header *types.Header
 	}
 	if !chain.Config().Alien.SideChain {
 		// calculate votes write into header.extra
-		mcCurrentHeaderExtra, refundGas, err := a.processCustomTx(currentHeaderExtra, chain, header, state, txs, receipts)
+		var history *fulHistory
+		if a.fulHistory != nil {
+			history = new(fulHistory)
+		}
+		mcCurrentHeaderExtra, refundGas, err := a.processCustomTx(currentHeaderExtra, chain, header, state, txs, receipts, history)
 		if err != nil {
 			return err
 		}
 		currentHeaderExtra = mcCurrentHeaderExtra
+		if history != nil {
+			a.indexFulHistory(header, txs, history, &currentHeaderExtra)
+		}
 		currentHeaderExtra.ConfirmedBlockNumber = snap.getLastConfirmedBlockNumber(currentHeaderExtra.CurrentBlockConfirmations).Uint64()
 		// write signerQueue in first header, from self vote signers in genesis block
 		if number == 1 {
@@ -1021,10 +1098,15 @@ func (a *Alien) Finalize(chain consensus.ChainHeaderReader, header *types.Header
 		for proposer, refund := range snap.calculateProposalRefund() {
 			state.AddBalance(proposer, refund)
 		}
-		if isGeFulTrieNumber(number){
+		if isGeFulTrieNumber(a.config, number){
 			snap1 := snap.copy()
 			currentHeaderExtra.FulDataRoot = snap1.Ful.Root()
 		}
+		if a.config.IsRandomBeacon(header.Number) {
+			if err := a.sealRandom(&currentHeaderExtra, header.Coinbase, number, snap); err != nil {
+				return err
+			}
+		}
 	} else {
 		// use currentHeaderExtra.SignerQueue as signer queue
 		currentHeaderExtra.SignerQueue = append([]common.Address{header.Coinbase}, parentHeaderExtra.SignerQueue...)
@@ -1055,6 +1137,12 @@ func (a *Alien) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *t
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
 }
 
+// SetClock replaces the wall clock blocks are timed and verified against, so
+// simulated chains can run ahead of the real time.
+func (a *Alien) SetClock(now func() time.Time) {
+	a.now = now
+}
+
 // Authorize injects a private key into the consensus engine to mint new blocks with.
 func (a *Alien) Authorize(signer common.Address, signFn SignerFn, signTxFn SignTxFn) {
 	a.lock.Lock()
@@ -1105,7 +1193,7 @@ func (a *Alien) Seal(chain consensus.ChainHeaderReader, block *types.Block, resu
 	}
 
 	// For 0-period chains, refuse to seal empty blocks (no reward but would spin sealing)
-	if a.config.Period == 0 && len(block.Transactions()) == 0 {
+	if a.sealOnDemand && len(block.Transactions()) == 0 {
 		return errWaitTransactions
 	}
 	// Don't hold the signer fields for the entire sealing procedure
@@ -1155,7 +1243,7 @@ func (a *Alien) Seal(chain consensus.ChainHeaderReader, block *types.Block, resu
 	}
 
 	// correct the time
-	delay := time.Unix(int64(header.Time), 0).Sub(time.Now())
+	delay := time.Unix(int64(header.Time), 0).Sub(a.now())
 
 	sighash, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeAlien, AlienRLP(header))
 	if err != nil {
@@ -1195,8 +1283,9 @@ func (a *Alien) SealHash(header *types.Header) common.Hash {
 	return SealHash(header)
 }
 
-// Close implements consensus.Engine. It's a noop for clique as there are no background threads.
+// Close implements consensus.Engine, stopping the background snapshot migration.
 func (a *Alien) Close() error {
+	a.closeOnce.Do(func() { close(a.quit) })
 	return nil
 }
 
@@ -1322,7 +1411,7 @@ func paymentPledge(hasContract bool, pledge *PledgeItem, state *state.StateDB, h
 		} else {
 			payAddress=pledge.MultiSignature
 		}
-		if isGrantProfitOneTimeBlockNumber(header){
+		if payAddressAll != nil {
 			addPayAddressBalance(payAddress,payAddressAll,amount)
 			return 0,amount
 		}else{
@@ -1342,7 +1431,7 @@ func nYearBandwidthReward(n float64) decimal.Decimal {
 }
 
 func accumulateBandwidthRewards(currentLockReward []LockRewardRecord, config *params.ChainConfig, header *types.Header, snap *Snapshot, db ethdb.Database) []LockRewardRecord {
-	blockNumPerYear := secondsPerYear / config.Alien.Period
+	blockNumPerYear := secondsPerYear / snap.config.Period
 	yearCount := header.Number.Uint64() / blockNumPerYear
 	var yearReward decimal.Decimal
 	yearCount++
@@ -1494,7 +1583,7 @@ func getFlowRewardScale(flowTotal decimal.Decimal) decimal.Decimal {
 // AccumulateRewards credits the coinbase of the given block with the mining reward.
 func accumulateRewards(currentLockReward []LockRewardRecord, config *params.ChainConfig, state *state.StateDB, header *types.Header, snap *Snapshot, refundGas RefundGas, gasReward *big.Int) []LockRewardRecord {
 	// Calculate the block reword by year
-	blockNumPerYear := secondsPerYear / config.Alien.Period
+	blockNumPerYear := secondsPerYear / snap.config.Period
 	yearCount := header.Number.Uint64() / blockNumPerYear
 	if yearCount*blockNumPerYear != header.Number.Uint64() {
 		yearCount++
@@ -1653,18 +1742,9 @@ func addPayAddressBalance(addBalanceAddress common.Address, payAddressAll map[co
 }
 
 func toPayAddressBalance(header *types.Header, payAddressAll map[common.Address]*big.Int, state *state.StateDB)  {
-	if isGrantProfitOneTimeBlockNumber(header) {
-		for payAddress, amount := range payAddressAll {
-			state.AddBalance(payAddress, amount)
-			log.Info("payAddressAll", "payAddress", payAddress, "amount", amount)
-		}
+	for payAddress, amount := range payAddressAll {
+		state.AddBalance(payAddress, amount)
+		log.Info("payAddressAll", "payAddress", payAddress, "amount", amount)
 	}
 	return
-}
-
-func isGrantProfitOneTimeBlockNumber(header *types.Header) bool {
-	if header.Number.Uint64()> grantProfitOneTimeBlockNumber {
-		return true
-	}
-	return false
 }
\ No newline at end of file
@@ -1,64 +1,67 @@
 package alien
 
-import "math/big"
+import (
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/params"
+)
 
 const (
 	checkpointInterval = 360              //360        // About N hours if config.period is N
-
-	secondsPerDay                    = 24 * 60 * 60 // Number of seconds for one day
-	accumulateFlowRewardInterval     = 2 * 60 * 60  // accumulate flow reward interval every day
-	accumulateBandwithRewardInterval = 1 * 60 * 60             // accumulate flow reward interval every day
-
-	paySignerRewardInterval = 0 // pay singer reward  interval every day
-	payFlowRewardInterval      = 2*60*60 + 30*60 //  pay flow reward  interval every day
-	payBandwidthRewardInterval = 1 * 60 * 60 + 30*60  //  pay bandwidth reward  interval every day
-
-	signerPledgeLockParamPeriod    = 180 * 24 * 60 * 60
-	signerPledgeLockParamRlsPeriod = 0
-	signerPledgeLockParamInterval  = 0
-
-	flowPledgeLockParamPeriod    = 180 * 24 * 60 * 60
-	flowPledgeLockParamRlsPeriod = 0
-	flowPledgeLockParamInterval  = 0
-
-	rewardLockParamPeriod    = 30 * 24 * 60 * 60
-	rewardLockParamRlsPeriod = 180 * 24 * 60 * 60
-	rewardLockParamInterval  = 24 * 60 * 60
-	maxCandidateMiner = 500  //	The maximum number of candidate nodes participating in each election is 500
-	electionPartitionThreshold = 36 //Election partition threshold
-	signFixBlockNumber = 397520
-	grantProfitOneTimeBlockNumber=397545
-  	lockSimplifyEffectBlocknumber = 397595
-	lockMergeNumber = 397570
-    FulTrieNumber=2342357
 )
 
 var (
-	minCndPledgeBalance = new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(36)) // candidate pledge balance
-
 	minSignerLockBalance    = new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(0)) // signer reward lock balance
 	minFlwLockBalance       = new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(0)) // flow reward lock balance
 	minBandwidthLockBalance = new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(0)) // bandwidth reward lock balance
 )
 
+// economics returns the reward and lock economics of the chain, the mainnet
+// ones unless the genesis configures its own.
+func economics(config *params.AlienConfig) *params.AlienEconomics {
+	if config != nil && config.Economics != nil {
+		return config.Economics
+	}
+	return &params.DefaultAlienEconomics
+}
+
+// genesisManagerAddress returns the genesis configured manager, or def if the
+// chain keeps the mainnet managers.
+func genesisManagerAddress(config *params.AlienConfig, def common.Address) common.Address {
+	if config != nil && config.ManagerAddress != nil {
+		return *config.ManagerAddress
+	}
+	return def
+}
+
+// newLockParameter converts a lock configured in seconds into blocks.
+func newLockParameter(lock params.AlienLockConfig, period uint64) *LockParameter {
+	return &LockParameter{
+		LockPeriod: uint32(lock.Period / period),
+		RlsPeriod:  uint32(lock.RlsPeriod / period),
+		Interval:   uint32(lock.Interval / period),
+	}
+}
+
 func (a *Alien) blockPerDay() uint64 {
-	return secondsPerDay / a.config.Period
+	return economics(a.config).RewardCycle / a.config.Period
 }
 
 func (a *Alien) blockAccumulateFlowRewardInterval() uint64 {
-	return accumulateFlowRewardInterval / a.config.Period
+	return economics(a.config).AccumulateFlowRewardInterval / a.config.Period
 }
 
 func (a *Alien) blockAccumulateBandwithRewardInterval() uint64 {
-	return accumulateBandwithRewardInterval / a.config.Period
+	return economics(a.config).AccumulateBandwidthRewardInterval / a.config.Period
 }
 
 func (a *Alien) blockPaySignerRewardInterval() uint64 {
-	return paySignerRewardInterval / a.config.Period
+	return economics(a.config).PaySignerRewardInterval / a.config.Period
 }
 
 func (a *Alien) blockPayFlowRewardInterval() uint64 {
-	return payFlowRewardInterval / a.config.Period
+	return economics(a.config).PayFlowRewardInterval / a.config.Period
 }
 
 func (a *Alien) isAccumulateFlowRewards(number uint64) bool {
@@ -73,31 +76,46 @@ func (a *Alien) isAccumulateBandWidthRewards(number uint64) bool {
 	return block == number%blockPerDay && block != number
 }
 
-func isPayBandWidthRewards(number uint64, period uint64) bool {
-	block := payBandwidthRewardInterval / period
-	blockPerDay := secondsPerDay / period
+func isPayBandWidthRewards(number uint64, config *params.AlienConfig) bool {
+	block := economics(config).PayBandwidthRewardInterval / config.Period
+	blockPerDay := economics(config).RewardCycle / config.Period
 	return block == number%blockPerDay && block != number
 }
 
-func isPayFlowRewards(number uint64, period uint64) bool {
-	block := payFlowRewardInterval / period
-	blockPerDay := secondsPerDay / period
+func isPayFlowRewards(number uint64, config *params.AlienConfig) bool {
+	block := economics(config).PayFlowRewardInterval / config.Period
+	blockPerDay := economics(config).RewardCycle / config.Period
 	return block == number%blockPerDay && block != number
 }
-func isPaySignerRewards(number uint64, period uint64) bool {
-	block := paySignerRewardInterval / period
-	blockPerDay := secondsPerDay / period
+func isPaySignerRewards(number uint64, config *params.AlienConfig) bool {
+	block := economics(config).PaySignerRewardInterval / config.Period
+	blockPerDay := economics(config).RewardCycle / config.Period
 	return block == number%blockPerDay && block != number
 }
 
-func  islockSimplifyEffectBlocknumber(number uint64) bool {
-	return number>=lockSimplifyEffectBlocknumber
+func  islockSimplifyEffectBlocknumber(config *params.AlienConfig, number uint64) bool {
+	return config.IsLockSimplify(new(big.Int).SetUint64(number))
+}
+
+func isGeFulTrieNumber(config *params.AlienConfig, number uint64) bool{
+	return config.IsFulTrie(new(big.Int).SetUint64(number))
+}
+
+func isLtFulTrieNumber(config *params.AlienConfig, number uint64) bool{
+	return !isGeFulTrieNumber(config, number)
+}
+
+// isForkedNumber returns whether a fork is active at number.
+func isForkedNumber(isForked func(*big.Int) bool, number uint64) bool {
+	return isForked(new(big.Int).SetUint64(number))
 }
 
-func isGeFulTrieNumber(number uint64) bool{
-	return number >=FulTrieNumber
+// isForkBlock returns whether number is the first block of a fork.
+func isForkBlock(isForked func(*big.Int) bool, number uint64) bool {
+	return isForked(new(big.Int).SetUint64(number)) && (number == 0 || !isForked(new(big.Int).SetUint64(number-1)))
 }
 
-func isLtFulTrieNumber(number uint64) bool{
-	return number <FulTrieNumber
+// isAfterForkBlock returns whether number is past the first block of a fork.
+func isAfterForkBlock(isForked func(*big.Int) bool, number uint64) bool {
+	return number > 0 && isForked(new(big.Int).SetUint64(number-1))
 }
\ No newline at end of file
@@ -582,4 +582,22 @@ func  TestAlien_accumulateFlowRewards(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+func TestAlien_SealOnDemand(t *testing.T) {
+	tests := []struct {
+		config       params.AlienConfig
+		period       uint64
+		sealOnDemand bool
+	}{
+		{params.AlienConfig{MinVoterBalance: new(big.Int)}, defaultBlockPeriod, false},
+		{params.AlienConfig{MinVoterBalance: new(big.Int), SealOnDemand: true}, onDemandBlockPeriod, true},
+		{params.AlienConfig{MinVoterBalance: new(big.Int), Period: 3, SealOnDemand: true}, 3, false},
+	}
+	for i, tt := range tests {
+		alien := New(&tt.config, rawdb.NewMemoryDatabase())
+		if alien.config.Period != tt.period || alien.sealOnDemand != tt.sealOnDemand {
+			t.Errorf("test %d: period %d sealing on demand %v, want %d %v", i, alien.config.Period, alien.sealOnDemand, tt.period, tt.sealOnDemand)
+		}
+		alien.Close()
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package aliensim builds in-memory alien chains for tests. Every block is
+// assembled by the real engine, sealed by the signer in turn and imported into
+// a full blockchain, so the resulting snapshots and state are the ones a node
+// would compute.
+package aliensim
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaskycheng/sdvn/accounts"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/state"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/core/vm"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+// DefaultPeriod is the block period of simulated chains unless the genesis is
+// configured otherwise.
+const DefaultPeriod = 3
+
+var (
+	// SignerBalance is the genesis balance of every signer, staked as its
+	// genesis self vote.
+	SignerBalance = new(big.Int).Mul(big.NewInt(1e6), big.NewInt(params.Ether))
+
+	errNoSigners      = errors.New("no signers")
+	errOnDemandPeriod = errors.New("0-period chains are not supported")
+)
+
+// Simulator is an in-memory alien chain sealed by a set of generated signer
+// keys. The engine runs on a simulated clock which advances block by block, so
+// chains spanning days are generated without waiting.
+type Simulator struct {
+	keys    []*ecdsa.PrivateKey
+	signers []common.Address
+	keyOf   map[common.Address]*ecdsa.PrivateKey
+	genesis *core.Genesis
+	period  uint64
+
+	db     ethdb.Database
+	engine *alien.Alien
+	chain  *core.BlockChain
+	api    *alien.API
+
+	now uint64 // Simulated unix time the engine runs on (atomic)
+}
+
+// NewSimulator creates a chain with the given number of signers, all voting for
+// themselves in the genesis block. The genesis is based on the developer alien
+// genesis, with every alien fork enabled and the first signer as the manager of
+// the system parameters. configure may adjust it, e.g. to set the economics or
+// fund further accounts, before it is committed.
+func NewSimulator(signers int, configure func(*core.Genesis)) (*Simulator, error) {
+	if signers <= 0 {
+		return nil, errNoSigners
+	}
+	s := &Simulator{keyOf: make(map[common.Address]*ecdsa.PrivateKey)}
+	for i := 0; i < signers; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		s.keys = append(s.keys, key)
+		s.signers = append(s.signers, addr)
+		s.keyOf[addr] = key
+	}
+	genesis := core.DeveloperAlienGenesisBlock(DefaultPeriod, s.signers[0], nil)
+	genesis.Config.Alien.MaxSignerCount = uint64(signers)
+	genesis.Config.Alien.SelfVoteSigners = nil
+	for _, signer := range s.signers {
+		genesis.Config.Alien.SelfVoteSigners = append(genesis.Config.Alien.SelfVoteSigners, common.UnprefixedAddress(signer))
+		genesis.Alloc[signer] = core.GenesisAccount{Balance: new(big.Int).Set(SignerBalance)}
+	}
+	if configure != nil {
+		configure(genesis)
+	}
+	if genesis.Config.Alien.Period == 0 {
+		return nil, errOnDemandPeriod
+	}
+	s.genesis, s.period = genesis, genesis.Config.Alien.Period
+
+	// Start the clock at the genesis, the countdown to the first block is skipped
+	s.now = genesis.Timestamp
+	if genesis.Config.Alien.GenesisTimestamp > s.now {
+		s.now = genesis.Config.Alien.GenesisTimestamp
+	}
+	s.db = rawdb.NewMemoryDatabase()
+	if _, err := genesis.Commit(s.db); err != nil {
+		return nil, err
+	}
+	s.engine = alien.New(genesis.Config.Alien, s.db)
+	s.engine.SetClock(s.clock)
+
+	chain, err := core.NewBlockChain(s.db, nil, genesis.Config, s.engine, vm.Config{}, nil, nil)
+	if err != nil {
+		s.engine.Close()
+		return nil, err
+	}
+	s.chain = chain
+	s.api = s.engine.APIs(chain)[0].Service.(*alien.API)
+	return s, nil
+}
+
+// Close stops the blockchain and the engine.
+func (s *Simulator) Close() {
+	s.chain.Stop()
+	s.engine.Close()
+}
+
+// clock returns the simulated time, the engine's replacement of time.Now.
+func (s *Simulator) clock() time.Time {
+	return time.Unix(int64(atomic.LoadUint64(&s.now)), 0)
+}
+
+// Signers returns the addresses of the signers in the genesis order.
+func (s *Simulator) Signers() []common.Address {
+	return append([]common.Address(nil), s.signers...)
+}
+
+// Key returns the private key of the i-th signer.
+func (s *Simulator) Key(i int) *ecdsa.PrivateKey {
+	return s.keys[i]
+}
+
+// Genesis returns the genesis the chain was created with.
+func (s *Simulator) Genesis() *core.Genesis {
+	return s.genesis
+}
+
+// Engine returns the consensus engine of the chain.
+func (s *Simulator) Engine() *alien.Alien {
+	return s.engine
+}
+
+// BlockChain returns the blockchain the generated blocks are imported into.
+func (s *Simulator) BlockChain() *core.BlockChain {
+	return s.chain
+}
+
+// Database returns the database holding the chain, its state and snapshots.
+func (s *Simulator) Database() ethdb.Database {
+	return s.db
+}
+
+// State returns the state at the head of the chain.
+func (s *Simulator) State() (*state.StateDB, error) {
+	return s.chain.State()
+}
+
+// StateAt returns the state after the block with the given number.
+func (s *Simulator) StateAt(number uint64) (*state.StateDB, error) {
+	header := s.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, fmt.Errorf("unknown block %d", number)
+	}
+	return s.chain.StateAt(header.Root)
+}
+
+// Snapshot returns the alien snapshot after the block with the given number.
+// The snapshot is shared with the engine and must not be modified.
+func (s *Simulator) Snapshot(number uint64) (*alien.Snapshot, error) {
+	return s.api.GetSnapshotAtNumber(number)
+}
+
+// Generate creates n blocks on top of the head of the chain and imports them.
+// The generator function is called for every block with a block generator,
+// every transaction added to it becomes part of the block. If gen is nil, the
+// blocks will be empty.
+func (s *Simulator) Generate(n int, gen func(int, *BlockGen)) ([]*types.Block, error) {
+	blocks := make([]*types.Block, 0, n)
+	for i := 0; i < n; i++ {
+		block, err := s.generate(i, gen)
+		if err != nil {
+			return blocks, fmt.Errorf("block %d: %w", s.chain.CurrentHeader().Number.Uint64()+1, err)
+		}
+		if _, err := s.chain.InsertChain(types.Blocks{block}); err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// generate assembles and seals the block on top of the current head the way
+// the miner does, at the next slot of the parent.
+func (s *Simulator) generate(i int, gen func(int, *BlockGen)) (*types.Block, error) {
+	parent := s.chain.CurrentBlock()
+	blockTime := parent.Time() + s.period
+	signer, err := s.inturn(parent, blockTime)
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreUint64(&s.now, blockTime)
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Coinbase:   signer,
+		Time:       blockTime,
+	}
+	if err := s.engine.Prepare(s.chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := s.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	b := &BlockGen{
+		i:         i,
+		sim:       s,
+		header:    header,
+		statedb:   statedb,
+		gasPool:   new(core.GasPool).AddGas(header.GasLimit),
+		gasReward: new(big.Int),
+	}
+	if gen != nil {
+		gen(i, b)
+	}
+	payProfit := b.grantProfit()
+	// The signer seals its random beacon commitment into the extra
+	s.engine.Authorize(signer, s.signData, nil)
+	block, err := s.engine.FinalizeAndAssemble(s.chain, header, statedb, b.txs, nil, b.receipts, payProfit, b.gasReward)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(signer, block)
+}
+
+// inturn returns the signer whose turn it is to seal the child of parent at the
+// given time, following the rotation the engine checks the seal against.
+func (s *Simulator) inturn(parent *types.Block, blockTime uint64) (common.Address, error) {
+	var (
+		signers   []common.Address
+		loopStart uint64
+	)
+	if parent.NumberU64() == 0 {
+		// The genesis snapshot is created along with the genesis votes by the
+		// first block, before that the queue is the self vote signers repeated
+		config := s.genesis.Config.Alien
+		if len(config.SelfVoteSigners) == 0 {
+			return common.Address{}, errNoSigners
+		}
+		for i := uint64(0); i < config.MaxSignerCount; i++ {
+			signers = append(signers, common.Address(config.SelfVoteSigners[i%uint64(len(config.SelfVoteSigners))]))
+		}
+		loopStart = config.GenesisTimestamp
+	} else {
+		snap, err := s.Snapshot(parent.NumberU64())
+		if err != nil {
+			return common.Address{}, err
+		}
+		for _, signer := range snap.Signers {
+			signers = append(signers, *signer)
+		}
+		loopStart = snap.LoopStartTime
+	}
+	if len(signers) == 0 {
+		return common.Address{}, errNoSigners
+	}
+	signer := signers[((blockTime-loopStart)/s.period)%uint64(len(signers))]
+	if _, ok := s.keyOf[signer]; !ok {
+		return common.Address{}, fmt.Errorf("no key for signer %x in turn", signer)
+	}
+	return signer, nil
+}
+
+// seal signs the block with the key of signer.
+func (s *Simulator) seal(signer common.Address, block *types.Block) (*types.Block, error) {
+	s.engine.Authorize(signer, s.signData, nil)
+
+	results := make(chan *types.Block, 1)
+	if err := s.engine.Seal(s.chain, block, results, nil); err != nil {
+		return nil, err
+	}
+	return <-results, nil
+}
+
+// signData implements alien.SignerFn with the simulated signer keys.
+func (s *Simulator) signData(account accounts.Account, mimeType string, message []byte) ([]byte, error) {
+	key, ok := s.keyOf[account.Address]
+	if !ok {
+		return nil, fmt.Errorf("unknown signer %x", account.Address)
+	}
+	return crypto.Sign(crypto.Keccak256(message), key)
+}
+
+// BlockGen collects the transactions of a block being generated.
+type BlockGen struct {
+	i       int
+	sim     *Simulator
+	header  *types.Header
+	statedb *state.StateDB
+
+	gasPool   *core.GasPool
+	gasReward *big.Int
+	txs       []*types.Transaction
+	receipts  []*types.Receipt
+}
+
+// Number returns the block number of the block being generated.
+func (b *BlockGen) Number() *big.Int {
+	return new(big.Int).Set(b.header.Number)
+}
+
+// Time returns the timestamp of the block being generated.
+func (b *BlockGen) Time() uint64 {
+	return b.header.Time
+}
+
+// Signer returns the signer in turn sealing the block, which is its coinbase.
+func (b *BlockGen) Signer() common.Address {
+	return b.header.Coinbase
+}
+
+// State returns the state of the block being generated.
+func (b *BlockGen) State() *state.StateDB {
+	return b.statedb
+}
+
+// TxNonce returns the next valid transaction nonce of addr.
+func (b *BlockGen) TxNonce(addr common.Address) uint64 {
+	return b.statedb.GetNonce(addr)
+}
+
+// AddTx adds a transaction to the generated block.
+//
+// AddTx panics if the transaction cannot be executed, like its core.BlockGen
+// counterpart.
+func (b *BlockGen) AddTx(tx *types.Transaction) {
+	b.statedb.Prepare(tx.Hash(), common.Hash{}, len(b.txs))
+	receipt, gasReward, err := core.ApplyTransaction(b.sim.genesis.Config, b.sim.chain, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vm.Config{})
+	if err != nil {
+		panic(err)
+	}
+	if gasReward != nil {
+		b.gasReward.Add(b.gasReward, gasReward)
+	}
+	b.txs = append(b.txs, tx)
+	b.receipts = append(b.receipts, receipt)
+}
+
+// AddCustomTx signs the custom transaction carrying payload with key and adds
+// it to the generated block. The transaction is sent to the given address
+// without value, paying no gas price.
+func (b *BlockGen) AddCustomTx(key *ecdsa.PrivateKey, to common.Address, payload customtx.Payload) *types.Transaction {
+	return b.AddCustomTxWithValue(key, to, new(big.Int), payload)
+}
+
+// AddCustomTxWithValue is AddCustomTx for custom transactions carrying value.
+func (b *BlockGen) AddCustomTxWithValue(key *ecdsa.PrivateKey, to common.Address, value *big.Int, payload customtx.Payload) *types.Transaction {
+	data := payload.Encode()
+	gas, err := core.IntrinsicGas(data, nil, false, true, true)
+	if err != nil {
+		panic(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := types.SignTx(types.NewTransaction(b.TxNonce(from), to, value, gas, new(big.Int), data), types.NewEIP155Signer(b.sim.genesis.Config.ChainID), key)
+	if err != nil {
+		panic(err)
+	}
+	b.AddTx(tx)
+	return tx
+}
+
+// grantProfit pays the released pledges and rewards of the block, returning
+// the grants to record in the header.
+func (b *BlockGen) grantProfit() []consensus.GrantProfitRecord {
+	grantProfit, payProfit := b.sim.engine.GrantProfit(b.sim.chain, b.header, b.statedb)
+	for _, item := range grantProfit {
+		data := common.FromHex("0xeec31edf") // GrantProfit(address)
+		if item.MultiSignature == (common.Address{}) {
+			data = append(data, item.RevenueAddress.Hash().Bytes()...)
+		} else {
+			data = append(data, item.MultiSignature.Hash().Bytes()...)
+		}
+		txIndex := uint64(len(b.receipts))
+		gasPrice := new(big.Int).SetUint64(176190476190)
+		gasLimit := uint64(5000000000)
+		tx := types.NewTransaction(txIndex, item.RevenueContract, item.Amount, gasLimit, gasPrice, data)
+		msg := types.NewMessage(item.MinerAddress, &item.RevenueContract, txIndex, item.Amount, gasLimit, gasPrice, gasPrice, gasPrice, data, nil, false)
+
+		snap := b.statedb.Snapshot()
+		b.statedb.Prepare(tx.Hash(), common.Hash{}, int(txIndex))
+		receipt, err := core.GrantProfit(tx, msg, b.sim.genesis.Config, b.sim.chain, &b.header.Coinbase, new(core.GasPool).AddGas(b.header.GasLimit), b.statedb, b.header, &b.header.GasUsed, vm.Config{})
+		if err != nil {
+			b.statedb.RevertToSnapshot(snap)
+			continue
+		}
+		payProfit = append(payProfit, item)
+		b.receipts = append(b.receipts, receipt)
+	}
+	return payProfit
+}
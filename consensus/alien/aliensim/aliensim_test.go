@@ -0,0 +1,95 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package aliensim
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core"
+	"github.com/seaskycheng/sdvn/crypto"
+)
+
+func TestSimulator(t *testing.T) {
+	voterKey, _ := crypto.GenerateKey()
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+
+	sim, err := NewSimulator(3, func(genesis *core.Genesis) {
+		genesis.Alloc[voter] = core.GenesisAccount{Balance: new(big.Int).Set(SignerBalance)}
+	})
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+	defer sim.Close()
+
+	signers := sim.Signers()
+	blocks, err := sim.Generate(10, func(i int, b *BlockGen) {
+		if i == 4 {
+			b.AddCustomTx(voterKey, signers[1], new(customtx.Vote))
+		}
+	})
+	if err != nil {
+		t.Fatalf("failed to generate blocks: %v", err)
+	}
+	if head := sim.BlockChain().CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head %d not the last generated block", head.NumberU64())
+	}
+	sealed := make(map[common.Address]int)
+	for i, block := range blocks {
+		author, err := sim.Engine().Author(block.Header())
+		if err != nil {
+			t.Fatalf("block %d: failed to recover author: %v", i+1, err)
+		}
+		if author != block.Coinbase() {
+			t.Errorf("block %d: sealed by %x, coinbase %x", i+1, author, block.Coinbase())
+		}
+		if i > 0 && block.Time() != blocks[i-1].Time()+DefaultPeriod {
+			t.Errorf("block %d: time %d, parent %d", i+1, block.Time(), blocks[i-1].Time())
+		}
+		sealed[author]++
+	}
+	if len(sealed) != len(signers) {
+		t.Errorf("sealed by %d signers, want %d", len(sealed), len(signers))
+	}
+	if len(blocks[4].Transactions()) != 1 {
+		t.Fatalf("custom transaction not included")
+	}
+	before, err := sim.Snapshot(4)
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	if _, ok := before.Votes[voter]; ok {
+		t.Errorf("vote known before its block")
+	}
+	snap, err := sim.Snapshot(uint64(len(blocks)))
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	vote, ok := snap.Votes[voter]
+	if !ok || vote.Candidate != signers[1] {
+		t.Fatalf("vote not applied: %+v", vote)
+	}
+	statedb, err := sim.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if nonce := statedb.GetNonce(voter); nonce != 1 {
+		t.Errorf("voter nonce %d, want 1", nonce)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package aliensim
+
+import (
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+)
+
+func TestRandomBeacon(t *testing.T) {
+	sim, err := NewSimulator(3, nil)
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+	defer sim.Close()
+
+	// Every signer commits in its first block and reveals in its second one
+	blocks, err := sim.Generate(9, nil)
+	if err != nil {
+		t.Fatalf("failed to generate blocks: %v", err)
+	}
+	snap, err := sim.Snapshot(uint64(len(blocks)))
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	for _, signer := range sim.Signers() {
+		if commit, ok := snap.RandomCommits[signer]; !ok || commit.Hash == (common.Hash{}) {
+			t.Errorf("signer %x: no commitment", signer)
+		}
+	}
+	if snap.RandomBeacon == (common.Hash{}) {
+		t.Errorf("no secret revealed into the beacon")
+	}
+	prev, err := sim.Snapshot(uint64(len(blocks) - 1))
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	if prev.RandomBeacon == snap.RandomBeacon {
+		t.Errorf("beacon unchanged by the last block")
+	}
+}
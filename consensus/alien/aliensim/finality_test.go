@@ -0,0 +1,119 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package aliensim
+
+import (
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/core"
+)
+
+func TestFinality(t *testing.T) {
+	sim, err := NewSimulator(4, func(genesis *core.Genesis) {
+		genesis.Config.Alien.PBFTEnable = true
+	})
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+	defer sim.Close()
+
+	if _, err := sim.Generate(8, nil); err != nil {
+		t.Fatalf("failed to generate blocks: %v", err)
+	}
+	chain := sim.BlockChain()
+	head := chain.CurrentHeader()
+
+	// Every signer runs its own engine on the shared chain, the simulator's one
+	// only watches the votes
+	sim.Engine().Authorize(common.Address{}, nil, nil)
+
+	var nodes []*alien.Alien
+	for _, signer := range sim.Signers() {
+		node := alien.New(sim.Genesis().Config.Alien, sim.Database())
+		node.Authorize(signer, sim.signData, nil)
+		defer node.Close()
+		nodes = append(nodes, node)
+	}
+	deliver := func(votes []*alien.FinalityVote) []*alien.FinalityVote {
+		var cast []*alien.FinalityVote
+		for _, node := range nodes {
+			relay, err := node.HandleFinalityVotes(chain, votes)
+			if err != nil {
+				t.Fatalf("failed to handle votes: %v", err)
+			}
+			for _, vote := range relay {
+				if vote.Type == alien.FinalityPrecommit {
+					cast = append(cast, vote)
+				}
+			}
+		}
+		if _, err := sim.Engine().HandleFinalityVotes(chain, votes); err != nil {
+			t.Fatalf("failed to watch votes: %v", err)
+		}
+		return cast
+	}
+	var prevotes []*alien.FinalityVote
+	for _, node := range nodes {
+		prevotes = append(prevotes, node.NewFinalityHead(chain, head)...)
+	}
+	if len(prevotes) != len(nodes) {
+		t.Fatalf("cast %d prevotes, want %d", len(prevotes), len(nodes))
+	}
+	// Two prevotes out of four signers are no quorum
+	if precommits := deliver(prevotes[:1]); len(precommits) != 0 {
+		t.Fatalf("precommitted without a quorum")
+	}
+	precommits := deliver(prevotes[1:])
+	if len(precommits) != len(nodes) {
+		t.Fatalf("cast %d precommits, want %d", len(precommits), len(nodes))
+	}
+	// Two precommits don't finalize the block, three do
+	deliver(precommits[:2])
+	if number, _ := sim.Engine().Finalized(); number != 0 {
+		t.Fatalf("finalized block %d without a quorum", number)
+	}
+	deliver(precommits[2:3])
+	if number, hash := sim.Engine().Finalized(); number != head.Number.Uint64() || hash != head.Hash() {
+		t.Fatalf("finalized %d %x, want %d %x", number, hash, head.Number, head.Hash())
+	}
+	if votes := sim.Engine().FinalityJustification(); len(votes) != 3 {
+		t.Errorf("justified by %d precommits, want 3", len(votes))
+	}
+	if number, err := sim.Engine().Confirmed(chain, head); err != nil || number != head.Number.Uint64() {
+		t.Errorf("confirmed block %d (%v), want %d", number, err, head.Number)
+	}
+	// Votes of non signers are dropped, forged ones rejected
+	outsider := alien.New(sim.Genesis().Config.Alien, sim.Database())
+	defer outsider.Close()
+	outsider.Authorize(common.Address{0x01}, sim.signData, nil)
+	if votes := outsider.NewFinalityHead(chain, head); len(votes) != 0 {
+		t.Errorf("non signer cast votes")
+	}
+	forged := *prevotes[0]
+	forged.Signature = make([]byte, 65)
+	if _, err := sim.Engine().HandleFinalityVotes(chain, []*alien.FinalityVote{&forged}); err == nil {
+		t.Errorf("forged vote accepted")
+	}
+	// The finalized block survives a restart
+	restarted := alien.New(sim.Genesis().Config.Alien, sim.Database())
+	defer restarted.Close()
+	if number, _ := restarted.Finalized(); number != head.Number.Uint64() {
+		t.Errorf("finalized block %d after restart, want %d", number, head.Number)
+	}
+}
@@ -97,7 +97,7 @@ func (api *API) GetSnapshotByHeaderTime(targetTime uint64, scHash common.Hash) (
 	if header == nil {
 		return nil, errUnknownBlock
 	}
-	period := new(big.Int).SetUint64(api.chain.Config().Alien.Period)
+	period := new(big.Int).SetUint64(api.alien.config.Period)
 	target := new(big.Int).SetUint64(targetTime)
 	ceil := new(big.Int).Add(new(big.Int).SetUint64(header.Time), period)
 	if target.Cmp(ceil) > 0 {
@@ -502,6 +502,67 @@ func (api *API) GetFulBalance(address common.Address) (*SnapshotAddrFul,error) {
 	return api.GetFulBalanceAtNumber(address,header.Number.Uint64())
 }
 
+// GetFulProof returns the proof of the FUL balance of address against the
+// FulDataRoot of a block, the head unless a block is given. The balance is the
+// one held when the block started.
+func (api *API) GetFulProof(address common.Address, number *rpc.BlockNumber) (*FulProof, error) {
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	if header.Number.Sign() == 0 || !api.alien.config.IsFulTrie(header.Number) {
+		return nil, errFulTrieInactive
+	}
+	parent := api.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return nil, errUnknownBlock
+	}
+	snapshot, err := api.getSnapshotCache(parent)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Ful == nil {
+		return nil, errFulTrieInactive
+	}
+	var headerExtra HeaderExtra
+	if err := decodeHeaderExtra(api.alien.config, header.Number, header.Extra[extraVanity:len(header.Extra)-extraSeal], &headerExtra); err != nil {
+		return nil, err
+	}
+	if root := snapshot.Ful.Root(); root != headerExtra.FulDataRoot {
+		return nil, &fulRootError{header: headerExtra.FulDataRoot, calculated: root}
+	}
+	return newFulProof(snapshot.Ful, header, address)
+}
+
+// GetFulHistory returns a page of the FUL debits and credits of address from
+// block from to block to, the head unless a block is given. The next page
+// starts at the block Next of the page.
+func (api *API) GetFulHistory(address common.Address, from rpc.BlockNumber, to *rpc.BlockNumber) (*FulHistory, error) {
+	if api.alien.fulHistory == nil {
+		return nil, errFulHistoryDisabled
+	}
+	head := api.chain.CurrentHeader().Number.Uint64()
+	first, last := uint64(from.Int64()), head
+	if from < 0 {
+		first = head
+	}
+	if to != nil && *to >= 0 && uint64(to.Int64()) < head {
+		last = uint64(to.Int64())
+	}
+	return api.alien.fulHistory.history(address, first, last, func(number uint64) (common.Hash, common.Hash) {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return common.Hash{}, common.Hash{}
+		}
+		return fulHistoryBlockID(header.ParentHash, header.TxHash), header.Hash()
+	})
+}
+
 func (api *API) getSnapshotCache(header *types.Header) (*Snapshot, error) {
 	number:=header.Number.Uint64()
 	s:=api.findInSnapCache(number)
@@ -570,4 +631,157 @@ func (api *API) GetFulBalAtNumber(number uint64) (*SnapshotFul, error) {
 }
 type SnapshotFul struct {
 	FulBal map[common.Address]*big.Int `json:"fulbal"`
-}
\ No newline at end of file
+}
+
+// GetDoubleSignEvidence returns the conflicting headers sealed by signer this
+// node has seen, to be submitted in a NFC:1:DblSign transaction.
+func (api *API) GetDoubleSignEvidence(signer common.Address) ([]*DoubleSignEvidence, error) {
+	return api.alien.DoubleSignEvidence(signer)
+}
+
+// JailState is the jail state of a signer along with its jail history.
+type JailState struct {
+	Jailed  bool          `json:"jailed"`
+	Credit  uint64        `json:"credit"`  // Current punished credit
+	Release uint64        `json:"release"` // First block the signer may be unjailed at, zero if not jailed
+	History []*JailRecord `json:"history"` // Jail records, oldest first
+}
+
+// GetJailState returns the jail state of signer at a given block.
+func (api *API) GetJailState(signer common.Address, number *rpc.BlockNumber) (*JailState, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	state := &JailState{
+		Credit:  snapshot.Punished[signer],
+		History: append([]*JailRecord{}, snapshot.Jails[signer]...),
+	}
+	if record := snapshot.jailRecord(signer); record != nil {
+		state.Jailed = true
+		state.Release = record.Release
+	}
+	return state, nil
+}
+
+// GetJailedSigners returns the current jail record of the signers jailed at a
+// given block.
+func (api *API) GetJailedSigners(number *rpc.BlockNumber) (map[common.Address]*JailRecord, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	jailed := make(map[common.Address]*JailRecord)
+	for signer := range snapshot.Jails {
+		if record := snapshot.jailRecord(signer); record != nil {
+			jailed[signer] = record
+		}
+	}
+	return jailed, nil
+}
+
+// PreviewSignerQueue returns the signer queue the next recalculation of the
+// signers would produce with the votes and pledges at a given block.
+func (api *API) PreviewSignerQueue(number *rpc.BlockNumber) (*SignerQueuePreview, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.previewSignerQueue()
+}
+
+// ExplainElection returns the stakes, ranks and credit of address and why it is
+// elected or not in the next recalculation of the signers, at the head unless
+// a block is given.
+func (api *API) ExplainElection(address common.Address, number *rpc.BlockNumber) (*ElectionExplanation, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.explainElection(address)
+}
+
+// VoteHistory is the current vote of a voter and its changes.
+type VoteHistory struct {
+	Vote    *Vote         `json:"vote"`    // Current vote, nil if not voting
+	Records []*VoteRecord `json:"records"` // Vote changes, oldest first
+}
+
+// GetVoteHistory returns the current vote of voter and the changes of it up to
+// the head unless a block is given.
+func (api *API) GetVoteHistory(voter common.Address, number *rpc.BlockNumber) (*VoteHistory, error) {
+	if api.alien.voteHistory == nil {
+		return nil, errVoteHistoryDisabled
+	}
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	records, err := api.alien.voteHistory.history(voter, snapshot.Number, func(number uint64) common.Hash {
+		if number == snapshot.Number {
+			return snapshot.Hash
+		}
+		if header := api.chain.GetHeaderByNumber(number); header != nil {
+			return header.Hash()
+		}
+		return common.Hash{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &VoteHistory{Vote: snapshot.Votes[voter], Records: records}, nil
+}
+
+// GetProposals returns the active proposals and the latest concluded ones with
+// the given status, one of active, passed and rejected, or all of them if the
+// status is empty, at the head unless a block is given.
+func (api *API) GetProposals(status string, number *rpc.BlockNumber) ([]*ProposalStatus, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.proposalStatuses(status)
+}
+
+// GetProposal returns the status of the proposal sent in the transaction with
+// the given hash, at the head unless a block is given.
+func (api *API) GetProposal(hash common.Hash, number *rpc.BlockNumber) (*ProposalStatus, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	status := snapshot.proposalStatus(hash)
+	if status == nil {
+		return nil, errUnknownProposal
+	}
+	return status, nil
+}
+
+// GetMultiSigProposals returns the proposals of the multi-signature address
+// collecting approvals, oldest first, at the head unless a block is given.
+func (api *API) GetMultiSigProposals(multiSig common.Address, number *rpc.BlockNumber) ([]*MultiSigProposal, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	proposals := []*MultiSigProposal{}
+	for _, proposal := range snapshot.multiSigPending(multiSig) {
+		proposals = append(proposals, proposal.copy())
+	}
+	return proposals, nil
+}
+
+// GetMultiSigProposal returns the multi-signature proposal sent in the
+// transaction with the given hash while it collects approvals, at the head
+// unless a block is given.
+func (api *API) GetMultiSigProposal(hash common.Hash, number *rpc.BlockNumber) (*MultiSigProposal, error) {
+	snapshot, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	proposal, ok := snapshot.MultiSigPending[hash]
+	if !ok {
+		return nil, errUnknownMultiSigProposal
+	}
+	return proposal.copy(), nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/accounts"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// From the RandomBeaconBlock on the signer queue is ordered by a random beacon
+// instead of the block hashes, which the signers can grind by choosing the
+// transactions of their blocks. Each block commits to a secret of its signer
+// and reveals the secret of the previous commitment of the signer, the beacon
+// hashes in every reveal. The only way to bias it is to withhold a block,
+// which is punished as a missed one.
+//
+// The secret committed at a block is the hash of the signature of the signer
+// over the block number, so signers don't have to keep secrets around. Their
+// keys have to sign deterministically, as the keystore does.
+var (
+	randomDomain = []byte("alien-random") // Prefix of the signed secret payload, apart from the headers
+
+	// errMissingRandomCommit is returned if a block after the random beacon
+	// fork doesn't commit to a secret.
+	errMissingRandomCommit = errors.New("missing random commitment")
+
+	// errInvalidRandomReveal is returned if a block doesn't reveal the secret
+	// of the previous commitment of its signer.
+	errInvalidRandomReveal = errors.New("invalid random reveal")
+
+	// errUnexpectedRandom is returned if a block before the random beacon fork
+	// commits to or reveals a secret.
+	errUnexpectedRandom = errors.New("random beacon not active")
+)
+
+// RandomCommit is the last secret committed to by a signer.
+type RandomCommit struct {
+	Number uint64      `json:"number"` // Block the commitment is sealed in
+	Hash   common.Hash `json:"hash"`   // Hash of the secret
+}
+
+// randomSecret derives the secret signer commits to at number.
+func randomSecret(signer common.Address, signFn SignerFn, number uint64) (common.Hash, error) {
+	payload, err := rlp.EncodeToBytes([]interface{}{randomDomain, number})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeAlien, payload)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(sig), nil
+}
+
+// sealRandom fills the commitment and the reveal into the extra of the block at
+// number if the local signer seals it on top of snap. Imported blocks carry the
+// ones of their signer.
+func (a *Alien) sealRandom(extra *HeaderExtra, coinbase common.Address, number uint64, snap *Snapshot) error {
+	a.lock.RLock()
+	signer, signFn := a.signer, a.signFn
+	a.lock.RUnlock()
+
+	if signFn == nil || signer != coinbase {
+		return nil
+	}
+	secret, err := randomSecret(signer, signFn, number)
+	if err != nil {
+		return err
+	}
+	extra.RandomCommit = crypto.Keccak256Hash(secret.Bytes())
+
+	if commit, ok := snap.RandomCommits[signer]; ok {
+		reveal, err := randomSecret(signer, signFn, commit.Number)
+		if err != nil {
+			return err
+		}
+		if crypto.Keccak256Hash(reveal.Bytes()) != commit.Hash {
+			// The key doesn't sign deterministically, the block would be refused
+			return errInvalidRandomReveal
+		}
+		extra.RandomReveal = reveal
+	}
+	return nil
+}
+
+// verifyRandom checks the commitment and the reveal of header against the
+// snapshot of its parent.
+func (a *Alien) verifyRandom(header *types.Header, snap *Snapshot) error {
+	extra := HeaderExtra{}
+	if err := decodeHeaderExtra(a.config, header.Number, header.Extra[extraVanity:len(header.Extra)-extraSeal], &extra); err != nil {
+		return err
+	}
+	if !a.config.IsRandomBeacon(header.Number) {
+		if extra.RandomCommit != (common.Hash{}) || extra.RandomReveal != (common.Hash{}) {
+			return errUnexpectedRandom
+		}
+		return nil
+	}
+	if extra.RandomCommit == (common.Hash{}) {
+		return errMissingRandomCommit
+	}
+	commit, ok := snap.RandomCommits[header.Coinbase]
+	if !ok {
+		if extra.RandomReveal != (common.Hash{}) {
+			return errInvalidRandomReveal
+		}
+		return nil
+	}
+	if crypto.Keccak256Hash(extra.RandomReveal.Bytes()) != commit.Hash {
+		return errInvalidRandomReveal
+	}
+	return nil
+}
+
+// updateRandomBeacon hashes the reveal of the block at number into the beacon
+// and records the new commitment of its signer.
+func (s *Snapshot) updateRandomBeacon(coinbase common.Address, number *big.Int, extra HeaderExtra) {
+	if !s.config.IsRandomBeacon(number) {
+		return
+	}
+	if extra.RandomReveal != (common.Hash{}) {
+		s.RandomBeacon = crypto.Keccak256Hash(s.RandomBeacon.Bytes(), extra.RandomReveal.Bytes())
+	}
+	if s.RandomCommits == nil {
+		s.RandomCommits = make(map[common.Address]*RandomCommit)
+	}
+	s.RandomCommits[coinbase] = &RandomCommit{Number: number.Uint64(), Hash: extra.RandomCommit}
+}
+
+// randomSignerHashes replaces the block hashes ordering the selected signers of
+// the next block by hashes of the random beacon once it is active.
+func (s *Snapshot) randomSignerHashes(signerSlice SignerSlice) {
+	if !s.config.IsRandomBeacon(new(big.Int).SetUint64(s.Number + 1)) {
+		return
+	}
+	for i := range signerSlice {
+		signerSlice[i].hash = crypto.Keccak256Hash(s.RandomBeacon.Bytes(), new(big.Int).SetInt64(int64(i)).Bytes())
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/accounts"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestRandomBeacon(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	config := &params.AlienConfig{Period: 3, MaxSignerCount: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), RandomBeaconBlock: big.NewInt(5)}
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+	alien.Authorize(signer, func(account accounts.Account, mimeType string, message []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(message), key)
+	}, nil)
+
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 1)
+	// seal returns the header sealed by the local signer at number on top of
+	// snap, and applies it to the snapshot
+	seal := func(number uint64) (*types.Header, HeaderExtra) {
+		var extra HeaderExtra
+		if err := alien.sealRandom(&extra, signer, number, snap); err != nil {
+			t.Fatalf("block %d: failed to seal random: %v", number, err)
+		}
+		header := randomTestHeader(t, config, signer, number, extra)
+		if err := alien.verifyRandom(header, snap); err != nil {
+			t.Fatalf("block %d: sealed random refused: %v", number, err)
+		}
+		snap.updateRandomBeacon(signer, header.Number, extra)
+		return header, extra
+	}
+	_, first := seal(5)
+	if first.RandomCommit == (common.Hash{}) || first.RandomReveal != (common.Hash{}) {
+		t.Fatalf("unexpected first commitment %+v", first)
+	}
+	if snap.RandomBeacon != (common.Hash{}) {
+		t.Errorf("beacon changed without a reveal")
+	}
+	_, second := seal(8)
+	if crypto.Keccak256Hash(second.RandomReveal.Bytes()) != first.RandomCommit {
+		t.Fatalf("reveal doesn't open the previous commitment")
+	}
+	if want := crypto.Keccak256Hash(common.Hash{}.Bytes(), second.RandomReveal.Bytes()); snap.RandomBeacon != want {
+		t.Errorf("beacon %x, want %x", snap.RandomBeacon, want)
+	}
+	if commit := snap.RandomCommits[signer]; commit.Number != 8 || commit.Hash != second.RandomCommit {
+		t.Errorf("commitment not recorded: %+v", commit)
+	}
+
+	// Forged reveals, missing commitments and early beacons are refused
+	forged := second
+	forged.RandomReveal = common.Hash{0x01}
+	if err := alien.verifyRandom(randomTestHeader(t, config, signer, 11, forged), snap); err != errInvalidRandomReveal {
+		t.Errorf("forged reveal: %v, want %v", err, errInvalidRandomReveal)
+	}
+	if err := alien.verifyRandom(randomTestHeader(t, config, signer, 11, HeaderExtra{}), snap); err != errMissingRandomCommit {
+		t.Errorf("missing commitment: %v, want %v", err, errMissingRandomCommit)
+	}
+	if err := alien.verifyRandom(randomTestHeader(t, config, signer, 4, first), snap); err != errUnexpectedRandom {
+		t.Errorf("commitment before the fork: %v, want %v", err, errUnexpectedRandom)
+	}
+
+	// The signer order follows the beacon instead of the block hashes
+	snap.Number = 8
+	signers := SignerSlice{{common.Address{0x01}, common.Hash{0x01}}, {common.Address{0x02}, common.Hash{0x02}}}
+	snap.randomSignerHashes(signers)
+	for i, item := range signers {
+		if want := crypto.Keccak256Hash(snap.RandomBeacon.Bytes(), big.NewInt(int64(i)).Bytes()); item.hash != want {
+			t.Errorf("signer %d: hash %x, want %x", i, item.hash, want)
+		}
+	}
+}
+
+// randomTestHeader returns a header at number of signer carrying extra.
+func randomTestHeader(t *testing.T, config *params.AlienConfig, signer common.Address, number uint64, extra HeaderExtra) *types.Header {
+	enc, err := encodeHeaderExtra(config, new(big.Int).SetUint64(number), extra)
+	if err != nil {
+		t.Fatalf("failed to encode header extra: %v", err)
+	}
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Coinbase: signer}
+	header.Extra = append(make([]byte, extraVanity), enc...)
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	return header
+}
@@ -20,15 +20,13 @@ package alien
 
 import (
 	"errors"
-	"fmt"
-	"github.com/seaskycheng/sdvn/common/hexutil"
 	"github.com/seaskycheng/sdvn/crypto"
 	"math/big"
-	"strconv"
 	"strings"
 
 	"github.com/seaskycheng/sdvn/common"
 	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
 	"github.com/seaskycheng/sdvn/core/state"
 	"github.com/seaskycheng/sdvn/core/types"
 	"github.com/seaskycheng/sdvn/log"
@@ -37,85 +35,7 @@ import (
 )
 
 const (
-	ufoVersion            = "1"
-
-	ufoPrefix             = "ufo"
-	nfcPrefix             = "NFC"
-	sscPrefix             = "SSC"
-
-	ufoCategoryEvent      = "event"
-	ufoCategoryLog        = "oplog"
-	ufoCategorySC         = "sc"
-	ufoEventVote          = "vote"
-	ufoEventConfirm       = "confirm"
-	ufoEventPorposal      = "proposal"
-	ufoEventDeclare       = "declare"
-	ufoEventSetCoinbase   = "setcb"
-	ufoEventDelCoinbase   = "delcb"
-	ufoEventFlowReport1   = "flwrpt"
-	ufoEventFlowReport2   = "flwrptm"
-
-
-	nfcCategoryExch       = "Exch"
-	nfcCategoryMultiSign  = "Multi"
-	nfcCategoryBind       = "Bind"
-	nfcCategoryUnbind     = "Unbind"
-	nfcCategoryRebind     = "Rebind"
-	nfcCategoryCandReq    = "CandReq"
-	nfcCategoryCandExit   = "CandExit"
-	nfcCategoryCandPnsh   = "CandPnsh"
-	nfcCategoryFlwReq     = "FlwReq"
-	nfcCategoryFlwExit    = "FlwExit"
-	nfcEventFlowReportEn   = "flwrpten"
-
-	sscCategoryExchRate   = "ExchRate"
-	sscCategoryDeposit    = "Deposit"
-	sscCategoryCndLock    = "CndLock"
-	sscCategoryFlwLock    = "FlwLock"
-	sscCategoryRwdLock    = "RwdLock"
-	sscCategoryOffLine    = "OffLine"
-	sscCategoryQOS        = "QOS"
-	sscCategoryWdthPnsh   = "WdthPnsh"
-	sscCategoryManager    = "Manager"
-
-	ufoMinSplitLen        = 3
-
-	posPrefix             = 0
-	posVersion            = 1
-	posCategory           = 2
-
-	posEventVote          = 3
-	posEventConfirm       = 3
-	posEventProposal      = 3
-	posEventDeclare       = 3
-	posEventSetCoinbase   = 3
-	posEventConfirmNumber = 4
-	posEventFlowReport    = 3
-	posEventFlowValue     = 4
-
-	nfcPosExchAddress     = 3
-	nfcPosExchValue       =	4
-	nfcPosThreshold       = 3
-    nfcPosMinerAddress    = 3
-	nfcPosRevenueType     = 4
-	nfcPosRevenueContract = 5
-	nfcPosMiltiSign       = 6
-	nfcPosRevenueAddress  = 7
-    nfcPosISPQosID        = 4
-    nfcPosBandwidth       = 5
-
-    sscPosExchRate        = 3
-	sscPosDeposit         = 3
-	sscPosDepositWho      = 4
-	sscPosLockPeriod      = 3
-	sscPosRlsPeriod       = 4
-	sscPosInterval        = 5
-	sscPosOffLine         = 3
-	sscPosQosID           = 3
-	sscPosQosValue        = 4
-	sscPosWdthPnsh        = 4
-	sscPosManagerID       = 3
-	sscPosManagerAddress  = 4
+	ufoVersion            = customtx.Version
 
 	sscEnumCndLock        = 0
 	sscEnumFlwLock        = 1
@@ -148,14 +68,14 @@ const (
 	/*
 	 * proposal related
 	 */
-	maxValidationLoopCnt     = 12342                   // About one month if period = 10 & 21 super nodes
-	minValidationLoopCnt     = 4                       // just for test, Note: 12350  About three days if seal each block per second & 21 super nodes
+	maxValidationLoopCnt     = customtx.MaxValidationLoopCnt
+	minValidationLoopCnt     = customtx.MinValidationLoopCnt
 	defaultValidationLoopCnt = 2880                    // About one week if period = 10 & 21 super nodes
-	maxProposalDeposit       = 100000                  // If no limit on max proposal deposit and 1 billion TTC deposit success passed, then no new proposal.
-	minSCRentFee             = 100                     // 100 TTC
-	minSCRentLength          = 259200                  // number of block about 1 month if period is 10
+	maxProposalDeposit       = customtx.MaxProposalDeposit
+	minSCRentFee             = customtx.MinSCRentFee
+	minSCRentLength          = customtx.MinSCRentLength
 	defaultSCRentLength      = minSCRentLength * 3     // number of block about 3 month if period is 10
-	maxSCRentLength          = defaultSCRentLength * 4 // number of block about 1 year if period is 10
+	maxSCRentLength          = customtx.MaxSCRentLength
 
 	/*
 	 * notice related
@@ -276,6 +196,12 @@ type CandidatePunishRecord struct {
 	Credit uint32
 }
 
+type DoubleSignRecord struct {
+	Target common.Address
+	Number uint64
+	Amount *big.Int
+}
+
 type ClaimedBandwidthRecord struct {
 	Target    common.Address
 	Amount    *big.Int
@@ -373,6 +299,16 @@ type HeaderExtra struct {
 	GrantProfit               []consensus.GrantProfitRecord
 	FlowReport                []MinerFlowReportRecord
 	FulDataRoot               common.Hash
+	DoubleSignSlash           []DoubleSignRecord      `rlp:"optional"`
+	CandidateUnjail           []common.Address        `rlp:"optional"`
+	RandomCommit              common.Hash             `rlp:"optional"` // Hash of the secret committed by the signer
+	RandomReveal              common.Hash             `rlp:"optional"` // Secret of the previous commitment of the signer
+	CurrentBlockUnvotes       []common.Address        `rlp:"optional"`
+	CurrentBlockRedelegates   []Vote                  `rlp:"optional"`
+	MultiSigProposals         []MultiSigProposeRecord `rlp:"optional"`
+	MultiSigApprovals         []MultiSigApproveRecord `rlp:"optional"`
+	FulTransfers              []FulTransferRecord     `rlp:"optional"`
+	FulAllowances             []FulAllowanceRecord    `rlp:"optional"`
 }
 
 type OldHeaderExtra struct {
@@ -457,7 +393,7 @@ func encodeHeaderExtra(config *params.AlienConfig, number *big.Int, val HeaderEx
 	//case config.IsTrantor(number):
 
 	default:
-		if isLtFulTrieNumber(number.Uint64()){
+		if isLtFulTrieNumber(config, number.Uint64()){
 			oldHeaderExtra:=copyToOldHeaderExtra(val)
 			return rlp.EncodeToBytes(oldHeaderExtra)
 		}
@@ -510,7 +446,7 @@ func decodeHeaderExtra(config *params.AlienConfig, number *big.Int, b []byte, va
 	switch {
 	//case config.IsTrantor(number):
 	default:
-		if isLtFulTrieNumber(number.Uint64()){
+		if isLtFulTrieNumber(config, number.Uint64()){
 			oldVal := &OldHeaderExtra{}
 			err = rlp.DecodeBytes(b, oldVal)
 			copyFromOldHeaderExtra(val,oldVal)
@@ -558,14 +494,25 @@ func copyFromOldHeaderExtra(val *HeaderExtra, oldVal *OldHeaderExtra) {
 
 // Build side chain confirm data
 func (a *Alien) buildSCEventConfirmData(scHash common.Hash, headerNumber *big.Int, headerTime *big.Int, lastLoopInfo string, chargingInfo string) []byte {
-	return []byte(fmt.Sprintf("%s:%s:%s:%s:%s:%d:%d:%s:%s",
-		ufoPrefix, ufoVersion, ufoCategorySC, ufoEventConfirm,
-		scHash.Hex(), headerNumber.Uint64(), headerTime.Uint64(), lastLoopInfo, chargingInfo))
-
+	confirm := customtx.SCConfirm{
+		SCHash: scHash,
+		Number: headerNumber,
+		Time: headerTime,
+		LoopInfo: lastLoopInfo,
+		ChargingInfo: chargingInfo,
+	}
+	return confirm.Encode()
 }
 
 // Calculate Votes from transaction in this block, write into header.Extra
-func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt) (HeaderExtra, RefundGas, error) {
+// and collect the FUL spent by flow reports into history if it isn't nil
+func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt, history *fulHistory) (HeaderExtra, RefundGas, error) {
+	return a.applyCustomTx(headerExtra, chain, header, state, txs, receipts, nil, history)
+}
+
+// applyCustomTx is processCustomTx which also reports every rejected custom
+// transaction to rejected if it isn't nil.
+func (a *Alien) applyCustomTx(headerExtra HeaderExtra, chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt, rejected func(*types.Transaction, CustomTxReject), history *fulHistory) (HeaderExtra, RefundGas, error) {
 	// if predecessor voter make transaction and vote in this block,
 	// just process as vote, do it in snapshot.apply
 	var (
@@ -593,130 +540,153 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainHe
 			continue
 		}
 
-		if len(string(tx.Data())) >= len(ufoPrefix) {
-			txData := string(tx.Data())
-			txDataInfo := strings.Split(txData, ":")
-			if len(txDataInfo) >= ufoMinSplitLen {
-				if txDataInfo[posPrefix] == ufoPrefix {
-					if txDataInfo[posVersion] == ufoVersion {
-						// process vote event
-						if txDataInfo[posCategory] == ufoCategoryEvent {
-							if len(txDataInfo) > ufoMinSplitLen {
-								// check is vote or not
-								if txDataInfo[posEventVote] == ufoEventVote && (!candidateNeedPD || snap.isCandidate(*tx.To())) && state.GetBalance(txSender).Cmp(snap.MinVB) > 0 {
-									headerExtra.CurrentBlockVotes = a.processEventVote(headerExtra.CurrentBlockVotes, state, tx, txSender)
-								} else if txDataInfo[posEventConfirm] == ufoEventConfirm && snap.isCandidate(txSender) {
-									headerExtra.CurrentBlockConfirmations, refundHash = a.processEventConfirm(headerExtra.CurrentBlockConfirmations, chain, txDataInfo, number, tx, txSender, refundHash)
-								} else if txDataInfo[posEventProposal] == ufoEventPorposal {
-									headerExtra.CurrentBlockProposals = a.processEventProposal(headerExtra.CurrentBlockProposals, txDataInfo, state, tx, txSender, snap)
-								} else if txDataInfo[posEventDeclare] == ufoEventDeclare && snap.isCandidate(txSender) {
-									headerExtra.CurrentBlockDeclares = a.processEventDeclare(headerExtra.CurrentBlockDeclares, txDataInfo, tx, txSender)
-								}
-							} else {
-								// todo : something wrong, leave this transaction to process as normal transaction
-							}
-						} else if txDataInfo[posCategory] == ufoCategoryLog {
-							// todo :
-						} else if txDataInfo[posCategory] == ufoCategorySC {
-							if len(txDataInfo) > ufoMinSplitLen {
-								if txDataInfo[posEventConfirm] == ufoEventConfirm {
-									if len(txDataInfo) > ufoMinSplitLen+5 {
-										number := new(big.Int)
-										if err := number.UnmarshalText([]byte(txDataInfo[ufoMinSplitLen+2])); err != nil {
-											log.Trace("Side chain confirm info fail", "number", txDataInfo[ufoMinSplitLen+2])
-											continue
-										}
-										if err := new(big.Int).UnmarshalText([]byte(txDataInfo[ufoMinSplitLen+3])); err != nil {
-											log.Trace("Side chain confirm info fail", "time", txDataInfo[ufoMinSplitLen+3])
-											continue
-										}
-										loopInfo := txDataInfo[ufoMinSplitLen+4]
-										scHash := common.HexToHash(txDataInfo[ufoMinSplitLen+1])
-										headerExtra.SideChainConfirmations, refundHash = a.processSCEventConfirm(headerExtra.SideChainConfirmations,
-											scHash, number.Uint64(), loopInfo, tx, txSender, refundHash)
-
-										chargingInfo := txDataInfo[ufoMinSplitLen+5]
-										headerExtra.SideChainNoticeConfirmed = a.processSCEventNoticeConfirm(headerExtra.SideChainNoticeConfirmed,
-											scHash, number.Uint64(), chargingInfo, txSender)
-
-									}
-								} else if txDataInfo[posEventSetCoinbase] == ufoEventSetCoinbase && a.isManagerAddressFlowReport(txSender,snap) {
-									if len(txDataInfo) > ufoMinSplitLen+1 {
-										// the signer of main chain must send some value to coinbase of side chain for confirm tx of side chain
-										if tx.Value().Cmp(minSCSetCoinbaseValue) >= 0 {
-											headerExtra.SideChainSetCoinbases = a.processSCEventSetCoinbase(headerExtra.SideChainSetCoinbases,
-												common.HexToHash(txDataInfo[ufoMinSplitLen+1]), txSender, *tx.To(), true)
-										}
-									}
-								} else if txDataInfo[posEventSetCoinbase] == ufoEventDelCoinbase && a.isManagerAddressFlowReport(txSender,snap) {
-									if len(txDataInfo) > ufoMinSplitLen+1 {
-										headerExtra.SideChainSetCoinbases = a.processSCEventSetCoinbase(headerExtra.SideChainSetCoinbases,
-											common.HexToHash(txDataInfo[ufoMinSplitLen+1]), txSender, *tx.To(), false)
-									}
-								} else if ufoEventFlowReport1 == txDataInfo[posEventFlowReport] {
-									ok := false
-									headerExtra.FlowReport, ok = a.processFlowReport1 (headerExtra.FlowReport, txDataInfo, txSender, snap,number)
-									if ok {
-										refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
-									}
-								} else if ufoEventFlowReport2 == txDataInfo[posEventFlowReport] && a.isManagerAddressFlowReport(txSender,snap) {
-										headerExtra.FlowReport = a.processFlowReport2 (headerExtra.FlowReport, txDataInfo,number,snap)
-										refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
-								}
-							}
-						}
-					}
-				} else if txDataInfo[posPrefix] == nfcPrefix {
-					if txDataInfo[posVersion] == ufoVersion {
-						if txDataInfo[posCategory] == nfcCategoryExch {
-							headerExtra.ExchangeNFC = a.processExchangeNFC (headerExtra.ExchangeNFC, txDataInfo, txSender, tx, receipts, state, snap)
-						} else if txDataInfo[posCategory] == nfcCategoryMultiSign {
-							a.processCreateMultiSignature (txDataInfo, txSender, tx, receipts, state)
-						} else if txDataInfo[posCategory] == nfcCategoryBind {
-							headerExtra.DeviceBind = a.processDeviceBind (headerExtra.DeviceBind, txDataInfo, txSender, tx, receipts, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryUnbind {
-							headerExtra.DeviceBind = a.processDeviceUnbind (headerExtra.DeviceBind, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryRebind {
-							headerExtra.DeviceBind = a.processDeviceRebind (headerExtra.DeviceBind, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryCandReq {
-							headerExtra.CandidatePledge = a.processCandidatePledge (headerExtra.CandidatePledge, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryCandExit {
-							headerExtra.CandidateExit = a.processCandidateExit (headerExtra.CandidateExit, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryCandPnsh {
-							headerExtra.CandidatePunish = a.processCandidatePunish (headerExtra.CandidatePunish, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryFlwReq {
-							headerExtra.ClaimedBandwidth = a.processMinerPledge (headerExtra.ClaimedBandwidth, txDataInfo, txSender, tx, receipts, state, snapCache)
-						} else if txDataInfo[posCategory] == nfcCategoryFlwExit {
-							headerExtra.FlowMinerExit = a.processMinerExit (headerExtra.FlowMinerExit, txDataInfo, txSender, tx, receipts, state, snapCache)
-						}
-						if isGeFulTrieNumber(number){
-							headerExtra=a.processFlowCustomTx(txDataInfo,headerExtra,txSender, tx, receipts, snapCache, header.Number,state,chain,fulBalances)
-						}
-					}
-				}  else if txDataInfo[posPrefix] == sscPrefix {
-					if txDataInfo[posVersion] == ufoVersion {
-						if txDataInfo[posCategory] == sscCategoryExchRate {
-							headerExtra.ConfigExchRate = a.processExchRate (txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryDeposit {
-							headerExtra.ConfigDeposit = a.processCandidateDeposit (headerExtra.ConfigDeposit, txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryCndLock {
-							headerExtra.LockParameters = a.processCndLockConfig (headerExtra.LockParameters, txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryFlwLock {
-							headerExtra.LockParameters = a.processFlwLockConfig (headerExtra.LockParameters, txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryRwdLock {
-							headerExtra.LockParameters = a.processRwdLockConfig (headerExtra.LockParameters, txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryOffLine {
-							headerExtra.ConfigOffLine = a.processOffLine (txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryQOS {
-							headerExtra.ConfigISPQOS = a.processISPQos (headerExtra.ConfigISPQOS, txDataInfo, txSender, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryWdthPnsh {
-							headerExtra.BandwidthPunish = a.processBandwidthPunish (headerExtra.BandwidthPunish, txDataInfo, txSender, tx, receipts, snapCache)
-						} else if txDataInfo[posCategory] == sscCategoryManager {
-							headerExtra.ManagerAddress = a.processManagerAddress (headerExtra.ManagerAddress, txDataInfo, txSender, snapCache)
-						}
-					}
+		txData := tx.Data()
+		reject := RejectNone
+		switch customtx.Identify(txData) {
+		// process vote event
+		case customtx.KindVote:
+			if candidateNeedPD && !snap.isCandidate(*tx.To()) {
+				reject = RejectNotCandidate
+			} else if state.GetBalance(txSender).Cmp(snap.MinVB) <= 0 {
+				reject = RejectInsufficientBalance
+			} else {
+				headerExtra.CurrentBlockVotes = a.processEventVote(headerExtra.CurrentBlockVotes, state, tx, txSender)
+			}
+		case customtx.KindUnvote:
+			headerExtra.CurrentBlockUnvotes, reject = a.processEventUnvote(headerExtra.CurrentBlockUnvotes, txData, txSender, tx, receipts, number, snapCache)
+		case customtx.KindRedelegate:
+			headerExtra.CurrentBlockRedelegates, reject = a.processEventRedelegate(headerExtra.CurrentBlockRedelegates, txData, txSender, tx, receipts, number, snapCache)
+		case customtx.KindConfirm:
+			if snap.isCandidate(txSender) {
+				headerExtra.CurrentBlockConfirmations, refundHash, reject = a.processEventConfirm(headerExtra.CurrentBlockConfirmations, chain, txData, number, tx, txSender, refundHash)
+			} else {
+				reject = RejectNotCandidate
+			}
+		case customtx.KindProposal:
+			headerExtra.CurrentBlockProposals, reject = a.processEventProposal(headerExtra.CurrentBlockProposals, txData, state, tx, txSender, snap)
+		case customtx.KindDeclare:
+			if snap.isCandidate(txSender) {
+				headerExtra.CurrentBlockDeclares, reject = a.processEventDeclare(headerExtra.CurrentBlockDeclares, txData, tx, txSender)
+			} else {
+				reject = RejectNotCandidate
+			}
+		// process side chain event
+		case customtx.KindSCConfirm:
+			var scConfirm customtx.SCConfirm
+			if err := scConfirm.Decode(txData); err != nil {
+				if errors.Is(err, customtx.ErrInvalidField) {
+					log.Trace("Side chain confirm info fail", "err", err)
+					a.rejectCustomTx(tx, receipts, header.Number, RejectMalformed, rejected)
+					continue
 				}
+				reject = RejectMalformed
+			} else {
+				headerExtra.SideChainConfirmations, refundHash = a.processSCEventConfirm(headerExtra.SideChainConfirmations,
+					scConfirm.SCHash, scConfirm.Number.Uint64(), scConfirm.LoopInfo, tx, txSender, refundHash)
+
+				headerExtra.SideChainNoticeConfirmed = a.processSCEventNoticeConfirm(headerExtra.SideChainNoticeConfirmed,
+					scConfirm.SCHash, scConfirm.Number.Uint64(), scConfirm.ChargingInfo, txSender)
 			}
+		case customtx.KindSetCoinbase:
+			var setCoinbase customtx.SetCoinbase
+			if !a.isManagerAddressFlowReport(txSender,snap) {
+				reject = RejectNotManager
+			} else if setCoinbase.Decode(txData) != nil {
+				reject = RejectMalformed
+			} else if tx.Value().Cmp(minSCSetCoinbaseValue) < 0 {
+				// the signer of main chain must send some value to coinbase of side chain for confirm tx of side chain
+				reject = RejectValueTooLow
+			} else {
+				headerExtra.SideChainSetCoinbases = a.processSCEventSetCoinbase(headerExtra.SideChainSetCoinbases,
+					setCoinbase.SCHash, txSender, *tx.To(), true)
+			}
+		case customtx.KindDelCoinbase:
+			var delCoinbase customtx.DelCoinbase
+			if !a.isManagerAddressFlowReport(txSender,snap) {
+				reject = RejectNotManager
+			} else if delCoinbase.Decode(txData) != nil {
+				reject = RejectMalformed
+			} else {
+				headerExtra.SideChainSetCoinbases = a.processSCEventSetCoinbase(headerExtra.SideChainSetCoinbases,
+					delCoinbase.SCHash, txSender, *tx.To(), false)
+			}
+		case customtx.KindFlowReport:
+			headerExtra.FlowReport, reject = a.processFlowReport1 (headerExtra.FlowReport, txData, txSender, snap,number)
+			if reject == RejectNone {
+				refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
+			}
+		case customtx.KindFlowReportM:
+			if a.isManagerAddressFlowReport(txSender,snap) {
+				headerExtra.FlowReport, reject = a.processFlowReport2 (headerExtra.FlowReport, txData,number,snap)
+				refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
+			} else {
+				reject = RejectNotManager
+			}
+		// process NFC transaction
+		case customtx.KindExchange:
+			headerExtra.ExchangeNFC, reject = a.processExchangeNFC (headerExtra.ExchangeNFC, txData, txSender, tx, receipts, state, snap)
+		case customtx.KindMultiSign:
+			reject = a.processCreateMultiSignature (txData, txSender, tx, receipts, state)
+		case customtx.KindBind:
+			headerExtra.DeviceBind, reject = a.processDeviceBind (headerExtra.DeviceBind, txData, txSender, tx, receipts, snapCache)
+		case customtx.KindUnbind:
+			headerExtra.DeviceBind, reject = a.processDeviceUnbind (headerExtra.DeviceBind, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindRebind:
+			headerExtra.DeviceBind, reject = a.processDeviceRebind (headerExtra.DeviceBind, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindCandidatePledge:
+			headerExtra.CandidatePledge, reject = a.processCandidatePledge (headerExtra.CandidatePledge, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindCandidateExit:
+			headerExtra.CandidateExit, reject = a.processCandidateExit (headerExtra.CandidateExit, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindCandidatePunish:
+			headerExtra.CandidatePunish, reject = a.processCandidatePunish (headerExtra.CandidatePunish, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindMinerPledge:
+			headerExtra.ClaimedBandwidth, reject = a.processMinerPledge (headerExtra.ClaimedBandwidth, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindMinerExit:
+			headerExtra.FlowMinerExit, reject = a.processMinerExit (headerExtra.FlowMinerExit, txData, txSender, tx, receipts, state, snapCache)
+		case customtx.KindFlowReportEn:
+			if isGeFulTrieNumber(a.config, number){
+				headerExtra, reject = a.processFlowCustomTx(txData,headerExtra,txSender, tx, receipts, snapCache, header.Number,state,chain,fulBalances,history)
+			} else {
+				reject = RejectNotActive
+			}
+		// process system config transaction
+		case customtx.KindExchRate:
+			headerExtra.ConfigExchRate, reject = a.processExchRate (txData, txSender, snapCache)
+		case customtx.KindDeposit:
+			headerExtra.ConfigDeposit, reject = a.processCandidateDeposit (headerExtra.ConfigDeposit, txData, txSender, snapCache)
+		case customtx.KindCndLock:
+			headerExtra.LockParameters, reject = a.processCndLockConfig (headerExtra.LockParameters, txData, txSender, snapCache)
+		case customtx.KindFlwLock:
+			headerExtra.LockParameters, reject = a.processFlwLockConfig (headerExtra.LockParameters, txData, txSender, snapCache)
+		case customtx.KindRwdLock:
+			headerExtra.LockParameters, reject = a.processRwdLockConfig (headerExtra.LockParameters, txData, txSender, snapCache)
+		case customtx.KindOffLine:
+			headerExtra.ConfigOffLine, reject = a.processOffLine (txData, txSender, snapCache)
+		case customtx.KindISPQos:
+			headerExtra.ConfigISPQOS, reject = a.processISPQos (headerExtra.ConfigISPQOS, txData, txSender, snapCache)
+		case customtx.KindWdthPnsh:
+			headerExtra.BandwidthPunish, reject = a.processBandwidthPunish (headerExtra.BandwidthPunish, txData, txSender, tx, receipts, snapCache)
+		case customtx.KindManager:
+			headerExtra.ManagerAddress, reject = a.processManagerAddress (headerExtra.ManagerAddress, txData, txSender, snapCache)
+		case customtx.KindDoubleSign:
+			headerExtra.DoubleSignSlash, reject = a.processDoubleSign (headerExtra.DoubleSignSlash, chain, txData, tx, receipts, number, snapCache)
+		case customtx.KindUnjail:
+			headerExtra.CandidateUnjail, reject = a.processUnjail (headerExtra.CandidateUnjail, txData, txSender, tx, receipts, state, number, snapCache)
+		case customtx.KindMultiSignPropose:
+			reject = a.processMultiSigPropose(&headerExtra, txData, txSender, tx, receipts, state, number, snap, snapCache)
+		case customtx.KindMultiSignApprove:
+			reject = a.processMultiSigApprove(&headerExtra, txData, txSender, tx, receipts, state, number, snap, snapCache)
+		case customtx.KindMultiSignAdd, customtx.KindMultiSignRemove, customtx.KindMultiSignRequire:
+			reject = a.processMultiSigChange(txData, txSender, tx, receipts, state, number)
+		case customtx.KindFulTransfer:
+			headerExtra.FulTransfers, reject = a.processFulTransfer(headerExtra.FulTransfers, txData, txSender, tx, receipts, number, snapCache, fulBalances)
+		case customtx.KindFulApprove:
+			headerExtra.FulAllowances, reject = a.processFulApprove(headerExtra.FulAllowances, txData, txSender, tx, receipts, number, snapCache)
+		case customtx.KindFulTransferFrom:
+			reject = a.processFulTransferFrom(&headerExtra, txData, txSender, tx, receipts, number, snapCache, fulBalances)
+		}
+		if reject != RejectNone {
+			a.rejectCustomTx(tx, receipts, header.Number, reject, rejected)
 		}
 		// check each address
 		if number > 1 {
@@ -776,13 +746,14 @@ func (a *Alien) processSCEventSetCoinbase(scEventSetCoinbases []SCSetCoinbase, h
 	return scEventSetCoinbases
 }
 
-func (a *Alien) processEventProposal(currentBlockProposals []Proposal, txDataInfo []string, state *state.StateDB, tx *types.Transaction, proposer common.Address, snap *Snapshot) []Proposal {
+func (a *Alien) processEventProposal(currentBlockProposals []Proposal, txData []byte, state *state.StateDB, tx *types.Transaction, proposer common.Address, snap *Snapshot) ([]Proposal, CustomTxReject) {
 	// sample for add side chain proposal
 	// eth.sendTransaction({from:eth.accounts[0],to:eth.accounts[0],value:0,data:web3.toHex("ufo:1:event:proposal:proposal_type:4:sccount:2:screward:50:schash:0x3210000000000000000000000000000000000000000000000000000000000000:vlcnt:4")})
 	// sample for declare
 	// eth.sendTransaction({from:eth.accounts[0],to:eth.accounts[0],value:0,data:web3.toHex("ufo:1:event:declare:hash:0x853e10706e6b9d39c5f4719018aa2417e8b852dec8ad18f9c592d526db64c725:decision:yes")})
-	if len(txDataInfo) <= posEventProposal+2 {
-		return currentBlockProposals
+	var payload customtx.Proposal
+	if err := payload.Decode(txData); err != nil {
+		return currentBlockProposals, RejectMalformed
 	}
 
 	proposal := Proposal{
@@ -805,134 +776,77 @@ func (a *Alien) processEventProposal(currentBlockProposals []Proposal, txDataInf
 		SCRentLength:           defaultSCRentLength,
 	}
 
-	for i := 0; i < len(txDataInfo[posEventProposal+1:])/2; i++ {
-		k, v := txDataInfo[posEventProposal+1+i*2], txDataInfo[posEventProposal+2+i*2]
-		switch k {
-		case "vlcnt":
-			// If vlcnt is missing then user default value, but if the vlcnt is beyond the min/max value then ignore this proposal
-			if validationLoopCnt, err := strconv.Atoi(v); err != nil || validationLoopCnt < minValidationLoopCnt || validationLoopCnt > maxValidationLoopCnt {
-				return currentBlockProposals
-			} else {
-				proposal.ValidationLoopCnt = uint64(validationLoopCnt)
-			}
-		case "schash":
-			proposal.SCHash.UnmarshalText([]byte(v))
-		case "sccount":
-			if scBlockCountPerPeriod, err := strconv.Atoi(v); err != nil {
-				return currentBlockProposals
-			} else {
-				proposal.SCBlockCountPerPeriod = uint64(scBlockCountPerPeriod)
-			}
-		case "screward":
-			if scBlockRewardPerPeriod, err := strconv.Atoi(v); err != nil {
-				return currentBlockProposals
-			} else {
-				proposal.SCBlockRewardPerPeriod = uint64(scBlockRewardPerPeriod)
-			}
-		case "proposal_type":
-			if proposalType, err := strconv.Atoi(v); err != nil {
-				return currentBlockProposals
-			} else {
-				proposal.ProposalType = uint64(proposalType)
-			}
-		case "candidate":
-			// not check here
-			proposal.TargetAddress.UnmarshalText([]byte(v))
-		case "mrpt":
-			// miner reward per thousand
-			if mrpt, err := strconv.Atoi(v); err != nil || mrpt <= 0 || mrpt > 1000 {
-				return currentBlockProposals
-			} else {
-				proposal.MinerRewardPerThousand = uint64(mrpt)
-			}
-		case "mvb":
-			// minVoterBalance
-			if mvb, err := strconv.Atoi(v); err != nil || mvb <= 0 {
-				return currentBlockProposals
-			} else {
-				proposal.MinVoterBalance = uint64(mvb)
-			}
-		case "mpd":
-			// proposalDeposit
-			if mpd, err := strconv.Atoi(v); err != nil || mpd <= 0 || mpd > maxProposalDeposit {
-				return currentBlockProposals
-			} else {
-				proposal.ProposalDeposit = uint64(mpd)
-			}
-		case "scrt":
-			// target address on side chain to charge gas
-			proposal.TargetAddress.UnmarshalText([]byte(v))
-		case "scrf":
-			// side chain rent fee
-			if scrf, err := strconv.Atoi(v); err != nil || scrf < minSCRentFee {
-				return currentBlockProposals
-			} else {
-				proposal.SCRentFee = uint64(scrf)
-			}
-		case "scrr":
-			// side chain rent rate
-			if scrr, err := strconv.Atoi(v); err != nil || scrr <= 0 {
-				return currentBlockProposals
-			} else {
-				proposal.SCRentRate = uint64(scrr)
-			}
-		case "scrl":
-			// side chain rent length
-			if scrl, err := strconv.Atoi(v); err != nil || scrl < minSCRentLength || scrl > maxSCRentLength {
-				return currentBlockProposals
-			} else {
-				proposal.SCRentLength = uint64(scrl)
-			}
-		}
+	// the limits of the values are checked by the decoder
+	if payload.ValidationLoopCnt != nil {
+		proposal.ValidationLoopCnt = uint64(*payload.ValidationLoopCnt)
+	}
+	if payload.SCHash != nil {
+		proposal.SCHash = *payload.SCHash
+	}
+	if payload.SCBlockCountPerPeriod != nil {
+		proposal.SCBlockCountPerPeriod = uint64(*payload.SCBlockCountPerPeriod)
+	}
+	if payload.SCBlockRewardPerPeriod != nil {
+		proposal.SCBlockRewardPerPeriod = uint64(*payload.SCBlockRewardPerPeriod)
+	}
+	if payload.ProposalType != nil {
+		proposal.ProposalType = uint64(*payload.ProposalType)
+	}
+	if payload.TargetAddress != nil {
+		// candidate, or target address on side chain to charge gas, not check here
+		proposal.TargetAddress = *payload.TargetAddress
+	}
+	if payload.MinerRewardPerThousand != nil {
+		proposal.MinerRewardPerThousand = uint64(*payload.MinerRewardPerThousand)
+	}
+	if payload.MinVoterBalance != nil {
+		proposal.MinVoterBalance = uint64(*payload.MinVoterBalance)
+	}
+	if payload.ProposalDeposit != nil {
+		proposal.ProposalDeposit = uint64(*payload.ProposalDeposit)
+	}
+	if payload.SCRentFee != nil {
+		proposal.SCRentFee = uint64(*payload.SCRentFee)
+	}
+	if payload.SCRentRate != nil {
+		proposal.SCRentRate = uint64(*payload.SCRentRate)
+	}
+	if payload.SCRentLength != nil {
+		proposal.SCRentLength = uint64(*payload.SCRentLength)
 	}
 	// now the proposal is built
 	currentProposalPay := new(big.Int).Set(proposalDeposit)
 	if proposal.ProposalType == proposalTypeRentSideChain {
 		// check if the proposal target side chain exist
 		if !snap.isSideChainExist(proposal.SCHash) {
-			return currentBlockProposals
+			return currentBlockProposals, RejectSideChainNotExist
 		}
 		if (proposal.TargetAddress == common.Address{}) {
-			return currentBlockProposals
+			return currentBlockProposals, RejectMissingTarget
 		}
 		currentProposalPay.Add(currentProposalPay, new(big.Int).Mul(new(big.Int).SetUint64(proposal.SCRentFee), big.NewInt(1e+18)))
 	}
 	// check enough balance for deposit
 	if state.GetBalance(proposer).Cmp(currentProposalPay) < 0 {
-		return currentBlockProposals
+		return currentBlockProposals, RejectInsufficientBalance
 	}
 	// collection the fee for this proposal (deposit and other fee , sc rent fee ...)
 	state.SetBalance(proposer, new(big.Int).Sub(state.GetBalance(proposer), currentProposalPay))
 
-	return append(currentBlockProposals, proposal)
+	return append(currentBlockProposals, proposal), RejectNone
 }
 
-func (a *Alien) processEventDeclare(currentBlockDeclares []Declare, txDataInfo []string, tx *types.Transaction, declarer common.Address) []Declare {
-	if len(txDataInfo) <= posEventDeclare+2 {
-		return currentBlockDeclares
+func (a *Alien) processEventDeclare(currentBlockDeclares []Declare, txData []byte, tx *types.Transaction, declarer common.Address) ([]Declare, CustomTxReject) {
+	var payload customtx.Declare
+	if err := payload.Decode(txData); err != nil {
+		return currentBlockDeclares, RejectMalformed
 	}
 	declare := Declare{
-		ProposalHash: common.Hash{},
+		ProposalHash: payload.ProposalHash,
 		Declarer:     declarer,
-		Decision:     true,
-	}
-	for i := 0; i < len(txDataInfo[posEventDeclare+1:])/2; i++ {
-		k, v := txDataInfo[posEventDeclare+1+i*2], txDataInfo[posEventDeclare+2+i*2]
-		switch k {
-		case "hash":
-			declare.ProposalHash.UnmarshalText([]byte(v))
-		case "decision":
-			if v == "yes" {
-				declare.Decision = true
-			} else if v == "no" {
-				declare.Decision = false
-			} else {
-				return currentBlockDeclares
-			}
-		}
+		Decision:     payload.Decision,
 	}
-
-	return append(currentBlockDeclares, declare)
+	return append(currentBlockDeclares, declare), RejectNone
 }
 
 func (a *Alien) processEventVote(currentBlockVotes []Vote, state *state.StateDB, tx *types.Transaction, voter common.Address) []Vote {
@@ -950,41 +864,118 @@ func (a *Alien) processEventVote(currentBlockVotes []Vote, state *state.StateDB,
 	return currentBlockVotes
 }
 
-func (a *Alien) processEventConfirm(currentBlockConfirmations []Confirmation, chain consensus.ChainHeaderReader, txDataInfo []string, number uint64, tx *types.Transaction, confirmer common.Address, refundHash RefundHash) ([]Confirmation, RefundHash) {
-	if len(txDataInfo) > posEventConfirmNumber {
-		confirmedBlockNumber := new(big.Int)
-		err := confirmedBlockNumber.UnmarshalText([]byte(txDataInfo[posEventConfirmNumber]))
-		if err != nil || number-confirmedBlockNumber.Uint64() > a.config.MaxSignerCount || number-confirmedBlockNumber.Uint64() < 0 {
-			return currentBlockConfirmations, refundHash
-		}
-		// check if the voter is in block
-		confirmedHeader := chain.GetHeaderByNumber(confirmedBlockNumber.Uint64())
-		if confirmedHeader == nil {
-			//log.Info("Fail to get confirmedHeader")
-			return currentBlockConfirmations, refundHash
-		}
-		confirmedHeaderExtra := HeaderExtra{}
-		if extraVanity+extraSeal > len(confirmedHeader.Extra) {
-			return currentBlockConfirmations, refundHash
-		}
-		err = decodeHeaderExtra(a.config, confirmedBlockNumber, confirmedHeader.Extra[extraVanity:len(confirmedHeader.Extra)-extraSeal], &confirmedHeaderExtra)
-		if err != nil {
-			log.Info("Fail to decode parent header", "err", err)
-			return currentBlockConfirmations, refundHash
-		}
-		for _, s := range confirmedHeaderExtra.SignerQueue {
-			if s == confirmer {
-				currentBlockConfirmations = append(currentBlockConfirmations, Confirmation{
-					Signer:      confirmer,
-					BlockNumber: new(big.Int).Set(confirmedBlockNumber),
-				})
-				refundHash[tx.Hash()] = RefundPair{confirmer, tx.GasPrice()}
-				break
-			}
+func (a *Alien) processEventUnvote(currentBlockUnvotes []common.Address, txData []byte, voter common.Address, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot) ([]common.Address, CustomTxReject) {
+	if !isForkedNumber(a.config.IsUnvote, number) {
+		return currentBlockUnvotes, RejectNotActive
+	}
+	var payload customtx.Unvote
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Unvote", "err", err)
+		return currentBlockUnvotes, RejectMalformed
+	}
+	vote, ok := snap.Votes[voter]
+	if !ok {
+		log.Warn("Unvote", "not voted", voter)
+		return currentBlockUnvotes, RejectNotVoted
+	}
+	if snap.inVoteCooldown(voter, number) {
+		log.Warn("Unvote", "cooldown of", voter)
+		return currentBlockUnvotes, RejectVoteCooldown
+	}
+	candidate, stake := vote.Candidate, new(big.Int).Set(vote.Stake)
+	snap.unvote(voter, number)
+	topics := make([]common.Hash, 3)
+	topics[0].UnmarshalText([]byte("0xaa0e554f781c3c3b2be110a0557f260f11af9a8aa2c64bc1e7a31dbb21e32fa2")) //web3.sha3("Unvote(address,address,uint256)")
+	topics[1].SetBytes(voter.Bytes())
+	topics[2].SetBytes(candidate.Bytes())
+	data := common.BigToHash(stake).Bytes()
+	a.addCustomerTxLog(tx, receipts, topics, data)
+	currentBlockUnvotes = append(currentBlockUnvotes, voter)
+	return currentBlockUnvotes, RejectNone
+}
+
+func (a *Alien) processEventRedelegate(currentBlockRedelegates []Vote, txData []byte, voter common.Address, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot) ([]Vote, CustomTxReject) {
+	if !isForkedNumber(a.config.IsUnvote, number) {
+		return currentBlockRedelegates, RejectNotActive
+	}
+	var payload customtx.Redelegate
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Redelegate", "err", err)
+		return currentBlockRedelegates, RejectMalformed
+	}
+	if tx.To() == nil {
+		return currentBlockRedelegates, RejectMissingTarget
+	}
+	candidate := *tx.To()
+	if candidateNeedPD && !snap.isCandidate(candidate) {
+		log.Warn("Redelegate", "not candidate", candidate)
+		return currentBlockRedelegates, RejectNotCandidate
+	}
+	vote, ok := snap.Votes[voter]
+	if !ok {
+		log.Warn("Redelegate", "not voted", voter)
+		return currentBlockRedelegates, RejectNotVoted
+	}
+	if vote.Candidate == candidate {
+		log.Warn("Redelegate", "same candidate", candidate)
+		return currentBlockRedelegates, RejectSameCandidate
+	}
+	if snap.inVoteCooldown(voter, number) {
+		log.Warn("Redelegate", "cooldown of", voter)
+		return currentBlockRedelegates, RejectVoteCooldown
+	}
+	previous, stake := vote.Candidate, new(big.Int).Set(vote.Stake)
+	snap.redelegate(voter, candidate, number)
+	topics := make([]common.Hash, 4)
+	topics[0].UnmarshalText([]byte("0x3ca49cdc02409b294ed8ec6b982a9785702a2ae85e08b6208625dd6beee36036")) //web3.sha3("Redelegate(address,address,address,uint256)")
+	topics[1].SetBytes(voter.Bytes())
+	topics[2].SetBytes(previous.Bytes())
+	topics[3].SetBytes(candidate.Bytes())
+	data := common.BigToHash(stake).Bytes()
+	a.addCustomerTxLog(tx, receipts, topics, data)
+	currentBlockRedelegates = append(currentBlockRedelegates, Vote{
+		Voter:     voter,
+		Candidate: candidate,
+		Stake:     stake,
+	})
+	return currentBlockRedelegates, RejectNone
+}
+
+func (a *Alien) processEventConfirm(currentBlockConfirmations []Confirmation, chain consensus.ChainHeaderReader, txData []byte, number uint64, tx *types.Transaction, confirmer common.Address, refundHash RefundHash) ([]Confirmation, RefundHash, CustomTxReject) {
+	var payload customtx.Confirm
+	if err := payload.Decode(txData); err != nil {
+		return currentBlockConfirmations, refundHash, RejectMalformed
+	}
+	confirmedBlockNumber := payload.Number
+	if number-confirmedBlockNumber.Uint64() > a.config.MaxSignerCount || number-confirmedBlockNumber.Uint64() < 0 {
+		return currentBlockConfirmations, refundHash, RejectConfirmOutOfRange
+	}
+	// check if the voter is in block
+	confirmedHeader := chain.GetHeaderByNumber(confirmedBlockNumber.Uint64())
+	if confirmedHeader == nil {
+		//log.Info("Fail to get confirmedHeader")
+		return currentBlockConfirmations, refundHash, RejectUnknownBlock
+	}
+	confirmedHeaderExtra := HeaderExtra{}
+	if extraVanity+extraSeal > len(confirmedHeader.Extra) {
+		return currentBlockConfirmations, refundHash, RejectUnknownBlock
+	}
+	err := decodeHeaderExtra(a.config, confirmedBlockNumber, confirmedHeader.Extra[extraVanity:len(confirmedHeader.Extra)-extraSeal], &confirmedHeaderExtra)
+	if err != nil {
+		log.Info("Fail to decode parent header", "err", err)
+		return currentBlockConfirmations, refundHash, RejectUnknownBlock
+	}
+	for _, s := range confirmedHeaderExtra.SignerQueue {
+		if s == confirmer {
+			currentBlockConfirmations = append(currentBlockConfirmations, Confirmation{
+				Signer:      confirmer,
+				BlockNumber: new(big.Int).Set(confirmedBlockNumber),
+			})
+			refundHash[tx.Hash()] = RefundPair{confirmer, tx.GasPrice()}
+			return currentBlockConfirmations, refundHash, RejectNone
 		}
 	}
-
-	return currentBlockConfirmations, refundHash
+	return currentBlockConfirmations, refundHash, RejectNotSigner
 }
 
 func (a *Alien) processPredecessorVoter(modifyPredecessorVotes []Vote, state *state.StateDB, tx *types.Transaction, voter common.Address, snap *Snapshot) []Vote {
@@ -1015,6 +1006,27 @@ func (a *Alien) processPredecessorVoter(modifyPredecessorVotes []Vote, state *st
 	return modifyPredecessorVotes
 }
 
+// customTxLogCount returns the number of logs in the receipt of tx.
+func customTxLogCount(tx *types.Transaction, receipts []*types.Receipt) int {
+	for _, receipt := range receipts {
+		if receipt.TxHash == tx.Hash() {
+			return len(receipt.Logs)
+		}
+	}
+	return 0
+}
+
+// revertCustomTxLogs removes the logs added to the receipt of tx after it had
+// count logs.
+func revertCustomTxLogs(tx *types.Transaction, receipts []*types.Receipt, count int) {
+	for _, receipt := range receipts {
+		if receipt.TxHash == tx.Hash() && len(receipt.Logs) > count {
+			receipt.Logs = receipt.Logs[:count]
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		}
+	}
+}
+
 func (a *Alien) addCustomerTxLog (tx *types.Transaction, receipts []*types.Receipt, topics []common.Hash, data []byte) bool {
 	for _, receipt := range receipts {
 		if receipt.TxHash != tx.Hash() {
@@ -1042,15 +1054,8 @@ func (a *Alien) addCustomerTxLog (tx *types.Transaction, receipts []*types.Recei
 }
 
 func (a *Alien) verifyMultiSignatureAddress(state *state.StateDB, address common.Address, signers []common.Address) bool {
-	if state.Empty(address) {
-		return false
-	}
-	contractHash := state.GetCodeHash(address)
-	if state.GetNonce(address) != 1 || contractHash == (common.Hash{}) || contractHash == crypto.Keccak256Hash(nil) {
-		return false
-	}
-	var parameter consensus.MultiSignatureData
-	if err := rlp.DecodeBytes(state.GetCode(address), &parameter); nil != err {
+	parameter, ok := multiSignatureData(state, address)
+	if !ok {
 		return false
 	}
 	assistAddress := make(map[common.Address]bool)
@@ -1070,39 +1075,28 @@ func (a *Alien) verifyMultiSignatureAddress(state *state.StateDB, address common
 	return okNumber >= int(parameter.Threshold)
 }
 
-func (a *Alien) processCreateMultiSignature (txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB) {
-	if len(txDataInfo) <= nfcPosThreshold + 2 {
-		log.Warn("Create Multi-Signature fail", "parameter number", len(txDataInfo))
-		return
+func (a *Alien) processCreateMultiSignature (txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB) CustomTxReject {
+	var payload customtx.MultiSign
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Create Multi-Signature fail", "err", err)
+		return RejectMalformed
 	}
 	parameter := consensus.MultiSignatureData{
 		Threshold: 0,
 		MultiSigners: []common.Address{},
 	}
-	if threshold, err := strconv.ParseUint(txDataInfo[nfcPosThreshold], 10, 32); err == nil {
-		if 2 > threshold || 10 < threshold {
-			log.Warn("Create Multi-Signature", "threshold", txDataInfo[nfcPosThreshold])
-			return
-		} else {
-			if len(txDataInfo) < nfcPosThreshold + 2 + int(threshold) || len(txDataInfo) > nfcPosThreshold + 1000 {
-				log.Warn("Create Multi-Signature fail", "parameter number", len(txDataInfo))
-				return
-			}
-		}
-		parameter.Threshold = uint32(threshold)
+	if threshold := payload.Threshold; minMultiSigThreshold > threshold || maxMultiSigThreshold < threshold {
+		log.Warn("Create Multi-Signature", "threshold", threshold)
+		return RejectInvalidThreshold
 	} else {
-		log.Warn("Create Multi-Signature", "threshold", txDataInfo[nfcPosThreshold])
-		return
+		if len(payload.Owners) < int(threshold) + 1 || len(payload.Owners) > maxMultiSigOwners {
+			log.Warn("Create Multi-Signature fail", "owner number", len(payload.Owners))
+			return RejectInvalidOwners
+		}
 	}
+	parameter.Threshold = payload.Threshold
 	signers := make(map[common.Address]bool)
-	i := nfcPosThreshold + 1
-	for i < len(txDataInfo) {
-		var address common.Address
-		if err := address.UnmarshalText1([]byte(txDataInfo[i])); err != nil {
-			log.Warn("Create Multi-Signature", "address", txDataInfo[i])
-			return
-		}
-		i++
+	for _, address := range payload.Owners {
 		if _, ok := signers[address]; !ok {
 			signers[address] = true
 			parameter.MultiSigners = append(parameter.MultiSigners, address)
@@ -1110,16 +1104,16 @@ func (a *Alien) processCreateMultiSignature (txDataInfo []string, txSender commo
 	}
 	if len(parameter.MultiSigners) <= int(parameter.Threshold) {
 		log.Warn("Create Multi-Signature fail", "Owner number", len(parameter.MultiSigners), "threshold", parameter.Threshold)
-		return
+		return RejectInvalidOwners
 	}
 	data, err := rlp.EncodeToBytes(parameter)
 	if nil != err {
 		log.Warn("Create Multi-Signature fail", "err", err)
-		return
+		return RejectMalformed
 	}
 	if len(data) > params.MaxCodeSize {
 		log.Warn("Create Multi-Signature fail for max code size exceeded")
-		return
+		return RejectCodeSizeExceeded
 	}
 	snapshot := state.Snapshot()
 	contractAddr := crypto.CreateAddress(txSender, tx.Nonce())
@@ -1128,7 +1122,7 @@ func (a *Alien) processCreateMultiSignature (txDataInfo []string, txSender commo
 	if state.GetNonce(contractAddr) != 0 || (contractHash != (common.Hash{}) && contractHash != crypto.Keccak256Hash(nil)) {
 		state.RevertToSnapshot(snapshot)
 		log.Warn("Create Multi-Signature fail", "err", err)
-		return
+		return RejectAddressInUse
 	}
 	state.CreateAccount(contractAddr)
 	state.SetNonce(contractAddr, 1)
@@ -1138,30 +1132,23 @@ func (a *Alien) processCreateMultiSignature (txDataInfo []string, txSender commo
 	topics[1].SetBytes(txSender.Bytes())
 	topics[2].SetBytes(big.NewInt(int64(tx.Nonce())).Bytes())
 	a.addCustomerTxLog (tx, receipts, topics, contractAddr.Hash().Bytes())
+	return RejectNone
 }
 
-func (a *Alien) processExchangeNFC (currentExchangeNFC []ExchangeNFCRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []ExchangeNFCRecord {
-	if len(txDataInfo) <= nfcPosExchValue {
-		log.Warn("Exchange NFC to FUL fail", "parameter number", len(txDataInfo))
-		return currentExchangeNFC
+func (a *Alien) processExchangeNFC (currentExchangeNFC []ExchangeNFCRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]ExchangeNFCRecord, CustomTxReject) {
+	var payload customtx.Exchange
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Exchange NFC to FUL fail", "err", err)
+		return currentExchangeNFC, RejectMalformed
 	}
 	exchangeNFC := ExchangeNFCRecord {
-		Target: common.Address{},
+		Target: payload.Target,
 		Amount: big.NewInt(0),
 	}
-	if err := exchangeNFC.Target.UnmarshalText1([]byte(txDataInfo[nfcPosExchAddress])); err != nil {
-		log.Warn("Exchange NFC to FUL fail", "address", txDataInfo[nfcPosExchAddress])
-		return currentExchangeNFC
-	}
-	amount := big.NewInt(0)
-	var err error
-	if amount, err = hexutil.UnmarshalText1([]byte(txDataInfo[nfcPosExchValue])); err != nil {
-		log.Warn("Exchange NFC to FUL fail", "number", txDataInfo[nfcPosExchValue])
-		return currentExchangeNFC
-	}
+	amount := payload.Amount
 	if state.GetBalance(txSender).Cmp(amount) < 0 {
 		log.Warn("Exchange NFC to FUL fail", "balance", state.GetBalance(txSender))
-		return currentExchangeNFC
+		return currentExchangeNFC, RejectInsufficientBalance
 	}
 	exchangeNFC.Amount = new(big.Int).Div(new(big.Int).Mul(amount, big.NewInt(int64(snap.SystemConfig.ExchRate))),big.NewInt(10000))
 	state.SetBalance(txSender, new(big.Int).Sub(state.GetBalance(txSender), amount))
@@ -1177,59 +1164,38 @@ func (a *Alien) processExchangeNFC (currentExchangeNFC []ExchangeNFCRecord, txDa
 	data = append(data, dataList[1].Bytes()...)
 	a.addCustomerTxLog (tx, receipts, topics, data)
 	currentExchangeNFC = append(currentExchangeNFC, exchangeNFC)
-	return currentExchangeNFC
+	return currentExchangeNFC, RejectNone
 }
 
-func (a *Alien) processDeviceBind (currentDeviceBind []DeviceBindRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snap *Snapshot) []DeviceBindRecord {
-	if len(txDataInfo) <= nfcPosMiltiSign {
-		log.Warn("Device bind revenue", "parameter number", len(txDataInfo))
-		return currentDeviceBind
+func (a *Alien) processDeviceBind (currentDeviceBind []DeviceBindRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snap *Snapshot) ([]DeviceBindRecord, CustomTxReject) {
+	var payload customtx.Bind
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Device bind revenue", "err", err)
+		return currentDeviceBind, RejectMalformed
 	}
 	deviceBind := DeviceBindRecord {
-		Device: common.Address{},
+		Device: payload.Device,
 		Revenue: txSender,
-		Contract: common.Address{},
-		MultiSign: common.Address{},
-		Type: 0,
+		Contract: payload.Contract,
+		MultiSign: payload.MultiSign,
+		Type: payload.Type,
 		Bind: true,
 	}
-	if err := deviceBind.Device.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Device bind revenue", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentDeviceBind
-	}
-	if revenueType, err := strconv.ParseUint(txDataInfo[nfcPosRevenueType], 10, 32); err == nil {
-		if revenueType == 0 {
-			if _, ok := snap.RevenueNormal[deviceBind.Device]; ok {
-				log.Warn("Device bind revenue", "device already bond", txDataInfo[nfcPosMinerAddress])
-				return currentDeviceBind
-			}
-		} else {
-			if _, ok := snap.RevenueFlow[deviceBind.Device]; ok {
-				log.Warn("Device bind revenue", "device already bond", txDataInfo[nfcPosMinerAddress])
-				return currentDeviceBind
-			}
+	if deviceBind.Type == 0 {
+		if _, ok := snap.RevenueNormal[deviceBind.Device]; ok {
+			log.Warn("Device bind revenue", "device already bond", deviceBind.Device)
+			return currentDeviceBind, RejectAlreadyBound
 		}
-		deviceBind.Type = uint32(revenueType)
 	} else {
-		log.Warn("Device bind revenue", "type", txDataInfo[nfcPosRevenueType])
-		return currentDeviceBind
-	}
-	if 0 < len(txDataInfo[nfcPosRevenueContract]) {
-		if err := deviceBind.Contract.UnmarshalText1([]byte(txDataInfo[nfcPosRevenueContract])); err != nil {
-			log.Warn("Device bind revenue", "contract address", txDataInfo[nfcPosRevenueContract])
-			return currentDeviceBind
-		}
-	}
-	if 0 < len(txDataInfo[nfcPosMiltiSign]) {
-		if err := deviceBind.MultiSign.UnmarshalText1([]byte(txDataInfo[nfcPosMiltiSign])); err != nil {
-			log.Warn("Device bind revenue", "milti-signature address", txDataInfo[nfcPosRevenueContract])
-			return currentDeviceBind
+		if _, ok := snap.RevenueFlow[deviceBind.Device]; ok {
+			log.Warn("Device bind revenue", "device already bond", deviceBind.Device)
+			return currentDeviceBind, RejectAlreadyBound
 		}
 	}
 
 	if err := a.checkRevenueNormalBind(deviceBind,snap); err != nil {
 		log.Warn("Device bind revenue", "checkRevenueNormalBind", err.Error())
-		return currentDeviceBind
+		return currentDeviceBind, RejectRevenueInUse
 	}
 
 	topics := make([]common.Hash, 3)
@@ -1259,68 +1225,59 @@ func (a *Alien) processDeviceBind (currentDeviceBind []DeviceBindRecord, txDataI
 			MultiSignature: deviceBind.MultiSign,
 		}
 	}
-	return currentDeviceBind
+	return currentDeviceBind, RejectNone
 }
 
-func (a *Alien) processDeviceUnbind (currentDeviceBind []DeviceBindRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []DeviceBindRecord {
-	if len(txDataInfo) <= nfcPosRevenueType {
-		log.Warn("Device unbind revenue", "parameter number", len(txDataInfo))
-		return currentDeviceBind
+func (a *Alien) processDeviceUnbind (currentDeviceBind []DeviceBindRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]DeviceBindRecord, CustomTxReject) {
+	var payload customtx.Unbind
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Device unbind revenue", "err", err)
+		return currentDeviceBind, RejectMalformed
 	}
 	nilHash := common.Address{}
 	zeroHash := common.BigToAddress(big.NewInt(0))
 	deviceBind := DeviceBindRecord {
-		Device: common.Address{},
+		Device: payload.Device,
 		Revenue: common.Address{},
 		Contract: common.Address{},
 		MultiSign: common.Address{},
-		Type: 0,
+		Type: payload.Type,
 		Bind: false,
 	}
-	if err := deviceBind.Device.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Device unbind revenue", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentDeviceBind
-	}
-	if revenueType, err := strconv.ParseUint(txDataInfo[nfcPosRevenueType], 10, 32); err == nil {
-		if revenueType == 0 {
-			if oldBind, ok := snap.RevenueNormal[deviceBind.Device]; !ok {
-				log.Warn("Device unbind revenue", "device never bond", txDataInfo[nfcPosMinerAddress])
-				return currentDeviceBind
+	if deviceBind.Type == 0 {
+		if oldBind, ok := snap.RevenueNormal[deviceBind.Device]; !ok {
+			log.Warn("Device unbind revenue", "device never bond", deviceBind.Device)
+			return currentDeviceBind, RejectNotBound
+		} else {
+			if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
+				if oldBind.RevenueAddress != txSender {
+					log.Warn("Device unbind revenue", "revenue address", oldBind.RevenueAddress)
+					return currentDeviceBind, RejectNotRevenueOwner
+				}
 			} else {
-				if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
-					if oldBind.RevenueAddress != txSender {
-						log.Warn("Device unbind revenue", "revenue address", oldBind.RevenueAddress)
-						return currentDeviceBind
-					}
-				} else {
-					if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
-						log.Warn("Device unbind revenue failed to verify multi-signature")
-						return currentDeviceBind
-					}
+				if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
+					log.Warn("Device unbind revenue failed to verify multi-signature")
+					return currentDeviceBind, RejectMultiSignature
 				}
 			}
+		}
+	} else {
+		if oldBind, ok := snap.RevenueFlow[deviceBind.Device]; !ok {
+			log.Warn("Device unbind revenue", "device never bond", deviceBind.Device)
+			return currentDeviceBind, RejectNotBound
 		} else {
-			if oldBind, ok := snap.RevenueFlow[deviceBind.Device]; !ok {
-				log.Warn("Device unbind revenue", "device never bond", txDataInfo[nfcPosMinerAddress])
-				return currentDeviceBind
+			if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
+				if oldBind.RevenueAddress != txSender {
+					log.Warn("Device unbind revenue", "revenue address", oldBind.RevenueAddress)
+					return currentDeviceBind, RejectNotRevenueOwner
+				}
 			} else {
-				if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
-					if oldBind.RevenueAddress != txSender {
-						log.Warn("Device unbind revenue", "revenue address", oldBind.RevenueAddress)
-						return currentDeviceBind
-					}
-				} else {
-					if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
-						log.Warn("Device unbind revenue failed to verify multi-signature")
-						return currentDeviceBind
-					}
+				if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
+					log.Warn("Device unbind revenue failed to verify multi-signature")
+					return currentDeviceBind, RejectMultiSignature
 				}
 			}
 		}
-		deviceBind.Type = uint32(revenueType)
-	} else {
-		log.Warn("Device unbind revenue", "type", txDataInfo[nfcPosRevenueType])
-		return currentDeviceBind
 	}
 	topics := make([]common.Hash, 3)
 	topics[0].UnmarshalText([]byte("0xf061654231b0035280bd8dd06084a38aa871445d0b7311be8cc2605c5672a6e3")) //web3.sha3("DeviceBind(uint32,byte32,byte32,address)")
@@ -1334,89 +1291,64 @@ func (a *Alien) processDeviceUnbind (currentDeviceBind []DeviceBindRecord, txDat
 	} else {
 		delete(snap.RevenueFlow, deviceBind.Device)
 	}
-	return currentDeviceBind
+	return currentDeviceBind, RejectNone
 }
 
-func (a *Alien) processDeviceRebind (currentDeviceBind []DeviceBindRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []DeviceBindRecord {
-	if len(txDataInfo) <= nfcPosRevenueAddress {
-		log.Warn("Device rebind revenue", "parameter number", len(txDataInfo))
-		return currentDeviceBind
+func (a *Alien) processDeviceRebind (currentDeviceBind []DeviceBindRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]DeviceBindRecord, CustomTxReject) {
+	var payload customtx.Rebind
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Device rebind revenue", "err", err)
+		return currentDeviceBind, RejectMalformed
 	}
 	nilHash := common.Address{}
 	zeroHash := common.BigToAddress(big.NewInt(0))
 	deviceBind := DeviceBindRecord {
-		Device: common.Address{},
-		Revenue: txSender,
-		Contract: common.Address{},
-		MultiSign: common.Address{},
-		Type: 0,
+		Device: payload.Device,
+		Revenue: payload.Revenue,
+		Contract: payload.Contract,
+		MultiSign: payload.MultiSign,
+		Type: payload.Type,
 		Bind: true,
 	}
-	if err := deviceBind.Device.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Device rebind revenue", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentDeviceBind
-	}
-	if err := deviceBind.Revenue.UnmarshalText1([]byte(txDataInfo[nfcPosRevenueAddress])); err != nil {
-		log.Warn("Device rebind revenue", "revenue address", txDataInfo[nfcPosMinerAddress])
-		return currentDeviceBind
-	}
-	if revenueType, err := strconv.ParseUint(txDataInfo[nfcPosRevenueType], 10, 32); err == nil {
-		if revenueType == 0 {
-			if oldBind, ok := snap.RevenueNormal[deviceBind.Device]; ok {
-				if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
-					if oldBind.RevenueAddress != txSender {
-						log.Warn("Device rebind revenue", "revenue address", oldBind.RevenueAddress)
-						return currentDeviceBind
-					}
-				} else {
-					if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
-						log.Warn("Device rebind revenue failed to verify multi-signature")
-						return currentDeviceBind
-					}
+	if deviceBind.Type == 0 {
+		if oldBind, ok := snap.RevenueNormal[deviceBind.Device]; ok {
+			if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
+				if oldBind.RevenueAddress != txSender {
+					log.Warn("Device rebind revenue", "revenue address", oldBind.RevenueAddress)
+					return currentDeviceBind, RejectNotRevenueOwner
 				}
-			} else if deviceBind.Revenue != txSender {
-				log.Warn("Device rebind revenue", "device cnnnot bind", deviceBind.Revenue)
-				return currentDeviceBind
-			}
-		} else {
-			if oldBind, ok := snap.RevenueFlow[deviceBind.Device]; ok {
-				if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
-					if oldBind.RevenueAddress != txSender {
-						log.Warn("Device rebind revenue", "revenue address", oldBind.RevenueAddress)
-						return currentDeviceBind
-					}
-				} else {
-					if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
-						log.Warn("Device rebind revenue failed to verify multi-signature")
-						return currentDeviceBind
-					}
+			} else {
+				if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
+					log.Warn("Device rebind revenue failed to verify multi-signature")
+					return currentDeviceBind, RejectMultiSignature
 				}
-			} else if deviceBind.Revenue != txSender {
-				log.Warn("Device rebind revenue", "device cnnnot bind", deviceBind.Revenue)
-				return currentDeviceBind
 			}
+		} else if deviceBind.Revenue != txSender {
+			log.Warn("Device rebind revenue", "device cnnnot bind", deviceBind.Revenue)
+			return currentDeviceBind, RejectNotRevenueOwner
 		}
-		deviceBind.Type = uint32(revenueType)
 	} else {
-		log.Warn("Device rebind revenue", "type", txDataInfo[nfcPosRevenueType])
-		return currentDeviceBind
-	}
-	if 0 < len(txDataInfo[nfcPosRevenueContract]) {
-		if err := deviceBind.Contract.UnmarshalText1([]byte(txDataInfo[nfcPosRevenueContract])); err != nil {
-			log.Warn("Device rebind revenue", "contract address", txDataInfo[nfcPosRevenueContract])
-			return currentDeviceBind
-		}
-	}
-	if 0 < len(txDataInfo[nfcPosMiltiSign]) {
-		if err := deviceBind.MultiSign.UnmarshalText1([]byte(txDataInfo[nfcPosMiltiSign])); err != nil {
-			log.Warn("Device rebind revenue", "milti-signature address", txDataInfo[nfcPosRevenueContract])
-			return currentDeviceBind
+		if oldBind, ok := snap.RevenueFlow[deviceBind.Device]; ok {
+			if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
+				if oldBind.RevenueAddress != txSender {
+					log.Warn("Device rebind revenue", "revenue address", oldBind.RevenueAddress)
+					return currentDeviceBind, RejectNotRevenueOwner
+				}
+			} else {
+				if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
+					log.Warn("Device rebind revenue failed to verify multi-signature")
+					return currentDeviceBind, RejectMultiSignature
+				}
+			}
+		} else if deviceBind.Revenue != txSender {
+			log.Warn("Device rebind revenue", "device cnnnot bind", deviceBind.Revenue)
+			return currentDeviceBind, RejectNotRevenueOwner
 		}
 	}
 
 	if err := a.checkRevenueNormalBind(deviceBind,snap); err != nil {
 		log.Warn("Device rebind revenue", "checkRevenueNormalBind", err.Error())
-		return currentDeviceBind
+		return currentDeviceBind, RejectRevenueInUse
 	}
 
 	topics := make([]common.Hash, 3)
@@ -1446,33 +1378,30 @@ func (a *Alien) processDeviceRebind (currentDeviceBind []DeviceBindRecord, txDat
 			MultiSignature: deviceBind.MultiSign,
 		}
 	}
-	return currentDeviceBind
+	return currentDeviceBind, RejectNone
 }
 
-func (a *Alien) processCandidatePledge (currentCandidatePledge []CandidatePledgeRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []CandidatePledgeRecord {
-	if len(txDataInfo) <= nfcPosMinerAddress {
-		log.Warn("Candidate pledge", "parameter number", len(txDataInfo))
-		return currentCandidatePledge
+func (a *Alien) processCandidatePledge (currentCandidatePledge []CandidatePledgeRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]CandidatePledgeRecord, CustomTxReject) {
+	var payload customtx.CandidatePledge
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Candidate pledge", "err", err)
+		return currentCandidatePledge, RejectMalformed
 	}
 	candidatePledge := CandidatePledgeRecord{
-		Target: common.Address{},
-		Amount: new(big.Int).Set(minCndPledgeBalance),
+		Target: payload.Target,
+		Amount: new(big.Int).Set(economics(a.config).MinCndPledgeBalance),
 	}
 	if deposit, ok := snap.SystemConfig.Deposit[0]; ok {
 		candidatePledge.Amount = new(big.Int).Set(deposit)
 	}
-	if err := candidatePledge.Target.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Candidate pledge", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentCandidatePledge
-	}
 	if state.GetBalance(txSender).Cmp(candidatePledge.Amount) < 0 {
 		log.Warn("Candidate pledge", "balance", state.GetBalance(txSender))
-		return currentCandidatePledge
+		return currentCandidatePledge, RejectInsufficientBalance
 	}
 	if pledgeItem, ok := snap.CandidatePledge[candidatePledge.Target]; ok {
 		if pledgeItem.StartHigh > 0 {
 			log.Warn("Candidate pledge", "candidate already exit", pledgeItem.StartHigh)
-			return currentCandidatePledge
+			return currentCandidatePledge, RejectExiting
 		}
 		pledgeItem.Amount = new(big.Int).Add(pledgeItem.Amount, candidatePledge.Amount)
 	} else {
@@ -1489,43 +1418,40 @@ func (a *Alien) processCandidatePledge (currentCandidatePledge []CandidatePledge
 	data.SetBytes(candidatePledge.Amount.Bytes())
 	a.addCustomerTxLog (tx, receipts, topics, data.Bytes())
 	currentCandidatePledge = append(currentCandidatePledge, candidatePledge)
-	return currentCandidatePledge
+	return currentCandidatePledge, RejectNone
 }
 
-func (a *Alien) processCandidateExit (currentCandidateExit []common.Address, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []common.Address {
-	if len(txDataInfo) <= nfcPosMinerAddress {
-		log.Warn("Candidate exit", "parameter number", len(txDataInfo))
-		return currentCandidateExit
+func (a *Alien) processCandidateExit (currentCandidateExit []common.Address, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]common.Address, CustomTxReject) {
+	var payload customtx.CandidateExit
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Candidate exit", "err", err)
+		return currentCandidateExit, RejectMalformed
 	}
-	minerAddress := common.Address{}
+	minerAddress := payload.Target
 	nilHash := common.Address{}
 	zeroHash := common.BigToAddress(big.NewInt(0))
-	if err := minerAddress.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Candidate exit", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentCandidateExit
-	}
 	if oldBind, ok := snap.RevenueNormal[minerAddress]; ok {
 		if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
 			if oldBind.RevenueAddress != txSender {
 				log.Warn("Candidate exit", "revenue address", oldBind.RevenueAddress)
-				return currentCandidateExit
+				return currentCandidateExit, RejectNotRevenueOwner
 			}
 		} else {
 			if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
 				log.Warn("Candidate exit failed to verify multi-signature")
-				return currentCandidateExit
+				return currentCandidateExit, RejectMultiSignature
 			}
 		}
 	}
 	if pledgeItem, ok := snap.CandidatePledge[minerAddress]; ok {
 		if pledgeItem.StartHigh > 0 {
 			log.Warn("Candidate exit", "candidate already exit", pledgeItem.StartHigh)
-			return currentCandidateExit
+			return currentCandidateExit, RejectExiting
 		}
 		pledgeItem.StartHigh = snap.Number + 1
 	} else {
 		log.Warn("Candidate exit", "candidate isnot exist", minerAddress)
-		return currentCandidateExit
+		return currentCandidateExit, RejectNotPledged
 	}
 	topics := make([]common.Hash, 3)
 	topics[0].UnmarshalText([]byte("0x9489b96ebcb056332b79de467a2645c56a999089b730c99fead37b20420d58e7")) //web3.sha3("PledgeExit(address)")
@@ -1534,29 +1460,26 @@ func (a *Alien) processCandidateExit (currentCandidateExit []common.Address, txD
 	topics[2].SetBytes(big.NewInt(sscEnumCndLock).Bytes())
 	a.addCustomerTxLog (tx, receipts, topics, nil)
 	currentCandidateExit = append(currentCandidateExit, minerAddress)
-	return currentCandidateExit
+	return currentCandidateExit, RejectNone
 }
 
-func (a *Alien) processCandidatePunish (currentCandidatePunish []CandidatePunishRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []CandidatePunishRecord {
-	if len(txDataInfo) <= nfcPosMinerAddress {
-		log.Warn("Candidate punish", "parameter number", len(txDataInfo))
-		return currentCandidatePunish
+func (a *Alien) processCandidatePunish (currentCandidatePunish []CandidatePunishRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]CandidatePunishRecord, CustomTxReject) {
+	var payload customtx.CandidatePunish
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Candidate punish", "err", err)
+		return currentCandidatePunish, RejectMalformed
 	}
 	candidatePunish := CandidatePunishRecord{
-		Target: common.Address{},
+		Target: payload.Target,
 		Amount: big.NewInt(0),
 		Credit: 0,
 	}
-	if err := candidatePunish.Target.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Candidate punish", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentCandidatePunish
-	}
 	if candidateCredit, ok := snap.Punished[candidatePunish.Target]; !ok {
 		log.Warn("Candidate punish", "not punish", candidatePunish.Target)
-		return currentCandidatePunish
+		return currentCandidatePunish, RejectNotPunished
 	} else {
 		candidatePunish.Credit = uint32(candidateCredit)
-	    deposit := new(big.Int).Set(minCndPledgeBalance)
+	    deposit := new(big.Int).Set(economics(a.config).MinCndPledgeBalance)
 		if _, ok := snap.SystemConfig.Deposit[0]; ok {
 			deposit = new(big.Int).Set(snap.SystemConfig.Deposit[0])
 		}
@@ -1564,15 +1487,15 @@ func (a *Alien) processCandidatePunish (currentCandidatePunish []CandidatePunish
 	}
 	if state.GetBalance(txSender).Cmp(candidatePunish.Amount) < 0 {
 		log.Warn("Candidate punish", "balance", state.GetBalance(txSender))
-		return currentCandidatePunish
+		return currentCandidatePunish, RejectInsufficientBalance
 	}
 	if pledgeItem, ok := snap.CandidatePledge[candidatePunish.Target]; !ok {
 		log.Warn("Candidate punish", "candidate isnot exist", candidatePunish.Target)
-		return currentCandidatePunish
+		return currentCandidatePunish, RejectNotPledged
 	} else {
 		if pledgeItem.StartHigh > 0 {
 			log.Warn("Candidate punish", "candidate already exit", pledgeItem.StartHigh)
-			return currentCandidatePunish
+			return currentCandidatePunish, RejectExiting
 		}
 		pledgeItem.Amount = new(big.Int).Add(pledgeItem.Amount, candidatePunish.Amount)
 	}
@@ -1589,39 +1512,119 @@ func (a *Alien) processCandidatePunish (currentCandidatePunish []CandidatePunish
 	data = append(data, dataList[1].Bytes()...)
 	a.addCustomerTxLog (tx, receipts, topics, data)
 	currentCandidatePunish = append(currentCandidatePunish, candidatePunish)
-	return currentCandidatePunish
+	return currentCandidatePunish, RejectNone
 }
 
-func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []ClaimedBandwidthRecord {
-	if len(txDataInfo) <= nfcPosBandwidth {
-		log.Warn("Claimed bandwidth", "parameter number", len(txDataInfo))
-		return currentClaimedBandwidth
+func (a *Alien) processDoubleSign (currentDoubleSign []DoubleSignRecord, chain consensus.ChainHeaderReader, txData []byte, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot) ([]DoubleSignRecord, CustomTxReject) {
+	if !isForkedNumber(a.config.IsDoubleSign, number) {
+		return currentDoubleSign, RejectNotActive
+	}
+	var payload customtx.DoubleSign
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Double sign", "err", err)
+		return currentDoubleSign, RejectMalformed
+	}
+	signer, err := a.verifyDoubleSign(chain, payload.Header1, payload.Header2, number)
+	if err != nil {
+		log.Warn("Double sign", "err", err)
+		return currentDoubleSign, RejectInvalidEvidence
+	}
+	height := payload.Header1.Number.Uint64()
+	if slashed, ok := snap.DoubleSigned[signer]; ok && slashed >= height {
+		log.Warn("Double sign", "already slashed", signer, "number", slashed)
+		return currentDoubleSign, RejectStaleEvidence
+	}
+	pledgeItem, ok := snap.CandidatePledge[signer]
+	if !ok {
+		log.Warn("Double sign", "candidate isnot exist", signer)
+		return currentDoubleSign, RejectNotPledged
+	}
+	doubleSign := DoubleSignRecord{
+		Target: signer,
+		Number: height,
+		Amount: new(big.Int).Set(pledgeItem.Amount),
+	}
+	snap.slashDoubleSign(signer, height)
+	topics := make([]common.Hash, 3)
+	topics[0].UnmarshalText([]byte("0x5aca8921f5ad910d1d9487fa15cfc9de4d761a93974d7106475dcac2e388f84d")) //web3.sha3("DoubleSignSlash(address,uint256,uint256)")
+	topics[1].SetBytes(signer.Bytes())
+	topics[2].SetBytes(big.NewInt(sscEnumCndLock).Bytes())
+	dataList := make([]common.Hash, 2)
+	dataList[0].SetBytes(new(big.Int).SetUint64(height).Bytes())
+	dataList[1].SetBytes(doubleSign.Amount.Bytes())
+	data := dataList[0].Bytes()
+	data = append(data, dataList[1].Bytes()...)
+	a.addCustomerTxLog (tx, receipts, topics, data)
+	currentDoubleSign = append(currentDoubleSign, doubleSign)
+	return currentDoubleSign, RejectNone
+}
+
+// processUnjail releases a signer jailed by updateJailed on request of its
+// revenue owner, once the jail period is over. It is active whenever the
+// economics jail signers.
+func (a *Alien) processUnjail (currentUnjail []common.Address, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64, snap *Snapshot) ([]common.Address, CustomTxReject) {
+	if !jailEnabled(a.config) {
+		return currentUnjail, RejectNotActive
+	}
+	var payload customtx.Unjail
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Candidate unjail", "err", err)
+		return currentUnjail, RejectMalformed
+	}
+	minerAddress := payload.Target
+	nilHash := common.Address{}
+	zeroHash := common.BigToAddress(big.NewInt(0))
+	if oldBind, ok := snap.RevenueNormal[minerAddress]; ok {
+		if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
+			if oldBind.RevenueAddress != txSender {
+				log.Warn("Candidate unjail", "revenue address", oldBind.RevenueAddress)
+				return currentUnjail, RejectNotRevenueOwner
+			}
+		} else {
+			if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
+				log.Warn("Candidate unjail failed to verify multi-signature")
+				return currentUnjail, RejectMultiSignature
+			}
+		}
+	} else if minerAddress != txSender {
+		log.Warn("Candidate unjail", "not miner", txSender)
+		return currentUnjail, RejectNotRevenueOwner
+	}
+	record := snap.jailRecord(minerAddress)
+	if record == nil {
+		log.Warn("Candidate unjail", "not jailed", minerAddress)
+		return currentUnjail, RejectNotJailed
+	}
+	if number < record.Release {
+		log.Warn("Candidate unjail", "jailed until", record.Release)
+		return currentUnjail, RejectJailPeriod
+	}
+	jailed := record.Number
+	snap.unjail(minerAddress, number)
+	topics := make([]common.Hash, 2)
+	topics[0].UnmarshalText([]byte("0x1a7519a09e91112bc4578da3c72c4d3840fb2a0c7c8009fd9d6e103cad75cfc8")) //web3.sha3("SignerUnjail(address,uint256)")
+	topics[1].SetBytes(minerAddress.Bytes())
+	data := common.BigToHash(new(big.Int).SetUint64(jailed)).Bytes()
+	a.addCustomerTxLog (tx, receipts, topics, data)
+	currentUnjail = append(currentUnjail, minerAddress)
+	return currentUnjail, RejectNone
+}
+
+func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]ClaimedBandwidthRecord, CustomTxReject) {
+	var payload customtx.MinerPledge
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Claimed bandwidth", "err", err)
+		return currentClaimedBandwidth, RejectMalformed
 	}
 	claimedBandwidth := ClaimedBandwidthRecord{
-		Target: common.Address{},
+		Target: payload.Target,
 		Amount: big.NewInt(0),
-		ISPQosID: 0,
-		Bandwidth: 0,
-	}
-	if err := claimedBandwidth.Target.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Claimed bandwidth", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentClaimedBandwidth
+		ISPQosID: payload.ISPQosID,
+		Bandwidth: payload.Bandwidth,
 	}
 	if pledge, ok := snap.FlowPledge[claimedBandwidth.Target]; ok && 0 < pledge.StartHigh {
 		log.Warn("Claimed bandwidth", "miner exiting", claimedBandwidth.Target)
-		return currentClaimedBandwidth
-	}
-	if ISPQosID, err := strconv.ParseUint(txDataInfo[nfcPosISPQosID], 16, 32); err != nil {
-		log.Warn("Claimed bandwidth", "ISP qos id", txDataInfo[nfcPosISPQosID])
-		return currentClaimedBandwidth
-	} else {
-		claimedBandwidth.ISPQosID = uint32(ISPQosID)
-	}
-	if bandwidth, err := strconv.ParseUint(txDataInfo[nfcPosBandwidth], 16, 32); err != nil {
-		log.Warn("Claimed bandwidth", "bandwidth", txDataInfo[nfcPosBandwidth])
-		return currentClaimedBandwidth
-	} else {
-		claimedBandwidth.Bandwidth = uint32(bandwidth)
+		return currentClaimedBandwidth, RejectExiting
 	}
 	total := big.NewInt(0)
 	for _, bandwidthItem := range snap.Bandwidth {
@@ -1631,7 +1634,7 @@ func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRe
 	if oldBandwidth, ok := snap.Bandwidth[claimedBandwidth.Target]; ok {
 		if claimedBandwidth.Bandwidth < oldBandwidth.BandwidthClaimed {
 			log.Warn("Claimed bandwidth", "bandwidth reduce", oldBandwidth.BandwidthClaimed)
-			return currentClaimedBandwidth
+			return currentClaimedBandwidth, RejectBandwidthReduced
 		}
 		bandwidth -= oldBandwidth.BandwidthClaimed
 	}
@@ -1654,7 +1657,7 @@ func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRe
 	}
 	if state.GetBalance(txSender).Cmp(claimedBandwidth.Amount) < 0 {
 		log.Warn("Claimed bandwidth", "balance", state.GetBalance(txSender))
-		return currentClaimedBandwidth
+		return currentClaimedBandwidth, RejectInsufficientBalance
 	}
 	if pledgeItem, ok := snap.FlowPledge[claimedBandwidth.Target]; !ok {
 		pledgeItem := NewPledgeItem(claimedBandwidth.Amount)
@@ -1662,7 +1665,7 @@ func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRe
 	} else {
 		if pledgeItem.StartHigh > 0 {
 			log.Warn("Claimed bandwidth", "miner already exit", pledgeItem.StartHigh)
-			return currentClaimedBandwidth
+			return currentClaimedBandwidth, RejectExiting
 		}
 		pledgeItem.Amount = new(big.Int).Add(pledgeItem.Amount, claimedBandwidth.Amount)
 	}
@@ -1700,43 +1703,40 @@ func (a *Alien) processMinerPledge (currentClaimedBandwidth []ClaimedBandwidthRe
 	data = append(data, dataList[1].Bytes()...)
 	a.addCustomerTxLog (tx, receipts, topics, data)
 	currentClaimedBandwidth = append(currentClaimedBandwidth, claimedBandwidth)
-	return currentClaimedBandwidth
+	return currentClaimedBandwidth, RejectNone
 }
 
-func (a *Alien) processMinerExit (currentFlowMinerExit []common.Address, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) []common.Address {
-	if len(txDataInfo) <= nfcPosMinerAddress {
-		log.Warn("Flow miner exit", "parameter number", len(txDataInfo))
-		return currentFlowMinerExit
+func (a *Alien) processMinerExit (currentFlowMinerExit []common.Address, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, snap *Snapshot) ([]common.Address, CustomTxReject) {
+	var payload customtx.MinerExit
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Flow miner exit", "err", err)
+		return currentFlowMinerExit, RejectMalformed
 	}
-	minerAddress := common.Address{}
+	minerAddress := payload.Target
 	nilHash := common.Address{}
 	zeroHash := common.BigToAddress(big.NewInt(0))
-	if err := minerAddress.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Flow miner exit", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentFlowMinerExit
-	}
 	if oldBind, ok := snap.RevenueFlow[minerAddress]; ok {
 		if oldBind.MultiSignature == nilHash || oldBind.MultiSignature == zeroHash {
 			if oldBind.RevenueAddress != txSender {
 				log.Warn("Flow miner exit", "revenue address", oldBind.RevenueAddress)
-				return currentFlowMinerExit
+				return currentFlowMinerExit, RejectNotRevenueOwner
 			}
 		} else {
 			if !a.verifyMultiSignatureAddress(state, oldBind.MultiSignature, tx.AllSigners()) {
 				log.Warn("Flow miner exit failed to verify multi-signature")
-				return currentFlowMinerExit
+				return currentFlowMinerExit, RejectMultiSignature
 			}
 		}
 	}
 	if pledgeItem, ok := snap.FlowPledge[minerAddress]; ok {
 		if pledgeItem.StartHigh > 0 {
 			log.Warn("Flow miner exit", "miner already exit", pledgeItem.StartHigh)
-			return currentFlowMinerExit
+			return currentFlowMinerExit, RejectExiting
 		}
 		pledgeItem.StartHigh = snap.Number + 1
 	} else {
 		log.Warn("Flow miner exit", "miner isnot exist", minerAddress)
-		return currentFlowMinerExit
+		return currentFlowMinerExit, RejectNotPledged
 	}
 	delete(snap.Bandwidth, minerAddress)
 	topics := make([]common.Hash, 3)
@@ -1746,35 +1746,26 @@ func (a *Alien) processMinerExit (currentFlowMinerExit []common.Address, txDataI
 	topics[2].SetBytes(big.NewInt(sscEnumFlwLock).Bytes())
 	a.addCustomerTxLog (tx, receipts, topics, nil)
 	currentFlowMinerExit = append(currentFlowMinerExit, minerAddress)
-	return currentFlowMinerExit
+	return currentFlowMinerExit, RejectNone
 }
 
-func (a *Alien) processBandwidthPunish (currentBandwidthPunish []BandwidthPunishRecord, txDataInfo []string, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snap *Snapshot) []BandwidthPunishRecord {
-	if len(txDataInfo) <= sscPosWdthPnsh {
-		log.Warn("Bandwidth punish", "parameter number", len(txDataInfo))
-		return currentBandwidthPunish
+func (a *Alien) processBandwidthPunish (currentBandwidthPunish []BandwidthPunishRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snap *Snapshot) ([]BandwidthPunishRecord, CustomTxReject) {
+	var payload customtx.WdthPnsh
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Bandwidth punish", "err", err)
+		return currentBandwidthPunish, RejectMalformed
 	}
 	if snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh].String() != txSender.String() {
 		log.Warn("Bandwidth punish", "manager address", txSender)
-		return currentBandwidthPunish
+		return currentBandwidthPunish, RejectNotManager
 	}
 	bandwidthPunish := BandwidthPunishRecord{
-		Target: common.Address{},
-		WdthPnsh: 0,
-	}
-	if err := bandwidthPunish.Target.UnmarshalText1([]byte(txDataInfo[nfcPosMinerAddress])); err != nil {
-		log.Warn("Bandwidth punish", "miner address", txDataInfo[nfcPosMinerAddress])
-		return currentBandwidthPunish
+		Target: payload.Target,
+		WdthPnsh: payload.Bandwidth,
 	}
 	if _, ok := snap.Bandwidth[bandwidthPunish.Target]; !ok {
 		log.Warn("Bandwidth punish", "miner hasnot claimed bandwidth", bandwidthPunish.Target)
-		return currentBandwidthPunish
-	}
-	if bandwidth, err := strconv.ParseUint(txDataInfo[sscPosWdthPnsh], 16, 32); err != nil {
-		log.Warn("Bandwidth punish", "bandwidth", txDataInfo[sscPosWdthPnsh])
-		return currentBandwidthPunish
-	} else {
-		bandwidthPunish.WdthPnsh = uint32(bandwidth)
+		return currentBandwidthPunish, RejectBandwidthNotClaimed
 	}
 	topics := make([]common.Hash, 3)
 	topics[0].UnmarshalText([]byte("0x041e56787332f2495a47171278fa0f1ddb21961f702d0ba53c2bb2c079ccd418")) //web3.sha3("ClaimedBandwidth(address,uint32,uint32)")
@@ -1789,301 +1780,181 @@ func (a *Alien) processBandwidthPunish (currentBandwidthPunish []BandwidthPunish
 	a.addCustomerTxLog (tx, receipts, topics, data)
 	snap.Bandwidth[bandwidthPunish.Target].BandwidthClaimed = bandwidthPunish.WdthPnsh
 	currentBandwidthPunish = append(currentBandwidthPunish, bandwidthPunish)
-	return currentBandwidthPunish
+	return currentBandwidthPunish, RejectNone
 }
 
-func (a *Alien) processExchRate (txDataInfo []string, txSender common.Address, snap *Snapshot) uint32 {
-	if len(txDataInfo) <= sscPosExchRate {
-		log.Warn("Config exchrate", "parameter number", len(txDataInfo))
-		return 0
+func (a *Alien) processExchRate (txData []byte, txSender common.Address, snap *Snapshot) (uint32, CustomTxReject) {
+	var payload customtx.ExchRate
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config exchrate", "err", err)
+		return 0, RejectMalformed
 	}
-	if exchRate, err := strconv.ParseUint(txDataInfo[sscPosExchRate], 10, 32); err != nil {
-		log.Warn("Config exchrate", "exchrate", txDataInfo[sscPosExchRate])
-		return 0
-	} else {
-		if snap.SystemConfig.ManagerAddress[sscEnumExchRate].String() != txSender.String() {
-			log.Warn("Config exchrate", "manager address", txSender)
-			return 0
-		}
-		return uint32(exchRate)
+	if snap.SystemConfig.ManagerAddress[sscEnumExchRate].String() != txSender.String() {
+		log.Warn("Config exchrate", "manager address", txSender)
+		return 0, RejectNotManager
 	}
+	return payload.Rate, RejectNone
 }
 
-func (a *Alien) processCandidateDeposit (currentDeposit []ConfigDepositRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []ConfigDepositRecord {
-	if len(txDataInfo) <= sscPosDepositWho {
-		log.Warn("Config candidate deposit", "parameter number", len(txDataInfo))
-		return currentDeposit
+func (a *Alien) processCandidateDeposit (currentDeposit []ConfigDepositRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]ConfigDepositRecord, CustomTxReject) {
+	var payload customtx.Deposit
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config candidate deposit", "err", err)
+		return currentDeposit, RejectMalformed
 	}
 	deposit := ConfigDepositRecord{
-		Who: 0,
-		Amount: big.NewInt(0),
-	}
-	var err error
-	if deposit.Amount, err = hexutil.UnmarshalText1([]byte(txDataInfo[sscPosDeposit])); err != nil {
-		log.Warn("Config candidate deposit", "deposit", txDataInfo[sscPosDeposit])
-		return currentDeposit
-	}
-	if id, err := strconv.ParseUint(txDataInfo[sscPosDepositWho], 10, 32); err != nil {
-		log.Warn("Config manager", "id", txDataInfo[sscPosDepositWho])
-		return currentDeposit
-	} else {
-		deposit.Who = uint32(id)
+		Who: payload.Who,
+		Amount: payload.Amount,
 	}
 	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
 		log.Warn("Config candidate deposit", "manager address", txSender)
-		return currentDeposit
+		return currentDeposit, RejectNotManager
 	}
 	currentDeposit = append(currentDeposit, deposit)
-	return currentDeposit
+	return currentDeposit, RejectNone
 }
 
-func (a *Alien) processCndLockConfig (currentLockParameters []LockParameterRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []LockParameterRecord {
-	if len(txDataInfo) <= sscPosInterval {
-		log.Warn("Config candidate lock", "parameter number", len(txDataInfo))
-		return currentLockParameters
-	}
+func (a *Alien) processLockConfig (currentLockParameters []LockParameterRecord, who uint32, lock customtx.LockParameters, txSender common.Address, snap *Snapshot) ([]LockParameterRecord, CustomTxReject) {
 	lockParameter := LockParameterRecord{
-		Who: sscEnumCndLock,
-		LockPeriod: uint32(180 * 24 * 60 * 60 / a.config.Period),
-		RlsPeriod: 0,
-		Interval: 0,
-	}
-	if lockPeriod, err := strconv.ParseUint(txDataInfo[sscPosLockPeriod], 16, 32); err != nil {
-		log.Warn("Config candidate lock", "lock period", txDataInfo[sscPosLockPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.LockPeriod = uint32(lockPeriod)
-	}
-	if releasePeriod, err := strconv.ParseUint(txDataInfo[sscPosRlsPeriod], 16, 32); err != nil {
-		log.Warn("Config candidate lock", "release period", txDataInfo[sscPosRlsPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.RlsPeriod = uint32(releasePeriod)
-	}
-	if interval, err := strconv.ParseUint(txDataInfo[sscPosInterval], 16, 32); err != nil {
-		log.Warn("Config candidate lock", "release interval", txDataInfo[sscPosInterval])
-		return currentLockParameters
-	} else {
-		lockParameter.Interval = uint32(interval)
+		Who: who,
+		LockPeriod: lock.LockPeriod,
+		RlsPeriod: lock.RlsPeriod,
+		Interval: lock.Interval,
 	}
 	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
-		log.Warn("Config candidate lock", "manager address", txSender)
-		return currentLockParameters
+		log.Warn("Config lock", "who", who, "manager address", txSender)
+		return currentLockParameters, RejectNotManager
 	}
 	currentLockParameters = append(currentLockParameters, lockParameter)
-	return currentLockParameters
+	return currentLockParameters, RejectNone
 }
 
-func (a *Alien) processFlwLockConfig (currentLockParameters []LockParameterRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []LockParameterRecord {
-	if len(txDataInfo) <= sscPosInterval {
-		log.Warn("Config miner lock", "parameter number", len(txDataInfo))
-		return currentLockParameters
-	}
-	lockParameter := LockParameterRecord{
-		Who: sscEnumFlwLock,
-		LockPeriod: uint32(180 * 24 * 60 * 60 / a.config.Period),
-		RlsPeriod: 0,
-		Interval: 0,
-	}
-	if lockPeriod, err := strconv.ParseUint(txDataInfo[sscPosLockPeriod], 16, 32); err != nil {
-		log.Warn("Config miner lock", "lock period", txDataInfo[sscPosLockPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.LockPeriod = uint32(lockPeriod)
-	}
-	if releasePeriod, err := strconv.ParseUint(txDataInfo[sscPosRlsPeriod], 16, 32); err != nil {
-		log.Warn("Config miner lock", "release period", txDataInfo[sscPosRlsPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.RlsPeriod = uint32(releasePeriod)
-	}
-	if interval, err := strconv.ParseUint(txDataInfo[sscPosInterval], 16, 32); err != nil {
-		log.Warn("Config miner lock", "release interval", txDataInfo[sscPosInterval])
-		return currentLockParameters
-	} else {
-		lockParameter.Interval = uint32(interval)
-	}
-	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
-		log.Warn("Config miner lock", "manager address", txSender)
-		return currentLockParameters
+func (a *Alien) processCndLockConfig (currentLockParameters []LockParameterRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]LockParameterRecord, CustomTxReject) {
+	var payload customtx.CndLock
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config candidate lock", "err", err)
+		return currentLockParameters, RejectMalformed
 	}
-	currentLockParameters = append(currentLockParameters, lockParameter)
-	return currentLockParameters
+	return a.processLockConfig(currentLockParameters, sscEnumCndLock, payload.LockParameters, txSender, snap)
 }
 
-func (a *Alien) processRwdLockConfig (currentLockParameters []LockParameterRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []LockParameterRecord {
-	if len(txDataInfo) <= sscPosInterval {
-		log.Warn("Config reward lock", "parameter number", len(txDataInfo))
-		return currentLockParameters
-	}
-	lockParameter := LockParameterRecord{
-		Who: sscEnumRwdLock,
-		LockPeriod: uint32(180 * 24 * 60 * 60 / a.config.Period),
-		RlsPeriod: 0,
-		Interval: 0,
-	}
-	if lockPeriod, err := strconv.ParseUint(txDataInfo[sscPosLockPeriod], 16, 32); err != nil {
-		log.Warn("Config reward lock", "lock period", txDataInfo[sscPosLockPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.LockPeriod = uint32(lockPeriod)
-	}
-	if releasePeriod, err := strconv.ParseUint(txDataInfo[sscPosRlsPeriod], 16, 32); err != nil {
-		log.Warn("Config reward lock", "release period", txDataInfo[sscPosRlsPeriod])
-		return currentLockParameters
-	} else {
-		lockParameter.RlsPeriod = uint32(releasePeriod)
+func (a *Alien) processFlwLockConfig (currentLockParameters []LockParameterRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]LockParameterRecord, CustomTxReject) {
+	var payload customtx.FlwLock
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config miner lock", "err", err)
+		return currentLockParameters, RejectMalformed
 	}
-	if interval, err := strconv.ParseUint(txDataInfo[sscPosInterval], 16, 32); err != nil {
-		log.Warn("Config reward lock", "release interval", txDataInfo[sscPosInterval])
-		return currentLockParameters
-	} else {
-		lockParameter.Interval = uint32(interval)
-	}
-	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
-		log.Warn("Config reward lock", "manager address", txSender)
-		return currentLockParameters
+	return a.processLockConfig(currentLockParameters, sscEnumFlwLock, payload.LockParameters, txSender, snap)
+}
+
+func (a *Alien) processRwdLockConfig (currentLockParameters []LockParameterRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]LockParameterRecord, CustomTxReject) {
+	var payload customtx.RwdLock
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config reward lock", "err", err)
+		return currentLockParameters, RejectMalformed
 	}
-	currentLockParameters = append(currentLockParameters, lockParameter)
-	return currentLockParameters
+	return a.processLockConfig(currentLockParameters, sscEnumRwdLock, payload.LockParameters, txSender, snap)
 }
 
-func (a *Alien) processOffLine (txDataInfo []string, txSender common.Address, snap *Snapshot) uint32 {
-	if len(txDataInfo) <= sscPosOffLine {
-		log.Warn("Config offLine", "parameter number", len(txDataInfo))
-		return 0
+func (a *Alien) processOffLine (txData []byte, txSender common.Address, snap *Snapshot) (uint32, CustomTxReject) {
+	var payload customtx.OffLine
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config offline", "err", err)
+		return 0, RejectMalformed
 	}
-	if offline, err := strconv.ParseUint(txDataInfo[sscPosOffLine], 10, 32); err != nil {
-		log.Warn("Config offline", "offline", txDataInfo[sscPosOffLine])
-		return 0
-	} else {
-		if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
-			log.Warn("Config offLine", "manager address", txSender)
-			return 0
-		}
-		return uint32(offline)
+	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
+		log.Warn("Config offLine", "manager address", txSender)
+		return 0, RejectNotManager
 	}
+	return payload.Penalty, RejectNone
 }
 
-func (a *Alien) processISPQos (currentISPQOS []ISPQOSRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []ISPQOSRecord {
-	if len(txDataInfo) <= sscPosQosValue {
-		log.Warn("Config isp qos", "parameter number", len(txDataInfo))
-		return currentISPQOS
+func (a *Alien) processISPQos (currentISPQOS []ISPQOSRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]ISPQOSRecord, CustomTxReject) {
+	var payload customtx.ISPQos
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config isp qos", "err", err)
+		return currentISPQOS, RejectMalformed
 	}
 	ISPQOS := ISPQOSRecord{
-		ISPID: 0,
-		QOS: 0,
-	}
-	if id, err := strconv.ParseUint(txDataInfo[sscPosQosID], 10, 32); err != nil {
-		log.Warn("Config isp qos", "isp id", txDataInfo[sscPosQosID])
-		return currentISPQOS
-	} else {
-		ISPQOS.ISPID = uint32(id)
-	}
-	if qos, err := strconv.ParseUint(txDataInfo[sscPosQosValue], 10, 32); err != nil {
-		log.Warn("Config isp qos", "qos", txDataInfo[sscPosQosValue])
-		return currentISPQOS
-	} else {
-		ISPQOS.QOS = uint32(qos)
+		ISPID: payload.ISPID,
+		QOS: payload.QOS,
 	}
 	if snap.SystemConfig.ManagerAddress[sscEnumSystem].String() != txSender.String() {
 		log.Warn("Config isp qos", "manager address", txSender)
-		return currentISPQOS
+		return currentISPQOS, RejectNotManager
 	}
 	currentISPQOS = append(currentISPQOS, ISPQOS)
-	return currentISPQOS
+	return currentISPQOS, RejectNone
 }
 
-func (a *Alien) processManagerAddress (currentManagerAddress []ManagerAddressRecord, txDataInfo []string, txSender common.Address, snap *Snapshot) []ManagerAddressRecord {
-	if len(txDataInfo) <= sscPosManagerAddress {
-		log.Warn("Config manager", "parameter number", len(txDataInfo))
-		return currentManagerAddress
+func (a *Alien) processManagerAddress (currentManagerAddress []ManagerAddressRecord, txData []byte, txSender common.Address, snap *Snapshot) ([]ManagerAddressRecord, CustomTxReject) {
+	var payload customtx.Manager
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Config manager", "err", err)
+		return currentManagerAddress, RejectMalformed
 	}
-	if txSender.String() != managerAddressManager.String() {
+	if txSender.String() != genesisManagerAddress(a.config, managerAddressManager).String() {
 		log.Warn("Config manager", "manager", txSender)
-		return currentManagerAddress
+		return currentManagerAddress, RejectNotManager
 	}
 	managerAddress := ManagerAddressRecord{
-		Target: common.Address{},
-		Who: 0,
-	}
-	if id, err := strconv.ParseUint(txDataInfo[sscPosManagerID], 10, 32); err != nil {
-		log.Warn("Config manager", "id", txDataInfo[sscPosManagerID])
-		return currentManagerAddress
-	} else {
-		managerAddress.Who = uint32(id)
-	}
-	if err := managerAddress.Target.UnmarshalText1([]byte(txDataInfo[sscPosManagerAddress])); err != nil {
-		log.Warn("Config manager", "address", txDataInfo[sscPosManagerAddress])
-		return currentManagerAddress
+		Target: payload.Target,
+		Who: payload.Who,
 	}
 	snap.SystemConfig.ManagerAddress[managerAddress.Who] = managerAddress.Target
 	currentManagerAddress = append(currentManagerAddress, managerAddress)
-	return currentManagerAddress
-}
-
-func (a *Alien) processFlowReport1(flowReport []MinerFlowReportRecord, txDataInfo []string, txSender common.Address, snap *Snapshot, number uint64) ([]MinerFlowReportRecord, bool) {
-	if len(txDataInfo) <= posEventFlowValue {
-		log.Warn("Flow report", "parameter number", len(txDataInfo))
-		return flowReport, false
-	}
-	ok := false
-	var report MinerFlowReportRecord
-	if err := rlp.DecodeBytes(common.FromHex(txDataInfo[posEventFlowValue]), &report); err == nil {
-		if isGeFulTrieNumber(number){
-            if snap.CheckFulEnough(flowReport,report) {
-				flowReport = append(flowReport, report)
-				ok = true
-			}else{
-				log.Warn("processFlowReport1 ", "err", "CheckFulEnough fail")
-			}
-		}else{
-			if snap.isSideChainCoinbase (report.ChainHash, txSender, true) {
-				flowReport = append(flowReport, report)
-				ok = true
-			}
-		}
-	} else {
-		log.Warn("processFlowReport1", "err", err)
-	}
-	return flowReport, ok
+	return currentManagerAddress, RejectNone
 }
 
-func (a *Alien) processFlowReport2(flowReport []MinerFlowReportRecord, txDataInfo []string, number uint64, snap *Snapshot) []MinerFlowReportRecord {
-	if len(txDataInfo) <= posEventFlowValue {
-		log.Warn("Flow report", "parameter number", len(txDataInfo))
-		return flowReport
-	}
-	buffer := common.Hex2Bytes(txDataInfo[posEventFlowValue])
-	reportTime := new(big.Int).SetBytes(buffer[:8]).Uint64()
-	census := MinerFlowReportRecord{
-		ChainHash: common.Hash{},
+func newMinerFlowReportRecord(chainHash common.Hash, reportTime uint64, items []customtx.FlowReportItem) MinerFlowReportRecord {
+	report := MinerFlowReportRecord{
+		ChainHash: chainHash,
 		ReportTime: reportTime,
 		ReportContent: []MinerFlowReportItem{},
 	}
-	post := 8
-	for post + 40 <= len(buffer) {
-		address := common.Address{}
-		address.SetBytes(buffer[post:post+20])
-		post += 20
-		census.ReportContent = append(census.ReportContent, MinerFlowReportItem{
-			Target: address,
-			FlowValue1: new(big.Int).SetBytes(buffer[post:post+8]).Uint64(),
-			FlowValue2: new(big.Int).SetBytes(buffer[post+8:post+16]).Uint64(),
-			ReportNumber: uint32(new(big.Int).SetBytes(buffer[post+16:post+20]).Uint64()),
-		})
-		post += 20
+	for _, item := range items {
+		report.ReportContent = append(report.ReportContent, MinerFlowReportItem(item))
 	}
-	if isGeFulTrieNumber(number){
-		if snap.CheckFulEnough(flowReport,census) {
-			flowReport = append(flowReport, census)
-		}else{
+	return report
+}
+
+func (a *Alien) processFlowReport1(flowReport []MinerFlowReportRecord, txData []byte, txSender common.Address, snap *Snapshot, number uint64) ([]MinerFlowReportRecord, CustomTxReject) {
+	var payload customtx.FlowReport
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("processFlowReport1", "err", err)
+		return flowReport, RejectMalformed
+	}
+	report := newMinerFlowReportRecord(payload.Report.ChainHash, payload.Report.ReportTime, payload.Report.ReportContent)
+	if isGeFulTrieNumber(a.config, number){
+		if !snap.CheckFulEnough(flowReport,report) {
+			log.Warn("processFlowReport1 ", "err", "CheckFulEnough fail")
+			return flowReport, RejectFulNotEnough
+		}
+	}else{
+		if !snap.isSideChainCoinbase (report.ChainHash, txSender, true) {
+			return flowReport, RejectNotSideChainCoinbase
+		}
+	}
+	return append(flowReport, report), RejectNone
+}
+
+func (a *Alien) processFlowReport2(flowReport []MinerFlowReportRecord, txData []byte, number uint64, snap *Snapshot) ([]MinerFlowReportRecord, CustomTxReject) {
+	var payload customtx.FlowReportM
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("processFlowReport2", "err", err)
+		return flowReport, RejectMalformed
+	}
+	census := newMinerFlowReportRecord(common.Hash{}, payload.ReportTime, payload.Items)
+	if isGeFulTrieNumber(a.config, number){
+		if !snap.CheckFulEnough(flowReport,census) {
 			log.Warn("processFlowReport2 ", "err", "CheckFulEnough fail")
+			return flowReport, RejectFulNotEnough
 		}
+		flowReport = append(flowReport, census)
 	}else{
 		flowReport = append(flowReport, census)
 	}
-	return flowReport
+	return flowReport, RejectNone
 }
 
 
@@ -0,0 +1,152 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/log"
+)
+
+// ErrCustomTxRefused is returned by the transaction pool for custom
+// transactions the engine would ignore on top of the current head.
+var ErrCustomTxRefused = errors.New("custom transaction refused")
+
+// TxPoolValidator refuses the custom transactions which are malformed or
+// certainly ignored by processCustomTx, before they take a slot in the pool.
+type TxPoolValidator struct {
+	alien *Alien
+	chain consensus.ChainHeaderReader
+
+	head *types.Header // Head the pool was last reset to
+	snap *Snapshot     // Snapshot of head, nil if it couldn't be retrieved
+	lock sync.RWMutex
+}
+
+// NewTxPoolValidator creates the validator of the transaction pool checking
+// custom transactions against the snapshot of the head the pool is reset to.
+func (a *Alien) NewTxPoolValidator(chain consensus.ChainHeaderReader) *TxPoolValidator {
+	return &TxPoolValidator{alien: a, chain: chain}
+}
+
+// Reset implements core.TxValidator, retrieving the snapshot of the new head
+// once instead of on every validation.
+func (v *TxPoolValidator) Reset(head *types.Header) {
+	if v.alien.config.SideChain {
+		return
+	}
+	snap, err := v.alien.snapshot(v.chain, head.Number.Uint64(), head.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		log.Debug("Custom txs not checked against head", "number", head.Number, "hash", head.Hash(), "err", err)
+		snap = nil
+	}
+	v.lock.Lock()
+	v.head, v.snap = head, snap
+	v.lock.Unlock()
+}
+
+// ValidateTx implements core.TxValidator.
+func (v *TxPoolValidator) ValidateTx(tx *types.Transaction, from common.Address) error {
+	kind := customtx.Identify(tx.Data())
+	if kind == customtx.KindUnknown || v.alien.config.SideChain {
+		return nil
+	}
+	if err := customtx.New(kind).Decode(tx.Data()); err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrCustomTxRefused, RejectMalformed, err)
+	}
+	v.lock.RLock()
+	head, snap := v.head, v.snap
+	v.lock.RUnlock()
+	if snap == nil {
+		return nil
+	}
+	if reject := v.alien.checkPoolCustomTx(kind, from, head.Number.Uint64()+1, snap); reject != RejectNone {
+		return fmt.Errorf("%w: %v", ErrCustomTxRefused, reject)
+	}
+	return nil
+}
+
+// checkPoolCustomTx returns the reason why a well formed custom transaction
+// included in the given block on top of snap would be ignored. A manager or a
+// pledge changed by an earlier transaction of the same block isn't considered,
+// such transactions have to be sent again once the change is sealed.
+func (a *Alien) checkPoolCustomTx(kind customtx.Kind, txSender common.Address, number uint64, snap *Snapshot) CustomTxReject {
+	manager := func(who uint32) CustomTxReject {
+		if snap.SystemConfig.ManagerAddress[who] != txSender {
+			return RejectNotManager
+		}
+		return RejectNone
+	}
+	switch kind {
+	case customtx.KindFlowReportM, customtx.KindSetCoinbase, customtx.KindDelCoinbase:
+		return manager(sscEnumFlowReport)
+	case customtx.KindDoubleSign:
+		if !isForkedNumber(a.config.IsDoubleSign, number) {
+			return RejectNotActive
+		}
+	case customtx.KindUnjail:
+		if !jailEnabled(a.config) {
+			return RejectNotActive
+		}
+	case customtx.KindMultiSignPropose, customtx.KindMultiSignApprove,
+		customtx.KindMultiSignAdd, customtx.KindMultiSignRemove, customtx.KindMultiSignRequire:
+		if !isForkedNumber(a.config.IsMultiSig, number) {
+			return RejectNotActive
+		}
+	case customtx.KindFulTransfer, customtx.KindFulApprove, customtx.KindFulTransferFrom:
+		if !isForkedNumber(a.config.IsFulTransfer, number) {
+			return RejectNotActive
+		}
+		if kind == customtx.KindFulTransfer && snap.Ful != nil && snap.Ful.Get(txSender).Sign() == 0 {
+			return RejectFulNotEnough
+		}
+	case customtx.KindUnvote, customtx.KindRedelegate:
+		if !isForkedNumber(a.config.IsUnvote, number) {
+			return RejectNotActive
+		}
+		if _, ok := snap.Votes[txSender]; !ok {
+			return RejectNotVoted
+		}
+		if snap.inVoteCooldown(txSender, number) {
+			return RejectVoteCooldown
+		}
+	case customtx.KindFlowReportEn:
+		if !isGeFulTrieNumber(a.config, number) {
+			return RejectNotActive
+		}
+		if _, ok := snap.FlowPledge[txSender]; !ok {
+			return RejectNotPledged
+		}
+	case customtx.KindExchRate:
+		return manager(sscEnumExchRate)
+	case customtx.KindDeposit, customtx.KindCndLock, customtx.KindFlwLock, customtx.KindRwdLock, customtx.KindOffLine, customtx.KindISPQos:
+		return manager(sscEnumSystem)
+	case customtx.KindWdthPnsh:
+		return manager(sscEnumWdthPnsh)
+	case customtx.KindManager:
+		if txSender != genesisManagerAddress(a.config, managerAddressManager) {
+			return RejectNotManager
+		}
+	}
+	return RejectNone
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestAlien_checkPoolCustomTx(t *testing.T) {
+	system := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	flow := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	miner := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	snap := &Snapshot{
+		FlowPledge: map[common.Address]*PledgeItem{miner: {}},
+		SystemConfig: SystemParameter{
+			ManagerAddress: map[uint32]common.Address{
+				sscEnumSystem:     system,
+				sscEnumFlowReport: flow,
+			},
+		},
+	}
+	number := params.AlienFulTrieBlock.Uint64()
+	tests := []struct {
+		kind   customtx.Kind
+		sender common.Address
+		number uint64
+		reject CustomTxReject
+	}{
+		{customtx.KindVote, miner, number, RejectNone},
+		{customtx.KindDeposit, system, number, RejectNone},
+		{customtx.KindCndLock, flow, number, RejectNotManager},
+		{customtx.KindExchRate, system, number, RejectNotManager},
+		{customtx.KindFlowReportM, flow, number, RejectNone},
+		{customtx.KindSetCoinbase, system, number, RejectNotManager},
+		{customtx.KindManager, system, number, RejectNotManager},
+		{customtx.KindManager, managerAddressManager, number, RejectNone},
+		{customtx.KindFlowReportEn, miner, number, RejectNone},
+		{customtx.KindFlowReportEn, flow, number, RejectNotPledged},
+		{customtx.KindFlowReportEn, miner, number - 1, RejectNotActive},
+		{customtx.KindUnvote, miner, number, RejectNotVoted},
+		{customtx.KindRedelegate, miner, number - 1, RejectNotActive},
+		{customtx.KindMultiSignPropose, miner, number, RejectNone},
+		{customtx.KindMultiSignApprove, miner, number - 1, RejectNotActive},
+		{customtx.KindMultiSignRequire, miner, number - 1, RejectNotActive},
+		{customtx.KindFulApprove, miner, number, RejectNone},
+		{customtx.KindFulTransfer, miner, number - 1, RejectNotActive},
+		{customtx.KindDoubleSign, miner, number, RejectNone},
+		{customtx.KindUnjail, miner, number - 1, RejectNotActive},
+	}
+	alien := &Alien{config: &params.AlienConfig{DoubleSignBlock: new(big.Int).SetUint64(number), UnvoteBlock: new(big.Int).SetUint64(number), MultiSigBlock: new(big.Int).SetUint64(number), FulTransferBlock: new(big.Int).SetUint64(number)}}
+	for i, tt := range tests {
+		if reject := alien.checkPoolCustomTx(tt.kind, tt.sender, tt.number, snap); reject != tt.reject {
+			t.Errorf("test %d: %v from %x: have %v, want %v", i, tt.kind, tt.sender, reject, tt.reject)
+		}
+	}
+}
+
+func TestTxPoolValidator(t *testing.T) {
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int)}, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	chain, head := newDoubleSignTestChain(alien)
+	validator := alien.NewTxPoolValidator(chain)
+
+	sender := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	data := (&customtx.Manager{Who: sscEnumSystem, Target: sender}).Encode()
+	tx := types.NewTransaction(0, sender, new(big.Int), 100000, new(big.Int), data)
+
+	// Before the first reset there is no snapshot to check against
+	if err := validator.ValidateTx(tx, sender); err != nil {
+		t.Fatalf("tx refused without snapshot: %v", err)
+	}
+	validator.Reset(chain[head])
+
+	// The snapshot of the head is retrieved on reset only
+	alien.recents.Remove(head)
+	if err := validator.ValidateTx(tx, sender); !errors.Is(err, ErrCustomTxRefused) {
+		t.Errorf("tx of a non manager: have %v, want %v", err, ErrCustomTxRefused)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/state"
+	"github.com/seaskycheng/sdvn/core/types"
+)
+
+// CustomTxReject is the reason why the engine ignored a custom transaction.
+// The transaction itself is still included in the block as a plain transfer.
+type CustomTxReject uint16
+
+const (
+	RejectNone CustomTxReject = iota
+	RejectMalformed
+	RejectNotActive
+	RejectNotCandidate
+	RejectNotSigner
+	RejectNotManager
+	RejectNotRevenueOwner
+	RejectMultiSignature
+	RejectInsufficientBalance
+	RejectValueTooLow
+	RejectInvalidThreshold
+	RejectInvalidOwners
+	RejectCodeSizeExceeded
+	RejectAddressInUse
+	RejectAlreadyBound
+	RejectNotBound
+	RejectRevenueInUse
+	RejectNotPledged
+	RejectExiting
+	RejectNotPunished
+	RejectBandwidthReduced
+	RejectBandwidthNotClaimed
+	RejectSideChainNotExist
+	RejectMissingTarget
+	RejectConfirmOutOfRange
+	RejectUnknownBlock
+	RejectNotSideChainCoinbase
+	RejectFulNotEnough
+	RejectNoValidRecord
+	RejectInvalidEvidence
+	RejectStaleEvidence
+	RejectNotJailed
+	RejectJailPeriod
+	RejectNotVoted
+	RejectVoteCooldown
+	RejectSameCandidate
+	RejectNotMultiSignature
+	RejectNotOwner
+	RejectTooManyProposals
+	RejectInvalidAction
+	RejectUnknownProposal
+	RejectAlreadyApproved
+	RejectAllowanceExceeded
+
+	rejectCount
+)
+
+var customTxRejectNames = [rejectCount]string{
+	RejectNone:                 "none",
+	RejectMalformed:            "malformed",
+	RejectNotActive:            "not_active",
+	RejectNotCandidate:         "not_candidate",
+	RejectNotSigner:            "not_signer",
+	RejectNotManager:           "not_manager",
+	RejectNotRevenueOwner:      "not_revenue_owner",
+	RejectMultiSignature:       "multi_signature_unverified",
+	RejectInsufficientBalance:  "insufficient_balance",
+	RejectValueTooLow:          "value_too_low",
+	RejectInvalidThreshold:     "invalid_threshold",
+	RejectInvalidOwners:        "invalid_owners",
+	RejectCodeSizeExceeded:     "code_size_exceeded",
+	RejectAddressInUse:         "address_in_use",
+	RejectAlreadyBound:         "already_bound",
+	RejectNotBound:             "not_bound",
+	RejectRevenueInUse:         "revenue_in_use",
+	RejectNotPledged:           "not_pledged",
+	RejectExiting:              "exiting",
+	RejectNotPunished:          "not_punished",
+	RejectBandwidthReduced:     "bandwidth_reduced",
+	RejectBandwidthNotClaimed:  "bandwidth_not_claimed",
+	RejectSideChainNotExist:    "side_chain_not_exist",
+	RejectMissingTarget:        "missing_target",
+	RejectConfirmOutOfRange:    "confirm_out_of_range",
+	RejectUnknownBlock:         "unknown_block",
+	RejectNotSideChainCoinbase: "not_side_chain_coinbase",
+	RejectFulNotEnough:         "ful_not_enough",
+	RejectNoValidRecord:        "no_valid_record",
+	RejectInvalidEvidence:      "invalid_evidence",
+	RejectStaleEvidence:        "stale_evidence",
+	RejectNotJailed:            "not_jailed",
+	RejectJailPeriod:           "jail_period",
+	RejectNotVoted:             "not_voted",
+	RejectVoteCooldown:         "vote_cooldown",
+	RejectSameCandidate:        "same_candidate",
+	RejectNotMultiSignature:    "not_multi_signature",
+	RejectNotOwner:             "not_owner",
+	RejectTooManyProposals:     "too_many_proposals",
+	RejectInvalidAction:        "invalid_action",
+	RejectUnknownProposal:      "unknown_proposal",
+	RejectAlreadyApproved:      "already_approved",
+	RejectAllowanceExceeded:    "allowance_exceeded",
+}
+
+// customTxRejectTopic is topic[0] of the log added to the receipt of a
+// rejected custom transaction, topic[1] holds the reason code.
+var customTxRejectTopic = common.HexToHash("0xb126a763e47f69af58fe26be0269d13ab8efc369a3be7460aefd2c60847382b7") //web3.sha3("CustomTxRejected(uint256)")
+
+var errNotCustomTx = errors.New("not a custom transaction")
+
+func (r CustomTxReject) String() string {
+	if r < rejectCount {
+		return customTxRejectNames[r]
+	}
+	return fmt.Sprintf("unknown_%d", uint16(r))
+}
+
+// MarshalText implements encoding.TextMarshaler, the reason is returned by
+// its name over RPC.
+func (r CustomTxReject) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// CustomTxExplanation is the decision of the engine on a custom transaction.
+type CustomTxExplanation struct {
+	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Kind        string         `json:"kind"`
+	Accepted    bool           `json:"accepted"`
+	Code        uint16         `json:"code"`
+	Reason      CustomTxReject `json:"reason"`
+}
+
+// rejectCustomTx records why a custom transaction was ignored. The rejection
+// log changes the receipts, so it is only added from the RejectLogBlock on.
+func (a *Alien) rejectCustomTx(tx *types.Transaction, receipts []*types.Receipt, number *big.Int, reject CustomTxReject, rejected func(*types.Transaction, CustomTxReject)) {
+	if rejected != nil {
+		rejected(tx, reject)
+	}
+	if !a.config.IsRejectLog(number) {
+		return
+	}
+	topics := make([]common.Hash, 2)
+	topics[0] = customTxRejectTopic
+	topics[1].SetBytes(big.NewInt(int64(reject)).Bytes())
+	a.addCustomerTxLog(tx, receipts, topics, []byte(reject.String()))
+}
+
+// CustomTxRejectFromLog returns the reason code carried by a rejection log.
+func CustomTxRejectFromLog(log *types.Log) (CustomTxReject, bool) {
+	if len(log.Topics) != 2 || log.Topics[0] != customTxRejectTopic {
+		return RejectNone, false
+	}
+	return CustomTxReject(log.Topics[1].Big().Uint64()), true
+}
+
+// ExplainCustomTx runs the custom transactions of a block again and returns
+// the decision on the transaction with the given hash. The state must be the
+// one of the block after executing all its transactions, and the receipts the
+// ones of that execution; both are modified.
+func (a *Alien) ExplainCustomTx(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt, hash common.Hash) (*CustomTxExplanation, error) {
+	if a.config.SideChain {
+		return nil, errors.New("custom transactions are not processed on side chain")
+	}
+	var target *types.Transaction
+	for _, tx := range txs {
+		if tx.Hash() == hash {
+			target = tx
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("transaction %#x not in block %d", hash, header.Number.Uint64())
+	}
+	kind := customtx.Identify(target.Data())
+	if kind == customtx.KindUnknown {
+		return nil, errNotCustomTx
+	}
+	reject := RejectNone
+	_, _, err := a.applyCustomTx(HeaderExtra{}, chain, header, state, txs, receipts, func(tx *types.Transaction, r CustomTxReject) {
+		if tx.Hash() == hash {
+			reject = r
+		}
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomTxExplanation{
+		TxHash:      hash,
+		BlockNumber: header.Number.Uint64(),
+		Kind:        kind.String(),
+		Accepted:    reject == RejectNone,
+		Code:        uint16(reject),
+		Reason:      reject,
+	}, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestCustomTxRejectNames(t *testing.T) {
+	seen := make(map[string]CustomTxReject)
+	for r := RejectNone; r < rejectCount; r++ {
+		name := r.String()
+		if name == "" {
+			t.Errorf("reason %d has no name", r)
+		}
+		if prev, ok := seen[name]; ok {
+			t.Errorf("reasons %d and %d share name %q", prev, r, name)
+		}
+		seen[name] = r
+	}
+	if name := rejectCount.String(); name != "unknown_43" {
+		t.Errorf("unexpected name of unknown reason: %q", name)
+	}
+}
+
+func TestRejectCustomTxLog(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	alien := &Alien{config: &params.AlienConfig{RejectLogBlock: big.NewInt(10)}}
+
+	var reported CustomTxReject
+	rejected := func(_ *types.Transaction, r CustomTxReject) { reported = r }
+
+	receipts := []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}}
+	alien.rejectCustomTx(tx, receipts, big.NewInt(9), RejectNotManager, rejected)
+	if reported != RejectNotManager {
+		t.Errorf("reason not reported, have %v", reported)
+	}
+	if len(receipts[0].Logs) != 0 {
+		t.Fatalf("rejection logged before fork block")
+	}
+	alien.rejectCustomTx(tx, receipts, big.NewInt(10), RejectAlreadyBound, rejected)
+	if len(receipts[0].Logs) != 1 {
+		t.Fatalf("rejection not logged after fork block")
+	}
+	if r, ok := CustomTxRejectFromLog(receipts[0].Logs[0]); !ok || r != RejectAlreadyBound {
+		t.Errorf("decoded reason %v (%v), want %v", r, ok, RejectAlreadyBound)
+	}
+	if _, ok := CustomTxRejectFromLog(&types.Log{Topics: []common.Hash{customTxRejectTopic}}); ok {
+		t.Errorf("log without reason decoded")
+	}
+}
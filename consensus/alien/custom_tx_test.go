@@ -4,7 +4,6 @@ import (
 	"github.com/seaskycheng/sdvn/common"
 	"github.com/seaskycheng/sdvn/core/state"
 	"github.com/seaskycheng/sdvn/core/types"
-	"strings"
 	"testing"
 )
 func TestAlien_checkRevenueNormalBind(t *testing.T) {
@@ -51,7 +50,7 @@ func TestAlien_processDeviceBind(t *testing.T) {
 	dev:="bec92229b1bd96919c8ffc993171fa6504121dc6"
 	devAddr:=common.HexToAddress(dev)
 	txData := "NFC:1:Bind:"+dev+":1:0000000000000000000000000000000000000000:0000000000000000000000000000000000000000"
-	txDataInfo := strings.Split(txData, ":")
+	txDataInfo := []byte(txData)
 	txSender:= common.HexToAddress("NXa63b29EBe0A141B87A87e39dE17F17346e11e1b7")
 	tx:=&types.Transaction{}
 	receipts:=make([]*types.Receipt,0)
@@ -61,7 +60,7 @@ func TestAlien_processDeviceBind(t *testing.T) {
 	}
 	alien:=&Alien{
 	}
-	currentDeviceBind=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
+	currentDeviceBind,_=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
 	for index := range currentDeviceBind {
 		if txSender==currentDeviceBind[index].Revenue&&devAddr==currentDeviceBind[index].Device{
 			t.Logf("1 pass,Revenue=%s,Device=%s" ,currentDeviceBind[index].Revenue.String(),currentDeviceBind[index].Device.String())
@@ -70,9 +69,9 @@ func TestAlien_processDeviceBind(t *testing.T) {
 		}
 	}
 	txData= "NFC:1:Bind:"+dev+":0:0000000000000000000000000000000000000000:0000000000000000000000000000000000000000"
-	txDataInfo= strings.Split(txData, ":")
+	txDataInfo= []byte(txData)
 	currentDeviceBind=make([]DeviceBindRecord,0)
-	currentDeviceBind=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
+	currentDeviceBind,_=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
 	for index := range currentDeviceBind {
 		if txSender==currentDeviceBind[index].Revenue&&devAddr==currentDeviceBind[index].Device{
 			t.Logf("2 pass,Revenue=%s,Device=%s" ,currentDeviceBind[index].Revenue.String(),currentDeviceBind[index].Device.String())
@@ -81,9 +80,9 @@ func TestAlien_processDeviceBind(t *testing.T) {
 		}
 	}
 	txData= "NFC:1:Bind:"+dev+":0:0000000000000000000000000000000000000000:0000000000000000000000000000000000000000"
-	txDataInfo= strings.Split(txData, ":")
+	txDataInfo= []byte(txData)
 	currentDeviceBind=make([]DeviceBindRecord,0)
-	currentDeviceBind=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
+	currentDeviceBind,_=alien.processDeviceBind(currentDeviceBind,txDataInfo,txSender,tx,receipts,snap)
 	if len(currentDeviceBind)==0{
 		t.Logf("3 pass" )
 	}else{
@@ -92,7 +91,7 @@ func TestAlien_processDeviceBind(t *testing.T) {
 
 	state := &state.StateDB{
 	}
-	currentDeviceBind=alien.processDeviceRebind(currentDeviceBind,txDataInfo,txSender,tx,receipts,state,snap)
+	currentDeviceBind,_=alien.processDeviceRebind(currentDeviceBind,txDataInfo,txSender,tx,receipts,state,snap)
 	if len(currentDeviceBind)==0{
 		t.Logf("4 pass" )
 	}else{
@@ -102,8 +101,8 @@ func TestAlien_processDeviceBind(t *testing.T) {
 	newrev:="0Ff6e773Ff893fF39ed9352160889df13BDfc896"
 	newrevAddr:=common.HexToAddress(newrev)
 	txData= "NFC:1:Rebind:"+dev+":0:0000000000000000000000000000000000000000:0000000000000000000000000000000000000000:"+newrev
-	txDataInfo= strings.Split(txData, ":")
-	currentDeviceBind=alien.processDeviceRebind(currentDeviceBind,txDataInfo,txSender,tx,receipts,state,snap)
+	txDataInfo= []byte(txData)
+	currentDeviceBind,_=alien.processDeviceRebind(currentDeviceBind,txDataInfo,txSender,tx,receipts,state,snap)
 	for index := range currentDeviceBind {
 		if newrevAddr==currentDeviceBind[index].Revenue&&devAddr==currentDeviceBind[index].Device{
 			t.Logf("5 pass,Revenue=%s,Device=%s" ,currentDeviceBind[index].Revenue.String(),currentDeviceBind[index].Device.String())
@@ -0,0 +1,410 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package customtx implements the payload format of the custom transactions
+// understood by the alien consensus engine.
+//
+// A custom transaction is a normal transaction whose data is a colon separated
+// string such as "NFC:1:CandReq:0x...". The first three fields are the prefix
+// (ufo, NFC or SSC), the version and the category; ufo payloads carry the event
+// name in the fourth field. Every category has its own type with a canonical
+// Encode and a Decode which accepts exactly what the engine accepts on chain.
+package customtx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/common/hexutil"
+)
+
+const (
+	Version = "1"
+
+	PrefixUFO = "ufo"
+	PrefixNFC = "NFC"
+	PrefixSSC = "SSC"
+
+	CategoryEvent = "event"
+	CategoryLog   = "oplog"
+	CategorySC    = "sc"
+
+	separator = ":"
+
+	posPrefix   = 0
+	posVersion  = 1
+	posCategory = 2
+	posEvent    = 3
+)
+
+var (
+	// ErrUnknownPayload is returned if the data is not a known custom transaction.
+	ErrUnknownPayload = errors.New("unknown custom transaction payload")
+
+	// ErrKindMismatch is returned if a payload is decoded into the type of
+	// another category.
+	ErrKindMismatch = errors.New("custom transaction kind mismatch")
+
+	// ErrMissingField is returned if a payload has fewer fields than its
+	// category requires.
+	ErrMissingField = errors.New("missing field")
+
+	// ErrInvalidField is returned if a field of a payload can't be parsed.
+	ErrInvalidField = errors.New("invalid field")
+)
+
+// Kind identifies the category of a custom transaction payload.
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+
+	KindVote        // ufo:1:event:vote
+	KindConfirm     // ufo:1:event:confirm
+	KindProposal    // ufo:1:event:proposal
+	KindDeclare     // ufo:1:event:declare
+	KindSCConfirm   // ufo:1:sc:confirm
+	KindSetCoinbase // ufo:1:sc:setcb
+	KindDelCoinbase // ufo:1:sc:delcb
+	KindFlowReport  // ufo:1:sc:flwrpt
+	KindFlowReportM // ufo:1:sc:flwrptm
+
+	KindExchange        // NFC:1:Exch
+	KindMultiSign       // NFC:1:Multi
+	KindBind            // NFC:1:Bind
+	KindUnbind          // NFC:1:Unbind
+	KindRebind          // NFC:1:Rebind
+	KindCandidatePledge // NFC:1:CandReq
+	KindCandidateExit   // NFC:1:CandExit
+	KindCandidatePunish // NFC:1:CandPnsh
+	KindMinerPledge     // NFC:1:FlwReq
+	KindMinerExit       // NFC:1:FlwExit
+	KindFlowReportEn    // NFC:1:flwrpten
+
+	KindExchRate // SSC:1:ExchRate
+	KindDeposit  // SSC:1:Deposit
+	KindCndLock  // SSC:1:CndLock
+	KindFlwLock  // SSC:1:FlwLock
+	KindRwdLock  // SSC:1:RwdLock
+	KindOffLine  // SSC:1:OffLine
+	KindISPQos   // SSC:1:QOS
+	KindWdthPnsh // SSC:1:WdthPnsh
+	KindManager  // SSC:1:Manager
+
+	KindDoubleSign // NFC:1:DblSign
+	KindUnjail     // NFC:1:Unjail
+	KindUnvote     // ufo:1:event:unvote
+	KindRedelegate // ufo:1:event:redelegate
+
+	KindMultiSignPropose // NFC:1:MultiProp
+	KindMultiSignApprove // NFC:1:MultiAppr
+	KindMultiSignAdd     // NFC:1:MultiAdd
+	KindMultiSignRemove  // NFC:1:MultiDel
+	KindMultiSignRequire // NFC:1:MultiThr
+	KindFulTransfer      // NFC:1:FulTrans
+	KindFulApprove       // NFC:1:FulAppr
+	KindFulTransferFrom  // NFC:1:FulFrom
+
+	kindCount
+)
+
+type kindInfo struct {
+	prefix   string
+	category string
+	event    string
+}
+
+var kindInfos = [kindCount]kindInfo{
+	KindVote:        {PrefixUFO, CategoryEvent, "vote"},
+	KindConfirm:     {PrefixUFO, CategoryEvent, "confirm"},
+	KindProposal:    {PrefixUFO, CategoryEvent, "proposal"},
+	KindDeclare:     {PrefixUFO, CategoryEvent, "declare"},
+	KindSCConfirm:   {PrefixUFO, CategorySC, "confirm"},
+	KindSetCoinbase: {PrefixUFO, CategorySC, "setcb"},
+	KindDelCoinbase: {PrefixUFO, CategorySC, "delcb"},
+	KindFlowReport:  {PrefixUFO, CategorySC, "flwrpt"},
+	KindFlowReportM: {PrefixUFO, CategorySC, "flwrptm"},
+
+	KindExchange:        {PrefixNFC, "Exch", ""},
+	KindMultiSign:       {PrefixNFC, "Multi", ""},
+	KindBind:            {PrefixNFC, "Bind", ""},
+	KindUnbind:          {PrefixNFC, "Unbind", ""},
+	KindRebind:          {PrefixNFC, "Rebind", ""},
+	KindCandidatePledge: {PrefixNFC, "CandReq", ""},
+	KindCandidateExit:   {PrefixNFC, "CandExit", ""},
+	KindCandidatePunish: {PrefixNFC, "CandPnsh", ""},
+	KindMinerPledge:     {PrefixNFC, "FlwReq", ""},
+	KindMinerExit:       {PrefixNFC, "FlwExit", ""},
+	KindFlowReportEn:    {PrefixNFC, "flwrpten", ""},
+
+	KindExchRate: {PrefixSSC, "ExchRate", ""},
+	KindDeposit:  {PrefixSSC, "Deposit", ""},
+	KindCndLock:  {PrefixSSC, "CndLock", ""},
+	KindFlwLock:  {PrefixSSC, "FlwLock", ""},
+	KindRwdLock:  {PrefixSSC, "RwdLock", ""},
+	KindOffLine:  {PrefixSSC, "OffLine", ""},
+	KindISPQos:   {PrefixSSC, "QOS", ""},
+	KindWdthPnsh: {PrefixSSC, "WdthPnsh", ""},
+	KindManager:  {PrefixSSC, "Manager", ""},
+
+	KindDoubleSign: {PrefixNFC, "DblSign", ""},
+	KindUnjail:     {PrefixNFC, "Unjail", ""},
+	KindUnvote:     {PrefixUFO, CategoryEvent, "unvote"},
+	KindRedelegate: {PrefixUFO, CategoryEvent, "redelegate"},
+
+	KindMultiSignPropose: {PrefixNFC, "MultiProp", ""},
+	KindMultiSignApprove: {PrefixNFC, "MultiAppr", ""},
+	KindMultiSignAdd:     {PrefixNFC, "MultiAdd", ""},
+	KindMultiSignRemove:  {PrefixNFC, "MultiDel", ""},
+	KindMultiSignRequire: {PrefixNFC, "MultiThr", ""},
+	KindFulTransfer:      {PrefixNFC, "FulTrans", ""},
+	KindFulApprove:       {PrefixNFC, "FulAppr", ""},
+	KindFulTransferFrom:  {PrefixNFC, "FulFrom", ""},
+}
+
+// String returns the header of the payloads of this kind, e.g. "NFC:1:Bind".
+func (k Kind) String() string {
+	if k == KindUnknown || k >= kindCount {
+		return "unknown"
+	}
+	info := kindInfos[k]
+	header := info.prefix + separator + Version + separator + info.category
+	if info.event != "" {
+		header += separator + info.event
+	}
+	return header
+}
+
+// Payload is implemented by the typed payload of every custom transaction kind.
+type Payload interface {
+	Kind() Kind
+	Encode() []byte
+	Decode(data []byte) error
+}
+
+// Identify returns the kind of the custom transaction carried in data without
+// checking the fields behind the header. KindUnknown is returned for normal
+// transactions and for payloads the engine ignores.
+func Identify(data []byte) Kind {
+	if len(data) < len(PrefixUFO) {
+		return KindUnknown
+	}
+	return identify(strings.Split(string(data), separator))
+}
+
+func identify(fields []string) Kind {
+	if len(fields) < posEvent || fields[posVersion] != Version {
+		return KindUnknown
+	}
+	for kind := KindUnknown + 1; kind < kindCount; kind++ {
+		info := kindInfos[kind]
+		if fields[posPrefix] != info.prefix || fields[posCategory] != info.category {
+			continue
+		}
+		if info.event == "" {
+			return kind
+		}
+		if len(fields) > posEvent && fields[posEvent] == info.event {
+			return kind
+		}
+	}
+	return KindUnknown
+}
+
+// New returns an empty payload of the given kind, or nil for KindUnknown.
+func New(kind Kind) Payload {
+	switch kind {
+	case KindVote:
+		return new(Vote)
+	case KindConfirm:
+		return new(Confirm)
+	case KindProposal:
+		return new(Proposal)
+	case KindDeclare:
+		return new(Declare)
+	case KindSCConfirm:
+		return new(SCConfirm)
+	case KindSetCoinbase:
+		return new(SetCoinbase)
+	case KindDelCoinbase:
+		return new(DelCoinbase)
+	case KindFlowReport:
+		return new(FlowReport)
+	case KindFlowReportM:
+		return new(FlowReportM)
+	case KindExchange:
+		return new(Exchange)
+	case KindMultiSign:
+		return new(MultiSign)
+	case KindBind:
+		return new(Bind)
+	case KindUnbind:
+		return new(Unbind)
+	case KindRebind:
+		return new(Rebind)
+	case KindCandidatePledge:
+		return new(CandidatePledge)
+	case KindCandidateExit:
+		return new(CandidateExit)
+	case KindCandidatePunish:
+		return new(CandidatePunish)
+	case KindMinerPledge:
+		return new(MinerPledge)
+	case KindMinerExit:
+		return new(MinerExit)
+	case KindFlowReportEn:
+		return new(FlowReportEn)
+	case KindExchRate:
+		return new(ExchRate)
+	case KindDeposit:
+		return new(Deposit)
+	case KindCndLock:
+		return new(CndLock)
+	case KindFlwLock:
+		return new(FlwLock)
+	case KindRwdLock:
+		return new(RwdLock)
+	case KindOffLine:
+		return new(OffLine)
+	case KindISPQos:
+		return new(ISPQos)
+	case KindWdthPnsh:
+		return new(WdthPnsh)
+	case KindManager:
+		return new(Manager)
+	case KindDoubleSign:
+		return new(DoubleSign)
+	case KindUnjail:
+		return new(Unjail)
+	case KindUnvote:
+		return new(Unvote)
+	case KindRedelegate:
+		return new(Redelegate)
+	case KindMultiSignPropose:
+		return new(MultiSignPropose)
+	case KindMultiSignApprove:
+		return new(MultiSignApprove)
+	case KindMultiSignAdd:
+		return new(MultiSignAdd)
+	case KindMultiSignRemove:
+		return new(MultiSignRemove)
+	case KindMultiSignRequire:
+		return new(MultiSignRequire)
+	case KindFulTransfer:
+		return new(FulTransfer)
+	case KindFulApprove:
+		return new(FulApprove)
+	case KindFulTransferFrom:
+		return new(FulTransferFrom)
+	}
+	return nil
+}
+
+// Decode identifies and decodes the custom transaction carried in data.
+func Decode(data []byte) (Payload, error) {
+	p := New(Identify(data))
+	if p == nil {
+		return nil, ErrUnknownPayload
+	}
+	if err := p.Decode(data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// split breaks data into its fields and checks it is a payload of the given
+// kind with at least n fields. Fields beyond the ones a kind defines are
+// ignored, as they always have been by the engine.
+func split(data []byte, kind Kind, n int) ([]string, error) {
+	fields := strings.Split(string(data), separator)
+	if len(data) < len(PrefixUFO) || identify(fields) != kind {
+		return nil, fmt.Errorf("%w: want %v", ErrKindMismatch, kind)
+	}
+	if len(fields) < n {
+		return nil, fmt.Errorf("%v: %w, have %d fields, want %d", kind, ErrMissingField, len(fields), n)
+	}
+	return fields, nil
+}
+
+func join(kind Kind, fields ...string) []byte {
+	return []byte(strings.Join(append([]string{kind.String()}, fields...), separator))
+}
+
+func invalidField(kind Kind, name string, value string, err error) error {
+	return fmt.Errorf("%v: %w %s %q: %v", kind, ErrInvalidField, name, value, err)
+}
+
+func parseAddress(kind Kind, name string, value string) (common.Address, error) {
+	var address common.Address
+	if err := address.UnmarshalText1([]byte(value)); err != nil {
+		return common.Address{}, invalidField(kind, name, value, err)
+	}
+	return address, nil
+}
+
+// parseOptionalAddress is parseAddress which maps an empty field to the zero address.
+func parseOptionalAddress(kind Kind, name string, value string) (common.Address, error) {
+	if len(value) == 0 {
+		return common.Address{}, nil
+	}
+	return parseAddress(kind, name, value)
+}
+
+// parseHash parses a 0x prefixed hex field of exactly 32 bytes.
+func parseHash(kind Kind, name string, value string) (common.Hash, error) {
+	enc, err := hexutil.Decode(value)
+	if err == nil && len(enc) != common.HashLength {
+		err = fmt.Errorf("have %d bytes, want %d", len(enc), common.HashLength)
+	}
+	if err != nil {
+		return common.Hash{}, invalidField(kind, name, value, err)
+	}
+	return common.BytesToHash(enc), nil
+}
+
+func parseUint32(kind Kind, name string, value string, base int) (uint32, error) {
+	n, err := strconv.ParseUint(value, base, 32)
+	if err != nil {
+		return 0, invalidField(kind, name, value, err)
+	}
+	return uint32(n), nil
+}
+
+func parseAmount(kind Kind, name string, value string) (*big.Int, error) {
+	amount, err := hexutil.UnmarshalText1([]byte(value))
+	if err != nil {
+		return nil, invalidField(kind, name, value, err)
+	}
+	return amount, nil
+}
+
+func formatOptionalAddress(address common.Address) string {
+	if address == (common.Address{}) {
+		return ""
+	}
+	return address.Hex()
+}
+
+func formatAmount(amount *big.Int) string {
+	if amount == nil {
+		return "0x0"
+	}
+	return "0x" + amount.Text(16)
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package customtx
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	testAddress1 = common.HexToAddress("0xbec92229b1bd96919c8ffc993171fa6504121dc6")
+	testAddress2 = common.HexToAddress("0xa63b29ebe0a141b87a87e39de17f17346e11e1b7")
+	testHash     = common.HexToHash("0x4e6a6b6e1c2ad0f4a8f5d7d2b1f3e0e6a8b9c1d2e3f405162738495a6b7c8d9e")
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		data string
+		kind Kind
+	}{
+		{"", KindUnknown},
+		{"ufo", KindUnknown},
+		{"ufo:1", KindUnknown},
+		{"ufo:1:event", KindUnknown},
+		{"ufo:1:event:vote", KindVote},
+		{"ufo:1:event:vote:extra", KindVote},
+		{"ufo:2:event:vote", KindUnknown},
+		{"ufo:1:event:unknown", KindUnknown},
+		{"ufo:1:event:unvote", KindUnvote},
+		{"ufo:1:event:redelegate", KindRedelegate},
+		{"ufo:1:sc:confirm", KindSCConfirm},
+		{"ufo:1:sc:flwrptm:00", KindFlowReportM},
+		{"NFC:1:Bind", KindBind},
+		{"NFC:1:flwrpten:0:", KindFlowReportEn},
+		{"nfc:1:Bind", KindUnknown},
+		{"SSC:1:QOS:1:2", KindISPQos},
+		{"SSC:1:Unknown:1", KindUnknown},
+	}
+	for _, tt := range tests {
+		if kind := Identify([]byte(tt.data)); kind != tt.kind {
+			t.Errorf("Identify(%q) = %v, want %v", tt.data, kind, tt.kind)
+		}
+	}
+}
+
+func TestKindString(t *testing.T) {
+	for kind := KindUnknown + 1; kind < kindCount; kind++ {
+		if got := Identify([]byte(kind.String())); got != kind {
+			t.Errorf("header %q identified as %v, want %v", kind.String(), got, kind)
+		}
+		if p := New(kind); p == nil || p.Kind() != kind {
+			t.Errorf("New(%v) returned wrong payload %v", kind, p)
+		}
+	}
+	if KindUnknown.String() != "unknown" {
+		t.Errorf("unexpected name of unknown kind: %q", KindUnknown.String())
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	sig := bytes.Repeat([]byte{0x11}, 65)
+	payloads := []Payload{
+		&Vote{},
+		&Confirm{Number: big.NewInt(123)},
+		&Proposal{ProposalType: intPtr(proposalTypeRentSideChain), TargetAddress: &testAddress1, SCRentFee: intPtr(MinSCRentFee), SCRentRate: intPtr(3), SCRentLength: intPtr(MinSCRentLength)},
+		&Proposal{ProposalType: intPtr(3), ValidationLoopCnt: intPtr(10), MinerRewardPerThousand: intPtr(600), SCHash: &testHash},
+		&Declare{ProposalHash: testHash, Decision: true},
+		&Declare{ProposalHash: testHash, Decision: false},
+		&SCConfirm{SCHash: testHash, Number: big.NewInt(100), Time: big.NewInt(1600000000), LoopInfo: "a#b", ChargingInfo: "c"},
+		&SetCoinbase{SCHash: testHash},
+		&DelCoinbase{SCHash: testHash},
+		&FlowReport{Report: FlowReportRecord{ChainHash: testHash, ReportTime: 7, ReportContent: []FlowReportItem{{Target: testAddress1, ReportNumber: 1, FlowValue1: 2, FlowValue2: 3}}}},
+		&FlowReportM{ReportTime: 9, Items: []FlowReportItem{{Target: testAddress2, ReportNumber: 4, FlowValue1: 5, FlowValue2: 6}}},
+		&Exchange{Target: testAddress1, Amount: big.NewInt(1000)},
+		&MultiSign{Threshold: 2, Owners: []common.Address{testAddress1, testAddress2, testAddress1}},
+		&Bind{Device: testAddress1, Type: 1, Contract: testAddress2},
+		&Unbind{Device: testAddress1, Type: 0},
+		&Rebind{Device: testAddress1, Type: 0, MultiSign: testAddress2, Revenue: testAddress2},
+		&CandidatePledge{Target: testAddress1},
+		&CandidateExit{Target: testAddress1},
+		&CandidatePunish{Target: testAddress1},
+		&MinerPledge{Target: testAddress1, ISPQosID: 0x1f, Bandwidth: 0x64},
+		&MinerExit{Target: testAddress1},
+		&FlowReportEn{Reserved: "0", Records: []FlowRecord{{ReportNumber: decimal.New(5, 0), DeviceID: decimal.New(6, 0), FlowValue: decimal.New(7, 0), Signature: sig}}},
+		&ExchRate{Rate: 10000},
+		&Deposit{Amount: big.NewInt(1e18), Who: 0},
+		&CndLock{LockParameters{LockPeriod: 1, RlsPeriod: 2, Interval: 3}},
+		&FlwLock{LockParameters{LockPeriod: 4, RlsPeriod: 5, Interval: 6}},
+		&RwdLock{LockParameters{LockPeriod: 7, RlsPeriod: 8, Interval: 9}},
+		&OffLine{Penalty: 30},
+		&ISPQos{ISPID: 1, QOS: 80},
+		&WdthPnsh{Target: testAddress1, Bandwidth: 0x20},
+		&Manager{Who: 3, Target: testAddress2},
+		&DoubleSign{
+			Header1: &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(1), Time: 30, Coinbase: testAddress1, Extra: sig, Initial: new(big.Int)},
+			Header2: &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(1), Time: 30, Coinbase: testAddress1, Extra: sig, Initial: new(big.Int), GasLimit: 1},
+		},
+		&Unjail{Target: testAddress1},
+		&Unvote{},
+		&Redelegate{},
+		&MultiSignPropose{MultiSign: testAddress1, To: testAddress2, Amount: big.NewInt(1000)},
+		&MultiSignPropose{MultiSign: testAddress1, To: testAddress2, Amount: big.NewInt(1), Action: (&CandidateExit{Target: testAddress2}).Encode()},
+		&MultiSignApprove{Proposal: testHash},
+		&MultiSignAdd{MultiSign: testAddress1, Owner: testAddress2},
+		&MultiSignRemove{MultiSign: testAddress1, Owner: testAddress2},
+		&MultiSignRequire{MultiSign: testAddress1, Threshold: 3},
+		&FulTransfer{To: testAddress2, Amount: big.NewInt(1000)},
+		&FulApprove{Spender: testAddress2, Amount: big.NewInt(1000)},
+		&FulTransferFrom{From: testAddress1, To: testAddress2, Amount: big.NewInt(1000)},
+	}
+	for _, want := range payloads {
+		enc := want.Encode()
+		got, err := Decode(enc)
+		if err != nil {
+			t.Errorf("%v: failed to decode %q: %v", want.Kind(), enc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: round trip mismatch\n got %+v\nwant %+v", want.Kind(), got, want)
+		}
+		if reenc := got.Encode(); !bytes.Equal(reenc, enc) {
+			t.Errorf("%v: encoding not canonical: %q != %q", want.Kind(), reenc, enc)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		payload Payload
+		data    string
+		err     error
+	}{
+		{new(Bind), "NFC:1:Unbind:0x00:0", ErrKindMismatch},
+		{new(Confirm), "ufo:1:event:confirm", ErrMissingField},
+		{new(Confirm), "ufo:1:event:confirm:0x12z", ErrInvalidField},
+		{new(Exchange), "NFC:1:Exch:" + testAddress1.Hex(), ErrMissingField},
+		{new(Exchange), "NFC:1:Exch:0x1234:0x10", ErrInvalidField},
+		{new(MultiSign), "NFC:1:Multi:two:" + testAddress1.Hex() + ":" + testAddress2.Hex(), ErrInvalidField},
+		{new(Proposal), "ufo:1:event:proposal:vlcnt:1", ErrInvalidField},
+		{new(Proposal), "ufo:1:event:proposal:mrpt:1001", ErrInvalidField},
+		{new(Proposal), "ufo:1:event:proposal:mpd:" + big.NewInt(MaxProposalDeposit+1).String(), ErrInvalidField},
+		{new(Declare), "ufo:1:event:declare:hash:" + testHash.Hex() + ":decision:maybe", ErrInvalidField},
+		{new(FlowReportM), "ufo:1:sc:flwrptm:0011", ErrInvalidField},
+		{new(CndLock), "SSC:1:CndLock:1:2", ErrMissingField},
+		{new(ISPQos), "SSC:1:QOS:1:0x10", ErrInvalidField},
+		{new(DoubleSign), "NFC:1:DblSign:0xc0", ErrMissingField},
+		{new(DoubleSign), "NFC:1:DblSign:0xc0:0xc0", ErrInvalidField},
+		{new(FulTransferFrom), "NFC:1:FulFrom:" + testAddress1.Hex() + ":" + testAddress2.Hex(), ErrMissingField},
+	}
+	for _, tt := range tests {
+		err := tt.payload.Decode([]byte(tt.data))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("decoding %q: error %v, want %v", tt.data, err, tt.err)
+		}
+	}
+	if _, err := Decode([]byte("ufo:1:event:unknown")); err != ErrUnknownPayload {
+		t.Errorf("unexpected error for unknown payload: %v", err)
+	}
+}
+
+// The engine never rejected a proposal for an unparsable hash or address, nor
+// a payload for trailing fields; the decoder must stay as lenient.
+func TestDecodeLenient(t *testing.T) {
+	var proposal Proposal
+	data := "ufo:1:event:proposal:proposal_type:1:candidate:invalid:vlcnt:10:vlcnt:20:dangling"
+	if err := proposal.Decode([]byte(data)); err != nil {
+		t.Fatalf("failed to decode proposal: %v", err)
+	}
+	if proposal.TargetAddress != nil {
+		t.Errorf("invalid candidate address decoded as %v", proposal.TargetAddress)
+	}
+	if proposal.ValidationLoopCnt == nil || *proposal.ValidationLoopCnt != 20 {
+		t.Errorf("later key must override the earlier one, have %v", proposal.ValidationLoopCnt)
+	}
+
+	var unbind Unbind
+	if err := unbind.Decode([]byte("NFC:1:Unbind:" + testAddress1.Hex() + ":1:trailing:fields")); err != nil {
+		t.Fatalf("failed to decode unbind with trailing fields: %v", err)
+	}
+	if unbind.Device != testAddress1 || unbind.Type != 1 {
+		t.Errorf("unexpected unbind %+v", unbind)
+	}
+}
+
+func TestFlowReportEnRecords(t *testing.T) {
+	sig := "0x" + strings.Repeat("11", 65)
+	records := []string{
+		"1,2,3," + sig,
+		"",
+		"0,2,3," + sig,
+		"1,2,3,0x1122",
+		"1,2,3",
+	}
+	var report FlowReportEn
+	if err := report.Decode([]byte("NFC:1:flwrpten:0:" + strings.Join(records, "|"))); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if len(report.Records) != len(records) {
+		t.Fatalf("record count mismatch: have %d, want %d", len(report.Records), len(records))
+	}
+	if err := report.Records[0].Err; err != nil {
+		t.Errorf("valid record rejected: %v", err)
+	}
+	if !report.Records[1].IsEmpty() {
+		t.Errorf("empty record not marked, err %v", report.Records[1].Err)
+	}
+	for i := 2; i < len(records); i++ {
+		if r := report.Records[i]; r.Err == nil || r.IsEmpty() {
+			t.Errorf("record %d: malformed record accepted", i)
+		}
+	}
+}
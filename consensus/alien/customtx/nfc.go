@@ -0,0 +1,775 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package customtx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/common/hexutil"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/rlp"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	nfcPosTarget          = 3
+	nfcPosExchValue       = 4
+	nfcPosRevenueType     = 4
+	nfcPosRevenueContract = 5
+	nfcPosMultiSign       = 6
+	nfcPosRevenueAddress  = 7
+	nfcPosISPQosID        = 4
+	nfcPosBandwidth       = 5
+	nfcPosFlowRecords     = 4
+	nfcPosEvidence1       = 3
+	nfcPosEvidence2       = 4
+	nfcPosMultiSignTo     = 4
+	nfcPosMultiSignAmount = 5
+	nfcPosMultiSignAction = 6
+	nfcPosMultiSignOwner  = 4
+	nfcPosMultiSignNumber = 4
+	nfcPosFulAmount       = 4
+	nfcPosFulFromTo       = 4
+	nfcPosFulFromAmount   = 5
+
+	flowRecordSeparator = "|"
+	flowRecordFields    = 4
+)
+
+// errEmptyFlowRecord marks an empty entry in the record list of a flwrpten payload.
+var errEmptyFlowRecord = errors.New("empty flow record")
+
+// Exchange is the "NFC:1:Exch:<address>:<amount>" payload which exchanges
+// the given amount of NFC of the sender into FUL of the target.
+type Exchange struct {
+	Target common.Address
+	Amount *big.Int
+}
+
+func (p *Exchange) Kind() Kind { return KindExchange }
+
+func (p *Exchange) Encode() []byte {
+	return join(KindExchange, p.Target.Hex(), formatAmount(p.Amount))
+}
+
+func (p *Exchange) Decode(data []byte) error {
+	fields, err := split(data, KindExchange, nfcPosExchValue+1)
+	if err != nil {
+		return err
+	}
+	target, err := parseAddress(KindExchange, "address", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindExchange, "amount", fields[nfcPosExchValue])
+	if err != nil {
+		return err
+	}
+	*p = Exchange{Target: target, Amount: amount}
+	return nil
+}
+
+// MultiSign is the "NFC:1:Multi:<threshold>:<owner>..." payload which creates
+// a multi-signature address owned by the listed addresses. Duplicated owners
+// are kept here and merged by the engine.
+type MultiSign struct {
+	Threshold uint32
+	Owners    []common.Address
+}
+
+func (p *MultiSign) Kind() Kind { return KindMultiSign }
+
+func (p *MultiSign) Encode() []byte {
+	fields := []string{strconv.FormatUint(uint64(p.Threshold), 10)}
+	for _, owner := range p.Owners {
+		fields = append(fields, owner.Hex())
+	}
+	return join(KindMultiSign, fields...)
+}
+
+func (p *MultiSign) Decode(data []byte) error {
+	fields, err := split(data, KindMultiSign, nfcPosTarget+3)
+	if err != nil {
+		return err
+	}
+	threshold, err := parseUint32(KindMultiSign, "threshold", fields[nfcPosTarget], 10)
+	if err != nil {
+		return err
+	}
+	owners := make([]common.Address, 0, len(fields)-nfcPosTarget-1)
+	for _, field := range fields[nfcPosTarget+1:] {
+		owner, err := parseAddress(KindMultiSign, "owner", field)
+		if err != nil {
+			return err
+		}
+		owners = append(owners, owner)
+	}
+	*p = MultiSign{Threshold: threshold, Owners: owners}
+	return nil
+}
+
+// Bind is the "NFC:1:Bind:<device>:<type>:<contract>:<multisign>" payload
+// which binds the revenue of a device to the sender. Contract and multisign
+// may be empty.
+type Bind struct {
+	Device    common.Address
+	Type      uint32
+	Contract  common.Address
+	MultiSign common.Address
+}
+
+func (p *Bind) Kind() Kind { return KindBind }
+
+func (p *Bind) Encode() []byte {
+	return join(KindBind, p.Device.Hex(), strconv.FormatUint(uint64(p.Type), 10),
+		formatOptionalAddress(p.Contract), formatOptionalAddress(p.MultiSign))
+}
+
+func (p *Bind) Decode(data []byte) error {
+	fields, err := split(data, KindBind, nfcPosMultiSign+1)
+	if err != nil {
+		return err
+	}
+	var bind Bind
+	if bind.Device, err = parseAddress(KindBind, "device", fields[nfcPosTarget]); err != nil {
+		return err
+	}
+	if bind.Type, err = parseUint32(KindBind, "type", fields[nfcPosRevenueType], 10); err != nil {
+		return err
+	}
+	if bind.Contract, err = parseOptionalAddress(KindBind, "contract", fields[nfcPosRevenueContract]); err != nil {
+		return err
+	}
+	if bind.MultiSign, err = parseOptionalAddress(KindBind, "multisign", fields[nfcPosMultiSign]); err != nil {
+		return err
+	}
+	*p = bind
+	return nil
+}
+
+// Unbind is the "NFC:1:Unbind:<device>:<type>" payload which releases the
+// revenue binding of a device.
+type Unbind struct {
+	Device common.Address
+	Type   uint32
+}
+
+func (p *Unbind) Kind() Kind { return KindUnbind }
+
+func (p *Unbind) Encode() []byte {
+	return join(KindUnbind, p.Device.Hex(), strconv.FormatUint(uint64(p.Type), 10))
+}
+
+func (p *Unbind) Decode(data []byte) error {
+	fields, err := split(data, KindUnbind, nfcPosRevenueType+1)
+	if err != nil {
+		return err
+	}
+	var unbind Unbind
+	if unbind.Device, err = parseAddress(KindUnbind, "device", fields[nfcPosTarget]); err != nil {
+		return err
+	}
+	if unbind.Type, err = parseUint32(KindUnbind, "type", fields[nfcPosRevenueType], 10); err != nil {
+		return err
+	}
+	*p = unbind
+	return nil
+}
+
+// Rebind is the "NFC:1:Rebind:<device>:<type>:<contract>:<multisign>:<revenue>"
+// payload which moves the revenue of a device to a new address.
+type Rebind struct {
+	Device    common.Address
+	Type      uint32
+	Contract  common.Address
+	MultiSign common.Address
+	Revenue   common.Address
+}
+
+func (p *Rebind) Kind() Kind { return KindRebind }
+
+func (p *Rebind) Encode() []byte {
+	return join(KindRebind, p.Device.Hex(), strconv.FormatUint(uint64(p.Type), 10),
+		formatOptionalAddress(p.Contract), formatOptionalAddress(p.MultiSign), p.Revenue.Hex())
+}
+
+func (p *Rebind) Decode(data []byte) error {
+	fields, err := split(data, KindRebind, nfcPosRevenueAddress+1)
+	if err != nil {
+		return err
+	}
+	var rebind Rebind
+	if rebind.Device, err = parseAddress(KindRebind, "device", fields[nfcPosTarget]); err != nil {
+		return err
+	}
+	if rebind.Revenue, err = parseAddress(KindRebind, "revenue", fields[nfcPosRevenueAddress]); err != nil {
+		return err
+	}
+	if rebind.Type, err = parseUint32(KindRebind, "type", fields[nfcPosRevenueType], 10); err != nil {
+		return err
+	}
+	if rebind.Contract, err = parseOptionalAddress(KindRebind, "contract", fields[nfcPosRevenueContract]); err != nil {
+		return err
+	}
+	if rebind.MultiSign, err = parseOptionalAddress(KindRebind, "multisign", fields[nfcPosMultiSign]); err != nil {
+		return err
+	}
+	*p = rebind
+	return nil
+}
+
+// CandidatePledge is the "NFC:1:CandReq:<miner>" payload which pledges the
+// candidate deposit for a miner.
+type CandidatePledge struct {
+	Target common.Address
+}
+
+func (p *CandidatePledge) Kind() Kind { return KindCandidatePledge }
+
+func (p *CandidatePledge) Encode() []byte { return join(KindCandidatePledge, p.Target.Hex()) }
+
+func (p *CandidatePledge) Decode(data []byte) error {
+	return decodeTarget(data, KindCandidatePledge, &p.Target)
+}
+
+// CandidateExit is the "NFC:1:CandExit:<miner>" payload which starts the
+// release of a candidate pledge.
+type CandidateExit struct {
+	Target common.Address
+}
+
+func (p *CandidateExit) Kind() Kind { return KindCandidateExit }
+
+func (p *CandidateExit) Encode() []byte { return join(KindCandidateExit, p.Target.Hex()) }
+
+func (p *CandidateExit) Decode(data []byte) error {
+	return decodeTarget(data, KindCandidateExit, &p.Target)
+}
+
+// CandidatePunish is the "NFC:1:CandPnsh:<miner>" payload which pays off the
+// punishment of a candidate.
+type CandidatePunish struct {
+	Target common.Address
+}
+
+func (p *CandidatePunish) Kind() Kind { return KindCandidatePunish }
+
+func (p *CandidatePunish) Encode() []byte { return join(KindCandidatePunish, p.Target.Hex()) }
+
+func (p *CandidatePunish) Decode(data []byte) error {
+	return decodeTarget(data, KindCandidatePunish, &p.Target)
+}
+
+// MinerPledge is the "NFC:1:FlwReq:<miner>:<qosid>:<bandwidth>" payload which
+// claims bandwidth for a flow miner. Both numbers are hexadecimal without prefix.
+type MinerPledge struct {
+	Target    common.Address
+	ISPQosID  uint32
+	Bandwidth uint32
+}
+
+func (p *MinerPledge) Kind() Kind { return KindMinerPledge }
+
+func (p *MinerPledge) Encode() []byte {
+	return join(KindMinerPledge, p.Target.Hex(), strconv.FormatUint(uint64(p.ISPQosID), 16),
+		strconv.FormatUint(uint64(p.Bandwidth), 16))
+}
+
+func (p *MinerPledge) Decode(data []byte) error {
+	fields, err := split(data, KindMinerPledge, nfcPosBandwidth+1)
+	if err != nil {
+		return err
+	}
+	var pledge MinerPledge
+	if pledge.Target, err = parseAddress(KindMinerPledge, "miner", fields[nfcPosTarget]); err != nil {
+		return err
+	}
+	if pledge.ISPQosID, err = parseUint32(KindMinerPledge, "qosid", fields[nfcPosISPQosID], 16); err != nil {
+		return err
+	}
+	if pledge.Bandwidth, err = parseUint32(KindMinerPledge, "bandwidth", fields[nfcPosBandwidth], 16); err != nil {
+		return err
+	}
+	*p = pledge
+	return nil
+}
+
+// MinerExit is the "NFC:1:FlwExit:<miner>" payload which starts the release
+// of a flow miner pledge.
+type MinerExit struct {
+	Target common.Address
+}
+
+func (p *MinerExit) Kind() Kind { return KindMinerExit }
+
+func (p *MinerExit) Encode() []byte { return join(KindMinerExit, p.Target.Hex()) }
+
+func (p *MinerExit) Decode(data []byte) error {
+	return decodeTarget(data, KindMinerExit, &p.Target)
+}
+
+func decodeTarget(data []byte, kind Kind, target *common.Address) error {
+	fields, err := split(data, kind, nfcPosTarget+1)
+	if err != nil {
+		return err
+	}
+	address, err := parseAddress(kind, "miner", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	*target = address
+	return nil
+}
+
+// FlowRecord is one device flow record of a flwrpten payload, signed by the
+// device owner. Err is set by Decode if the record is malformed; such a record
+// is skipped by the engine without invalidating the rest of the report.
+type FlowRecord struct {
+	ReportNumber decimal.Decimal
+	DeviceID     decimal.Decimal
+	FlowValue    decimal.Decimal
+	Signature    []byte
+	Err          error
+}
+
+// IsEmpty reports whether the record was an empty entry of the record list.
+func (r *FlowRecord) IsEmpty() bool {
+	return r.Err == errEmptyFlowRecord
+}
+
+func (r *FlowRecord) encode() string {
+	return fmt.Sprintf("%s,%s,%s,0x%x", r.ReportNumber.String(), r.DeviceID.String(), r.FlowValue.String(), r.Signature)
+}
+
+func (r *FlowRecord) decode(record string) error {
+	if record == "" {
+		return errEmptyFlowRecord
+	}
+	items := strings.Split(record, ",")
+	if len(items) != flowRecordFields {
+		return fmt.Errorf("%w: flow record has %d items, want %d", ErrInvalidField, len(items), flowRecordFields)
+	}
+	reportNumber, err := parseFlowDecimal("report number", items[0], false)
+	if err != nil {
+		return err
+	}
+	deviceID, err := parseFlowDecimal("device id", items[1], true)
+	if err != nil {
+		return err
+	}
+	flowValue, err := parseFlowDecimal("flow value", items[2], false)
+	if err != nil {
+		return err
+	}
+	if len(items[3]) == 0 {
+		return invalidField(KindFlowReportEn, "signature", items[3], errors.New("empty signature"))
+	}
+	sig := common.FromHex(items[3])
+	if len(sig) != crypto.SignatureLength {
+		return invalidField(KindFlowReportEn, "signature", items[3], errors.New("wrong size for signature"))
+	}
+	*r = FlowRecord{
+		ReportNumber: reportNumber,
+		DeviceID:     deviceID,
+		FlowValue:    flowValue,
+		Signature:    sig,
+	}
+	return nil
+}
+
+// parseFlowDecimal parses a positive decimal whose integer part fits into an
+// uint64. Unless allowZero is set the integer part must not be zero either.
+func parseFlowDecimal(name string, value string, allowZero bool) (decimal.Decimal, error) {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Decimal{}, invalidField(KindFlowReportEn, name, value, err)
+	}
+	if d.Cmp(decimal.Zero) <= 0 {
+		return decimal.Decimal{}, invalidField(KindFlowReportEn, name, value, errors.New("not positive"))
+	}
+	if !d.BigInt().IsUint64() || (!allowZero && d.BigInt().Uint64() == 0) {
+		return decimal.Decimal{}, invalidField(KindFlowReportEn, name, value, errors.New("out of range"))
+	}
+	return d, nil
+}
+
+// FlowReportEn is the "NFC:1:flwrpten:<reserved>:<record>|<record>..." payload
+// a flow miner sends to report the flow of devices. Every record is
+// "<report number>,<device id>,<flow value>,<signature>" in decimal; the
+// engine ignores the reserved field.
+type FlowReportEn struct {
+	Reserved string
+	Records  []FlowRecord
+}
+
+func (p *FlowReportEn) Kind() Kind { return KindFlowReportEn }
+
+func (p *FlowReportEn) Encode() []byte {
+	records := make([]string, len(p.Records))
+	for i := range p.Records {
+		records[i] = p.Records[i].encode()
+	}
+	return join(KindFlowReportEn, p.Reserved, strings.Join(records, flowRecordSeparator))
+}
+
+// Decode only fails if the payload itself is malformed. Every record keeps
+// its position in the list, so indexes reported by the engine refer to the
+// original payload, and carries its own parse error in Err.
+func (p *FlowReportEn) Decode(data []byte) error {
+	fields, err := split(data, KindFlowReportEn, nfcPosFlowRecords+1)
+	if err != nil {
+		return err
+	}
+	records := strings.Split(fields[nfcPosFlowRecords], flowRecordSeparator)
+	*p = FlowReportEn{
+		Reserved: fields[nfcPosTarget],
+		Records:  make([]FlowRecord, len(records)),
+	}
+	for i, record := range records {
+		if err := p.Records[i].decode(record); err != nil {
+			p.Records[i] = FlowRecord{Err: err}
+		}
+	}
+	return nil
+}
+
+// DoubleSign is the "NFC:1:DblSign:<header>:<header>" payload which proves a
+// signer sealed two different headers for the same slot. Both headers are hex
+// encoded RLP, the engine verifies their seals before slashing the signer.
+type DoubleSign struct {
+	Header1 *types.Header
+	Header2 *types.Header
+}
+
+func (p *DoubleSign) Kind() Kind { return KindDoubleSign }
+
+func (p *DoubleSign) Encode() []byte {
+	enc1, _ := rlp.EncodeToBytes(p.Header1)
+	enc2, _ := rlp.EncodeToBytes(p.Header2)
+	return join(KindDoubleSign, hexutil.Encode(enc1), hexutil.Encode(enc2))
+}
+
+func (p *DoubleSign) Decode(data []byte) error {
+	fields, err := split(data, KindDoubleSign, nfcPosEvidence2+1)
+	if err != nil {
+		return err
+	}
+	header1, err := parseHeader(KindDoubleSign, "header", fields[nfcPosEvidence1])
+	if err != nil {
+		return err
+	}
+	header2, err := parseHeader(KindDoubleSign, "header", fields[nfcPosEvidence2])
+	if err != nil {
+		return err
+	}
+	*p = DoubleSign{Header1: header1, Header2: header2}
+	return nil
+}
+
+// Unjail is the "NFC:1:Unjail:<miner>" payload which returns a jailed signer
+// to the election once its jail period is over.
+type Unjail struct {
+	Target common.Address
+}
+
+func (p *Unjail) Kind() Kind { return KindUnjail }
+
+func (p *Unjail) Encode() []byte { return join(KindUnjail, p.Target.Hex()) }
+
+func (p *Unjail) Decode(data []byte) error {
+	return decodeTarget(data, KindUnjail, &p.Target)
+}
+
+// MultiSignPropose is the "NFC:1:MultiProp:<multisign>:<to>:<amount>:<action>"
+// payload by which an owner of a multi-signature address proposes to transfer
+// the amount from it to the given address and to send the custom transaction
+// action, hex encoded, as the multi-signature address. The action may be left
+// out and the amount be zero.
+type MultiSignPropose struct {
+	MultiSign common.Address
+	To        common.Address
+	Amount    *big.Int
+	Action    []byte
+}
+
+func (p *MultiSignPropose) Kind() Kind { return KindMultiSignPropose }
+
+func (p *MultiSignPropose) Encode() []byte {
+	fields := []string{p.MultiSign.Hex(), p.To.Hex(), formatAmount(p.Amount)}
+	if len(p.Action) > 0 {
+		fields = append(fields, hexutil.Encode(p.Action))
+	}
+	return join(KindMultiSignPropose, fields...)
+}
+
+func (p *MultiSignPropose) Decode(data []byte) error {
+	fields, err := split(data, KindMultiSignPropose, nfcPosMultiSignAmount+1)
+	if err != nil {
+		return err
+	}
+	multiSign, err := parseAddress(KindMultiSignPropose, "multisign", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	to, err := parseAddress(KindMultiSignPropose, "to", fields[nfcPosMultiSignTo])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindMultiSignPropose, "amount", fields[nfcPosMultiSignAmount])
+	if err != nil {
+		return err
+	}
+	var action []byte
+	if len(fields) > nfcPosMultiSignAction && fields[nfcPosMultiSignAction] != "" {
+		if action, err = hexutil.Decode(fields[nfcPosMultiSignAction]); err != nil {
+			return invalidField(KindMultiSignPropose, "action", fields[nfcPosMultiSignAction], err)
+		}
+	}
+	*p = MultiSignPropose{MultiSign: multiSign, To: to, Amount: amount, Action: action}
+	return nil
+}
+
+// MultiSignApprove is the "NFC:1:MultiAppr:<proposal>" payload by which the
+// owners signing the transaction approve the multi-signature proposal sent in
+// the transaction with the given hash.
+type MultiSignApprove struct {
+	Proposal common.Hash
+}
+
+func (p *MultiSignApprove) Kind() Kind { return KindMultiSignApprove }
+
+func (p *MultiSignApprove) Encode() []byte { return join(KindMultiSignApprove, p.Proposal.Hex()) }
+
+func (p *MultiSignApprove) Decode(data []byte) error {
+	fields, err := split(data, KindMultiSignApprove, nfcPosTarget+1)
+	if err != nil {
+		return err
+	}
+	proposal, err := parseHash(KindMultiSignApprove, "proposal", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	p.Proposal = proposal
+	return nil
+}
+
+// MultiSignAdd is the "NFC:1:MultiAdd:<multisign>:<owner>" payload which adds
+// an owner to a multi-signature address. Like the other changes of the owners,
+// it is sent as the address through a multi-signature proposal, or signed by
+// enough owners in a multi-signer transaction.
+type MultiSignAdd struct {
+	MultiSign common.Address
+	Owner     common.Address
+}
+
+func (p *MultiSignAdd) Kind() Kind { return KindMultiSignAdd }
+
+func (p *MultiSignAdd) Encode() []byte {
+	return join(KindMultiSignAdd, p.MultiSign.Hex(), p.Owner.Hex())
+}
+
+func (p *MultiSignAdd) Decode(data []byte) error {
+	multiSign, owner, err := decodeMultiSignOwner(KindMultiSignAdd, data)
+	if err != nil {
+		return err
+	}
+	*p = MultiSignAdd{MultiSign: multiSign, Owner: owner}
+	return nil
+}
+
+// MultiSignRemove is the "NFC:1:MultiDel:<multisign>:<owner>" payload which
+// removes an owner from a multi-signature address.
+type MultiSignRemove struct {
+	MultiSign common.Address
+	Owner     common.Address
+}
+
+func (p *MultiSignRemove) Kind() Kind { return KindMultiSignRemove }
+
+func (p *MultiSignRemove) Encode() []byte {
+	return join(KindMultiSignRemove, p.MultiSign.Hex(), p.Owner.Hex())
+}
+
+func (p *MultiSignRemove) Decode(data []byte) error {
+	multiSign, owner, err := decodeMultiSignOwner(KindMultiSignRemove, data)
+	if err != nil {
+		return err
+	}
+	*p = MultiSignRemove{MultiSign: multiSign, Owner: owner}
+	return nil
+}
+
+func decodeMultiSignOwner(kind Kind, data []byte) (common.Address, common.Address, error) {
+	fields, err := split(data, kind, nfcPosMultiSignOwner+1)
+	if err != nil {
+		return common.Address{}, common.Address{}, err
+	}
+	multiSign, err := parseAddress(kind, "multisign", fields[nfcPosTarget])
+	if err != nil {
+		return common.Address{}, common.Address{}, err
+	}
+	owner, err := parseAddress(kind, "owner", fields[nfcPosMultiSignOwner])
+	if err != nil {
+		return common.Address{}, common.Address{}, err
+	}
+	return multiSign, owner, nil
+}
+
+// MultiSignRequire is the "NFC:1:MultiThr:<multisign>:<threshold>" payload
+// which changes the number of owners whose approval a multi-signature address
+// requires.
+type MultiSignRequire struct {
+	MultiSign common.Address
+	Threshold uint32
+}
+
+func (p *MultiSignRequire) Kind() Kind { return KindMultiSignRequire }
+
+func (p *MultiSignRequire) Encode() []byte {
+	return join(KindMultiSignRequire, p.MultiSign.Hex(), strconv.FormatUint(uint64(p.Threshold), 10))
+}
+
+func (p *MultiSignRequire) Decode(data []byte) error {
+	fields, err := split(data, KindMultiSignRequire, nfcPosMultiSignNumber+1)
+	if err != nil {
+		return err
+	}
+	multiSign, err := parseAddress(KindMultiSignRequire, "multisign", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	threshold, err := parseUint32(KindMultiSignRequire, "threshold", fields[nfcPosMultiSignNumber], 10)
+	if err != nil {
+		return err
+	}
+	*p = MultiSignRequire{MultiSign: multiSign, Threshold: threshold}
+	return nil
+}
+
+// FulTransfer is the "NFC:1:FulTrans:<to>:<amount>" payload which transfers
+// the given amount of FUL of the sender to another address.
+type FulTransfer struct {
+	To     common.Address
+	Amount *big.Int
+}
+
+func (p *FulTransfer) Kind() Kind { return KindFulTransfer }
+
+func (p *FulTransfer) Encode() []byte {
+	return join(KindFulTransfer, p.To.Hex(), formatAmount(p.Amount))
+}
+
+func (p *FulTransfer) Decode(data []byte) error {
+	fields, err := split(data, KindFulTransfer, nfcPosFulAmount+1)
+	if err != nil {
+		return err
+	}
+	to, err := parseAddress(KindFulTransfer, "to", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindFulTransfer, "amount", fields[nfcPosFulAmount])
+	if err != nil {
+		return err
+	}
+	*p = FulTransfer{To: to, Amount: amount}
+	return nil
+}
+
+// FulApprove is the "NFC:1:FulAppr:<spender>:<amount>" payload which allows
+// the spender to transfer up to the given amount of FUL of the sender, in place
+// of any earlier allowance. A zero amount withdraws the allowance.
+type FulApprove struct {
+	Spender common.Address
+	Amount  *big.Int
+}
+
+func (p *FulApprove) Kind() Kind { return KindFulApprove }
+
+func (p *FulApprove) Encode() []byte {
+	return join(KindFulApprove, p.Spender.Hex(), formatAmount(p.Amount))
+}
+
+func (p *FulApprove) Decode(data []byte) error {
+	fields, err := split(data, KindFulApprove, nfcPosFulAmount+1)
+	if err != nil {
+		return err
+	}
+	spender, err := parseAddress(KindFulApprove, "spender", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindFulApprove, "amount", fields[nfcPosFulAmount])
+	if err != nil {
+		return err
+	}
+	*p = FulApprove{Spender: spender, Amount: amount}
+	return nil
+}
+
+// FulTransferFrom is the "NFC:1:FulFrom:<from>:<to>:<amount>" payload by which
+// a spender transfers FUL of another address out of its allowance.
+type FulTransferFrom struct {
+	From   common.Address
+	To     common.Address
+	Amount *big.Int
+}
+
+func (p *FulTransferFrom) Kind() Kind { return KindFulTransferFrom }
+
+func (p *FulTransferFrom) Encode() []byte {
+	return join(KindFulTransferFrom, p.From.Hex(), p.To.Hex(), formatAmount(p.Amount))
+}
+
+func (p *FulTransferFrom) Decode(data []byte) error {
+	fields, err := split(data, KindFulTransferFrom, nfcPosFulFromAmount+1)
+	if err != nil {
+		return err
+	}
+	from, err := parseAddress(KindFulTransferFrom, "from", fields[nfcPosTarget])
+	if err != nil {
+		return err
+	}
+	to, err := parseAddress(KindFulTransferFrom, "to", fields[nfcPosFulFromTo])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindFulTransferFrom, "amount", fields[nfcPosFulFromAmount])
+	if err != nil {
+		return err
+	}
+	*p = FulTransferFrom{From: from, To: to, Amount: amount}
+	return nil
+}
+
+func parseHeader(kind Kind, name string, value string) (*types.Header, error) {
+	enc, err := hexutil.Decode(value)
+	if err != nil {
+		return nil, invalidField(kind, name, value, err)
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(enc, header); err != nil {
+		return nil, invalidField(kind, name, value, err)
+	}
+	return header, nil
+}
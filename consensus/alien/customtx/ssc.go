@@ -0,0 +1,272 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package customtx
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/seaskycheng/sdvn/common"
+)
+
+const (
+	sscPosValue          = 3
+	sscPosDepositWho     = 4
+	sscPosRlsPeriod      = 4
+	sscPosInterval       = 5
+	sscPosQosValue       = 4
+	sscPosWdthPnsh       = 4
+	sscPosManagerAddress = 4
+)
+
+// The SSC payloads configure the system and are only accepted by the engine
+// from the matching manager address.
+
+// ExchRate is the "SSC:1:ExchRate:<rate>" payload which sets the NFC to FUL
+// exchange rate in ten thousandths.
+type ExchRate struct {
+	Rate uint32
+}
+
+func (p *ExchRate) Kind() Kind { return KindExchRate }
+
+func (p *ExchRate) Encode() []byte {
+	return join(KindExchRate, strconv.FormatUint(uint64(p.Rate), 10))
+}
+
+func (p *ExchRate) Decode(data []byte) error {
+	fields, err := split(data, KindExchRate, sscPosValue+1)
+	if err != nil {
+		return err
+	}
+	rate, err := parseUint32(KindExchRate, "rate", fields[sscPosValue], 10)
+	if err != nil {
+		return err
+	}
+	p.Rate = rate
+	return nil
+}
+
+// Deposit is the "SSC:1:Deposit:<amount>:<who>" payload which sets a pledge
+// deposit; who 0 is the candidate deposit.
+type Deposit struct {
+	Amount *big.Int
+	Who    uint32
+}
+
+func (p *Deposit) Kind() Kind { return KindDeposit }
+
+func (p *Deposit) Encode() []byte {
+	return join(KindDeposit, formatAmount(p.Amount), strconv.FormatUint(uint64(p.Who), 10))
+}
+
+func (p *Deposit) Decode(data []byte) error {
+	fields, err := split(data, KindDeposit, sscPosDepositWho+1)
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(KindDeposit, "deposit", fields[sscPosValue])
+	if err != nil {
+		return err
+	}
+	who, err := parseUint32(KindDeposit, "who", fields[sscPosDepositWho], 10)
+	if err != nil {
+		return err
+	}
+	*p = Deposit{Amount: amount, Who: who}
+	return nil
+}
+
+// LockParameters are the lock period, release period and release interval of
+// a lock configuration, encoded hexadecimal without prefix.
+type LockParameters struct {
+	LockPeriod uint32
+	RlsPeriod  uint32
+	Interval   uint32
+}
+
+func (p *LockParameters) encode(kind Kind) []byte {
+	return join(kind, strconv.FormatUint(uint64(p.LockPeriod), 16),
+		strconv.FormatUint(uint64(p.RlsPeriod), 16), strconv.FormatUint(uint64(p.Interval), 16))
+}
+
+func (p *LockParameters) decode(kind Kind, data []byte) error {
+	fields, err := split(data, kind, sscPosInterval+1)
+	if err != nil {
+		return err
+	}
+	var lock LockParameters
+	if lock.LockPeriod, err = parseUint32(kind, "lock period", fields[sscPosValue], 16); err != nil {
+		return err
+	}
+	if lock.RlsPeriod, err = parseUint32(kind, "release period", fields[sscPosRlsPeriod], 16); err != nil {
+		return err
+	}
+	if lock.Interval, err = parseUint32(kind, "release interval", fields[sscPosInterval], 16); err != nil {
+		return err
+	}
+	*p = lock
+	return nil
+}
+
+// CndLock is the "SSC:1:CndLock:<lock>:<release>:<interval>" payload which
+// configures the candidate lock.
+type CndLock struct {
+	LockParameters
+}
+
+func (p *CndLock) Kind() Kind { return KindCndLock }
+
+func (p *CndLock) Encode() []byte { return p.encode(KindCndLock) }
+
+func (p *CndLock) Decode(data []byte) error { return p.decode(KindCndLock, data) }
+
+// FlwLock is the "SSC:1:FlwLock:<lock>:<release>:<interval>" payload which
+// configures the flow miner lock.
+type FlwLock struct {
+	LockParameters
+}
+
+func (p *FlwLock) Kind() Kind { return KindFlwLock }
+
+func (p *FlwLock) Encode() []byte { return p.encode(KindFlwLock) }
+
+func (p *FlwLock) Decode(data []byte) error { return p.decode(KindFlwLock, data) }
+
+// RwdLock is the "SSC:1:RwdLock:<lock>:<release>:<interval>" payload which
+// configures the reward lock.
+type RwdLock struct {
+	LockParameters
+}
+
+func (p *RwdLock) Kind() Kind { return KindRwdLock }
+
+func (p *RwdLock) Encode() []byte { return p.encode(KindRwdLock) }
+
+func (p *RwdLock) Decode(data []byte) error { return p.decode(KindRwdLock, data) }
+
+// OffLine is the "SSC:1:OffLine:<penalty>" payload which sets the offline
+// penalty of flow miners.
+type OffLine struct {
+	Penalty uint32
+}
+
+func (p *OffLine) Kind() Kind { return KindOffLine }
+
+func (p *OffLine) Encode() []byte {
+	return join(KindOffLine, strconv.FormatUint(uint64(p.Penalty), 10))
+}
+
+func (p *OffLine) Decode(data []byte) error {
+	fields, err := split(data, KindOffLine, sscPosValue+1)
+	if err != nil {
+		return err
+	}
+	penalty, err := parseUint32(KindOffLine, "offline", fields[sscPosValue], 10)
+	if err != nil {
+		return err
+	}
+	p.Penalty = penalty
+	return nil
+}
+
+// ISPQos is the "SSC:1:QOS:<isp>:<qos>" payload which sets the QOS of an ISP.
+type ISPQos struct {
+	ISPID uint32
+	QOS   uint32
+}
+
+func (p *ISPQos) Kind() Kind { return KindISPQos }
+
+func (p *ISPQos) Encode() []byte {
+	return join(KindISPQos, strconv.FormatUint(uint64(p.ISPID), 10), strconv.FormatUint(uint64(p.QOS), 10))
+}
+
+func (p *ISPQos) Decode(data []byte) error {
+	fields, err := split(data, KindISPQos, sscPosQosValue+1)
+	if err != nil {
+		return err
+	}
+	var qos ISPQos
+	if qos.ISPID, err = parseUint32(KindISPQos, "isp id", fields[sscPosValue], 10); err != nil {
+		return err
+	}
+	if qos.QOS, err = parseUint32(KindISPQos, "qos", fields[sscPosQosValue], 10); err != nil {
+		return err
+	}
+	*p = qos
+	return nil
+}
+
+// WdthPnsh is the "SSC:1:WdthPnsh:<miner>:<bandwidth>" payload which lowers
+// the claimed bandwidth of a flow miner. The bandwidth is hexadecimal
+// without prefix.
+type WdthPnsh struct {
+	Target    common.Address
+	Bandwidth uint32
+}
+
+func (p *WdthPnsh) Kind() Kind { return KindWdthPnsh }
+
+func (p *WdthPnsh) Encode() []byte {
+	return join(KindWdthPnsh, p.Target.Hex(), strconv.FormatUint(uint64(p.Bandwidth), 16))
+}
+
+func (p *WdthPnsh) Decode(data []byte) error {
+	fields, err := split(data, KindWdthPnsh, sscPosWdthPnsh+1)
+	if err != nil {
+		return err
+	}
+	var punish WdthPnsh
+	if punish.Target, err = parseAddress(KindWdthPnsh, "miner", fields[sscPosValue]); err != nil {
+		return err
+	}
+	if punish.Bandwidth, err = parseUint32(KindWdthPnsh, "bandwidth", fields[sscPosWdthPnsh], 16); err != nil {
+		return err
+	}
+	*p = punish
+	return nil
+}
+
+// Manager is the "SSC:1:Manager:<who>:<address>" payload which assigns the
+// manager address of a system configuration.
+type Manager struct {
+	Who    uint32
+	Target common.Address
+}
+
+func (p *Manager) Kind() Kind { return KindManager }
+
+func (p *Manager) Encode() []byte {
+	return join(KindManager, strconv.FormatUint(uint64(p.Who), 10), p.Target.Hex())
+}
+
+func (p *Manager) Decode(data []byte) error {
+	fields, err := split(data, KindManager, sscPosManagerAddress+1)
+	if err != nil {
+		return err
+	}
+	var manager Manager
+	if manager.Who, err = parseUint32(KindManager, "id", fields[sscPosValue], 10); err != nil {
+		return err
+	}
+	if manager.Target, err = parseAddress(KindManager, "address", fields[sscPosManagerAddress]); err != nil {
+		return err
+	}
+	*p = manager
+	return nil
+}
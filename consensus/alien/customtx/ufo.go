@@ -0,0 +1,451 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package customtx
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// proposalTypeRentSideChain mirrors the alien proposal type whose target
+// address is encoded under the "scrt" key instead of "candidate".
+const proposalTypeRentSideChain = 8
+
+// Limits of the proposal values, a proposal beyond them is invalid.
+const (
+	MaxValidationLoopCnt = 12342                   // About one month if period = 10 & 21 super nodes
+	MinValidationLoopCnt = 4                       // just for test, Note: 12350  About three days if seal each block per second & 21 super nodes
+	MaxProposalDeposit   = 100000                  // If no limit on max proposal deposit and 1 billion TTC deposit success passed, then no new proposal.
+	MinSCRentFee         = 100                     // 100 TTC
+	MinSCRentLength      = 259200                  // number of block about 1 month if period is 10
+	MaxSCRentLength      = MinSCRentLength * 3 * 4 // number of block about 1 year if period is 10
+)
+
+// flowReportMHeaderLen and flowReportMItemLen are the sizes of the report
+// time and of one item in the binary flwrptm payload.
+const (
+	flowReportMHeaderLen = 8
+	flowReportMItemLen   = 40
+)
+
+// Vote is the "ufo:1:event:vote" payload. The sender votes for the recipient
+// of the transaction with all its balance.
+type Vote struct{}
+
+func (p *Vote) Kind() Kind { return KindVote }
+
+func (p *Vote) Encode() []byte { return join(KindVote) }
+
+func (p *Vote) Decode(data []byte) error {
+	_, err := split(data, KindVote, posEvent+1)
+	return err
+}
+
+// Unvote is the "ufo:1:event:unvote" payload. The sender withdraws its vote
+// from the candidate it voted for.
+type Unvote struct{}
+
+func (p *Unvote) Kind() Kind { return KindUnvote }
+
+func (p *Unvote) Encode() []byte { return join(KindUnvote) }
+
+func (p *Unvote) Decode(data []byte) error {
+	_, err := split(data, KindUnvote, posEvent+1)
+	return err
+}
+
+// Redelegate is the "ufo:1:event:redelegate" payload. The sender moves the
+// stake of its vote to the recipient of the transaction.
+type Redelegate struct{}
+
+func (p *Redelegate) Kind() Kind { return KindRedelegate }
+
+func (p *Redelegate) Encode() []byte { return join(KindRedelegate) }
+
+func (p *Redelegate) Decode(data []byte) error {
+	_, err := split(data, KindRedelegate, posEvent+1)
+	return err
+}
+
+// Confirm is the "ufo:1:event:confirm:<number>" payload sent by a signer after
+// it confirmed the block with the given number.
+type Confirm struct {
+	Number *big.Int
+}
+
+func (p *Confirm) Kind() Kind { return KindConfirm }
+
+func (p *Confirm) Encode() []byte {
+	number := "0"
+	if p.Number != nil {
+		number = p.Number.String()
+	}
+	return join(KindConfirm, number)
+}
+
+func (p *Confirm) Decode(data []byte) error {
+	fields, err := split(data, KindConfirm, posEvent+2)
+	if err != nil {
+		return err
+	}
+	number := new(big.Int)
+	if err := number.UnmarshalText([]byte(fields[posEvent+1])); err != nil {
+		return invalidField(KindConfirm, "number", fields[posEvent+1], err)
+	}
+	p.Number = number
+	return nil
+}
+
+// Proposal is the "ufo:1:event:proposal:<key>:<value>..." payload. A nil field
+// is not part of the payload and the engine uses its default value instead.
+type Proposal struct {
+	ProposalType           *int            // proposal_type
+	ValidationLoopCnt      *int            // vlcnt
+	SCHash                 *common.Hash    // schash
+	SCBlockCountPerPeriod  *int            // sccount
+	SCBlockRewardPerPeriod *int            // screward
+	TargetAddress          *common.Address // candidate or scrt
+	MinerRewardPerThousand *int            // mrpt
+	MinVoterBalance        *int            // mvb
+	ProposalDeposit        *int            // mpd
+	SCRentFee              *int            // scrf
+	SCRentRate             *int            // scrr
+	SCRentLength           *int            // scrl
+}
+
+func (p *Proposal) Kind() Kind { return KindProposal }
+
+func (p *Proposal) Encode() []byte {
+	var fields []string
+	addInt := func(key string, v *int) {
+		if v != nil {
+			fields = append(fields, key, strconv.Itoa(*v))
+		}
+	}
+	addInt("proposal_type", p.ProposalType)
+	addInt("vlcnt", p.ValidationLoopCnt)
+	if p.SCHash != nil {
+		fields = append(fields, "schash", p.SCHash.Hex())
+	}
+	addInt("sccount", p.SCBlockCountPerPeriod)
+	addInt("screward", p.SCBlockRewardPerPeriod)
+	if p.TargetAddress != nil {
+		key := "candidate"
+		if p.ProposalType != nil && *p.ProposalType == proposalTypeRentSideChain {
+			key = "scrt"
+		}
+		fields = append(fields, key, p.TargetAddress.Hex())
+	}
+	addInt("mrpt", p.MinerRewardPerThousand)
+	addInt("mvb", p.MinVoterBalance)
+	addInt("mpd", p.ProposalDeposit)
+	addInt("scrf", p.SCRentFee)
+	addInt("scrr", p.SCRentRate)
+	addInt("scrl", p.SCRentLength)
+	return join(KindProposal, fields...)
+}
+
+// Decode parses the key/value pairs of a proposal and checks every value
+// against the proposal limits. Unknown keys and a dangling key without value
+// are ignored, a later pair overrides an earlier one and an unparsable hash or
+// address is skipped, all as the engine always did.
+func (p *Proposal) Decode(data []byte) error {
+	fields, err := split(data, KindProposal, posEvent+3)
+	if err != nil {
+		return err
+	}
+	*p = Proposal{}
+	pairs := fields[posEvent+1:]
+	for i := 0; i < len(pairs)/2; i++ {
+		k, v := pairs[i*2], pairs[i*2+1]
+		var (
+			target **int
+			valid  func(int) bool
+		)
+		switch k {
+		case "proposal_type":
+			target = &p.ProposalType
+		case "vlcnt":
+			target, valid = &p.ValidationLoopCnt, func(n int) bool { return n >= MinValidationLoopCnt && n <= MaxValidationLoopCnt }
+		case "sccount":
+			target = &p.SCBlockCountPerPeriod
+		case "screward":
+			target = &p.SCBlockRewardPerPeriod
+		case "mrpt":
+			target, valid = &p.MinerRewardPerThousand, func(n int) bool { return n > 0 && n <= 1000 }
+		case "mvb":
+			target, valid = &p.MinVoterBalance, func(n int) bool { return n > 0 }
+		case "mpd":
+			target, valid = &p.ProposalDeposit, func(n int) bool { return n > 0 && n <= MaxProposalDeposit }
+		case "scrf":
+			target, valid = &p.SCRentFee, func(n int) bool { return n >= MinSCRentFee }
+		case "scrr":
+			target, valid = &p.SCRentRate, func(n int) bool { return n > 0 }
+		case "scrl":
+			target, valid = &p.SCRentLength, func(n int) bool { return n >= MinSCRentLength && n <= MaxSCRentLength }
+		case "schash":
+			var hash common.Hash
+			if hash.UnmarshalText([]byte(v)) == nil {
+				p.SCHash = &hash
+			}
+			continue
+		case "candidate", "scrt":
+			var address common.Address
+			if address.UnmarshalText([]byte(v)) == nil {
+				p.TargetAddress = &address
+			}
+			continue
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return invalidField(KindProposal, k, v, err)
+		}
+		if valid != nil && !valid(n) {
+			return invalidField(KindProposal, k, v, errors.New("out of range"))
+		}
+		*target = &n
+	}
+	return nil
+}
+
+// Declare is the "ufo:1:event:declare:hash:<hash>:decision:<yes|no>" payload a
+// candidate sends to decide on a proposal.
+type Declare struct {
+	ProposalHash common.Hash
+	Decision     bool
+}
+
+func (p *Declare) Kind() Kind { return KindDeclare }
+
+func (p *Declare) Encode() []byte {
+	decision := "no"
+	if p.Decision {
+		decision = "yes"
+	}
+	return join(KindDeclare, "hash", p.ProposalHash.Hex(), "decision", decision)
+}
+
+// Decode parses the key/value pairs of a declare. The decision defaults to yes
+// and an unparsable hash is skipped, as the engine always did.
+func (p *Declare) Decode(data []byte) error {
+	fields, err := split(data, KindDeclare, posEvent+3)
+	if err != nil {
+		return err
+	}
+	*p = Declare{Decision: true}
+	pairs := fields[posEvent+1:]
+	for i := 0; i < len(pairs)/2; i++ {
+		k, v := pairs[i*2], pairs[i*2+1]
+		switch k {
+		case "hash":
+			p.ProposalHash.UnmarshalText([]byte(v))
+		case "decision":
+			if v == "yes" {
+				p.Decision = true
+			} else if v == "no" {
+				p.Decision = false
+			} else {
+				return invalidField(KindDeclare, k, v, errors.New("want yes or no"))
+			}
+		}
+	}
+	return nil
+}
+
+// SCConfirm is the "ufo:1:sc:confirm" payload a side chain signer sends to the
+// main chain. LoopInfo and ChargingInfo are '#' separated lists.
+type SCConfirm struct {
+	SCHash       common.Hash
+	Number       *big.Int
+	Time         *big.Int
+	LoopInfo     string
+	ChargingInfo string
+}
+
+func (p *SCConfirm) Kind() Kind { return KindSCConfirm }
+
+func (p *SCConfirm) Encode() []byte {
+	number, time := "0", "0"
+	if p.Number != nil {
+		number = p.Number.String()
+	}
+	if p.Time != nil {
+		time = p.Time.String()
+	}
+	return join(KindSCConfirm, p.SCHash.Hex(), number, time, p.LoopInfo, p.ChargingInfo)
+}
+
+func (p *SCConfirm) Decode(data []byte) error {
+	fields, err := split(data, KindSCConfirm, posEvent+6)
+	if err != nil {
+		return err
+	}
+	number := new(big.Int)
+	if err := number.UnmarshalText([]byte(fields[posEvent+2])); err != nil {
+		return invalidField(KindSCConfirm, "number", fields[posEvent+2], err)
+	}
+	time := new(big.Int)
+	if err := time.UnmarshalText([]byte(fields[posEvent+3])); err != nil {
+		return invalidField(KindSCConfirm, "time", fields[posEvent+3], err)
+	}
+	*p = SCConfirm{
+		SCHash:       common.HexToHash(fields[posEvent+1]),
+		Number:       number,
+		Time:         time,
+		LoopInfo:     fields[posEvent+4],
+		ChargingInfo: fields[posEvent+5],
+	}
+	return nil
+}
+
+// SetCoinbase is the "ufo:1:sc:setcb:<schash>" payload which sets the
+// recipient of the transaction as coinbase of the sender on a side chain.
+type SetCoinbase struct {
+	SCHash common.Hash
+}
+
+func (p *SetCoinbase) Kind() Kind { return KindSetCoinbase }
+
+func (p *SetCoinbase) Encode() []byte { return join(KindSetCoinbase, p.SCHash.Hex()) }
+
+func (p *SetCoinbase) Decode(data []byte) error {
+	fields, err := split(data, KindSetCoinbase, posEvent+2)
+	if err != nil {
+		return err
+	}
+	p.SCHash = common.HexToHash(fields[posEvent+1])
+	return nil
+}
+
+// DelCoinbase is the "ufo:1:sc:delcb:<schash>" payload, the reverse of SetCoinbase.
+type DelCoinbase struct {
+	SCHash common.Hash
+}
+
+func (p *DelCoinbase) Kind() Kind { return KindDelCoinbase }
+
+func (p *DelCoinbase) Encode() []byte { return join(KindDelCoinbase, p.SCHash.Hex()) }
+
+func (p *DelCoinbase) Decode(data []byte) error {
+	fields, err := split(data, KindDelCoinbase, posEvent+2)
+	if err != nil {
+		return err
+	}
+	p.SCHash = common.HexToHash(fields[posEvent+1])
+	return nil
+}
+
+// FlowReportItem is the flow of one miner within a flow report. The field
+// order is part of the RLP encoding and matches alien.MinerFlowReportItem.
+type FlowReportItem struct {
+	Target       common.Address
+	ReportNumber uint32
+	FlowValue1   uint64
+	FlowValue2   uint64
+}
+
+// FlowReportRecord is a flow report, matching alien.MinerFlowReportRecord.
+type FlowReportRecord struct {
+	ChainHash     common.Hash
+	ReportTime    uint64
+	ReportContent []FlowReportItem
+}
+
+// FlowReport is the "ufo:1:sc:flwrpt:<hex>" payload which carries an RLP
+// encoded flow report.
+type FlowReport struct {
+	Report FlowReportRecord
+}
+
+func (p *FlowReport) Kind() Kind { return KindFlowReport }
+
+func (p *FlowReport) Encode() []byte {
+	enc, _ := rlp.EncodeToBytes(&p.Report)
+	return join(KindFlowReport, "0x"+hex.EncodeToString(enc))
+}
+
+func (p *FlowReport) Decode(data []byte) error {
+	fields, err := split(data, KindFlowReport, posEvent+2)
+	if err != nil {
+		return err
+	}
+	var report FlowReportRecord
+	if err := rlp.DecodeBytes(common.FromHex(fields[posEvent+1]), &report); err != nil {
+		return invalidField(KindFlowReport, "report", fields[posEvent+1], err)
+	}
+	p.Report = report
+	return nil
+}
+
+// FlowReportM is the "ufo:1:sc:flwrptm:<hex>" payload, the compact binary form
+// of a flow report sent by the flow report manager. The hex string holds an
+// 8 byte report time followed by 40 byte items: address, two 8 byte flow
+// values and a 4 byte report number, all big endian.
+type FlowReportM struct {
+	ReportTime uint64
+	Items      []FlowReportItem
+}
+
+func (p *FlowReportM) Kind() Kind { return KindFlowReportM }
+
+func (p *FlowReportM) Encode() []byte {
+	buffer := make([]byte, flowReportMHeaderLen, flowReportMHeaderLen+len(p.Items)*flowReportMItemLen)
+	binary.BigEndian.PutUint64(buffer, p.ReportTime)
+	for _, item := range p.Items {
+		var value [flowReportMItemLen - common.AddressLength]byte
+		binary.BigEndian.PutUint64(value[0:8], item.FlowValue1)
+		binary.BigEndian.PutUint64(value[8:16], item.FlowValue2)
+		binary.BigEndian.PutUint32(value[16:20], item.ReportNumber)
+		buffer = append(buffer, item.Target.Bytes()...)
+		buffer = append(buffer, value[:]...)
+	}
+	return join(KindFlowReportM, hex.EncodeToString(buffer))
+}
+
+// Decode parses the binary report; a trailing partial item is ignored.
+func (p *FlowReportM) Decode(data []byte) error {
+	fields, err := split(data, KindFlowReportM, posEvent+2)
+	if err != nil {
+		return err
+	}
+	buffer := common.Hex2Bytes(fields[posEvent+1])
+	if len(buffer) < flowReportMHeaderLen {
+		return invalidField(KindFlowReportM, "report", fields[posEvent+1], errors.New("report time missing"))
+	}
+	*p = FlowReportM{
+		ReportTime: binary.BigEndian.Uint64(buffer[:flowReportMHeaderLen]),
+		Items:      []FlowReportItem{},
+	}
+	for post := flowReportMHeaderLen; post+flowReportMItemLen <= len(buffer); post += flowReportMItemLen {
+		item := buffer[post : post+flowReportMItemLen]
+		p.Items = append(p.Items, FlowReportItem{
+			Target:       common.BytesToAddress(item[:20]),
+			FlowValue1:   binary.BigEndian.Uint64(item[20:28]),
+			FlowValue2:   binary.BigEndian.Uint64(item[28:36]),
+			ReportNumber: binary.BigEndian.Uint32(item[36:40]),
+		})
+	}
+	return nil
+}
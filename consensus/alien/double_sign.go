@@ -0,0 +1,171 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// A signer double signs when it seals two different headers for the same slot,
+// that is the same height at the same time. The node keeps the seals verified
+// recently by slot and stores the evidence once it sees a conflicting one.
+// Anyone can then submit both headers in a NFC:1:DblSign transaction, which
+// slashes the candidate pledge of the signer and removes it from the
+// candidates.
+const inMemorySlots = 4096 // Number of recent sealed slots to keep in memory
+
+var (
+	evidencePrefix = []byte("alien-evidence-") // evidencePrefix + signer + number (uint64 big endian) -> DoubleSignEvidence
+
+	errInvalidEvidence = errors.New("invalid double sign evidence")
+)
+
+// DoubleSignEvidence is the pair of conflicting headers sealed by a signer.
+type DoubleSignEvidence struct {
+	Header1 *types.Header `json:"header1"`
+	Header2 *types.Header `json:"header2"`
+}
+
+// sealedSlot identifies the slot a header is sealed for.
+type sealedSlot struct {
+	signer common.Address
+	number uint64
+	time   uint64
+}
+
+func evidenceKey(signer common.Address, number uint64) []byte {
+	key := append(append([]byte{}, evidencePrefix...), signer.Bytes()...)
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return append(key, enc...)
+}
+
+// detectDoubleSign records the slot of a header with a verified seal and
+// stores the evidence if the signer already sealed another header for it.
+func (a *Alien) detectDoubleSign(header *types.Header, signer common.Address) {
+	slot := sealedSlot{signer: signer, number: header.Number.Uint64(), time: header.Time}
+	known, ok := a.slots.Get(slot)
+	if !ok {
+		a.slots.Add(slot, header)
+		return
+	}
+	first := known.(*types.Header)
+	if first.Hash() == header.Hash() {
+		return
+	}
+	key := evidenceKey(signer, slot.number)
+	if ok, _ := a.db.Has(key); ok {
+		return
+	}
+	log.Warn("Signer sealed conflicting headers", "signer", signer, "number", slot.number, "first", first.Hash(), "second", header.Hash())
+	blob, err := rlp.EncodeToBytes(&DoubleSignEvidence{Header1: first, Header2: header})
+	if err != nil {
+		log.Warn("Failed to encode double sign evidence", "err", err)
+		return
+	}
+	if err := a.db.Put(key, blob); err != nil {
+		log.Warn("Failed to store double sign evidence", "err", err)
+	}
+}
+
+// DoubleSignEvidence returns the stored evidence of the double signs of signer.
+func (a *Alien) DoubleSignEvidence(signer common.Address) ([]*DoubleSignEvidence, error) {
+	prefix := append(append([]byte{}, evidencePrefix...), signer.Bytes()...)
+	it := a.db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var evidence []*DoubleSignEvidence
+	for it.Next() {
+		if len(it.Key()) != len(prefix)+8 {
+			continue
+		}
+		item := new(DoubleSignEvidence)
+		if err := rlp.DecodeBytes(it.Value(), item); err != nil {
+			return nil, err
+		}
+		evidence = append(evidence, item)
+	}
+	return evidence, it.Error()
+}
+
+// verifyDoubleSign checks two headers prove a double sign before the block at
+// number, returning the signer who sealed both. One of the headers has to
+// extend this chain, and the signer has to be in turn for the slot in the
+// signer queue of the snapshot below it.
+func (a *Alien) verifyDoubleSign(chain consensus.ChainHeaderReader, header1, header2 *types.Header, number uint64) (common.Address, error) {
+	if header1 == nil || header2 == nil || header1.Number == nil || header2.Number == nil {
+		return common.Address{}, fmt.Errorf("%w: missing header", errInvalidEvidence)
+	}
+	if header1.Number.Cmp(header2.Number) != 0 || header1.Time != header2.Time {
+		return common.Address{}, fmt.Errorf("%w: headers for different slots", errInvalidEvidence)
+	}
+	if header1.Hash() == header2.Hash() {
+		return common.Address{}, fmt.Errorf("%w: same header", errInvalidEvidence)
+	}
+	if !header1.Number.IsUint64() || header1.Number.Uint64() >= number || header1.Number.Sign() == 0 {
+		return common.Address{}, fmt.Errorf("%w: header %v not below block %d", errInvalidEvidence, header1.Number, number)
+	}
+	signer1, err := ecrecover(header1, a.signatures)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", errInvalidEvidence, err)
+	}
+	signer2, err := ecrecover(header2, a.signatures)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", errInvalidEvidence, err)
+	}
+	if signer1 != signer2 {
+		return common.Address{}, fmt.Errorf("%w: sealed by %x and %x", errInvalidEvidence, signer1, signer2)
+	}
+	// The parent of a canonical header is always known, so a known parent
+	// covers both a canonical header and one forking off this chain
+	height := header1.Number.Uint64()
+	parent := chain.GetHeader(header1.ParentHash, height-1)
+	if parent == nil {
+		parent = chain.GetHeader(header2.ParentHash, height-1)
+	}
+	if parent == nil {
+		return common.Address{}, fmt.Errorf("%w: headers at %d not on this chain", errInvalidEvidence, height)
+	}
+	snap, err := a.snapshot(chain, height-1, parent.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", errInvalidEvidence, err)
+	}
+	if !snap.inturn(signer1, header1.Time) {
+		return common.Address{}, fmt.Errorf("%w: %x not in turn at %d", errInvalidEvidence, signer1, height)
+	}
+	return signer1, nil
+}
+
+// slashDoubleSign drops the candidate pledge of a signer who double signed at
+// number and removes it from the candidates.
+func (s *Snapshot) slashDoubleSign(signer common.Address, number uint64) {
+	delete(s.CandidatePledge, signer)
+	delete(s.TallyMiner, signer)
+	delete(s.Candidates, signer)
+	if s.DoubleSigned == nil {
+		s.DoubleSigned = make(map[common.Address]uint64)
+	}
+	s.DoubleSigned[signer] = number
+}
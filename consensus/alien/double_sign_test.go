@@ -0,0 +1,199 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+// signedTestHeader returns a header at number on top of parent sealed by key.
+func signedTestHeader(t *testing.T, key *ecdsa.PrivateKey, parent common.Hash, number uint64, time uint64, gasLimit uint64) *types.Header {
+	header := &types.Header{
+		ParentHash: parent,
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(1),
+		Time:       time,
+		GasLimit:   gasLimit,
+		Coinbase:   crypto.PubkeyToAddress(key.PublicKey),
+		Extra:      make([]byte, extraVanity+extraSeal),
+		Initial:    new(big.Int),
+	}
+	hash, err := sigHash(header)
+	if err != nil {
+		t.Fatalf("failed to hash header: %v", err)
+	}
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		t.Fatalf("failed to sign header: %v", err)
+	}
+	copy(header.Extra[extraVanity:], sig)
+	return header
+}
+
+// testHeaderChain implements consensus.ChainHeaderReader over a set of
+// known headers.
+type testHeaderChain map[common.Hash]*types.Header
+
+func (c testHeaderChain) Config() *params.ChainConfig  { return params.AllAlienProtocolChanges }
+func (c testHeaderChain) CurrentHeader() *types.Header { panic("not supported") }
+func (c testHeaderChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := c[hash]; ok && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+func (c testHeaderChain) GetHeaderByNumber(uint64) *types.Header         { panic("not supported") }
+func (c testHeaderChain) GetHeaderByHash(hash common.Hash) *types.Header { return c[hash] }
+
+// newDoubleSignTestChain returns a chain knowing the header at 9 and caches the
+// snapshot on top of it with signers taking turns from time 0.
+func newDoubleSignTestChain(alien *Alien, signers ...common.Address) (testHeaderChain, common.Hash) {
+	parent := &types.Header{Number: big.NewInt(9), Difficulty: big.NewInt(1), Initial: new(big.Int)}
+	snap := newSnapshot(alien.config, alien.signatures, parent.Hash(), nil, 0)
+	for i := range signers {
+		snap.Signers = append(snap.Signers, &signers[i])
+	}
+	snap.LoopStartTime = 0
+	alien.recents.Add(parent.Hash(), snap)
+	return testHeaderChain{parent.Hash(): parent}, parent.Hash()
+}
+
+func TestDoubleSignEvidence(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), DoubleSignBlock: big.NewInt(0)}, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	first := signedTestHeader(t, key, common.Hash{}, 10, 30, 1)
+	alien.detectDoubleSign(first, signer)
+	alien.detectDoubleSign(first, signer)
+	alien.detectDoubleSign(signedTestHeader(t, key, common.Hash{}, 10, 33, 2), signer)
+	if evidence, _ := alien.DoubleSignEvidence(signer); len(evidence) != 0 {
+		t.Fatalf("evidence stored for distinct slots")
+	}
+	second := signedTestHeader(t, key, common.Hash{}, 10, 30, 2)
+	alien.detectDoubleSign(second, signer)
+	evidence, err := alien.DoubleSignEvidence(signer)
+	if err != nil {
+		t.Fatalf("failed to read evidence: %v", err)
+	}
+	if len(evidence) != 1 || evidence[0].Header1.Hash() != first.Hash() || evidence[0].Header2.Hash() != second.Hash() {
+		t.Fatalf("unexpected evidence %+v", evidence)
+	}
+	if evidence, _ := alien.DoubleSignEvidence(common.Address{0x01}); len(evidence) != 0 {
+		t.Errorf("evidence returned for another signer")
+	}
+}
+
+func TestVerifyDoubleSign(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int)}, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	// The signer is in turn at 30, the other signer at 33
+	chain, parent := newDoubleSignTestChain(alien, crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(other.PublicKey))
+	header := signedTestHeader(t, key, parent, 10, 30, 1)
+	tests := []struct {
+		header   *types.Header
+		conflict *types.Header
+		number   uint64
+		valid    bool
+	}{
+		{header, signedTestHeader(t, key, parent, 10, 30, 2), 11, true},
+		{header, signedTestHeader(t, key, common.Hash{0x01}, 10, 30, 2), 11, true},
+		{signedTestHeader(t, key, common.Hash{0x01}, 10, 30, 1), header, 11, true},
+		{signedTestHeader(t, key, common.Hash{0x01}, 10, 30, 1), signedTestHeader(t, key, common.Hash{0x02}, 10, 30, 2), 11, false},
+		{signedTestHeader(t, key, parent, 10, 33, 1), signedTestHeader(t, key, parent, 10, 33, 2), 11, false},
+		{header, signedTestHeader(t, key, parent, 10, 30, 2), 10, false},
+		{header, signedTestHeader(t, key, parent, 10, 33, 2), 11, false},
+		{header, signedTestHeader(t, key, parent, 11, 30, 2), 12, false},
+		{header, signedTestHeader(t, other, parent, 10, 30, 2), 11, false},
+		{header, header, 11, false},
+		{header, nil, 11, false},
+	}
+	for i, tt := range tests {
+		signer, err := alien.verifyDoubleSign(chain, tt.header, tt.conflict, tt.number)
+		if tt.valid && (err != nil || signer != crypto.PubkeyToAddress(key.PublicKey)) {
+			t.Errorf("test %d: evidence refused: %v", i, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("test %d: invalid evidence accepted", i)
+		}
+	}
+}
+
+func TestProcessDoubleSign(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), DoubleSignBlock: big.NewInt(0)}, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+	chain, parent := newDoubleSignTestChain(alien, signer)
+
+	snap := &Snapshot{
+		Candidates:      map[common.Address]uint64{signer: candidateStateNormal},
+		CandidatePledge: map[common.Address]*PledgeItem{signer: NewPledgeItem(big.NewInt(1000))},
+		TallyMiner:      map[common.Address]*CandidateState{signer: {Stake: big.NewInt(1)}},
+		DoubleSigned:    make(map[common.Address]uint64),
+	}
+	payload := &customtx.DoubleSign{
+		Header1: signedTestHeader(t, key, parent, 10, 30, 1),
+		Header2: signedTestHeader(t, key, parent, 10, 30, 2),
+	}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), payload.Encode())
+	receipts := []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(20)}}
+
+	records, reject := alien.processDoubleSign(nil, chain, tx.Data(), tx, receipts, 20, snap)
+	if reject != RejectNone {
+		t.Fatalf("evidence rejected: %v", reject)
+	}
+	if len(records) != 1 || records[0].Target != signer || records[0].Number != 10 || records[0].Amount.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("unexpected slash records %+v", records)
+	}
+	if len(receipts[0].Logs) != 1 {
+		t.Errorf("slash not logged")
+	}
+	if _, ok := snap.CandidatePledge[signer]; ok {
+		t.Errorf("pledge not slashed")
+	}
+	if snap.isCandidate(signer) {
+		t.Errorf("signer still a candidate")
+	}
+	if _, reject := alien.processDoubleSign(records, chain, tx.Data(), tx, receipts, 20, snap); reject != RejectStaleEvidence {
+		t.Errorf("evidence replayed: %v", reject)
+	}
+
+	// The snapshot applies the slash recorded in the header the same way
+	applied := &Snapshot{
+		Candidates:      map[common.Address]uint64{signer: candidateStateNormal},
+		CandidatePledge: map[common.Address]*PledgeItem{signer: NewPledgeItem(big.NewInt(1000))},
+		TallyMiner:      map[common.Address]*CandidateState{signer: {Stake: big.NewInt(1)}},
+	}
+	applied.updateDoubleSignSlash(records)
+	if _, ok := applied.CandidatePledge[signer]; ok || applied.isCandidate(signer) || applied.DoubleSigned[signer] != 10 {
+		t.Errorf("slash not applied to the snapshot")
+	}
+}
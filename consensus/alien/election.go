@@ -0,0 +1,156 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/seaskycheng/sdvn/common"
+)
+
+// SignerQueuePreview is the signer queue the next recalculation of the signers
+// would produce if the votes and pledges stayed as they are. The signers are
+// final, their order is shuffled again with the block hashes of that time.
+type SignerQueuePreview struct {
+	Number        uint64           `json:"number"`        // Block the preview is built on
+	Recalculation uint64           `json:"recalculation"` // Block after which the signers are recalculated
+	Signers       []common.Address `json:"signers"`
+}
+
+// ElectionExplanation is the standing of an address in the next recalculation
+// of the signers.
+type ElectionExplanation struct {
+	Address    common.Address `json:"address"`
+	Number     uint64         `json:"number"`     // Block the explanation is built on
+	Candidate  bool           `json:"candidate"`  // Whether the address is a candidate of the main miners
+	Tally      *big.Int       `json:"tally"`      // Stake voted for the address
+	Pledge     *big.Int       `json:"pledge"`     // Candidate pledge of the address
+	MinerStake *big.Int       `json:"minerStake"` // Stake of the address as a second miner
+	Credit     uint64         `json:"credit"`     // Punished credit lowering the weight of the stakes
+	Jailed     bool           `json:"jailed"`
+	MainRank   int            `json:"mainRank"`   // Position in the main miners by weighted stake, zero if not one
+	SecondRank int            `json:"secondRank"` // Position in the second miners by weighted stake, zero if not one
+	Elected    bool           `json:"elected"`
+	Reason     string         `json:"reason"` // Why the address is elected or not
+}
+
+// nextRecalculation returns the block after which the signers are recalculated
+// next, at or after the block of the snapshot.
+func (s *Snapshot) nextRecalculation() uint64 {
+	loop := s.config.MaxSignerCount * s.LCRS
+	return (s.Number+loop)/loop*loop - 1
+}
+
+// previewSignerQueue returns the signer queue the next recalculation would
+// produce with the votes and pledges of the snapshot.
+func (s *Snapshot) previewSignerQueue() (*SignerQueuePreview, error) {
+	if s.LCRS == 0 || uint64(len(s.HistoryHash)) < s.config.MaxSignerCount {
+		return nil, errCreateSignerQueueNotAllowed
+	}
+	signers, err := s.signerQueue(s.electSigners())
+	if err != nil {
+		return nil, err
+	}
+	return &SignerQueuePreview{
+		Number:        s.Number,
+		Recalculation: s.nextRecalculation(),
+		Signers:       signers,
+	}, nil
+}
+
+// explainElection returns the standing of address in the next recalculation of
+// the signers, following the checks of buildTallySlice and buildTallyMiner.
+func (s *Snapshot) explainElection(address common.Address) (*ElectionExplanation, error) {
+	preview, err := s.previewSignerQueue()
+	if err != nil {
+		return nil, err
+	}
+	explanation := &ElectionExplanation{
+		Address:    address,
+		Number:     s.Number,
+		Candidate:  s.isCandidate(address),
+		Tally:      new(big.Int),
+		Pledge:     new(big.Int),
+		MinerStake: new(big.Int),
+		Credit:     s.Punished[address],
+		Jailed:     s.isJailed(address),
+	}
+	if tally, ok := s.Tally[address]; ok {
+		explanation.Tally.Set(tally)
+	}
+	pledge, pledged := s.CandidatePledge[address]
+	if pledged {
+		explanation.Pledge.Set(pledge.Amount)
+	}
+	if miner, ok := s.TallyMiner[address]; ok && miner.Stake != nil {
+		explanation.MinerStake.Set(miner.Stake)
+	}
+	mainMinerSlice := s.buildTallySlice()
+	sort.Sort(mainMinerSlice)
+	secondMinerSlice := s.buildTallyMiner()
+	sort.Sort(secondMinerSlice)
+	explanation.MainRank = tallyRank(mainMinerSlice, address)
+	explanation.SecondRank = tallyRank(secondMinerSlice, address)
+
+	for _, signer := range preview.Signers {
+		if signer == address {
+			explanation.Elected = true
+		}
+	}
+	_, voted := s.Tally[address]
+	_, mining := s.TallyMiner[address]
+	maxCandidateMiner := int(economics(s.config).MaxCandidateMiner)
+
+	switch {
+	case explanation.Elected && explanation.Jailed:
+		explanation.Reason = "elected while jailed, no other signer is left"
+	case explanation.Elected:
+		explanation.Reason = "elected"
+	case explanation.Jailed:
+		explanation.Reason = fmt.Sprintf("jailed until block %d", s.jailRecord(address).Release)
+	case !voted && !mining:
+		explanation.Reason = "neither voted for nor mining"
+	case explanation.MainRank == 0 && explanation.SecondRank == 0 && voted && candidateNeedPD && !explanation.Candidate:
+		explanation.Reason = "not a candidate"
+	case explanation.MainRank == 0 && explanation.SecondRank == 0 && !pledged:
+		explanation.Reason = "no candidate pledge"
+	case explanation.MainRank == 0 && explanation.SecondRank == 0 && pledge.StartHigh > 0:
+		explanation.Reason = fmt.Sprintf("candidate pledge exiting since block %d", pledge.StartHigh)
+	case explanation.MainRank == 0 && explanation.SecondRank == 0 && explanation.Credit >= minCalSignerQueueCredit:
+		explanation.Reason = fmt.Sprintf("punished credit %d reached %d", explanation.Credit, minCalSignerQueueCredit)
+	case explanation.MainRank == 0 && s.config.MaxSignerCount < defaultOfficialMaxSignerCount:
+		explanation.Reason = fmt.Sprintf("second miners only sign in queues of %d signers or more", defaultOfficialMaxSignerCount)
+	case explanation.MainRank == 0 && explanation.SecondRank > maxCandidateMiner-len(mainMinerSlice):
+		explanation.Reason = fmt.Sprintf("beyond the %d candidates taking part in the election", maxCandidateMiner)
+	default:
+		explanation.Reason = "ranked below the elected signers"
+	}
+	return explanation, nil
+}
+
+// tallyRank returns the position of address in the sorted slice, zero if it
+// isn't in it.
+func tallyRank(slice TallySlice, address common.Address) int {
+	for i, item := range slice {
+		if item.addr == address {
+			return i + 1
+		}
+	}
+	return 0
+}
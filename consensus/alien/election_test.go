@@ -0,0 +1,88 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestPreviewSignerQueue(t *testing.T) {
+	var (
+		signer1, signer2, signer3 = common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+		voted, stranger           = common.Address{0x04}, common.Address{0x05}
+	)
+	config := &params.AlienConfig{Period: 3, MaxSignerCount: 3}
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 2)
+	snap.Number = 4
+	snap.HistoryHash = []common.Hash{{0x0a}, {0x0b}, {0x0c}, {0x0d}, {0x0e}}
+	snap.Hash = snap.HistoryHash[len(snap.HistoryHash)-1]
+	for i, signer := range []common.Address{signer1, signer2, signer3} {
+		snap.Tally[signer] = big.NewInt(int64(10 * (i + 1)))
+		snap.Candidates[signer] = candidateStateNormal
+	}
+	snap.Tally[voted] = big.NewInt(100)
+	snap.Punished[signer1] = 40
+
+	preview, err := snap.previewSignerQueue()
+	if err != nil {
+		t.Fatalf("failed to preview signer queue: %v", err)
+	}
+	if preview.Number != 4 || preview.Recalculation != 5 {
+		t.Errorf("preview at %d for recalculation %d, want 4 and 5", preview.Number, preview.Recalculation)
+	}
+	// The recalculation at the block gives the same queue
+	snap.Number = 5
+	queue, err := snap.createSignerQueue()
+	if err != nil {
+		t.Fatalf("failed to create signer queue: %v", err)
+	}
+	if !reflect.DeepEqual(preview.Signers, queue) {
+		t.Errorf("preview %v, signer queue %v", preview.Signers, queue)
+	}
+	if snap.nextRecalculation() != 5 {
+		t.Errorf("next recalculation %d, want 5", snap.nextRecalculation())
+	}
+
+	explanation, err := snap.explainElection(signer1)
+	if err != nil {
+		t.Fatalf("failed to explain election: %v", err)
+	}
+	if !explanation.Elected || explanation.Credit != 40 || explanation.MainRank != 3 || explanation.Tally.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+	for _, tt := range []struct {
+		address common.Address
+		reason  string
+	}{
+		{signer3, "elected"},
+		{voted, "not a candidate"},
+		{stranger, "neither voted for nor mining"},
+	} {
+		explanation, err := snap.explainElection(tt.address)
+		if err != nil {
+			t.Fatalf("failed to explain election of %x: %v", tt.address, err)
+		}
+		if explanation.Reason != tt.reason {
+			t.Errorf("%x: reason %q, want %q", tt.address, explanation.Reason, tt.reason)
+		}
+	}
+}
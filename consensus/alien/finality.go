@@ -0,0 +1,371 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/seaskycheng/sdvn/accounts"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// The finality gadget runs next to the block production when PBFTEnable is set.
+// Once a signer imports a new head it broadcasts a signed prevote for it, and
+// once 2/3+1 of the signers of that block prevoted for it, a precommit. A block
+// precommitted by 2/3+1 of its signers is final along with all its ancestors,
+// the chain refuses to reorganise below it. The votes are exchanged over the
+// `bft` protocol and never enter a block.
+const (
+	FinalityPrevote   = uint8(0) // Vote for a block seen as the head
+	FinalityPrecommit = uint8(1) // Vote for a block prevoted by a quorum
+
+	finalityWindow = 256 // Blocks around the head votes are kept for
+)
+
+var (
+	finalityKey    = []byte("alien-finalized") // finalityKey -> last finalized block and its precommits
+	finalityDomain = []byte("alien-bft")       // Prefix of the signed vote payload, apart from the headers
+
+	errInvalidFinalityVote = errors.New("invalid finality vote")
+)
+
+// FinalityVote is the prevote or precommit of a signer for a block.
+type FinalityVote struct {
+	Type      uint8
+	Number    uint64
+	Hash      common.Hash
+	Signature []byte
+}
+
+// ID returns the hash identifying the vote with its signature.
+func (v *FinalityVote) ID() common.Hash {
+	enc, _ := rlp.EncodeToBytes(v)
+	return crypto.Keccak256Hash(enc)
+}
+
+// payload returns the data signed by the voter.
+func (v *FinalityVote) payload() []byte {
+	enc, _ := rlp.EncodeToBytes([]interface{}{finalityDomain, v.Type, v.Number, v.Hash})
+	return enc
+}
+
+// signer recovers the address of the voter.
+func (v *FinalityVote) signer() (common.Address, error) {
+	if v.Type > FinalityPrecommit || len(v.Signature) != crypto.SignatureLength {
+		return common.Address{}, errInvalidFinalityVote
+	}
+	pubkey, err := crypto.Ecrecover(crypto.Keccak256(v.payload()), v.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// finalityRecord is the stored last finalized block, with the precommits which
+// justify it to peers.
+type finalityRecord struct {
+	Number uint64
+	Hash   common.Hash
+	Votes  []*FinalityVote
+}
+
+// finalityRound holds the votes for the blocks at one height.
+type finalityRound struct {
+	votes [2]map[common.Address]*FinalityVote // Prevotes and precommits by signer
+	voted [2]bool                             // Whether the local signer cast its prevote and precommit
+}
+
+// count returns the votes of the given type for hash by the signers.
+func (r *finalityRound) count(kind uint8, hash common.Hash, signers map[common.Address]struct{}) []*FinalityVote {
+	var votes []*FinalityVote
+	for signer, vote := range r.votes[kind] {
+		if _, ok := signers[signer]; ok && vote.Hash == hash {
+			votes = append(votes, vote)
+		}
+	}
+	return votes
+}
+
+// finality tracks the votes of the signers and the last finalized block.
+type finality struct {
+	lock   sync.Mutex
+	rounds map[uint64]*finalityRound
+	last   finalityRecord
+}
+
+// loadFinality reads the last finalized block from the database.
+func loadFinality(db ethdb.KeyValueReader) *finality {
+	f := &finality{rounds: make(map[uint64]*finalityRound)}
+	if blob, err := db.Get(finalityKey); err == nil {
+		if err := rlp.DecodeBytes(blob, &f.last); err != nil {
+			log.Warn("Failed to decode finalized block", "err", err)
+			f.last = finalityRecord{}
+		}
+	}
+	return f
+}
+
+// round returns the round at number, creating it if needed.
+func (f *finality) round(number uint64) *finalityRound {
+	round := f.rounds[number]
+	if round == nil {
+		round = &finalityRound{votes: [2]map[common.Address]*FinalityVote{make(map[common.Address]*FinalityVote), make(map[common.Address]*FinalityVote)}}
+		f.rounds[number] = round
+	}
+	return round
+}
+
+// outOfWindow returns whether votes for number are finalized already or out of
+// the window around head.
+func (f *finality) outOfWindow(number uint64, head uint64) bool {
+	return number <= f.last.Number || number > head+finalityWindow || number+finalityWindow <= head
+}
+
+// add records the vote of signer, returning false if it is known or out of the
+// window around head.
+func (f *finality) add(vote *FinalityVote, signer common.Address, head uint64) bool {
+	if f.outOfWindow(vote.Number, head) {
+		return false
+	}
+	round := f.round(vote.Number)
+	if _, ok := round.votes[vote.Type][signer]; ok {
+		return false
+	}
+	round.votes[vote.Type][signer] = vote
+	return true
+}
+
+// prune drops the rounds which can't be finalized anymore.
+func (f *finality) prune(head uint64) {
+	for number := range f.rounds {
+		if number <= f.last.Number || number+finalityWindow <= head {
+			delete(f.rounds, number)
+		}
+	}
+}
+
+// Finalized implements consensus.BFT, returning the last block finalized by the
+// signers, zero values if none is.
+func (a *Alien) Finalized() (uint64, common.Hash) {
+	a.finality.lock.Lock()
+	defer a.finality.lock.Unlock()
+
+	return a.finality.last.Number, a.finality.last.Hash
+}
+
+// Confirmed implements consensus.Confirmer, returning the highest of the block
+// confirmed by the signers at head and the last finalized block.
+func (a *Alien) Confirmed(chain consensus.ChainHeaderReader, head *types.Header) (uint64, error) {
+	snap, err := a.snapshot(chain, head.Number.Uint64(), head.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		return 0, err
+	}
+	confirmed := snap.ConfirmedNumber
+	if finalized, _ := a.Finalized(); finalized > confirmed {
+		confirmed = finalized
+	}
+	if confirmed > head.Number.Uint64() {
+		confirmed = head.Number.Uint64()
+	}
+	return confirmed, nil
+}
+
+// FinalityJustification returns the precommits which finalized the last
+// finalized block, to bring peers up to date.
+func (a *Alien) FinalityJustification() []*FinalityVote {
+	a.finality.lock.Lock()
+	defer a.finality.lock.Unlock()
+
+	return append([]*FinalityVote(nil), a.finality.last.Votes...)
+}
+
+// NewFinalityHead casts the prevote of the local signer for a new chain head if
+// PBFTEnable is set, and counts the votes which arrived before the block. The
+// votes to broadcast are returned.
+func (a *Alien) NewFinalityHead(chain consensus.ChainHeaderReader, head *types.Header) []*FinalityVote {
+	number := head.Number.Uint64()
+
+	a.finality.lock.Lock()
+	a.finality.prune(number)
+	a.finality.lock.Unlock()
+
+	return a.updateFinality(chain, head, true)
+}
+
+// HandleFinalityVotes records the votes received from a peer and updates the
+// finality of the blocks they are for. The new votes to broadcast are returned,
+// the accepted remote ones along with the precommits cast in turn. An error is
+// returned if a vote isn't properly signed.
+func (a *Alien) HandleFinalityVotes(chain consensus.ChainHeaderReader, votes []*FinalityVote) ([]*FinalityVote, error) {
+	head := chain.CurrentHeader()
+
+	var (
+		relay   []*FinalityVote
+		numbers []uint64
+		signers = make(map[uint64]map[common.Address]struct{})
+	)
+	for _, vote := range votes {
+		signer, err := vote.signer()
+		if err != nil {
+			return relay, err
+		}
+		// Drop the votes out of the window before looking up their signers
+		a.finality.lock.Lock()
+		outOfWindow := a.finality.outOfWindow(vote.Number, head.Number.Uint64())
+		a.finality.lock.Unlock()
+		if outOfWindow {
+			continue
+		}
+		// Only keep the votes of the signers of the block, or of the head for
+		// blocks not imported yet
+		if _, ok := signers[vote.Number]; !ok {
+			header := chain.GetHeaderByNumber(vote.Number)
+			if header == nil {
+				header = head
+			}
+			if signers[vote.Number], err = a.finalitySigners(chain, header); err != nil {
+				log.Warn("Failed to retrieve signers to finalize", "number", header.Number, "hash", header.Hash(), "err", err)
+			}
+			numbers = append(numbers, vote.Number)
+		}
+		if _, ok := signers[vote.Number][signer]; !ok {
+			continue
+		}
+		a.finality.lock.Lock()
+		added := a.finality.add(vote, signer, head.Number.Uint64())
+		a.finality.lock.Unlock()
+		if added {
+			relay = append(relay, vote)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, number := range numbers {
+		// Votes for blocks not imported yet are counted along with the head
+		if header := chain.GetHeaderByNumber(number); header != nil {
+			relay = append(relay, a.updateFinality(chain, header, false)...)
+		}
+	}
+	return relay, nil
+}
+
+// finalitySigners returns the signers voting on the finality of header.
+func (a *Alien) finalitySigners(chain consensus.ChainHeaderReader, header *types.Header) (map[common.Address]struct{}, error) {
+	snap, err := a.snapshot(chain, header.Number.Uint64(), header.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		return nil, err
+	}
+	signers := make(map[common.Address]struct{})
+	for _, signer := range snap.Signers {
+		signers[*signer] = struct{}{}
+	}
+	return signers, nil
+}
+
+// updateFinality casts the votes of the local signer for the canonical header
+// and finalizes it once precommitted by a quorum of its signers, returning the
+// cast votes.
+func (a *Alien) updateFinality(chain consensus.ChainHeaderReader, header *types.Header, head bool) []*FinalityVote {
+	number, hash := header.Number.Uint64(), header.Hash()
+
+	signers, err := a.finalitySigners(chain, header)
+	if err != nil {
+		log.Warn("Failed to retrieve signers to finalize", "number", number, "hash", hash, "err", err)
+		return nil
+	}
+	quorum := len(signers)*2/3 + 1
+
+	a.lock.RLock()
+	local, signFn := a.signer, a.signFn
+	a.lock.RUnlock()
+	_, isSigner := signers[local]
+	voting := isSigner && signFn != nil && chain.Config().Alien != nil && chain.Config().Alien.PBFTEnable
+
+	a.finality.lock.Lock()
+	defer a.finality.lock.Unlock()
+
+	if number <= a.finality.last.Number {
+		return nil
+	}
+	var (
+		round = a.finality.round(number)
+		cast  []*FinalityVote
+	)
+	vote := func(kind uint8) {
+		if !voting || round.voted[kind] {
+			return
+		}
+		round.voted[kind] = true
+		v := &FinalityVote{Type: kind, Number: number, Hash: hash}
+		sig, err := signFn(accounts.Account{Address: local}, accounts.MimetypeAlien, v.payload())
+		if err != nil {
+			log.Warn("Failed to sign finality vote", "number", number, "hash", hash, "err", err)
+			return
+		}
+		v.Signature = sig
+		if _, ok := round.votes[kind][local]; !ok {
+			round.votes[kind][local] = v
+			cast = append(cast, v)
+		}
+	}
+	if head {
+		vote(FinalityPrevote)
+	}
+	if len(round.count(FinalityPrevote, hash, signers)) >= quorum {
+		vote(FinalityPrecommit)
+	}
+	precommits := round.count(FinalityPrecommit, hash, signers)
+	if len(precommits) < quorum {
+		return cast
+	}
+	// A block forking off below the finalized one is never final
+	if !descends(chain, header, a.finality.last.Number, a.finality.last.Hash) {
+		log.Warn("Refused finality of a block not descending from the finalized one", "number", number, "hash", hash, "finalized", a.finality.last.Number, "finalizedHash", a.finality.last.Hash)
+		return cast
+	}
+	a.finality.last = finalityRecord{Number: number, Hash: hash, Votes: precommits}
+	a.finality.prune(number)
+
+	if blob, err := rlp.EncodeToBytes(&a.finality.last); err != nil {
+		log.Warn("Failed to encode finalized block", "err", err)
+	} else if err := a.db.Put(finalityKey, blob); err != nil {
+		log.Warn("Failed to store finalized block", "err", err)
+	}
+	log.Info("Finalized alien block", "number", number, "hash", hash, "precommits", len(precommits), "signers", len(signers))
+	return cast
+}
+
+// descends returns whether header descends from the block at number with hash,
+// which is always the case if no block is finalized yet.
+func descends(chain consensus.ChainHeaderReader, header *types.Header, number uint64, hash common.Hash) bool {
+	if hash == (common.Hash{}) {
+		return true
+	}
+	for header != nil && header.Number.Uint64() > number {
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return header != nil && header.Hash() == hash
+}
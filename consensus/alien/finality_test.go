@@ -0,0 +1,91 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestConflictingFinality(t *testing.T) {
+	var (
+		keys    []*ecdsa.PrivateKey
+		signers []common.Address
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys, signers = append(keys, key), append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int)}, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	// Two branches fork off at block 1, each signed by all the signers
+	chain := make(testHeaderChain)
+	header := func(parent common.Hash, number int64, branch byte) *types.Header {
+		h := &types.Header{ParentHash: parent, Number: big.NewInt(number), Difficulty: big.NewInt(1), Initial: new(big.Int), Extra: []byte{branch}}
+		snap := newSnapshot(alien.config, alien.signatures, h.Hash(), nil, 0)
+		for i := range signers {
+			snap.Signers = append(snap.Signers, &signers[i])
+		}
+		alien.recents.Add(h.Hash(), snap)
+		chain[h.Hash()] = h
+		return h
+	}
+	a1 := header(common.Hash{}, 1, 0)
+	a3 := header(header(a1.Hash(), 2, 0).Hash(), 3, 0)
+	b1 := header(common.Hash{}, 1, 1)
+	b2 := header(b1.Hash(), 2, 1)
+
+	certify := func(h *types.Header) {
+		for i, key := range keys {
+			vote := &FinalityVote{Type: FinalityPrecommit, Number: h.Number.Uint64(), Hash: h.Hash()}
+			sig, err := crypto.Sign(crypto.Keccak256(vote.payload()), key)
+			if err != nil {
+				t.Fatalf("failed to sign vote: %v", err)
+			}
+			vote.Signature = sig
+			alien.finality.lock.Lock()
+			alien.finality.add(vote, signers[i], h.Number.Uint64())
+			alien.finality.lock.Unlock()
+		}
+		alien.updateFinality(chain, h, false)
+	}
+	certify(a1)
+	if number, hash := alien.Finalized(); number != 1 || hash != a1.Hash() {
+		t.Fatalf("finalized %d %x, want 1 %x", number, hash, a1.Hash())
+	}
+	// A certificate of the other branch doesn't replace the finalized block
+	certify(b2)
+	if number, hash := alien.Finalized(); number != 1 || hash != a1.Hash() {
+		t.Errorf("conflicting block finalized: %d %x", number, hash)
+	}
+	if restarted := loadFinality(alien.db); restarted.last.Hash != a1.Hash() {
+		t.Errorf("conflicting block stored as finalized: %d %x", restarted.last.Number, restarted.last.Hash)
+	}
+	// The finalized branch is still extended
+	certify(a3)
+	if number, hash := alien.Finalized(); number != 3 || hash != a3.Hash() {
+		t.Errorf("finalized %d %x, want 3 %x", number, hash, a3.Hash())
+	}
+}
@@ -1,10 +1,10 @@
 package alien
 
 import (
-	"errors"
 	"fmt"
 	"github.com/seaskycheng/sdvn/common"
 	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
 	"github.com/seaskycheng/sdvn/core/state"
 	"github.com/seaskycheng/sdvn/core/types"
 	"github.com/seaskycheng/sdvn/crypto"
@@ -17,34 +17,25 @@ import (
 	"strings"
 )
 
-var (
-	calFlowToFULRatio= uint64(13671875000000)//0.014 FUL/GB
-)
-
-func (a *Alien) processFlowCustomTx(txDataInfo []string, headerExtra HeaderExtra, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snapCache *Snapshot, number *big.Int, state *state.StateDB, chain consensus.ChainHeaderReader,fulBalances map[common.Address]*big.Int) HeaderExtra {
-	if  txDataInfo[posCategory]==nfcEventFlowReportEn {
-		headerExtra.FlowReport = a.processFlowReportEn (headerExtra.FlowReport, txDataInfo,number.Uint64(),snapCache,txSender, tx, receipts,fulBalances)
+func (a *Alien) processFlowCustomTx(txData []byte, headerExtra HeaderExtra, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, snapCache *Snapshot, number *big.Int, state *state.StateDB, chain consensus.ChainHeaderReader,fulBalances map[common.Address]*big.Int,history *fulHistory) (HeaderExtra, CustomTxReject) {
+	reject := RejectNone
+	if customtx.Identify(txData) == customtx.KindFlowReportEn {
+		headerExtra.FlowReport, reject = a.processFlowReportEn (headerExtra.FlowReport, txData,number.Uint64(),snapCache,txSender, tx, receipts,fulBalances,history)
 	}
-	return headerExtra
+	return headerExtra, reject
 }
 
 
-func (a *Alien) processFlowReportEn(flowReport []MinerFlowReportRecord, txDataInfo []string, number uint64, snap *Snapshot, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt,fulBalances map[common.Address]*big.Int) []MinerFlowReportRecord {
-	if len(txDataInfo) <= 4 {
-		log.Warn("En Flow report", "parameter number", len(txDataInfo))
-		return flowReport
+func (a *Alien) processFlowReportEn(flowReport []MinerFlowReportRecord, txData []byte, number uint64, snap *Snapshot, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt,fulBalances map[common.Address]*big.Int,history *fulHistory) ([]MinerFlowReportRecord, CustomTxReject) {
+	var payload customtx.FlowReportEn
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("En Flow report", "err", err)
+		return flowReport, RejectMalformed
 	}
-	position := 3
 	enAddr := txSender
 	if _, ok := snap.FlowPledge[enAddr]; !ok {
 		log.Warn("En Flow report", "enAddr is not in FlowPledge", enAddr)
-		return flowReport
-	}
-	position++
-	verifyArr :=strings.Split(txDataInfo[position],"|")
-	if len(verifyArr)==0 {
-		log.Warn("En Flow report", " verifyArr len = 0", txSender, len(verifyArr))
-		return flowReport
+		return flowReport, RejectNotPledged
 	}
 	census := MinerFlowReportRecord{
 		ChainHash: common.Hash{},
@@ -53,61 +44,18 @@ func (a *Alien) processFlowReportEn(flowReport []MinerFlowReportRecord, txDataIn
 	}
 	zeroAddr:=common.Address{}
 	var verifyResult []int
-	flowrecordlen:=4
-	for index,verifydata :=range verifyArr {
-		if verifydata == "" {
-			continue
-		}
-		flowrecord :=strings.Split(verifydata,",")
-		if len(flowrecord)!=flowrecordlen{
-			log.Warn("En Flow report ", "flowrecordlen", len(flowrecord),"index", index)
-			continue
-		}
-		i:=uint8(0)
-		reportNumber, err := decimal.NewFromString(flowrecord[i])
-		if err !=nil|| reportNumber.Cmp(decimal.Zero)<=0{
-			log.Warn("En Flow report ", "reportNumber is wrong", flowrecord[i],"index", index)
-			continue
-		}
-		if !reportNumber.BigInt().IsUint64()||reportNumber.BigInt().Uint64()==uint64(0){
-			log.Warn("En Flow report ", "reportNumber is not uint64 or is zero",reportNumber,"checkReportNumber index", index)
+	for index,record :=range payload.Records {
+		if record.Err != nil {
+			if !record.IsEmpty() {
+				log.Warn("En Flow report ", "err", record.Err,"index", index)
+			}
 			continue
 		}
+		reportNumber, deviceId, flowValue, sig := record.ReportNumber, record.DeviceID, record.FlowValue, record.Signature
 		if !snap.checkReportNumber(reportNumber,number) {
 			log.Warn("En Flow report ", "checkReportNumber index", index)
 			continue
 		}
-		i++
-		deviceId, err := decimal.NewFromString(flowrecord[i])
-		if err !=nil|| deviceId.Cmp(decimal.Zero)<=0{
-			log.Warn("En Flow report ", "deviceId is wrong", flowrecord[i],"index", index)
-			continue
-		}
-		if !deviceId.BigInt().IsUint64(){
-			log.Warn("En Flow report ", "deviceId is not uint64", flowrecord[i],"index", index)
-			continue
-		}
-		i++
-		flowValue, err := decimal.NewFromString(flowrecord[i])
-		if err !=nil||flowValue.Cmp(decimal.Zero)<=0{
-			log.Warn("En Flow report ", "flowValue is wrong", flowrecord[i],"index", index)
-			continue
-		}
-		if !flowValue.BigInt().IsUint64()||flowValue.BigInt().Uint64()==uint64(0){
-			log.Warn("En Flow report ", "flowValue is not uint64 or is zero", flowrecord[i],"index", index)
-			continue
-		}
-		i++
-		rsv:=flowrecord[i]
-		if len(rsv)==0{
-			log.Warn("En Flow report ", "len(vrs) is zero", flowrecord[i],"index", index)
-			continue
-		}
-		sig:=common.FromHex(rsv)
-		if len(sig) != crypto.SignatureLength {
-			log.Warn("En Flow report ", "wrong size for signature", flowrecord[i],"index", index)
-			continue
-		}
 		var from common.Address
 		if singer,ok:=isCheckFlowRecordSign(reportNumber,deviceId,enAddr,flowValue,sig);!ok{
 			log.Warn("En Flow report ", "checkFlowRecordSign index", index)
@@ -148,6 +96,7 @@ func (a *Alien) processFlowReportEn(flowReport []MinerFlowReportRecord, txDataIn
 		census.ReportContent=append(census.ReportContent,flowReportItem2)
 		verifyResult=append(verifyResult, index)
 		fulBalances[from]=new(big.Int).Sub(fulBalances[from],costFul)
+		history.addFlow(number,from,enAddr,costFul,reportNumber.BigInt().Uint64(),deviceId.String())
 	}
 	if len(census.ReportContent)>0{
 		flowReport = append(flowReport, census)
@@ -164,7 +113,10 @@ func (a *Alien) processFlowReportEn(flowReport []MinerFlowReportRecord, txDataIn
 		topics[0].UnmarshalText([]byte("0xea40f050c9c577748d5ddcdb6a19aab17cacb2fa5f63f3747c516b06b597afd1"))//web3.sha3("Flwrpten(address,uint256)")
 		a.addCustomerTxLog(tx, receipts, topics, []byte(topicdata))
 	}
-	return flowReport
+	if len(census.ReportContent)==0{
+		return flowReport, RejectNoValidRecord
+	}
+	return flowReport, RejectNone
 }
 
 func isCheckFlowRecordSign(reportNumber decimal.Decimal,deviceId decimal.Decimal, toAddress common.Address, flowValue decimal.Decimal,sig []byte) (common.Address,bool) {
@@ -191,15 +143,37 @@ func isCheckFlowRecordSign(reportNumber decimal.Decimal,deviceId decimal.Decimal
 	return signer,true
 }
 
+// fulRootError is returned by Snapshot.apply if the FUL trie root differs from
+// the FulDataRoot of the header.
+type fulRootError struct {
+	header     common.Hash
+	calculated common.Hash
+}
+
+func (e *fulRootError) Error() string {
+	return "Ful root hash is not same,head:" + e.header.String() + "cal:" + e.calculated.String()
+}
+
 func (s *Snapshot) calFulHashVer(roothash common.Hash, number uint64, db ethdb.Database) (*Snapshot,error) {
-	if isGeFulTrieNumber(number) {
+	if isGeFulTrieNumber(s.config, number) {
 		if s.Ful.Root() != roothash {
-			return s, errors.New("Ful root hash is not same,head:" + roothash.String() + "cal:" + s.Ful.Root().String())
+			return s, &fulRootError{header: roothash, calculated: s.Ful.Root()}
 		}
 	}
 	return s,nil
 }
 
+// initFulTrie moves the FUL balances into a new FUL trie.
+func (s *Snapshot) initFulTrie(db ethdb.Database) error {
+	ful, err := NewFUL(common.Hash{}, db)
+	if err != nil {
+		return err
+	}
+	s.Ful = ful
+	s.initFulBalance()
+	return nil
+}
+
 func (s *Snapshot) initFulBalance() {
 	delFul:=make([]common.Address,0)
 	for target, item := range s.FULBalance {
@@ -230,7 +204,7 @@ func (snap *Snapshot) updateFULBalanceCost(flowReport []MinerFlowReportRecord, h
 
 func (s *Snapshot) calCostFul(value uint64) *big.Int {
 	flowValue:=value
-	cost := new(big.Int).Mul(new(big.Int).SetUint64(flowValue), new(big.Int).SetUint64(calFlowToFULRatio))
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(flowValue), new(big.Int).SetUint64(economics(s.config).CalFlowToFULRatio))
 	return cost
 }
 
@@ -0,0 +1,221 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+	"github.com/seaskycheng/sdvn/trie"
+)
+
+// The FUL history is an optional index of every FUL debit and credit of an
+// address. It is filled in Finalize, where the report number and device id of
+// the flow report records are still known, so both imported and mined blocks
+// are indexed. As a mined block is only sealed afterwards, the records are
+// keyed by a block id derived from the parent hash and the transactions, and
+// only the records of the canonical block of a height are served.
+const maxFulHistoryRecords = 1000 // Number of records after which a page of FUL history ends
+
+var (
+	fulHistoryPrefix = []byte("alien-fulhistory-") // fulHistoryPrefix + address + number (uint64 big endian) + block id + index (uint32 big endian) -> FulHistoryRecord
+
+	// errFulHistoryDisabled is returned if the FUL history is requested from
+	// a node which doesn't index it.
+	errFulHistoryDisabled = errors.New("FUL history not indexed, enable it with --alien.fulhistory")
+)
+
+// Kinds of FUL history records.
+const (
+	FulHistoryExchange = "exchange" // FUL credited for SDVN
+	FulHistoryTransfer = "transfer" // FUL transferred between addresses
+	FulHistoryFlow     = "flow"     // FUL spent on a flow report record
+)
+
+// FulHistoryRecord is a FUL debit or credit of an address. The report number
+// and device id are only set for flow report records.
+type FulHistoryRecord struct {
+	Number       uint64         `json:"number"`
+	BlockHash    common.Hash    `json:"blockHash" rlp:"-"`
+	Kind         string         `json:"kind"`
+	Debit        bool           `json:"debit"`
+	Amount       *big.Int       `json:"amount"`
+	Counterparty common.Address `json:"counterparty"`
+	ReportNumber uint64         `json:"reportNumber"`
+	DeviceID     string         `json:"deviceId"`
+}
+
+// FulHistory is a page of the FUL history of an address. Next is the block
+// the following page starts at, 0 if the requested range is complete.
+type FulHistory struct {
+	Address common.Address      `json:"address"`
+	Records []*FulHistoryRecord `json:"records"`
+	Next    uint64              `json:"next"`
+}
+
+// fulHistoryEntry is a record of the FUL history of address.
+type fulHistoryEntry struct {
+	address common.Address
+	record  *FulHistoryRecord
+}
+
+// fulHistory collects the FUL debits of the flow report records of a block
+// while its transactions are processed.
+type fulHistory struct {
+	flows []fulHistoryEntry
+}
+
+// addFlow records the FUL consumer spent on a flow report record of miner.
+func (h *fulHistory) addFlow(number uint64, consumer common.Address, miner common.Address, amount *big.Int, reportNumber uint64, deviceID string) {
+	if h == nil {
+		return
+	}
+	h.flows = append(h.flows, fulHistoryEntry{address: consumer, record: &FulHistoryRecord{
+		Number:       number,
+		Kind:         FulHistoryFlow,
+		Debit:        true,
+		Amount:       new(big.Int).Set(amount),
+		Counterparty: miner,
+		ReportNumber: reportNumber,
+		DeviceID:     deviceID,
+	}})
+}
+
+// entries returns the FUL history of the block with headerExtra in the order
+// Snapshot.apply changes the balances.
+func (h *fulHistory) entries(number uint64, headerExtra *HeaderExtra) []fulHistoryEntry {
+	var entries []fulHistoryEntry
+	for _, item := range headerExtra.ExchangeNFC {
+		entries = append(entries, fulHistoryEntry{address: item.Target, record: &FulHistoryRecord{Number: number, Kind: FulHistoryExchange, Amount: item.Amount}})
+	}
+	for _, item := range headerExtra.FulTransfers {
+		entries = append(entries,
+			fulHistoryEntry{address: item.From, record: &FulHistoryRecord{Number: number, Kind: FulHistoryTransfer, Debit: true, Amount: item.Amount, Counterparty: item.To}},
+			fulHistoryEntry{address: item.To, record: &FulHistoryRecord{Number: number, Kind: FulHistoryTransfer, Amount: item.Amount, Counterparty: item.From}},
+		)
+	}
+	return append(entries, h.flows...)
+}
+
+// fulHistoryBlockID identifies the block with parent and the transactions
+// with root txHash, before it is sealed.
+func fulHistoryBlockID(parent common.Hash, txHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(parent.Bytes(), txHash.Bytes())
+}
+
+// fulHistoryIndex stores the FUL history in the database of the engine.
+type fulHistoryIndex struct {
+	db   ethdb.Database
+	lock sync.Mutex // Serializes the blocks indexed while importing and mining
+}
+
+// EnableFulHistory enables the index of the FUL debits and credits of every
+// address from the next block processed on.
+func (a *Alien) EnableFulHistory() {
+	a.fulHistory = &fulHistoryIndex{db: a.db}
+}
+
+// indexFulHistory stores the FUL history of the block with header and txs.
+func (a *Alien) indexFulHistory(header *types.Header, txs []*types.Transaction, history *fulHistory, headerExtra *HeaderExtra) {
+	number := header.Number.Uint64()
+	id := fulHistoryBlockID(header.ParentHash, types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil)))
+	if err := a.fulHistory.store(number, id, history.entries(number, headerExtra)); err != nil {
+		log.Warn("Failed to store FUL history", "number", number, "err", err)
+	}
+}
+
+func fulHistoryKey(address common.Address, number uint64, id common.Hash, index uint32) []byte {
+	key := append(append([]byte{}, fulHistoryPrefix...), address.Bytes()...)
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	key = append(append(key, enc...), id.Bytes()...)
+	binary.BigEndian.PutUint32(enc, index)
+	return append(key, enc[:4]...)
+}
+
+func (h *fulHistoryIndex) store(number uint64, id common.Hash, entries []fulHistoryEntry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	batch := h.db.NewBatch()
+	indexes := make(map[common.Address]uint32)
+	for _, entry := range entries {
+		blob, err := rlp.EncodeToBytes(entry.record)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(fulHistoryKey(entry.address, number, id, indexes[entry.address]), blob); err != nil {
+			return err
+		}
+		indexes[entry.address]++
+	}
+	return batch.Write()
+}
+
+// history returns the FUL history of address from block from to block to,
+// skipping the records of blocks which aren't canonical. canonical returns the
+// id and hash of the canonical block of a height. A page ends with a block, so
+// it may hold more than maxFulHistoryRecords records.
+func (h *fulHistoryIndex) history(address common.Address, from uint64, to uint64, canonical func(number uint64) (common.Hash, common.Hash)) (*FulHistory, error) {
+	prefix := append(append([]byte{}, fulHistoryPrefix...), address.Bytes()...)
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, from)
+	it := h.db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var (
+		page     = &FulHistory{Address: address, Records: []*FulHistoryRecord{}}
+		number   uint64
+		started  bool
+		id, hash common.Hash
+	)
+	for it.Next() {
+		key := it.Key()[len(prefix):]
+		if len(key) != 8+common.HashLength+4 {
+			continue
+		}
+		if n := binary.BigEndian.Uint64(key); !started || n != number {
+			if n > to {
+				break
+			}
+			if len(page.Records) >= maxFulHistoryRecords {
+				page.Next = n
+				break
+			}
+			number, started = n, true
+			id, hash = canonical(n)
+		}
+		if common.BytesToHash(key[8:8+common.HashLength]) != id {
+			continue
+		}
+		record := new(FulHistoryRecord)
+		if err := rlp.DecodeBytes(it.Value(), record); err != nil {
+			return nil, err
+		}
+		record.BlockHash = hash
+		page.Records = append(page.Records, record)
+	}
+	return page, it.Error()
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+)
+
+func TestFulHistory(t *testing.T) {
+	var (
+		consumer = common.Address{0x01}
+		miner    = common.Address{0x02}
+		friend   = common.Address{0x03}
+	)
+	index := &fulHistoryIndex{db: rawdb.NewMemoryDatabase()}
+	blockID := func(number uint64, fork byte) common.Hash {
+		return fulHistoryBlockID(common.Hash{fork}, common.BigToHash(new(big.Int).SetUint64(number)))
+	}
+	canonical := func(number uint64) (common.Hash, common.Hash) {
+		return blockID(number, 0), common.BigToHash(new(big.Int).SetUint64(number + 1000))
+	}
+
+	// A nil collector, as used while the index is disabled, ignores flows
+	var disabled *fulHistory
+	disabled.addFlow(1, consumer, miner, big.NewInt(1), 1, "1")
+
+	history := new(fulHistory)
+	history.addFlow(10, consumer, miner, big.NewInt(300), 9, "12345678901234567890")
+	headerExtra := &HeaderExtra{
+		ExchangeNFC:  []ExchangeNFCRecord{{Target: consumer, Amount: big.NewInt(1000)}},
+		FulTransfers: []FulTransferRecord{{From: consumer, To: friend, Amount: big.NewInt(200)}},
+	}
+	if err := index.store(10, blockID(10, 0), history.entries(10, headerExtra)); err != nil {
+		t.Fatalf("failed to store FUL history: %v", err)
+	}
+	// A competing block at the same height is left out unless it is canonical
+	if err := index.store(10, blockID(10, 1), history.entries(10, &HeaderExtra{})); err != nil {
+		t.Fatalf("failed to store FUL history: %v", err)
+	}
+
+	page, err := index.history(consumer, 0, 100, canonical)
+	if err != nil {
+		t.Fatalf("failed to read FUL history: %v", err)
+	}
+	want := []FulHistoryRecord{
+		{Number: 10, Kind: FulHistoryExchange, Amount: big.NewInt(1000)},
+		{Number: 10, Kind: FulHistoryTransfer, Debit: true, Amount: big.NewInt(200), Counterparty: friend},
+		{Number: 10, Kind: FulHistoryFlow, Debit: true, Amount: big.NewInt(300), Counterparty: miner, ReportNumber: 9, DeviceID: "12345678901234567890"},
+	}
+	if len(page.Records) != len(want) || page.Next != 0 {
+		t.Fatalf("history of consumer: %d records next %d, want %d records next 0", len(page.Records), page.Next, len(want))
+	}
+	for i, record := range page.Records {
+		if record.Number != want[i].Number || record.Kind != want[i].Kind || record.Debit != want[i].Debit || record.Amount.Cmp(want[i].Amount) != 0 ||
+			record.Counterparty != want[i].Counterparty || record.ReportNumber != want[i].ReportNumber || record.DeviceID != want[i].DeviceID {
+			t.Errorf("record %d: have %+v, want %+v", i, record, want[i])
+		}
+		if _, hash := canonical(10); record.BlockHash != hash {
+			t.Errorf("record %d: block hash %x, want %x", i, record.BlockHash, hash)
+		}
+	}
+	if page, _ := index.history(friend, 0, 100, canonical); len(page.Records) != 1 || page.Records[0].Debit || page.Records[0].Counterparty != consumer {
+		t.Errorf("history of friend: %+v", page.Records)
+	}
+	if page, _ := index.history(consumer, 11, 100, canonical); len(page.Records) != 0 {
+		t.Errorf("history after the records: %d records", len(page.Records))
+	}
+
+	// Pages end with the block after which they hold enough records
+	for number := uint64(20); number < 20+maxFulHistoryRecords/100+2; number++ {
+		history := new(fulHistory)
+		for i := 0; i < 100; i++ {
+			history.addFlow(number, miner, consumer, big.NewInt(1), number, "1")
+		}
+		if err := index.store(number, blockID(number, 0), history.entries(number, &HeaderExtra{})); err != nil {
+			t.Fatalf("failed to store FUL history: %v", err)
+		}
+	}
+	var records int
+	for from, pages := uint64(0), 0; ; pages++ {
+		page, err := index.history(miner, from, 1000, canonical)
+		if err != nil {
+			t.Fatalf("failed to read FUL history: %v", err)
+		}
+		if len(page.Records) > maxFulHistoryRecords {
+			t.Errorf("page %d: %d records", pages, len(page.Records))
+		}
+		records += len(page.Records)
+		if page.Next == 0 {
+			if pages != 1 {
+				t.Errorf("history in %d pages, want 2", pages+1)
+			}
+			break
+		}
+		from = page.Next
+	}
+	if want := maxFulHistoryRecords + 200; records != want {
+		t.Errorf("history of miner: %d records, want %d", records, want)
+	}
+}
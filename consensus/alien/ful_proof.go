@@ -0,0 +1,130 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/common/hexutil"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/ethdb/memorydb"
+	"github.com/seaskycheng/sdvn/rlp"
+	"github.com/seaskycheng/sdvn/trie"
+)
+
+// The FulDataRoot of a header is the root of the FUL trie once its parent is
+// applied, so a FUL proof against a header proves the balance an address held
+// when the block started. The trie is keyed by the hash of the address and
+// holds the RLP encoded FulAccount.
+
+var (
+	// errFulTrieInactive is returned if a FUL proof is requested for a block
+	// whose header doesn't commit to a FUL trie.
+	errFulTrieInactive = errors.New("FUL trie not active at block")
+
+	// errFulProofAccount is returned if a FUL proof holds the account of
+	// another address.
+	errFulProofAccount = errors.New("FUL proof of another address")
+)
+
+// FulProof is the proof of the FUL balance of an address against the
+// FulDataRoot of a header, in the style of eth_getProof.
+type FulProof struct {
+	Address     common.Address `json:"address"`
+	Balance     *hexutil.Big   `json:"balance"`
+	Number      uint64         `json:"number"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	FulDataRoot common.Hash    `json:"fulDataRoot"`
+	FulProof    []string       `json:"fulProof"` // Hex encoded trie nodes from the root to the account
+}
+
+// fulProofList collects the trie nodes of a proof as hex strings.
+type fulProofList []string
+
+func (n *fulProofList) Put(key []byte, value []byte) error {
+	*n = append(*n, hexutil.Encode(value))
+	return nil
+}
+
+func (n *fulProofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// newFulProof proves the balance of address in ful, the FUL trie committed to
+// by header.
+func newFulProof(ful FulState, header *types.Header, address common.Address) (*FulProof, error) {
+	var proof fulProofList
+	if err := ful.Prove(address, &proof); err != nil {
+		return nil, err
+	}
+	return &FulProof{
+		Address:     address,
+		Balance:     (*hexutil.Big)(new(big.Int).Set(ful.Get(address))),
+		Number:      header.Number.Uint64(),
+		BlockHash:   header.Hash(),
+		FulDataRoot: ful.Root(),
+		FulProof:    proof,
+	}, nil
+}
+
+// VerifyFulProof checks a FUL proof against the FulDataRoot of a header and
+// returns the balance of address it proves, zero if the proof shows the
+// address has no FUL.
+func VerifyFulProof(root common.Hash, address common.Address, proof []string) (*big.Int, error) {
+	if root == types.EmptyRootHash && len(proof) == 0 {
+		return big.NewInt(0), nil
+	}
+	db := memorydb.New()
+	for i, node := range proof {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %d: %v", i, err)
+		}
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	value, err := trie.VerifyProof(root, crypto.Keccak256(address.Bytes()), db)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return big.NewInt(0), nil
+	}
+	var account FulAccount
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return nil, err
+	}
+	if account.Address != address {
+		return nil, errFulProofAccount
+	}
+	return account.Balance, nil
+}
+
+// Verify checks the proof against the FulDataRoot of a header.
+func (p *FulProof) Verify(root common.Hash) error {
+	balance, err := VerifyFulProof(root, p.Address, p.FulProof)
+	if err != nil {
+		return err
+	}
+	if p.Balance == nil || balance.Cmp(p.Balance.ToInt()) != 0 {
+		return fmt.Errorf("FUL proof balance %v, claimed %v", balance, p.Balance)
+	}
+	return nil
+}
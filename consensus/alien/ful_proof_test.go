@@ -0,0 +1,75 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/common/hexutil"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+)
+
+func TestFulProof(t *testing.T) {
+	ful, err := NewFUL(common.Hash{}, rawdb.NewMemoryDatabase())
+	if err != nil {
+		t.Fatalf("failed to create FUL trie: %v", err)
+	}
+	header := &types.Header{Number: big.NewInt(10)}
+	if proof, err := newFulProof(ful, header, common.Address{0x01}); err != nil {
+		t.Fatalf("failed to prove in the empty trie: %v", err)
+	} else if balance, err := VerifyFulProof(proof.FulDataRoot, proof.Address, proof.FulProof); err != nil || balance.Sign() != 0 {
+		t.Errorf("empty trie: balance %v, err %v", balance, err)
+	}
+	for i := byte(1); i <= 20; i++ {
+		ful.Add(common.Address{i}, big.NewInt(int64(i)*100))
+	}
+	root, err := ful.Save(nil)
+	if err != nil {
+		t.Fatalf("failed to commit FUL trie: %v", err)
+	}
+
+	for _, address := range []common.Address{{0x01}, {0x07}, {0x14}, {0x30}} {
+		proof, err := newFulProof(ful, header, address)
+		if err != nil {
+			t.Fatalf("failed to prove %x: %v", address, err)
+		}
+		if proof.FulDataRoot != root {
+			t.Errorf("proof against %x, want %x", proof.FulDataRoot, root)
+		}
+		if err := proof.Verify(root); err != nil {
+			t.Errorf("proof of %x not verified: %v", address, err)
+		}
+		if balance, _ := VerifyFulProof(root, address, proof.FulProof); balance.Cmp(ful.Get(address)) != 0 {
+			t.Errorf("proven balance of %x %v, want %v", address, balance, ful.Get(address))
+		}
+	}
+
+	proof, _ := newFulProof(ful, header, common.Address{0x07})
+	proof.Balance = (*hexutil.Big)(big.NewInt(1000))
+	if err := proof.Verify(root); err == nil {
+		t.Errorf("claimed balance not checked")
+	}
+	if _, err := VerifyFulProof(common.Hash{0x01}, proof.Address, proof.FulProof); err == nil {
+		t.Errorf("proof verified against another root")
+	}
+	if _, err := VerifyFulProof(root, proof.Address, proof.FulProof[:len(proof.FulProof)-1]); err == nil {
+		t.Errorf("truncated proof verified")
+	}
+}
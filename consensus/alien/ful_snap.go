@@ -17,6 +17,7 @@ type FulState interface {
 	Save(db ethdb.Database) (common.Hash, error)
 	Root() common.Hash
 	GetAll() map[common.Address]*big.Int
+	Prove(addr common.Address, proofDb ethdb.KeyValueWriter) error
 }
 
 func NewFUL(root common.Hash,db ethdb.Database) (FulState,error) {
@@ -0,0 +1,194 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/log"
+)
+
+// Once the FUL trie is active, FUL moves between addresses with NFC:1:FulTrans
+// transactions. An address may also allow a spender, such as a service
+// provider, to move its FUL with NFC:1:FulAppr, which the spender uses with
+// NFC:1:FulFrom. Within a block the balances are tracked along with the FUL
+// spent by flow reports, so a transfer never spends FUL already spent.
+
+// FulAllowance is the FUL each spender may still transfer out of an address.
+type FulAllowance map[common.Address]*big.Int
+
+// FulTransferRecord is a transfer of FUL in a block. Spender is empty unless
+// the transfer was made out of an allowance.
+type FulTransferRecord struct {
+	From    common.Address
+	To      common.Address
+	Spender common.Address
+	Amount  *big.Int
+}
+
+// FulAllowanceRecord is the allowance of a spender after a change in a block.
+type FulAllowanceRecord struct {
+	Owner   common.Address
+	Spender common.Address
+	Amount  *big.Int
+}
+
+// fulAllowance returns the FUL spender may transfer out of owner.
+func (s *Snapshot) fulAllowance(owner common.Address, spender common.Address) *big.Int {
+	if amount, ok := s.FulAllowances[owner][spender]; ok {
+		return amount
+	}
+	return big.NewInt(0)
+}
+
+// setFulAllowance changes the FUL spender may transfer out of owner.
+func (s *Snapshot) setFulAllowance(owner common.Address, spender common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		delete(s.FulAllowances[owner], spender)
+		if len(s.FulAllowances[owner]) == 0 {
+			delete(s.FulAllowances, owner)
+		}
+		return
+	}
+	if s.FulAllowances == nil {
+		s.FulAllowances = make(map[common.Address]FulAllowance)
+	}
+	if _, ok := s.FulAllowances[owner]; !ok {
+		s.FulAllowances[owner] = make(FulAllowance)
+	}
+	s.FulAllowances[owner][spender] = new(big.Int).Set(amount)
+}
+
+func (s *Snapshot) updateFulTransfers(transfers []FulTransferRecord, headerNumber *big.Int) {
+	if !s.config.IsFulTransfer(headerNumber) || s.Ful == nil {
+		return
+	}
+	for _, item := range transfers {
+		if err := s.Ful.Sub(item.From, item.Amount); err != nil {
+			log.Warn("FUL transfer", "from", item.From, "err", err)
+			continue
+		}
+		s.Ful.Add(item.To, item.Amount)
+	}
+}
+
+func (s *Snapshot) updateFulAllowances(allowances []FulAllowanceRecord) {
+	for _, item := range allowances {
+		s.setFulAllowance(item.Owner, item.Spender, item.Amount)
+	}
+}
+
+// fulTransfer moves amount from one address to another in the FUL balances
+// of the block, false if the balance of from is too low.
+func fulTransfer(fulBalances map[common.Address]*big.Int, snap *Snapshot, from common.Address, to common.Address, amount *big.Int) bool {
+	for _, address := range []common.Address{from, to} {
+		if _, ok := fulBalances[address]; !ok {
+			fulBalances[address] = new(big.Int).Set(snap.Ful.Get(address))
+		}
+	}
+	if fulBalances[from].Cmp(amount) < 0 {
+		return false
+	}
+	fulBalances[from] = new(big.Int).Sub(fulBalances[from], amount)
+	fulBalances[to] = new(big.Int).Add(fulBalances[to], amount)
+	return true
+}
+
+func (a *Alien) addFulTransferLog(tx *types.Transaction, receipts []*types.Receipt, from common.Address, to common.Address, amount *big.Int) {
+	topics := make([]common.Hash, 3)
+	topics[0].UnmarshalText([]byte("0x0dffcb14cada38a9e3c87af0489b81eb90b9d2f6135be8561f76f5da32046e9f")) //web3.sha3("FulTransfer(address,address,uint256)")
+	topics[1].SetBytes(from.Bytes())
+	topics[2].SetBytes(to.Bytes())
+	a.addCustomerTxLog(tx, receipts, topics, common.BigToHash(amount).Bytes())
+}
+
+func (a *Alien) addFulApprovalLog(tx *types.Transaction, receipts []*types.Receipt, owner common.Address, spender common.Address, amount *big.Int) {
+	topics := make([]common.Hash, 3)
+	topics[0].UnmarshalText([]byte("0x39ab6d097ec346d40793dd06d9b7b3d3760b92ee018d32d89b4183d0e08c4c8d")) //web3.sha3("FulApproval(address,address,uint256)")
+	topics[1].SetBytes(owner.Bytes())
+	topics[2].SetBytes(spender.Bytes())
+	a.addCustomerTxLog(tx, receipts, topics, common.BigToHash(amount).Bytes())
+}
+
+func (a *Alien) processFulTransfer(currentTransfers []FulTransferRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot, fulBalances map[common.Address]*big.Int) ([]FulTransferRecord, CustomTxReject) {
+	if !isForkedNumber(a.config.IsFulTransfer, number) || snap.Ful == nil {
+		return currentTransfers, RejectNotActive
+	}
+	var payload customtx.FulTransfer
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("FUL transfer", "err", err)
+		return currentTransfers, RejectMalformed
+	}
+	if payload.Amount.Sign() == 0 {
+		log.Warn("FUL transfer", "amount", payload.Amount)
+		return currentTransfers, RejectValueTooLow
+	}
+	if !fulTransfer(fulBalances, snap, txSender, payload.To, payload.Amount) {
+		log.Warn("FUL transfer", "balance", fulBalances[txSender], "amount", payload.Amount)
+		return currentTransfers, RejectFulNotEnough
+	}
+	a.addFulTransferLog(tx, receipts, txSender, payload.To, payload.Amount)
+	return append(currentTransfers, FulTransferRecord{From: txSender, To: payload.To, Amount: payload.Amount}), RejectNone
+}
+
+func (a *Alien) processFulApprove(currentAllowances []FulAllowanceRecord, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot) ([]FulAllowanceRecord, CustomTxReject) {
+	if !isForkedNumber(a.config.IsFulTransfer, number) || snap.Ful == nil {
+		return currentAllowances, RejectNotActive
+	}
+	var payload customtx.FulApprove
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("FUL approve", "err", err)
+		return currentAllowances, RejectMalformed
+	}
+	snap.setFulAllowance(txSender, payload.Spender, payload.Amount)
+	a.addFulApprovalLog(tx, receipts, txSender, payload.Spender, payload.Amount)
+	return append(currentAllowances, FulAllowanceRecord{Owner: txSender, Spender: payload.Spender, Amount: payload.Amount}), RejectNone
+}
+
+func (a *Alien) processFulTransferFrom(headerExtra *HeaderExtra, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, number uint64, snap *Snapshot, fulBalances map[common.Address]*big.Int) CustomTxReject {
+	if !isForkedNumber(a.config.IsFulTransfer, number) || snap.Ful == nil {
+		return RejectNotActive
+	}
+	var payload customtx.FulTransferFrom
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("FUL transfer from", "err", err)
+		return RejectMalformed
+	}
+	if payload.Amount.Sign() == 0 {
+		log.Warn("FUL transfer from", "amount", payload.Amount)
+		return RejectValueTooLow
+	}
+	allowance := snap.fulAllowance(payload.From, txSender)
+	if allowance.Cmp(payload.Amount) < 0 {
+		log.Warn("FUL transfer from", "allowance", allowance, "amount", payload.Amount)
+		return RejectAllowanceExceeded
+	}
+	if !fulTransfer(fulBalances, snap, payload.From, payload.To, payload.Amount) {
+		log.Warn("FUL transfer from", "balance", fulBalances[payload.From], "amount", payload.Amount)
+		return RejectFulNotEnough
+	}
+	remaining := new(big.Int).Sub(allowance, payload.Amount)
+	snap.setFulAllowance(payload.From, txSender, remaining)
+	a.addFulTransferLog(tx, receipts, payload.From, payload.To, payload.Amount)
+	a.addFulApprovalLog(tx, receipts, payload.From, txSender, remaining)
+	headerExtra.FulTransfers = append(headerExtra.FulTransfers, FulTransferRecord{From: payload.From, To: payload.To, Spender: txSender, Amount: payload.Amount})
+	headerExtra.FulAllowances = append(headerExtra.FulAllowances, FulAllowanceRecord{Owner: payload.From, Spender: txSender, Amount: remaining})
+	return RejectNone
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func TestFulTransfer(t *testing.T) {
+	owner, spender, to1, to2 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}, common.Address{0x04}
+	config := &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), FulTransferBlock: big.NewInt(0)}
+	db := rawdb.NewMemoryDatabase()
+	alien := New(config, db)
+	defer alien.Close()
+
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	ful, err := NewFUL(common.Hash{}, db)
+	if err != nil {
+		t.Fatalf("failed to create FUL trie: %v", err)
+	}
+	snap.Ful = ful
+	snap.Ful.Add(owner, big.NewInt(100))
+	snapCache := snap.copy()
+	fulBalances := make(map[common.Address]*big.Int)
+
+	send := func(nonce uint64, payload customtx.Payload) (*types.Transaction, []*types.Receipt) {
+		tx := types.NewTransaction(nonce, owner, big.NewInt(0), 0, big.NewInt(0), payload.Encode())
+		return tx, []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}}
+	}
+	var headerExtra HeaderExtra
+	transfer := func(nonce uint64, to common.Address, amount int64) CustomTxReject {
+		tx, receipts := send(nonce, &customtx.FulTransfer{To: to, Amount: big.NewInt(amount)})
+		var reject CustomTxReject
+		headerExtra.FulTransfers, reject = alien.processFulTransfer(headerExtra.FulTransfers, tx.Data(), owner, tx, receipts, 10, snapCache, fulBalances)
+		if reject == RejectNone && len(receipts[0].Logs) != 1 {
+			t.Errorf("transfer not logged")
+		}
+		return reject
+	}
+	transferFrom := func(nonce uint64, to common.Address, amount int64) CustomTxReject {
+		tx, receipts := send(nonce, &customtx.FulTransferFrom{From: owner, To: to, Amount: big.NewInt(amount)})
+		return alien.processFulTransferFrom(&headerExtra, tx.Data(), spender, tx, receipts, 10, snapCache, fulBalances)
+	}
+	if reject := transfer(0, to1, 0); reject != RejectValueTooLow {
+		t.Errorf("transferred nothing: %v", reject)
+	}
+	if reject := transfer(0, to1, 30); reject != RejectNone {
+		t.Fatalf("transfer rejected: %v", reject)
+	}
+	if reject := transfer(1, to1, 80); reject != RejectFulNotEnough {
+		t.Errorf("transferred more than the balance: %v", reject)
+	}
+	if reject := transferFrom(2, to2, 10); reject != RejectAllowanceExceeded {
+		t.Errorf("transferred without an allowance: %v", reject)
+	}
+	approve, receipts := send(2, &customtx.FulApprove{Spender: spender, Amount: big.NewInt(50)})
+	headerExtra.FulAllowances, _ = alien.processFulApprove(headerExtra.FulAllowances, approve.Data(), owner, approve, receipts, 10, snapCache)
+	if reject := transferFrom(3, to2, 60); reject != RejectAllowanceExceeded {
+		t.Errorf("transferred more than the allowance: %v", reject)
+	}
+	if reject := transferFrom(3, to2, 40); reject != RejectNone {
+		t.Fatalf("transfer from rejected: %v", reject)
+	}
+	if reject := transfer(4, to1, 31); reject != RejectFulNotEnough {
+		t.Errorf("transferred FUL already spent in the block: %v", reject)
+	}
+	if allowance := snapCache.fulAllowance(owner, spender); allowance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("allowance left %v, want 10", allowance)
+	}
+
+	// The snapshot of the block moves the same balances
+	snap.updateFulTransfers(headerExtra.FulTransfers, big.NewInt(10))
+	snap.updateFulAllowances(headerExtra.FulAllowances)
+	for address, want := range map[common.Address]int64{owner: 30, to1: 30, to2: 40} {
+		if balance := snap.Ful.Get(address); balance.Cmp(big.NewInt(want)) != 0 {
+			t.Errorf("balance of %x: have %v, want %v", address, balance, want)
+		}
+	}
+	if allowance := snap.fulAllowance(owner, spender); allowance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("allowance of the snapshot %v, want 10", allowance)
+	}
+	snap.updateFulAllowances([]FulAllowanceRecord{{Owner: owner, Spender: spender, Amount: big.NewInt(0)}})
+	if len(snap.FulAllowances) != 0 {
+		t.Errorf("withdrawn allowance kept: %v", snap.FulAllowances)
+	}
+}
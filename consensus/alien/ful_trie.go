@@ -3,6 +3,7 @@ package alien
 import (
 	"errors"
 	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/crypto"
 	"github.com/seaskycheng/sdvn/ethdb"
 	"github.com/seaskycheng/sdvn/log"
 	"github.com/seaskycheng/sdvn/rlp"
@@ -198,6 +199,12 @@ func (s *FulTrie) Root() common.Hash {
 	return s.Hash()
 }
 
+// Prove writes the trie nodes proving the account of addr, or its absence, to
+// proofDb.
+func (s *FulTrie) Prove(addr common.Address, proofDb ethdb.KeyValueWriter) error {
+	return s.trie.Prove(crypto.Keccak256(addr.Bytes()), 0, proofDb)
+}
+
 func (s *FulTrie) GetAll() map[common.Address]*big.Int {
 	found := make(map[common.Address]*big.Int)
 	it := trie.NewIterator(s.trie.NodeIterator(nil))
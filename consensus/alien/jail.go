@@ -0,0 +1,90 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+const maxJailHistory = 16 // Jail records kept for each signer
+
+// JailRecord is one stay of a signer in jail.
+type JailRecord struct {
+	Number   uint64 `json:"number"`   // Block the signer was jailed at
+	Credit   uint64 `json:"credit"`   // Punished credit which jailed the signer
+	Release  uint64 `json:"release"`  // First block the signer may be unjailed at
+	Unjailed uint64 `json:"unjailed"` // Block the signer was unjailed at, zero while jailed
+}
+
+// jailRecord returns the record of the current jail of signer, nil if it isn't
+// jailed.
+func (s *Snapshot) jailRecord(signer common.Address) *JailRecord {
+	records := s.Jails[signer]
+	if len(records) == 0 || records[len(records)-1].Unjailed != 0 {
+		return nil
+	}
+	return records[len(records)-1]
+}
+
+// isJailed returns whether signer is left out of the signer queue.
+func (s *Snapshot) isJailed(signer common.Address) bool {
+	return s.jailRecord(signer) != nil
+}
+
+// jailEnabled returns whether the economics of config jail signers, which
+// enables both jailing and unjailing.
+func jailEnabled(config *params.AlienConfig) bool {
+	return economics(config).JailCredit > 0
+}
+
+// updateJailed jails the signers which missed a block at number and whose
+// credit reached the JailCredit of the economics. A jailed signer is left out
+// of the signer queue until its operator sends an unjail custom transaction,
+// which is accepted once the JailPeriod is over.
+func (s *Snapshot) updateJailed(signerMissing []common.Address, number uint64) {
+	if !jailEnabled(s.config) {
+		return
+	}
+	jailCredit := economics(s.config).JailCredit
+	for _, signer := range signerMissing {
+		if s.Punished[signer] < jailCredit || s.isJailed(signer) {
+			continue
+		}
+		if s.Jails == nil {
+			s.Jails = make(map[common.Address][]*JailRecord)
+		}
+		records := append(s.Jails[signer], &JailRecord{
+			Number:  number,
+			Credit:  s.Punished[signer],
+			Release: number + economics(s.config).JailPeriod/s.config.Period,
+		})
+		if len(records) > maxJailHistory {
+			records = records[len(records)-maxJailHistory:]
+		}
+		s.Jails[signer] = records
+	}
+}
+
+// unjail releases signer at number. Its punished credit is cleared as well, the
+// jail period is the penalty paid for it.
+func (s *Snapshot) unjail(signer common.Address, number uint64) {
+	if record := s.jailRecord(signer); record != nil {
+		record.Unjailed = number
+		delete(s.Punished, signer)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+// newJailTestConfig returns a config jailing signers at credit 60 for 30
+// blocks.
+func newJailTestConfig() *params.AlienConfig {
+	economics := params.DefaultAlienEconomics
+	economics.JailCredit = 60
+	economics.JailPeriod = 90
+	return &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), Economics: &economics}
+}
+
+func newJailTestSnapshot(config *params.AlienConfig, signers ...common.Address) *Snapshot {
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	for _, signer := range signers {
+		snap.Tally[signer] = big.NewInt(1)
+		snap.Candidates[signer] = candidateStateNormal
+	}
+	return snap
+}
+
+func TestJailSigner(t *testing.T) {
+	signer1, signer2 := common.Address{0x01}, common.Address{0x02}
+	snap := newJailTestSnapshot(newJailTestConfig(), signer1, signer2)
+
+	// A credit below the jail credit only weighs on the election
+	snap.Punished[signer1] = 30
+	snap.updateJailed([]common.Address{signer1}, 10)
+	if snap.isJailed(signer1) {
+		t.Fatalf("signer jailed below the jail credit")
+	}
+	snap.Punished[signer1] = 60
+	snap.updateJailed([]common.Address{signer1}, 11)
+	record := snap.jailRecord(signer1)
+	if record == nil || record.Number != 11 || record.Credit != 60 || record.Release != 41 {
+		t.Fatalf("unexpected jail record %+v", record)
+	}
+	snap.updateJailed([]common.Address{signer1}, 12)
+	if len(snap.Jails[signer1]) != 1 {
+		t.Errorf("jailed signer jailed again")
+	}
+	tally := snap.buildTallySlice()
+	if len(tally) != 1 || tally[0].addr != signer2 {
+		t.Errorf("jailed signer left in the election: %v", tally)
+	}
+	// A copy doesn't share the records unjailed later
+	cpy := snap.copy()
+	snap.unjail(signer1, 41)
+	if snap.isJailed(signer1) || snap.Punished[signer1] != 0 || snap.Jails[signer1][0].Unjailed != 41 {
+		t.Errorf("signer not unjailed: %+v", snap.Jails[signer1][0])
+	}
+	if !cpy.isJailed(signer1) {
+		t.Errorf("unjail changed the copied snapshot")
+	}
+	// Jailed signers still seal if nobody else is left
+	snap.Punished[signer1], snap.Punished[signer2] = 60, 60
+	snap.updateJailed([]common.Address{signer1, signer2}, 50)
+	if tally := snap.buildTallySlice(); len(tally) != 2 {
+		t.Errorf("signer queue emptied by jail: %v", tally)
+	}
+	if len(snap.Jails[signer1]) != 2 {
+		t.Errorf("jail history not kept: %d records", len(snap.Jails[signer1]))
+	}
+}
+
+func TestProcessUnjail(t *testing.T) {
+	signer, other := common.Address{0x01}, common.Address{0x02}
+	config := newJailTestConfig()
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	snap := newJailTestSnapshot(config, signer)
+	snap.Punished[signer] = 60
+	snap.updateJailed([]common.Address{signer}, 10)
+
+	payload := &customtx.Unjail{Target: signer}
+	tx := types.NewTransaction(0, signer, big.NewInt(0), 0, big.NewInt(0), payload.Encode())
+	receipts := []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(40)}}
+
+	// Unjailing is only active while the economics jail signers
+	disabled := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), DoubleSignBlock: big.NewInt(0)}, rawdb.NewMemoryDatabase())
+	defer disabled.Close()
+	if _, reject := disabled.processUnjail(nil, tx.Data(), signer, tx, receipts, nil, 40, snap); reject != RejectNotActive {
+		t.Errorf("unjailed without jail credit: %v", reject)
+	}
+	if _, reject := alien.processUnjail(nil, tx.Data(), other, tx, receipts, nil, 40, snap); reject != RejectNotRevenueOwner {
+		t.Errorf("unjailed by another account: %v", reject)
+	}
+	if _, reject := alien.processUnjail(nil, tx.Data(), signer, tx, receipts, nil, 39, snap); reject != RejectJailPeriod {
+		t.Errorf("unjailed within the jail period: %v", reject)
+	}
+	records, reject := alien.processUnjail(nil, tx.Data(), signer, tx, receipts, nil, 40, snap)
+	if reject != RejectNone {
+		t.Fatalf("unjail rejected: %v", reject)
+	}
+	if len(records) != 1 || records[0] != signer || snap.isJailed(signer) {
+		t.Fatalf("signer not unjailed: %v", records)
+	}
+	if len(receipts[0].Logs) != 1 {
+		t.Errorf("unjail not logged")
+	}
+	if _, reject := alien.processUnjail(records, tx.Data(), signer, tx, receipts, nil, 40, snap); reject != RejectNotJailed {
+		t.Errorf("signer unjailed twice: %v", reject)
+	}
+
+	// The snapshot applies the unjail recorded in the header the same way
+	applied := newJailTestSnapshot(config, signer)
+	applied.Punished[signer] = 60
+	applied.updateJailed([]common.Address{signer}, 10)
+	applied.updateCandidateUnjail(records, big.NewInt(40))
+	if applied.isJailed(signer) || applied.Jails[signer][0].Unjailed != 40 {
+		t.Errorf("unjail not applied to the snapshot")
+	}
+}
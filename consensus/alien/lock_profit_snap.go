@@ -8,6 +8,7 @@ import (
 	"github.com/seaskycheng/sdvn/core/types"
 	"github.com/seaskycheng/sdvn/ethdb"
 	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/params"
 	"github.com/seaskycheng/sdvn/rlp"
 	"math/big"
 	"time"
@@ -324,9 +325,9 @@ func (s *LockData) appendRlsLockData(rlsLockBalance map[common.Address]*RlsLockD
 		lockBalance[item.PledgeType] = item
 	}
 }
-func (snap *LockProfitSnap) updateMergeLockData( db ethdb.Database,period uint64,hash common.Hash) error {
+func (snap *LockProfitSnap) updateMergeLockData( db ethdb.Database,blockPerDay uint64,hash common.Hash) error {
 	log.Info("begin merge lockdata")
-	err := snap.RewardLock.mergeLockData(db,period,hash)
+	err := snap.RewardLock.mergeLockData(db,blockPerDay,hash)
 	if err == nil {
 		log.Info("updateMergeLockData","merge lockdata successful err=",err)
 	}else{
@@ -334,7 +335,7 @@ func (snap *LockProfitSnap) updateMergeLockData( db ethdb.Database,period uint64
 	}
 	return err
 }
-func (s *LockData) mergeLockData(db ethdb.Database,period uint64,hash common.Hash) error{
+func (s *LockData) mergeLockData(db ethdb.Database,blockPerDay uint64,hash common.Hash) error{
 	rlsLockBalance := make(map[common.Address]*RlsLockData)
 	items := []*PledgeItem{}
 	for _, pledges := range s.FlowRevenue {
@@ -358,7 +359,6 @@ func (s *LockData) mergeLockData(db ethdb.Database,period uint64,hash common.Has
 	}
 	s.appendRlsLockData(rlsLockBalance, items)
 	mergeRlsLkBalance := make(map[common.Hash]*RlsLockData)
-	blockPerDay := secondsPerDay / period
 	for  _,rlsLockData := range rlsLockBalance {
 		for  lockNumber,pledgeItem := range rlsLockData.LockBalance {
 			bnumber :=  blockPerDay * (lockNumber / blockPerDay)+1
@@ -539,7 +539,7 @@ func (s *LockProfitSnap) updateLockData(snap *Snapshot, LockReward []LockRewardR
 	blockNumber := headerNumber.Uint64()
 	for _, item := range LockReward {
 		if sscEnumSignerReward == item.IsReward {
-			if islockSimplifyEffectBlocknumber(blockNumber) {
+			if islockSimplifyEffectBlocknumber(snap.config, blockNumber) {
 				s.RewardLock.addLockData(snap, item, headerNumber)
 			} else {
 				s.RewardLock.updateLockData(snap, item, headerNumber)
@@ -550,7 +550,7 @@ func (s *LockProfitSnap) updateLockData(snap *Snapshot, LockReward []LockRewardR
 			s.BandwidthLock.updateLockData(snap, item, headerNumber)
 		}
 	}
-	if islockSimplifyEffectBlocknumber(blockNumber) {
+	if islockSimplifyEffectBlocknumber(snap.config, blockNumber) {
 		blockPerDay := snap.getBlockPreDay()
 		if 0 == blockNumber%blockPerDay && blockNumber != 0 {
 			s.RewardLock.updateAllLockData(snap, sscEnumSignerReward, headerNumber)
@@ -558,27 +558,27 @@ func (s *LockProfitSnap) updateLockData(snap *Snapshot, LockReward []LockRewardR
 	}
 }
 
-func (s *LockProfitSnap) payProfit(db ethdb.Database, period uint64, headerNumber uint64, currentGrantProfit []consensus.GrantProfitRecord, playGrantProfit []consensus.GrantProfitRecord, header *types.Header, state *state.StateDB,payAddressAll map[common.Address]*big.Int) ([]consensus.GrantProfitRecord, []consensus.GrantProfitRecord, error) {
+func (s *LockProfitSnap) payProfit(db ethdb.Database, config *params.AlienConfig, headerNumber uint64, currentGrantProfit []consensus.GrantProfitRecord, playGrantProfit []consensus.GrantProfitRecord, header *types.Header, state *state.StateDB,payAddressAll map[common.Address]*big.Int) ([]consensus.GrantProfitRecord, []consensus.GrantProfitRecord, error) {
 	number := header.Number.Uint64()
 	if number == 0 {
 		return currentGrantProfit, playGrantProfit, nil
 	}
-	if isPaySignerRewards(number, period) {
+	if isPaySignerRewards(number, config) {
 		log.Info("LockProfitSnap pay reward profit")
-		return s.RewardLock.payProfit(s.Hash, db, period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
+		return s.RewardLock.payProfit(s.Hash, db, config.Period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
 	}
-	if isPayFlowRewards(number, period) {
+	if isPayFlowRewards(number, config) {
 		log.Info("LockProfitSnap pay flow profit")
-		return s.FlowLock.payProfit(s.Hash, db, period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
+		return s.FlowLock.payProfit(s.Hash, db, config.Period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
 	}
-	if isPayBandWidthRewards(number, period) {
+	if isPayBandWidthRewards(number, config) {
 		log.Info("LockProfitSnap pay bandwidth profit")
-		return s.BandwidthLock.payProfit(s.Hash, db, period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
+		return s.BandwidthLock.payProfit(s.Hash, db, config.Period, headerNumber, currentGrantProfit, playGrantProfit, header, state,payAddressAll)
 	}
 	return currentGrantProfit, playGrantProfit, nil
 }
 
-func (snap *LockProfitSnap) updateGrantProfit(grantProfit []consensus.GrantProfitRecord, db ethdb.Database, headerHash common.Hash, number uint64) {
+func (snap *LockProfitSnap) updateGrantProfit(config *params.AlienConfig, grantProfit []consensus.GrantProfitRecord, db ethdb.Database, headerHash common.Hash, number uint64) {
 	shouldUpdateReward, shouldUpdateFlow, shouldUpdateBandwidth := false, false, false
 	for _, item := range grantProfit {
 		if 0 != item.BlockNumber {
@@ -592,7 +592,7 @@ func (snap *LockProfitSnap) updateGrantProfit(grantProfit []consensus.GrantProfi
 		}
 	}
 	storeHash:=snap.Hash
-	if isGeFulTrieNumber(number){
+	if isGeFulTrieNumber(config, number){
 		storeHash=headerHash
 	}
 	if shouldUpdateReward {
@@ -0,0 +1,480 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/common/hexutil"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/state"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/crypto"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/params"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// An owner of a multi-signature address proposes to move funds from it or to
+// send a custom transaction as it with a NFC:1:MultiProp transaction. The
+// owners signing it, through the extra signatures of a multi-signer
+// transaction, approve the proposal at once; others approve it later with
+// NFC:1:MultiAppr transactions. The proposal is executed by the transaction
+// bringing its approvals to the threshold of the address. A transaction whose
+// execution fails is ignored, so its approvals may be sent again once the
+// execution can succeed.
+//
+// The owners and the threshold of the address are changed the same way, by
+// proposing a NFC:1:MultiAdd, NFC:1:MultiDel or NFC:1:MultiThr action. They may
+// also be sent in a multi-signer transaction signed by enough owners.
+const (
+	maxMultiSigPending       = 16               // Pending proposals of each multi-signature address
+	multiSigProposalLifetime = 7 * 24 * 60 * 60 // Time a proposal collects approvals before it expires, in seconds
+
+	minMultiSigThreshold = 2   // Lowest threshold of a multi-signature address
+	maxMultiSigThreshold = 10  // Highest threshold of a multi-signature address
+	maxMultiSigOwners    = 999 // Most owners of a multi-signature address, which has more owners than its threshold
+)
+
+// errUnknownMultiSigProposal is returned if a multi-signature proposal isn't
+// collecting approvals.
+var errUnknownMultiSigProposal = errors.New("unknown multi-signature proposal")
+
+// MultiSigProposal is a pending proposal of a multi-signature address.
+type MultiSigProposal struct {
+	Hash      common.Hash      `json:"hash"` // Hash of the proposing transaction
+	MultiSig  common.Address   `json:"multisig"`
+	Proposer  common.Address   `json:"proposer"`
+	To        common.Address   `json:"to"`
+	Amount    *big.Int         `json:"amount"`
+	Action    hexutil.Bytes    `json:"action"`    // Custom transaction sent as the multi-signature address, may be empty
+	Number    uint64           `json:"number"`    // Block the proposal was sent at
+	Approvals []common.Address `json:"approvals"` // Owners which approved the proposal
+}
+
+func (p *MultiSigProposal) copy() *MultiSigProposal {
+	cpy := *p
+	cpy.Amount = new(big.Int).Set(p.Amount)
+	cpy.Action = append(hexutil.Bytes{}, p.Action...)
+	cpy.Approvals = append([]common.Address{}, p.Approvals...)
+	return &cpy
+}
+
+// MultiSigProposeRecord is a proposal sent in a block, it is only kept pending
+// if it isn't executed at once.
+type MultiSigProposeRecord struct {
+	Proposal MultiSigProposal
+	Executed bool
+}
+
+// MultiSigApproveRecord is an approval of a pending proposal sent in a block.
+type MultiSigApproveRecord struct {
+	Hash      common.Hash
+	Approvals []common.Address // Owners approving the proposal in the transaction
+	Executed  bool
+}
+
+// multiSignatureData returns the owners and the threshold of a multi-signature
+// address, false if address isn't one.
+func multiSignatureData(state *state.StateDB, address common.Address) (*consensus.MultiSignatureData, bool) {
+	if state.Empty(address) {
+		return nil, false
+	}
+	contractHash := state.GetCodeHash(address)
+	if state.GetNonce(address) != 1 || contractHash == (common.Hash{}) || contractHash == crypto.Keccak256Hash(nil) {
+		return nil, false
+	}
+	var parameter consensus.MultiSignatureData
+	if err := rlp.DecodeBytes(state.GetCode(address), &parameter); nil != err {
+		return nil, false
+	}
+	return &parameter, true
+}
+
+// multiSigApprovers returns the owners of parameter among the signers of tx,
+// in the order they signed.
+func multiSigApprovers(parameter *consensus.MultiSignatureData, tx *types.Transaction, txSender common.Address) []common.Address {
+	owners := make(map[common.Address]bool)
+	for _, owner := range parameter.MultiSigners {
+		owners[owner] = true
+	}
+	var approvers []common.Address
+	for _, signer := range append([]common.Address{txSender}, tx.AllSigners()...) {
+		if owners[signer] {
+			approvers = append(approvers, signer)
+			delete(owners, signer)
+		}
+	}
+	return approvers
+}
+
+// countMultiSigApprovals returns the approvals given by owners still listed in
+// parameter.
+func countMultiSigApprovals(parameter *consensus.MultiSignatureData, approvals []common.Address) int {
+	count := 0
+	for _, approval := range approvals {
+		for _, owner := range parameter.MultiSigners {
+			if approval == owner {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// multiSigActionAllowed returns whether a custom transaction of kind may be
+// sent as a multi-signature address. Kinds depending on the recipient or the
+// value of the transaction can't.
+func multiSigActionAllowed(kind customtx.Kind) bool {
+	switch kind {
+	case customtx.KindExchange, customtx.KindBind, customtx.KindUnbind, customtx.KindRebind,
+		customtx.KindCandidatePledge, customtx.KindCandidateExit, customtx.KindMinerPledge, customtx.KindMinerExit,
+		customtx.KindUnjail, customtx.KindMultiSignAdd, customtx.KindMultiSignRemove, customtx.KindMultiSignRequire:
+		return true
+	}
+	return false
+}
+
+// pendingMultiSig returns the proposal with the given hash if it still collects
+// approvals at number.
+func (s *Snapshot) pendingMultiSig(hash common.Hash, number uint64) *MultiSigProposal {
+	proposal, ok := s.MultiSigPending[hash]
+	if !ok || number > proposal.Number+multiSigProposalLifetime/s.config.Period {
+		return nil
+	}
+	return proposal
+}
+
+// multiSigPending returns the pending proposals of multiSig, oldest first.
+func (s *Snapshot) multiSigPending(multiSig common.Address) []*MultiSigProposal {
+	var pending []*MultiSigProposal
+	for _, proposal := range s.MultiSigPending {
+		if proposal.MultiSig == multiSig {
+			pending = append(pending, proposal)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Number != pending[j].Number {
+			return pending[i].Number < pending[j].Number
+		}
+		return hashLess(pending[i].Hash, pending[j].Hash)
+	})
+	return pending
+}
+
+func (s *Snapshot) updateMultiSigProposals(records []MultiSigProposeRecord, headerNumber *big.Int) {
+	for _, record := range records {
+		if record.Executed {
+			continue
+		}
+		proposal := record.Proposal.copy()
+		proposal.Number = headerNumber.Uint64()
+		if s.MultiSigPending == nil {
+			s.MultiSigPending = make(map[common.Hash]*MultiSigProposal)
+		}
+		s.MultiSigPending[proposal.Hash] = proposal
+	}
+}
+
+func (s *Snapshot) updateMultiSigApprovals(records []MultiSigApproveRecord) {
+	for _, record := range records {
+		proposal, ok := s.MultiSigPending[record.Hash]
+		if !ok {
+			continue
+		}
+		if record.Executed {
+			delete(s.MultiSigPending, record.Hash)
+		} else {
+			proposal.Approvals = append(proposal.Approvals, record.Approvals...)
+		}
+	}
+}
+
+// updateMultiSigExpired removes the proposals which stopped collecting
+// approvals at headerNumber.
+func (s *Snapshot) updateMultiSigExpired(headerNumber *big.Int) {
+	for hash := range s.MultiSigPending {
+		if s.pendingMultiSig(hash, headerNumber.Uint64()) == nil {
+			delete(s.MultiSigPending, hash)
+		}
+	}
+}
+
+// executeMultiSig moves the amount of a proposal and sends its action as the
+// multi-signature address. Nothing is changed if either fails.
+func (a *Alien) executeMultiSig(proposal *MultiSigProposal, headerExtra *HeaderExtra, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64, snap *Snapshot, snapCache *Snapshot) CustomTxReject {
+	if state.GetBalance(proposal.MultiSig).Cmp(proposal.Amount) < 0 {
+		log.Warn("Multi-signature execute", "balance", state.GetBalance(proposal.MultiSig))
+		return RejectInsufficientBalance
+	}
+	revert, saved := state.Snapshot(), *headerExtra
+	state.SubBalance(proposal.MultiSig, proposal.Amount)
+	state.AddBalance(proposal.To, proposal.Amount)
+	if len(proposal.Action) > 0 {
+		savedSnap, logs := snapCache.copy(), customTxLogCount(tx, receipts)
+		if reject := a.applyMultiSigAction(proposal.Action, proposal.MultiSig, headerExtra, tx, receipts, state, number, snap, snapCache); reject != RejectNone {
+			log.Warn("Multi-signature execute", "action", customtx.Identify(proposal.Action), "reject", reject)
+			state.RevertToSnapshot(revert)
+			*headerExtra = saved
+			*snapCache = *savedSnap
+			revertCustomTxLogs(tx, receipts, logs)
+			return reject
+		}
+	}
+	topics := make([]common.Hash, 3)
+	topics[0].UnmarshalText([]byte("0xea5ed975503b712161e1dd2da4acf3e34d2e437e7aa51eb062f9f527a8160911")) //web3.sha3("MultiSigExecuted(address,bytes32)")
+	topics[1].SetBytes(proposal.MultiSig.Bytes())
+	topics[2] = proposal.Hash
+	a.addCustomerTxLog(tx, receipts, topics, nil)
+	return RejectNone
+}
+
+// applyMultiSigAction processes the custom transaction txData sent as multiSig.
+func (a *Alien) applyMultiSigAction(txData []byte, multiSig common.Address, headerExtra *HeaderExtra, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64, snap *Snapshot, snapCache *Snapshot) CustomTxReject {
+	reject := RejectInvalidAction
+	switch customtx.Identify(txData) {
+	case customtx.KindExchange:
+		headerExtra.ExchangeNFC, reject = a.processExchangeNFC(headerExtra.ExchangeNFC, txData, multiSig, tx, receipts, state, snap)
+	case customtx.KindBind:
+		headerExtra.DeviceBind, reject = a.processDeviceBind(headerExtra.DeviceBind, txData, multiSig, tx, receipts, snapCache)
+	case customtx.KindUnbind:
+		headerExtra.DeviceBind, reject = a.processDeviceUnbind(headerExtra.DeviceBind, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindRebind:
+		headerExtra.DeviceBind, reject = a.processDeviceRebind(headerExtra.DeviceBind, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindCandidatePledge:
+		headerExtra.CandidatePledge, reject = a.processCandidatePledge(headerExtra.CandidatePledge, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindCandidateExit:
+		headerExtra.CandidateExit, reject = a.processCandidateExit(headerExtra.CandidateExit, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindMinerPledge:
+		headerExtra.ClaimedBandwidth, reject = a.processMinerPledge(headerExtra.ClaimedBandwidth, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindMinerExit:
+		headerExtra.FlowMinerExit, reject = a.processMinerExit(headerExtra.FlowMinerExit, txData, multiSig, tx, receipts, state, snapCache)
+	case customtx.KindUnjail:
+		headerExtra.CandidateUnjail, reject = a.processUnjail(headerExtra.CandidateUnjail, txData, multiSig, tx, receipts, state, number, snapCache)
+	case customtx.KindMultiSignAdd, customtx.KindMultiSignRemove, customtx.KindMultiSignRequire:
+		reject = a.processMultiSigChange(txData, multiSig, tx, receipts, state, number)
+	}
+	return reject
+}
+
+func (a *Alien) processMultiSigPropose(headerExtra *HeaderExtra, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64, snap *Snapshot, snapCache *Snapshot) CustomTxReject {
+	if !isForkedNumber(a.config.IsMultiSig, number) {
+		return RejectNotActive
+	}
+	var payload customtx.MultiSignPropose
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Multi-signature propose", "err", err)
+		return RejectMalformed
+	}
+	parameter, ok := multiSignatureData(state, payload.MultiSign)
+	if !ok {
+		log.Warn("Multi-signature propose", "not multi-signature", payload.MultiSign)
+		return RejectNotMultiSignature
+	}
+	approvals := multiSigApprovers(parameter, tx, txSender)
+	if len(approvals) == 0 || approvals[0] != txSender {
+		log.Warn("Multi-signature propose", "not owner", txSender)
+		return RejectNotOwner
+	}
+	if len(payload.Action) > 0 && !multiSigActionAllowed(customtx.Identify(payload.Action)) {
+		log.Warn("Multi-signature propose", "action", customtx.Identify(payload.Action))
+		return RejectInvalidAction
+	}
+	if len(snapCache.multiSigPending(payload.MultiSign)) >= maxMultiSigPending {
+		log.Warn("Multi-signature propose", "pending", maxMultiSigPending)
+		return RejectTooManyProposals
+	}
+	record := MultiSigProposeRecord{
+		Proposal: MultiSigProposal{
+			Hash:      tx.Hash(),
+			MultiSig:  payload.MultiSign,
+			Proposer:  txSender,
+			To:        payload.To,
+			Amount:    payload.Amount,
+			Action:    payload.Action,
+			Number:    number,
+			Approvals: approvals,
+		},
+	}
+	if len(approvals) >= int(parameter.Threshold) {
+		if reject := a.executeMultiSig(&record.Proposal, headerExtra, tx, receipts, state, number, snap, snapCache); reject != RejectNone {
+			return reject
+		}
+		record.Executed = true
+	} else {
+		if snapCache.MultiSigPending == nil {
+			snapCache.MultiSigPending = make(map[common.Hash]*MultiSigProposal)
+		}
+		snapCache.MultiSigPending[record.Proposal.Hash] = record.Proposal.copy()
+	}
+	topics := make([]common.Hash, 4)
+	topics[0].UnmarshalText([]byte("0x7df73f00caa787641832b379bcccd420f735a2706ebd6fe1c88713aefc0c9132")) //web3.sha3("MultiSigProposed(address,bytes32,address)")
+	topics[1].SetBytes(payload.MultiSign.Bytes())
+	topics[2] = record.Proposal.Hash
+	topics[3].SetBytes(txSender.Bytes())
+	a.addCustomerTxLog(tx, receipts, topics, nil)
+	headerExtra.MultiSigProposals = append(headerExtra.MultiSigProposals, record)
+	return RejectNone
+}
+
+func (a *Alien) processMultiSigApprove(headerExtra *HeaderExtra, txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64, snap *Snapshot, snapCache *Snapshot) CustomTxReject {
+	if !isForkedNumber(a.config.IsMultiSig, number) {
+		return RejectNotActive
+	}
+	var payload customtx.MultiSignApprove
+	if err := payload.Decode(txData); err != nil {
+		log.Warn("Multi-signature approve", "err", err)
+		return RejectMalformed
+	}
+	proposal := snapCache.pendingMultiSig(payload.Proposal, number)
+	if proposal == nil {
+		log.Warn("Multi-signature approve", "unknown proposal", payload.Proposal)
+		return RejectUnknownProposal
+	}
+	parameter, ok := multiSignatureData(state, proposal.MultiSig)
+	if !ok {
+		log.Warn("Multi-signature approve", "not multi-signature", proposal.MultiSig)
+		return RejectNotMultiSignature
+	}
+	approvers := multiSigApprovers(parameter, tx, txSender)
+	if len(approvers) == 0 || approvers[0] != txSender {
+		log.Warn("Multi-signature approve", "not owner", txSender)
+		return RejectNotOwner
+	}
+	approved := make(map[common.Address]bool)
+	for _, approval := range proposal.Approvals {
+		approved[approval] = true
+	}
+	record := MultiSigApproveRecord{Hash: proposal.Hash}
+	for _, approver := range approvers {
+		if !approved[approver] {
+			record.Approvals = append(record.Approvals, approver)
+		}
+	}
+	if len(record.Approvals) == 0 {
+		log.Warn("Multi-signature approve", "already approved by", txSender)
+		return RejectAlreadyApproved
+	}
+	approvals := append(append([]common.Address{}, proposal.Approvals...), record.Approvals...)
+	if countMultiSigApprovals(parameter, approvals) >= int(parameter.Threshold) {
+		if reject := a.executeMultiSig(proposal, headerExtra, tx, receipts, state, number, snap, snapCache); reject != RejectNone {
+			return reject
+		}
+		record.Executed = true
+		delete(snapCache.MultiSigPending, proposal.Hash)
+	} else {
+		proposal.Approvals = approvals
+	}
+	for _, approver := range record.Approvals {
+		topics := make([]common.Hash, 4)
+		topics[0].UnmarshalText([]byte("0x7a9ff62115d9e0afa217967094571ae80c4cbcb19758e19ccb477e28d00506f1")) //web3.sha3("MultiSigApproved(address,bytes32,address)")
+		topics[1].SetBytes(proposal.MultiSig.Bytes())
+		topics[2] = proposal.Hash
+		topics[3].SetBytes(approver.Bytes())
+		a.addCustomerTxLog(tx, receipts, topics, nil)
+	}
+	headerExtra.MultiSigApprovals = append(headerExtra.MultiSigApprovals, record)
+	return RejectNone
+}
+
+// processMultiSigChange adds or removes an owner of a multi-signature address
+// or changes its threshold. The transaction is sent as the address by an
+// executed proposal, or signed by enough of its owners.
+func (a *Alien) processMultiSigChange(txData []byte, txSender common.Address, tx *types.Transaction, receipts []*types.Receipt, state *state.StateDB, number uint64) CustomTxReject {
+	if !isForkedNumber(a.config.IsMultiSig, number) {
+		return RejectNotActive
+	}
+	payload, err := customtx.Decode(txData)
+	if err != nil {
+		log.Warn("Multi-signature change", "err", err)
+		return RejectMalformed
+	}
+	var multiSig common.Address
+	switch payload := payload.(type) {
+	case *customtx.MultiSignAdd:
+		multiSig = payload.MultiSign
+	case *customtx.MultiSignRemove:
+		multiSig = payload.MultiSign
+	case *customtx.MultiSignRequire:
+		multiSig = payload.MultiSign
+	}
+	parameter, ok := multiSignatureData(state, multiSig)
+	if !ok {
+		log.Warn("Multi-signature change", "not multi-signature", multiSig)
+		return RejectNotMultiSignature
+	}
+	if txSender != multiSig && !a.verifyMultiSignatureAddress(state, multiSig, tx.AllSigners()) {
+		log.Warn("Multi-signature change failed to verify multi-signature")
+		return RejectMultiSignature
+	}
+	owners := make(map[common.Address]bool)
+	for _, owner := range parameter.MultiSigners {
+		owners[owner] = true
+	}
+	topics := make([]common.Hash, 3)
+	topics[1].SetBytes(multiSig.Bytes())
+	switch payload := payload.(type) {
+	case *customtx.MultiSignAdd:
+		if owners[payload.Owner] || len(parameter.MultiSigners) >= maxMultiSigOwners {
+			log.Warn("Multi-signature add owner", "owner", payload.Owner, "owner number", len(parameter.MultiSigners))
+			return RejectInvalidOwners
+		}
+		parameter.MultiSigners = append(parameter.MultiSigners, payload.Owner)
+		topics[0].UnmarshalText([]byte("0x5de3191ba628c4203b89a298ed7b73b7f8c0cc5e4e8c32167bbd92ca9c06441b")) //web3.sha3("MultiSigOwnerAdded(address,address)")
+		topics[2].SetBytes(payload.Owner.Bytes())
+	case *customtx.MultiSignRemove:
+		if !owners[payload.Owner] || len(parameter.MultiSigners) <= int(parameter.Threshold)+1 {
+			log.Warn("Multi-signature remove owner", "owner", payload.Owner, "owner number", len(parameter.MultiSigners), "threshold", parameter.Threshold)
+			return RejectInvalidOwners
+		}
+		remaining := make([]common.Address, 0, len(parameter.MultiSigners)-1)
+		for _, owner := range parameter.MultiSigners {
+			if owner != payload.Owner {
+				remaining = append(remaining, owner)
+			}
+		}
+		parameter.MultiSigners = remaining
+		topics[0].UnmarshalText([]byte("0x144cddb4d4f45965a6368500e31a7d00e4872ba2d87fd414afbd5c655849487c")) //web3.sha3("MultiSigOwnerRemoved(address,address)")
+		topics[2].SetBytes(payload.Owner.Bytes())
+	case *customtx.MultiSignRequire:
+		threshold := payload.Threshold
+		if threshold < minMultiSigThreshold || threshold > maxMultiSigThreshold || len(parameter.MultiSigners) <= int(threshold) {
+			log.Warn("Multi-signature change threshold", "threshold", threshold, "owner number", len(parameter.MultiSigners))
+			return RejectInvalidThreshold
+		}
+		parameter.Threshold = threshold
+		topics[0].UnmarshalText([]byte("0x6d37869408d2b50d6c6382ecc452e3c8ce42c59d4c4361f1e7340b05502567c6")) //web3.sha3("MultiSigThresholdChanged(address,uint256)")
+		topics[2].SetBytes(big.NewInt(int64(threshold)).Bytes())
+	}
+	data, err := rlp.EncodeToBytes(parameter)
+	if err != nil {
+		log.Warn("Multi-signature change", "err", err)
+		return RejectMalformed
+	}
+	if len(data) > params.MaxCodeSize {
+		log.Warn("Multi-signature change failed for max code size exceeded")
+		return RejectCodeSizeExceeded
+	}
+	state.SetCode(multiSig, data)
+	a.addCustomerTxLog(tx, receipts, topics, nil)
+	return RejectNone
+}
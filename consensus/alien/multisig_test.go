@@ -0,0 +1,271 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/state"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// newMultiSigTestState returns a state holding the multi-signature address
+// multiSig with a balance of 1000 and the given owners and threshold.
+func newMultiSigTestState(t *testing.T, multiSig common.Address, threshold uint32, owners ...common.Address) *state.StateDB {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	code, err := rlp.EncodeToBytes(consensus.MultiSignatureData{Threshold: threshold, MultiSigners: owners})
+	if err != nil {
+		t.Fatalf("failed to encode owners: %v", err)
+	}
+	statedb.CreateAccount(multiSig)
+	statedb.SetNonce(multiSig, 1)
+	statedb.SetCode(multiSig, code)
+	statedb.AddBalance(multiSig, big.NewInt(1000))
+	return statedb
+}
+
+func TestMultiSigTransfer(t *testing.T) {
+	multiSig, to, outsider := common.Address{0x10}, common.Address{0x20}, common.Address{0x30}
+	owner1, owner2, owner3 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	config := &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), MultiSigBlock: big.NewInt(0)}
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	statedb := newMultiSigTestState(t, multiSig, 2, owner1, owner2, owner3)
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	snapCache := snap.copy()
+
+	send := func(nonce uint64, payload customtx.Payload) (*types.Transaction, []*types.Receipt) {
+		tx := types.NewTransaction(nonce, multiSig, big.NewInt(0), 0, big.NewInt(0), payload.Encode())
+		return tx, []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}}
+	}
+	var headerExtra HeaderExtra
+	propose, receipts := send(0, &customtx.MultiSignPropose{MultiSign: multiSig, To: to, Amount: big.NewInt(100)})
+	if reject := alien.processMultiSigPropose(&headerExtra, propose.Data(), outsider, propose, receipts, statedb, 10, snap, snapCache); reject != RejectNotOwner {
+		t.Errorf("proposed by an outsider: %v", reject)
+	}
+	vote, _ := send(0, &customtx.MultiSignPropose{MultiSign: multiSig, To: to, Amount: big.NewInt(0), Action: (&customtx.Vote{}).Encode()})
+	if reject := alien.processMultiSigPropose(&headerExtra, vote.Data(), owner1, vote, receipts, statedb, 10, snap, snapCache); reject != RejectInvalidAction {
+		t.Errorf("proposed a vote: %v", reject)
+	}
+	if reject := alien.processMultiSigPropose(&headerExtra, propose.Data(), owner1, propose, receipts, statedb, 10, snap, snapCache); reject != RejectNone {
+		t.Fatalf("proposal rejected: %v", reject)
+	}
+	if len(headerExtra.MultiSigProposals) != 1 || headerExtra.MultiSigProposals[0].Executed {
+		t.Fatalf("unexpected proposals %+v", headerExtra.MultiSigProposals)
+	}
+	if len(receipts[0].Logs) != 1 {
+		t.Errorf("proposal not logged")
+	}
+	pending := snapCache.pendingMultiSig(propose.Hash(), 10)
+	if pending == nil || len(pending.Approvals) != 1 || pending.Approvals[0] != owner1 {
+		t.Fatalf("unexpected pending proposal %+v", pending)
+	}
+
+	approval := &customtx.MultiSignApprove{Proposal: propose.Hash()}
+	approve, receipts := send(1, approval)
+	if reject := alien.processMultiSigApprove(&headerExtra, approve.Data(), owner1, approve, receipts, statedb, 11, snap, snapCache); reject != RejectAlreadyApproved {
+		t.Errorf("approved twice: %v", reject)
+	}
+	if reject := alien.processMultiSigApprove(&headerExtra, approve.Data(), owner2, approve, receipts, statedb, 11, snap, snapCache); reject != RejectNone {
+		t.Fatalf("approval rejected: %v", reject)
+	}
+	if statedb.GetBalance(multiSig).Cmp(big.NewInt(900)) != 0 || statedb.GetBalance(to).Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("amount not transferred: %v left, %v received", statedb.GetBalance(multiSig), statedb.GetBalance(to))
+	}
+	if len(headerExtra.MultiSigApprovals) != 1 || !headerExtra.MultiSigApprovals[0].Executed {
+		t.Fatalf("unexpected approvals %+v", headerExtra.MultiSigApprovals)
+	}
+	if len(receipts[0].Logs) != 2 {
+		t.Errorf("approval and execution logged %d times", len(receipts[0].Logs))
+	}
+	if reject := alien.processMultiSigApprove(&headerExtra, approve.Data(), owner3, approve, receipts, statedb, 11, snap, snapCache); reject != RejectUnknownProposal {
+		t.Errorf("approved an executed proposal: %v", reject)
+	}
+
+	// The snapshot of the block only keeps the proposals still collecting
+	// approvals, until they expire
+	snap.updateMultiSigProposals(headerExtra.MultiSigProposals, big.NewInt(10))
+	if snap.pendingMultiSig(propose.Hash(), 10) == nil {
+		t.Fatalf("proposal not pending")
+	}
+	snap.updateMultiSigApprovals(headerExtra.MultiSigApprovals)
+	if len(snap.MultiSigPending) != 0 {
+		t.Errorf("executed proposal still pending")
+	}
+	snap.updateMultiSigProposals(headerExtra.MultiSigProposals, big.NewInt(10))
+	expiry := 10 + multiSigProposalLifetime/config.Period
+	snap.updateMultiSigExpired(new(big.Int).SetUint64(expiry))
+	if len(snap.multiSigPending(multiSig)) != 1 {
+		t.Errorf("proposal expired early")
+	}
+	snap.updateMultiSigExpired(new(big.Int).SetUint64(expiry + 1))
+	if len(snap.multiSigPending(multiSig)) != 0 {
+		t.Errorf("proposal not expired")
+	}
+}
+
+func TestMultiSigAction(t *testing.T) {
+	multiSig, to, candidate := common.Address{0x10}, common.Address{0x20}, common.Address{0x40}
+	owner1, owner2, owner3 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	config := &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), MultiSigBlock: big.NewInt(0)}
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	statedb := newMultiSigTestState(t, multiSig, 2, owner1, owner2, owner3)
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	snap.RevenueNormal[candidate] = &RevenueParameter{RevenueAddress: multiSig}
+	snapCache := snap.copy()
+
+	exit := (&customtx.CandidateExit{Target: candidate}).Encode()
+	propose := types.NewTransaction(0, multiSig, big.NewInt(0), 0, big.NewInt(0), (&customtx.MultiSignPropose{MultiSign: multiSig, To: to, Amount: big.NewInt(100), Action: exit}).Encode())
+	approve := types.NewTransaction(1, multiSig, big.NewInt(0), 0, big.NewInt(0), (&customtx.MultiSignApprove{Proposal: propose.Hash()}).Encode())
+	receipts := []*types.Receipt{
+		{TxHash: propose.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)},
+		{TxHash: approve.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)},
+	}
+	var headerExtra HeaderExtra
+	if reject := alien.processMultiSigPropose(&headerExtra, propose.Data(), owner1, propose, receipts, statedb, 10, snap, snapCache); reject != RejectNone {
+		t.Fatalf("proposal rejected: %v", reject)
+	}
+	// The candidate hasn't pledged, so the action fails and nothing changes
+	if reject := alien.processMultiSigApprove(&headerExtra, approve.Data(), owner2, approve, receipts, statedb, 10, snap, snapCache); reject != RejectNotPledged {
+		t.Fatalf("failing action approved: %v", reject)
+	}
+	if statedb.GetBalance(multiSig).Cmp(big.NewInt(1000)) != 0 || statedb.GetBalance(to).Sign() != 0 {
+		t.Errorf("transfer of a failed execution not reverted")
+	}
+	if len(headerExtra.MultiSigApprovals) != 0 || len(headerExtra.CandidateExit) != 0 || len(receipts[1].Logs) != 0 {
+		t.Errorf("failed execution recorded")
+	}
+	if pending := snapCache.pendingMultiSig(propose.Hash(), 10); pending == nil || len(pending.Approvals) != 1 {
+		t.Fatalf("failed approval counted: %+v", pending)
+	}
+
+	snapCache.CandidatePledge[candidate] = NewPledgeItem(big.NewInt(1))
+	if reject := alien.processMultiSigApprove(&headerExtra, approve.Data(), owner2, approve, receipts, statedb, 10, snap, snapCache); reject != RejectNone {
+		t.Fatalf("approval rejected: %v", reject)
+	}
+	if len(headerExtra.CandidateExit) != 1 || headerExtra.CandidateExit[0] != candidate {
+		t.Errorf("action not executed: %v", headerExtra.CandidateExit)
+	}
+	if statedb.GetBalance(to).Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("amount not transferred: %v", statedb.GetBalance(to))
+	}
+}
+
+func TestRevertCustomTxLogs(t *testing.T) {
+	alien := &Alien{}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	receipts := []*types.Receipt{{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}}
+	topics := []common.Hash{{0x01}}
+	alien.addCustomerTxLog(tx, receipts, topics, nil)
+	bloom, count := receipts[0].Bloom, customTxLogCount(tx, receipts)
+
+	alien.addCustomerTxLog(tx, receipts, []common.Hash{{0x02}}, nil)
+	alien.addCustomerTxLog(tx, receipts, []common.Hash{{0x03}}, nil)
+	revertCustomTxLogs(tx, receipts, count)
+	if len(receipts[0].Logs) != 1 || receipts[0].Logs[0].Topics[0] != topics[0] {
+		t.Errorf("logs not reverted: %v", receipts[0].Logs)
+	}
+	if receipts[0].Bloom != bloom {
+		t.Errorf("bloom not reverted")
+	}
+}
+
+func TestMultiSigOwners(t *testing.T) {
+	multiSig, to := common.Address{0x10}, common.Address{0x20}
+	owner1, owner2, owner3, owner4 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}, common.Address{0x04}
+	config := &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), MultiSigBlock: big.NewInt(0)}
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	statedb := newMultiSigTestState(t, multiSig, 2, owner1, owner2, owner3)
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	snapCache := snap.copy()
+
+	var (
+		headerExtra HeaderExtra
+		nonce       uint64
+	)
+	// execute proposes the action with owner1 and approves it with owner2
+	execute := func(action customtx.Payload) CustomTxReject {
+		propose := types.NewTransaction(nonce, multiSig, big.NewInt(0), 0, big.NewInt(0), (&customtx.MultiSignPropose{MultiSign: multiSig, To: to, Amount: big.NewInt(0), Action: action.Encode()}).Encode())
+		approve := types.NewTransaction(nonce+1, multiSig, big.NewInt(0), 0, big.NewInt(0), (&customtx.MultiSignApprove{Proposal: propose.Hash()}).Encode())
+		nonce += 2
+		receipts := []*types.Receipt{
+			{TxHash: propose.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)},
+			{TxHash: approve.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)},
+		}
+		if reject := alien.processMultiSigPropose(&headerExtra, propose.Data(), owner1, propose, receipts, statedb, 10, snap, snapCache); reject != RejectNone {
+			return reject
+		}
+		return alien.processMultiSigApprove(&headerExtra, approve.Data(), owner2, approve, receipts, statedb, 10, snap, snapCache)
+	}
+	owners := func() []common.Address {
+		parameter, ok := multiSignatureData(statedb, multiSig)
+		if !ok {
+			t.Fatalf("multi-signature address lost")
+		}
+		return parameter.MultiSigners
+	}
+
+	if reject := execute(&customtx.MultiSignAdd{MultiSign: multiSig, Owner: owner4}); reject != RejectNone {
+		t.Fatalf("owner not added: %v", reject)
+	}
+	if have := owners(); len(have) != 4 || have[3] != owner4 {
+		t.Fatalf("unexpected owners %v", have)
+	}
+	if !alien.verifyMultiSignatureAddress(statedb, multiSig, []common.Address{owner4, owner3}) {
+		t.Errorf("added owner not honored")
+	}
+	if reject := execute(&customtx.MultiSignAdd{MultiSign: multiSig, Owner: owner4}); reject != RejectInvalidOwners {
+		t.Errorf("owner added twice: %v", reject)
+	}
+
+	// A change sent directly needs the signatures of enough owners
+	require := (&customtx.MultiSignRequire{MultiSign: multiSig, Threshold: 3}).Encode()
+	direct := types.NewTransaction(nonce, multiSig, big.NewInt(0), 0, big.NewInt(0), require)
+	if reject := alien.processMultiSigChange(require, owner1, direct, nil, statedb, 10); reject != RejectMultiSignature {
+		t.Errorf("threshold changed by a single owner: %v", reject)
+	}
+	if reject := execute(&customtx.MultiSignRequire{MultiSign: multiSig, Threshold: 4}); reject != RejectInvalidThreshold {
+		t.Errorf("threshold raised to the owner number: %v", reject)
+	}
+	if reject := execute(&customtx.MultiSignRemove{MultiSign: multiSig, Owner: owner3}); reject != RejectNone {
+		t.Fatalf("owner not removed: %v", reject)
+	}
+	if alien.verifyMultiSignatureAddress(statedb, multiSig, []common.Address{owner4, owner3}) {
+		t.Errorf("removed owner still honored")
+	}
+	if reject := execute(&customtx.MultiSignRemove{MultiSign: multiSig, Owner: owner4}); reject != RejectInvalidOwners {
+		t.Errorf("owners reduced to the threshold: %v", reject)
+	}
+	if have := owners(); len(have) != 3 || have[0] != owner1 || have[1] != owner2 || have[2] != owner4 {
+		t.Errorf("unexpected owners %v", have)
+	}
+}
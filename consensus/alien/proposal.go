@@ -0,0 +1,282 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus"
+	"github.com/seaskycheng/sdvn/core/types"
+)
+
+// A proposal is concluded once its validation loops are over. It passes if the
+// tally of the candidates declaring yes exceeds two thirds of the whole tally,
+// and is rejected otherwise. The snapshot keeps the latest concluded proposals
+// so they can still be looked up after leaving the proposals.
+const maxProposalResults = 64 // Concluded proposals kept in the snapshot
+
+// errUnknownProposal is returned if a proposal is neither active nor kept as
+// concluded.
+var errUnknownProposal = errors.New("unknown proposal")
+
+// Statuses of a proposal.
+const (
+	proposalStatusActive   = "active"
+	proposalStatusPassed   = "passed"
+	proposalStatusRejected = "rejected"
+)
+
+// Types of the proposal events.
+const (
+	proposalEventCreated  = "created"
+	proposalEventDeclared = "declared"
+)
+
+// ProposalResult is a concluded proposal with the stakes it was judged by.
+type ProposalResult struct {
+	Proposal      *Proposal `json:"proposal"`
+	Number        uint64    `json:"number"`        // Block the proposal was concluded at
+	Passed        bool      `json:"passed"`        // Whether the yes stake exceeded the required stake
+	YesStake      *big.Int  `json:"yesStake"`      // Tally of the candidates which declared yes
+	NoStake       *big.Int  `json:"noStake"`       // Tally of the candidates which declared no
+	RequiredStake *big.Int  `json:"requiredStake"` // Yes stake to exceed for the proposal to pass
+}
+
+// ProposalStatus is a proposal with its declare tallies, as returned by the
+// proposal RPCs. The stakes of an active proposal are the ones it would be
+// judged by if the tally stayed as it is.
+type ProposalStatus struct {
+	Proposal        *Proposal `json:"proposal"`
+	Status          string    `json:"status"` // One of active, passed and rejected
+	YesCount        int       `json:"yesCount"`
+	NoCount         int       `json:"noCount"`
+	YesStake        *big.Int  `json:"yesStake"`
+	NoStake         *big.Int  `json:"noStake"`
+	RequiredStake   *big.Int  `json:"requiredStake"`
+	EndNumber       uint64    `json:"endNumber"`       // Block the proposal is concluded at
+	RemainingBlocks uint64    `json:"remainingBlocks"` // Blocks left before the conclusion, zero once concluded
+}
+
+// ProposalEvent is a change of a proposal in an imported block.
+type ProposalEvent struct {
+	Type      string          `json:"type"` // One of created, declared, passed and rejected
+	Number    uint64          `json:"number"`
+	BlockHash common.Hash     `json:"blockHash"`
+	Proposal  common.Hash     `json:"proposal"`
+	Declare   *Declare        `json:"declare,omitempty"` // New declare of a declared event
+	Status    *ProposalStatus `json:"status"`            // Proposal after the block
+}
+
+// proposalEnd returns the block a proposal is concluded at.
+func (s *Snapshot) proposalEnd(proposal *Proposal) uint64 {
+	return proposal.ReceivedNumber.Uint64() + proposal.ValidationLoopCnt*s.config.MaxSignerCount + 1
+}
+
+// proposalStakes returns the tally of the candidates which declared yes and no
+// on a proposal, and the yes stake it has to exceed to pass.
+func (s *Snapshot) proposalStakes(proposal *Proposal) (yes *big.Int, no *big.Int, required *big.Int) {
+	required = big.NewInt(0)
+	for _, tally := range s.Tally {
+		required.Add(required, tally)
+	}
+	required.Mul(required, big.NewInt(2))
+	required.Div(required, big.NewInt(3))
+
+	yes, no = big.NewInt(0), big.NewInt(0)
+	for _, declare := range proposal.Declares {
+		tally, ok := s.Tally[declare.Declarer]
+		if !ok {
+			continue
+		}
+		if declare.Decision {
+			yes.Add(yes, tally)
+		} else {
+			no.Add(no, tally)
+		}
+	}
+	return yes, no, required
+}
+
+// recordProposalResult keeps a proposal concluded at number, dropping the
+// oldest results beyond maxProposalResults.
+func (s *Snapshot) recordProposalResult(proposal *Proposal, number uint64, yes *big.Int, no *big.Int, required *big.Int) {
+	s.ProposalResults = append(s.ProposalResults, &ProposalResult{
+		Proposal:      proposal.copy(),
+		Number:        number,
+		Passed:        yes.Cmp(required) > 0,
+		YesStake:      new(big.Int).Set(yes),
+		NoStake:       new(big.Int).Set(no),
+		RequiredStake: new(big.Int).Set(required),
+	})
+	// Proposals concluded at the same block are kept by hash, not in the
+	// random order of the proposals map
+	sort.SliceStable(s.ProposalResults, func(i, j int) bool {
+		a, b := s.ProposalResults[i], s.ProposalResults[j]
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		return hashLess(a.Proposal.Hash, b.Proposal.Hash)
+	})
+	if len(s.ProposalResults) > maxProposalResults {
+		s.ProposalResults = s.ProposalResults[len(s.ProposalResults)-maxProposalResults:]
+	}
+}
+
+func countDeclares(proposal *Proposal) (yes int, no int) {
+	for _, declare := range proposal.Declares {
+		if declare.Decision {
+			yes++
+		} else {
+			no++
+		}
+	}
+	return yes, no
+}
+
+// activeProposalStatus returns the status of a proposal not concluded yet.
+func (s *Snapshot) activeProposalStatus(proposal *Proposal) *ProposalStatus {
+	status := &ProposalStatus{
+		Proposal:  proposal.copy(),
+		Status:    proposalStatusActive,
+		EndNumber: s.proposalEnd(proposal),
+	}
+	status.YesCount, status.NoCount = countDeclares(proposal)
+	status.YesStake, status.NoStake, status.RequiredStake = s.proposalStakes(proposal)
+	if status.EndNumber > s.Number {
+		status.RemainingBlocks = status.EndNumber - s.Number
+	}
+	return status
+}
+
+// concludedProposalStatus returns the status of a concluded proposal.
+func concludedProposalStatus(result *ProposalResult) *ProposalStatus {
+	status := &ProposalStatus{
+		Proposal:      result.Proposal.copy(),
+		Status:        proposalStatusRejected,
+		YesStake:      new(big.Int).Set(result.YesStake),
+		NoStake:       new(big.Int).Set(result.NoStake),
+		RequiredStake: new(big.Int).Set(result.RequiredStake),
+		EndNumber:     result.Number,
+	}
+	if result.Passed {
+		status.Status = proposalStatusPassed
+	}
+	status.YesCount, status.NoCount = countDeclares(result.Proposal)
+	return status
+}
+
+// proposalStatuses returns the active and the kept concluded proposals with
+// the given status, or all of them if status is empty, by end block.
+func (s *Snapshot) proposalStatuses(status string) ([]*ProposalStatus, error) {
+	switch status {
+	case "", proposalStatusActive, proposalStatusPassed, proposalStatusRejected:
+	default:
+		return nil, fmt.Errorf("unknown proposal status %q", status)
+	}
+	var statuses []*ProposalStatus
+	for _, result := range s.ProposalResults {
+		if item := concludedProposalStatus(result); status == "" || item.Status == status {
+			statuses = append(statuses, item)
+		}
+	}
+	if status == "" || status == proposalStatusActive {
+		for _, proposal := range s.Proposals {
+			statuses = append(statuses, s.activeProposalStatus(proposal))
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		if statuses[i].EndNumber != statuses[j].EndNumber {
+			return statuses[i].EndNumber < statuses[j].EndNumber
+		}
+		return hashLess(statuses[i].Proposal.Hash, statuses[j].Proposal.Hash)
+	})
+	return statuses, nil
+}
+
+// proposalStatus returns the status of the proposal with the given hash, nil if
+// it is neither active nor kept as concluded.
+func (s *Snapshot) proposalStatus(hash common.Hash) *ProposalStatus {
+	if proposal, ok := s.Proposals[hash]; ok {
+		return s.activeProposalStatus(proposal)
+	}
+	for i := len(s.ProposalResults) - 1; i >= 0; i-- {
+		if s.ProposalResults[i].Proposal.Hash == hash {
+			return concludedProposalStatus(s.ProposalResults[i])
+		}
+	}
+	return nil
+}
+
+// proposalEvents returns the changes of the proposals between the snapshot of
+// a block and the one of its parent.
+func proposalEvents(parent *Snapshot, snap *Snapshot) []*ProposalEvent {
+	var events []*ProposalEvent
+	event := func(kind string, hash common.Hash, declare *Declare, status *ProposalStatus) {
+		events = append(events, &ProposalEvent{Type: kind, Number: snap.Number, BlockHash: snap.Hash, Proposal: hash, Declare: declare, Status: status})
+	}
+	hashes := make([]common.Hash, 0, len(snap.Proposals))
+	for hash := range snap.Proposals {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashLess(hashes[i], hashes[j]) })
+	for _, hash := range hashes {
+		proposal := snap.Proposals[hash]
+		status := snap.activeProposalStatus(proposal)
+		known := 0
+		if previous, ok := parent.Proposals[hash]; ok {
+			known = len(previous.Declares)
+		} else {
+			event(proposalEventCreated, hash, nil, status)
+		}
+		for _, declare := range proposal.Declares[known:] {
+			event(proposalEventDeclared, hash, declare, status)
+		}
+	}
+	for _, result := range snap.ProposalResults {
+		if result.Number != snap.Number {
+			continue
+		}
+		if _, ok := parent.Proposals[result.Proposal.Hash]; !ok {
+			continue
+		}
+		status := concludedProposalStatus(result)
+		event(status.Status, result.Proposal.Hash, nil, status)
+	}
+	return events
+}
+
+// ProposalEvents returns the proposals created, declared and concluded in the
+// block of the given header.
+func (a *Alien) ProposalEvents(chain consensus.ChainHeaderReader, header *types.Header) ([]*ProposalEvent, error) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, nil
+	}
+	parent, err := a.snapshot(chain, number-1, header.ParentHash, nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := a.snapshot(chain, number, header.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+	if err != nil {
+		return nil, err
+	}
+	return proposalEvents(parent, snap), nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+// applyProposalBlock applies the proposals and declares of block number to a
+// copy of parent, the way the snapshot does.
+func applyProposalBlock(parent *Snapshot, number uint64, proposals []Proposal, declares []Declare) *Snapshot {
+	snap := parent.copy()
+	snap.Number = number
+	snap.Hash = common.BigToHash(new(big.Int).SetUint64(number))
+	snap.updateSnapshotByProposals(proposals, new(big.Int).SetUint64(number))
+	snap.updateSnapshotByDeclares(declares, new(big.Int).SetUint64(number))
+	snap.calculateProposalResult(new(big.Int).SetUint64(number))
+	return snap
+}
+
+func TestProposalLifecycle(t *testing.T) {
+	var (
+		candidate1 = common.Address{0x01}
+		candidate2 = common.Address{0x02}
+		target     = common.Address{0x03}
+		passing    = common.Hash{0x01}
+		failing    = common.Hash{0x02}
+	)
+	config := &params.AlienConfig{Period: 3, MaxSignerCount: 2, MinVoterBalance: new(big.Int)}
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	snap.Tally[candidate1] = big.NewInt(70)
+	snap.Tally[candidate2] = big.NewInt(30)
+	snap.Candidates[candidate1] = candidateStateNormal
+	snap.Candidates[candidate2] = candidateStateNormal
+
+	proposal := func(hash common.Hash) Proposal {
+		return Proposal{Hash: hash, ValidationLoopCnt: 2, ProposalType: proposalTypeCandidateAdd, TargetAddress: target, CurrentDeposit: big.NewInt(0), Declares: []*Declare{}}
+	}
+	// A proposal received at block n is concluded at n + 2*2 + 1. They are sent
+	// in two blocks, as proposals of the same block share the one applied last.
+	block9 := applyProposalBlock(snap, 9, []Proposal{proposal(passing)}, nil)
+	block10 := applyProposalBlock(block9, 10, []Proposal{proposal(failing)}, nil)
+	events := proposalEvents(block9, block10)
+	if len(events) != 1 || events[0].Type != proposalEventCreated || events[0].Proposal != failing {
+		t.Fatalf("unexpected events of the proposal: %v", events)
+	}
+	if status := events[0].Status; status.EndNumber != 15 || status.RemainingBlocks != 5 || status.RequiredStake.Cmp(big.NewInt(66)) != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	block11 := applyProposalBlock(block10, 11, nil, []Declare{
+		{ProposalHash: passing, Declarer: candidate1, Decision: true},
+		{ProposalHash: failing, Declarer: candidate1, Decision: false},
+		{ProposalHash: failing, Declarer: candidate2, Decision: true},
+	})
+	events = proposalEvents(block10, block11)
+	if len(events) != 3 || events[0].Type != proposalEventDeclared || events[0].Declare.Declarer != candidate1 {
+		t.Fatalf("unexpected events of the declares: %v", events)
+	}
+	status := block11.proposalStatus(failing)
+	if status.YesCount != 1 || status.NoCount != 1 || status.YesStake.Cmp(big.NewInt(30)) != 0 || status.NoStake.Cmp(big.NewInt(70)) != 0 {
+		t.Errorf("unexpected tally %+v", status)
+	}
+	if active, _ := block11.proposalStatuses(proposalStatusActive); len(active) != 2 {
+		t.Errorf("have %d active proposals, want 2", len(active))
+	}
+
+	block14 := applyProposalBlock(block11, 14, nil, nil)
+	events = proposalEvents(block11, block14)
+	if len(events) != 1 || events[0].Type != proposalStatusPassed || events[0].Proposal != passing {
+		t.Fatalf("unexpected events of the first conclusion: %v", events)
+	}
+	if status := block14.proposalStatus(failing); status.RemainingBlocks != 1 {
+		t.Errorf("have %d remaining blocks, want 1", status.RemainingBlocks)
+	}
+	if _, ok := block14.Candidates[target]; !ok {
+		t.Errorf("passed proposal not applied")
+	}
+	block15 := applyProposalBlock(block14, 15, nil, nil)
+	events = proposalEvents(block14, block15)
+	if len(events) != 1 || events[0].Type != proposalStatusRejected || events[0].Proposal != failing {
+		t.Fatalf("unexpected events of the second conclusion: %v", events)
+	}
+	for status, want := range map[string]int{"": 2, proposalStatusActive: 0, proposalStatusPassed: 1, proposalStatusRejected: 1} {
+		if statuses, err := block15.proposalStatuses(status); err != nil || len(statuses) != want {
+			t.Errorf("have %d %q proposals, want %d (err %v)", len(statuses), status, want, err)
+		}
+	}
+	if _, err := block15.proposalStatuses("expired"); err == nil {
+		t.Errorf("unknown status accepted")
+	}
+	if status := block15.proposalStatus(passing); status == nil || status.Status != proposalStatusPassed || status.YesCount != 1 {
+		t.Errorf("unexpected concluded status %+v", status)
+	}
+	// Concluded results are carried over without new events
+	if events := proposalEvents(block15, applyProposalBlock(block15, 16, nil, nil)); len(events) != 0 {
+		t.Errorf("unexpected events after the conclusion: %v", events)
+	}
+}
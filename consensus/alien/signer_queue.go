@@ -89,9 +89,10 @@ func (s *Snapshot) verifySignerQueue(signerQueue []common.Address) error {
 }
 
 func (s *Snapshot) buildTallySlice() TallySlice {
-	var tallySlice TallySlice
+	var tallySlice, jailedSlice TallySlice
 	for address, stake := range s.Tally {
 		if !candidateNeedPD || s.isCandidate(address) {
+			var item TallyItem
 			if _, ok := s.Punished[address]; ok {
 				var creditWeight uint64
 				if s.Punished[address] > defaultFullCredit-minCalSignerQueueCredit {
@@ -99,19 +100,28 @@ func (s *Snapshot) buildTallySlice() TallySlice {
 				} else {
 					creditWeight = defaultFullCredit - s.Punished[address]
 				}
-				tallySlice = append(tallySlice, TallyItem{address, new(big.Int).Mul(stake, big.NewInt(int64(creditWeight)))})
+				item = TallyItem{address, new(big.Int).Mul(stake, big.NewInt(int64(creditWeight)))}
+			} else {
+				item = TallyItem{address, new(big.Int).Mul(stake, big.NewInt(defaultFullCredit))}
+			}
+			if s.isJailed(address) {
+				jailedSlice = append(jailedSlice, item)
 			} else {
-				tallySlice = append(tallySlice, TallyItem{address, new(big.Int).Mul(stake, big.NewInt(defaultFullCredit))})
+				tallySlice = append(tallySlice, item)
 			}
 		}
 	}
+	// jailed signers are only kept if the chain would stop without them
+	if len(tallySlice) == 0 {
+		return jailedSlice
+	}
 	return tallySlice
 }
 
 func (s *Snapshot) buildTallyMiner() TallySlice {
 	var tallySlice TallySlice
 	for address, stake := range s.TallyMiner {
-		if pledge, ok := s.CandidatePledge[address]; !ok || 0 < pledge.StartHigh || s.Punished[address] >= minCalSignerQueueCredit {
+		if pledge, ok := s.CandidatePledge[address]; !ok || 0 < pledge.StartHigh || s.Punished[address] >= minCalSignerQueueCredit || s.isJailed(address) {
 			continue
 		}
 		if _, ok := s.Punished[address]; ok {
@@ -147,56 +157,26 @@ func (s *Snapshot) createSignerQueue() ([]common.Address, error) {
 	}
 
 	var signerSlice SignerSlice
-	var topStakeAddress []common.Address
 
 	if (s.Number+1)%(s.config.MaxSignerCount*s.LCRS) == 0 {
-		// before recalculate the signers, clear the candidate is not in snap.Candidates
-		//log.Info("begin select node","blocknumbrt",s.Number)
 		// only recalculate signers from to tally per 10 loop,
 		// other loop end just reset the order of signers by block hash (nearly random)
-		mainMinerSlice := s.buildTallySlice()
-		sort.Sort(TallySlice(mainMinerSlice))
-		secondMinerSlice := s.buildTallyMiner()
-		sort.Sort(TallySlice(secondMinerSlice))
-		queueLength := int(s.config.MaxSignerCount)
-		mainSignerSliceLen := len(mainMinerSlice)
-
-		if queueLength >= defaultOfficialMaxSignerCount {
-			mainMinerNumber := (9*queueLength + defaultOfficialMaxSignerCount - 1) / defaultOfficialMaxSignerCount
-			secondMinerNumber := 12 * queueLength / defaultOfficialMaxSignerCount
-
-			if secondMinerNumber >= len(secondMinerSlice) {
-				secondMinerNumber = len(secondMinerSlice)
-				mainMinerNumber = queueLength - secondMinerNumber
-				signerSlice = s.selectSecondMinerInsufficient(secondMinerSlice, signerSlice)
-			} else {
-				mainMinerNumber = queueLength - secondMinerNumber
-				var candidatePledgeSlice TallySlice
-				if len(secondMinerSlice)+mainSignerSliceLen >= maxCandidateMiner {
-					for _, tallyItem := range secondMinerSlice[:maxCandidateMiner-mainSignerSliceLen] {
-						candidatePledgeSlice = append(candidatePledgeSlice, TallyItem{tallyItem.addr, tallyItem.stake})
-					}
-				} else {
-					candidatePledgeSlice = secondMinerSlice
-				}
-				signerSlice = s.selectSecondMiner(candidatePledgeSlice, secondMinerNumber, signerSlice, queueLength)
-			}
-			// select Main Miner
-			signerSlice = s.selectMainMiner(mainMinerNumber, mainSignerSliceLen, signerSlice, mainMinerSlice, secondMinerNumber)
-		} else {
-			if queueLength > len(mainMinerSlice) {
-				queueLength = len(mainMinerSlice)
-			}
-			for i, tallyItem := range mainMinerSlice[:queueLength] {
-				signerSlice = append(signerSlice, SignerItem{tallyItem.addr, s.HistoryHash[len(s.HistoryHash)-1-i]})
-			}
-		}
+		signerSlice = s.electSigners()
 	} else {
 		for i, signer := range s.Signers {
 			signerSlice = append(signerSlice, SignerItem{*signer, s.HistoryHash[len(s.HistoryHash)-1-i]})
 		}
 	}
+	return s.signerQueue(signerSlice)
+}
+
+// signerQueue orders the selected signers by block hash and fills the queue of
+// MaxSignerCount slots with them.
+func (s *Snapshot) signerQueue(signerSlice SignerSlice) ([]common.Address, error) {
+	var topStakeAddress []common.Address
+
 	// Set the top candidates in random order base on block hash
+	s.randomSignerHashes(signerSlice)
 	sort.Sort(SignerSlice(signerSlice))
 	if len(signerSlice) == 0 {
 		return nil, errSignerQueueEmpty
@@ -207,6 +187,59 @@ func (s *Snapshot) createSignerQueue() ([]common.Address, error) {
 	return topStakeAddress, nil
 }
 
+// electSigners selects the signers of the next loops from the main miners voted
+// for and the second miners pledged, as done every LCRS loops.
+func (s *Snapshot) electSigners() SignerSlice {
+	var signerSlice SignerSlice
+
+	// before recalculate the signers, clear the candidate is not in snap.Candidates
+	//log.Info("begin select node","blocknumbrt",s.Number)
+	mainMinerSlice := s.buildTallySlice()
+	sort.Sort(TallySlice(mainMinerSlice))
+	secondMinerSlice := s.buildTallyMiner()
+	sort.Sort(TallySlice(secondMinerSlice))
+	queueLength := int(s.config.MaxSignerCount)
+	mainSignerSliceLen := len(mainMinerSlice)
+
+	if queueLength >= defaultOfficialMaxSignerCount {
+		mainMinerNumber := (9*queueLength + defaultOfficialMaxSignerCount - 1) / defaultOfficialMaxSignerCount
+		secondMinerNumber := 12 * queueLength / defaultOfficialMaxSignerCount
+
+		if secondMinerNumber >= len(secondMinerSlice) {
+			secondMinerNumber = len(secondMinerSlice)
+			mainMinerNumber = queueLength - secondMinerNumber
+			signerSlice = s.selectSecondMinerInsufficient(secondMinerSlice, signerSlice)
+		} else {
+			mainMinerNumber = queueLength - secondMinerNumber
+			var candidatePledgeSlice TallySlice
+			maxCandidateMiner := int(economics(s.config).MaxCandidateMiner)
+			if len(secondMinerSlice)+mainSignerSliceLen >= maxCandidateMiner {
+				// The main miners may already fill all the candidate places
+				secondCandidateMiner := maxCandidateMiner - mainSignerSliceLen
+				if secondCandidateMiner < 0 {
+					secondCandidateMiner = 0
+				}
+				for _, tallyItem := range secondMinerSlice[:secondCandidateMiner] {
+					candidatePledgeSlice = append(candidatePledgeSlice, TallyItem{tallyItem.addr, tallyItem.stake})
+				}
+			} else {
+				candidatePledgeSlice = secondMinerSlice
+			}
+			signerSlice = s.selectSecondMiner(candidatePledgeSlice, secondMinerNumber, signerSlice, queueLength)
+		}
+		// select Main Miner
+		signerSlice = s.selectMainMiner(mainMinerNumber, mainSignerSliceLen, signerSlice, mainMinerSlice, secondMinerNumber)
+	} else {
+		if queueLength > len(mainMinerSlice) {
+			queueLength = len(mainMinerSlice)
+		}
+		for i, tallyItem := range mainMinerSlice[:queueLength] {
+			signerSlice = append(signerSlice, SignerItem{tallyItem.addr, s.HistoryHash[len(s.HistoryHash)-1-i]})
+		}
+	}
+	return signerSlice
+}
+
 func (s *Snapshot) selectMainMiner(mainMinerNumber int, mainSignerSliceLen int, signerSlice SignerSlice, mainMinerSlice TallySlice, secondMinerNumber int) SignerSlice {
 	if mainMinerNumber > mainSignerSliceLen {
 		//mainSignerSliceLen := len(mainMinerSlice)
@@ -223,7 +256,7 @@ func (s *Snapshot) selectMainMiner(mainMinerNumber int, mainSignerSliceLen int,
 
 func (s *Snapshot) selectSecondMiner(candidatePledgeSlice TallySlice, secondMinerNumber int, signerSlice SignerSlice, queueLength int) SignerSlice {
 	candidateLen := len(candidatePledgeSlice)
-	if candidateLen <= electionPartitionThreshold {
+	if candidateLen <= int(economics(s.config).ElectionPartitionThreshold) {
 		candidatePledgeSlice = s.rebuildTallyMiner(candidatePledgeSlice)
 		for i, tallyItem := range candidatePledgeSlice[:secondMinerNumber] {
 			signerSlice = append(signerSlice, SignerItem{tallyItem.addr, s.HistoryHash[len(s.HistoryHash)-1-i]})
@@ -19,7 +19,6 @@
 package alien
 
 import (
-	"encoding/json"
 	"errors"
 	"github.com/hashicorp/golang-lru"
 	"github.com/seaskycheng/sdvn/common"
@@ -63,8 +62,6 @@ const (
 	mcNoticeClearDelayLoopCount = 4 // this count can be hundreds times
 	scNoticeClearDelayLoopCount = mcNoticeClearDelayLoopCount * scMaxCountPerPeriod * 2
 	scGasChargingDelayLoopCount = 1 // 1 is always enough
-	// bug fix
-	bugFixBlockNumber = 14456164 // fix bug for header
 )
 
 // Score to calculate at one main chain block, for calculate the side chain reward
@@ -174,6 +171,8 @@ type LockBalanceData struct {
 type Snapshot struct {
 	config   *params.AlienConfig // Consensus engine parameters to fine tune behavior
 	sigcache *lru.ARCCache       // Cache of recent block signatures to speed up ecrecover
+	layers   uint64              // Number of stored diff layers above the nearest stored base
+	votes    *voteHistory        // Collector of the vote changes while applying headers, nil unless indexed
 	LCRS     uint64              // Loop count to recreate signers from top tally
 
 	Period          uint64                                            `json:"period"`            // Period of seal each block
@@ -213,13 +212,20 @@ type Snapshot struct {
 	FlowMiner    *FlowMinerSnap  `json:"flowminer"`
 	//FlowMiner      map[common.Address]map[common.Hash]*FlowMinerReport `json:"flowminerCurr"`
 	//FlowMinerPrev  map[common.Address]map[common.Hash]*FlowMinerReport `json:"flowminerPrev"`
-	FlowTotal      *big.Int                          `json:"flowtotal"`
-	SCMinerRevenue map[common.Address]common.Address `json:"scminerrevenue"`
-	SCFlowPledge   map[common.Address]bool           `json:"scflowpledge"`
-	SCFULBalance   map[common.Address]*big.Int       `json:"fulbalance"`
-	SignerMissing  []common.Address                  `json:"signermissing"`
-	Ful            FulState                          `json:"-"`
-	FulHash        common.Hash                       `json:"fulhash"`
+	FlowTotal       *big.Int                          `json:"flowtotal"`
+	SCMinerRevenue  map[common.Address]common.Address `json:"scminerrevenue"`
+	SCFlowPledge    map[common.Address]bool           `json:"scflowpledge"`
+	SCFULBalance    map[common.Address]*big.Int       `json:"fulbalance"`
+	SignerMissing   []common.Address                  `json:"signermissing"`
+	DoubleSigned    map[common.Address]uint64         `json:"doublesigned"`    // Height of the last double sign slashed for each signer
+	Jails           map[common.Address][]*JailRecord  `json:"jails"`           // Jail history of each signer, the last record is current while not unjailed
+	RandomCommits   map[common.Address]*RandomCommit  `json:"randomcommits"`   // Last secret committed to by each signer
+	RandomBeacon    common.Hash                       `json:"randombeacon"`    // Random beacon ordering the signer queue
+	ProposalResults []*ProposalResult                 `json:"proposalresults"` // Latest concluded proposals, oldest first
+	MultiSigPending map[common.Hash]*MultiSigProposal `json:"multisigpending"` // Proposals of the multi-signature addresses collecting approvals
+	FulAllowances   map[common.Address]FulAllowance   `json:"fulallowances"`   // FUL each spender may transfer out of an address
+	Ful             FulState                          `json:"-"`
+	FulHash         common.Hash                       `json:"fulhash"`
 }
 
 var (
@@ -291,6 +297,9 @@ func newSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, hash common
 		SCFlowPledge:   make(map[common.Address]bool),
 		SCFULBalance:   make(map[common.Address]*big.Int),
 		SignerMissing:  []common.Address{},
+		DoubleSigned:   make(map[common.Address]uint64),
+		Jails:          make(map[common.Address][]*JailRecord),
+		RandomCommits:  make(map[common.Address]*RandomCommit),
 	}
 	snap.HistoryHash = append(snap.HistoryHash, hash)
 
@@ -319,46 +328,30 @@ func newSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, hash common
 		}
 	}
 
-	snap.SystemConfig.LockParameters[sscEnumCndLock] = &LockParameter{
-		LockPeriod: uint32(signerPledgeLockParamPeriod / config.Period),
-		RlsPeriod:  signerPledgeLockParamRlsPeriod,
-		Interval:   signerPledgeLockParamInterval,
-	}
-	snap.SystemConfig.LockParameters[sscEnumFlwLock] = &LockParameter{
-		LockPeriod: uint32(flowPledgeLockParamPeriod / config.Period),
-		RlsPeriod:  flowPledgeLockParamRlsPeriod,
-		Interval:   flowPledgeLockParamInterval,
-	}
-	snap.SystemConfig.LockParameters[sscEnumRwdLock] = &LockParameter{
-		//LockPeriod: uint32(30 * 24 * 60 * 60 / config.Period),
-		LockPeriod: uint32(rewardLockParamPeriod / config.Period),
-		RlsPeriod:  uint32(rewardLockParamRlsPeriod / config.Period),
-		Interval:   uint32(rewardLockParamInterval / config.Period),
-	}
-	snap.SystemConfig.Deposit[0] = new(big.Int).Set(minCndPledgeBalance)
+	snap.SystemConfig.LockParameters[sscEnumCndLock] = newLockParameter(economics(config).SignerPledgeLock, config.Period)
+	snap.SystemConfig.LockParameters[sscEnumFlwLock] = newLockParameter(economics(config).FlowPledgeLock, config.Period)
+	snap.SystemConfig.LockParameters[sscEnumRwdLock] = newLockParameter(economics(config).RewardLock, config.Period)
+	snap.SystemConfig.Deposit[0] = new(big.Int).Set(economics(config).MinCndPledgeBalance)
 	snap.SystemConfig.Deposit[sscEnumSignerReward] = new(big.Int).Set(minSignerLockBalance)
 	snap.SystemConfig.Deposit[sscEnumFlwReward] = new(big.Int).Set(minFlwLockBalance)
 	snap.SystemConfig.Deposit[sscEnumBandwidthReward] = new(big.Int).Set(minBandwidthLockBalance)
-	snap.SystemConfig.ManagerAddress[sscEnumExchRate] = managerAddressExchRate
-	snap.SystemConfig.ManagerAddress[sscEnumSystem] = managerAddressSystem
-	snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = managerAddressWdthPnsh
-	snap.SystemConfig.ManagerAddress[sscEnumFlowReport] = managerAddressFlowReport
+	snap.SystemConfig.ManagerAddress[sscEnumExchRate] = genesisManagerAddress(config, managerAddressExchRate)
+	snap.SystemConfig.ManagerAddress[sscEnumSystem] = genesisManagerAddress(config, managerAddressSystem)
+	snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = genesisManagerAddress(config, managerAddressWdthPnsh)
+	snap.SystemConfig.ManagerAddress[sscEnumFlowReport] = genesisManagerAddress(config, managerAddressFlowReport)
 
 	return snap
 }
 
 // loadSnapshot loads an existing snapshot from the database.
 func loadSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, db ethdb.Database, hash common.Hash) (*Snapshot, error) {
-	blob, err := db.Get(append([]byte("alien-"), hash[:]...))
+	snap, layers, err := readSnapshot(db, hash)
 	if err != nil {
 		return nil, err
 	}
-	snap := new(Snapshot)
-	if err := json.Unmarshal(blob, snap); err != nil {
-		return nil, err
-	}
 	snap.config = config
 	snap.sigcache = sigcache
+	snap.layers = layers
 
 	// miner reward per thousand proposal must larger than 0
 	// so minerReward is zeron only when update the program
@@ -376,7 +369,7 @@ func loadSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, db ethdb.D
 		snap.SystemConfig.OffLine = 10000
 	}
 	if _, ok := snap.SystemConfig.Deposit[0]; !ok || 0 > snap.SystemConfig.Deposit[0].Cmp(big.NewInt(0)) {
-		snap.SystemConfig.Deposit[0] = new(big.Int).Set(minCndPledgeBalance)
+		snap.SystemConfig.Deposit[0] = new(big.Int).Set(economics(config).MinCndPledgeBalance)
 	}
 	if _, ok := snap.SystemConfig.Deposit[sscEnumSignerReward]; !ok || 0 > snap.SystemConfig.Deposit[sscEnumSignerReward].Cmp(big.NewInt(0)) {
 		snap.SystemConfig.Deposit[sscEnumSignerReward] = new(big.Int).Set(minSignerLockBalance)
@@ -388,37 +381,25 @@ func loadSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, db ethdb.D
 		snap.SystemConfig.Deposit[sscEnumBandwidthReward] = new(big.Int).Set(minBandwidthLockBalance)
 	}
 	if _, ok := snap.SystemConfig.LockParameters[sscEnumCndLock]; !ok {
-		snap.SystemConfig.LockParameters[sscEnumCndLock] = &LockParameter{
-			LockPeriod: uint32(signerPledgeLockParamPeriod / config.Period),
-			RlsPeriod:  signerPledgeLockParamRlsPeriod,
-			Interval:   signerPledgeLockParamInterval,
-		}
+		snap.SystemConfig.LockParameters[sscEnumCndLock] = newLockParameter(economics(config).SignerPledgeLock, config.Period)
 	}
 	if _, ok := snap.SystemConfig.LockParameters[sscEnumFlwLock]; !ok {
-		snap.SystemConfig.LockParameters[sscEnumFlwLock] = &LockParameter{
-			LockPeriod: uint32(flowPledgeLockParamPeriod / config.Period),
-			RlsPeriod:  flowPledgeLockParamRlsPeriod,
-			Interval:   flowPledgeLockParamInterval,
-		}
+		snap.SystemConfig.LockParameters[sscEnumFlwLock] = newLockParameter(economics(config).FlowPledgeLock, config.Period)
 	}
 	if _, ok := snap.SystemConfig.LockParameters[sscEnumRwdLock]; !ok {
-		snap.SystemConfig.LockParameters[sscEnumRwdLock] = &LockParameter{
-			LockPeriod: uint32(rewardLockParamPeriod / config.Period),
-			RlsPeriod:  uint32(rewardLockParamRlsPeriod / config.Period),
-			Interval:   uint32(rewardLockParamInterval / config.Period),
-		}
+		snap.SystemConfig.LockParameters[sscEnumRwdLock] = newLockParameter(economics(config).RewardLock, config.Period)
 	}
 	if _, ok := snap.SystemConfig.ManagerAddress[sscEnumExchRate]; !ok {
-		snap.SystemConfig.ManagerAddress[sscEnumExchRate] = managerAddressExchRate
+		snap.SystemConfig.ManagerAddress[sscEnumExchRate] = genesisManagerAddress(config, managerAddressExchRate)
 	}
 	if _, ok := snap.SystemConfig.ManagerAddress[sscEnumSystem]; !ok {
-		snap.SystemConfig.ManagerAddress[sscEnumSystem] = managerAddressSystem
+		snap.SystemConfig.ManagerAddress[sscEnumSystem] = genesisManagerAddress(config, managerAddressSystem)
 	}
 	if _, ok := snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh]; !ok {
-		snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = managerAddressWdthPnsh
+		snap.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = genesisManagerAddress(config, managerAddressWdthPnsh)
 	}
 	if _, ok := snap.SystemConfig.ManagerAddress[sscEnumFlowReport]; !ok {
-		snap.SystemConfig.ManagerAddress[sscEnumFlowReport] = managerAddressFlowReport
+		snap.SystemConfig.ManagerAddress[sscEnumFlowReport] = genesisManagerAddress(config, managerAddressFlowReport)
 	}
 	nilHash:=common.Hash{}
 	if snap.FulHash!=nilHash{
@@ -434,6 +415,12 @@ func loadSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, db ethdb.D
 	return snap, nil
 }
 
+// LoadSnapshot loads the voting snapshot with the given hash from the
+// database, for offline inspection of the alien state.
+func LoadSnapshot(config *params.AlienConfig, db ethdb.Database, hash common.Hash) (*Snapshot, error) {
+	return loadSnapshot(config, nil, db, hash)
+}
+
 // store inserts the snapshot into the database.
 func (s *Snapshot) store(db ethdb.Database) error {
 	err := s.FlowRevenue.saveCacheL1(db)
@@ -450,11 +437,15 @@ func (s *Snapshot) store(db ethdb.Database) error {
 			return err
 		}
 	}
-	blob, err := json.Marshal(s)
+	blob, err := encodeSnapshot(s)
 	if err != nil {
 		return err
 	}
-	return db.Put(append([]byte("alien-"), s.Hash[:]...), blob)
+	if err := db.Put(snapshotKey(s.Hash), blob); err != nil {
+		return err
+	}
+	s.layers = 0
+	return nil
 }
 
 // copy creates a deep copy of the snapshot, though not the individual votes.
@@ -512,6 +503,10 @@ func (s *Snapshot) copy() *Snapshot {
 		SCFlowPledge:   make(map[common.Address]bool),
 		SCFULBalance:   make(map[common.Address]*big.Int),
 		SignerMissing:  make([]common.Address, len(s.SignerMissing)),
+		DoubleSigned:   make(map[common.Address]uint64),
+		Jails:          make(map[common.Address][]*JailRecord),
+		RandomCommits:  make(map[common.Address]*RandomCommit),
+		RandomBeacon:   s.RandomBeacon,
 		Ful:            nil,
 		FulHash: s.FulHash,
 	}
@@ -543,6 +538,48 @@ func (s *Snapshot) copy() *Snapshot {
 	for signer, cnt := range s.Punished {
 		cpy.Punished[signer] = cnt
 	}
+	for signer, number := range s.DoubleSigned {
+		cpy.DoubleSigned[signer] = number
+	}
+	for signer, commit := range s.RandomCommits {
+		item := *commit
+		cpy.RandomCommits[signer] = &item
+	}
+	for signer, records := range s.Jails {
+		cpy.Jails[signer] = make([]*JailRecord, len(records))
+		for i, record := range records {
+			item := *record
+			cpy.Jails[signer][i] = &item
+		}
+	}
+	if s.ProposalResults != nil {
+		cpy.ProposalResults = make([]*ProposalResult, len(s.ProposalResults))
+		for i, result := range s.ProposalResults {
+			cpy.ProposalResults[i] = &ProposalResult{
+				Proposal:      result.Proposal.copy(),
+				Number:        result.Number,
+				Passed:        result.Passed,
+				YesStake:      new(big.Int).Set(result.YesStake),
+				NoStake:       new(big.Int).Set(result.NoStake),
+				RequiredStake: new(big.Int).Set(result.RequiredStake),
+			}
+		}
+	}
+	if s.FulAllowances != nil {
+		cpy.FulAllowances = make(map[common.Address]FulAllowance, len(s.FulAllowances))
+		for owner, allowance := range s.FulAllowances {
+			cpy.FulAllowances[owner] = make(FulAllowance, len(allowance))
+			for spender, amount := range allowance {
+				cpy.FulAllowances[owner][spender] = new(big.Int).Set(amount)
+			}
+		}
+	}
+	if s.MultiSigPending != nil {
+		cpy.MultiSigPending = make(map[common.Hash]*MultiSigProposal, len(s.MultiSigPending))
+		for hash, proposal := range s.MultiSigPending {
+			cpy.MultiSigPending[hash] = proposal.copy()
+		}
+	}
 	for blockNumber, confirmers := range s.Confirmations {
 		cpy.Confirmations[blockNumber] = make([]*common.Address, len(confirmers))
 		copy(cpy.Confirmations[blockNumber], confirmers)
@@ -695,7 +732,7 @@ func (s *Snapshot) copy() *Snapshot {
 		cpy.SystemConfig.Deposit[who] = new(big.Int).Set(value)
 	}
 	if _, ok := cpy.SystemConfig.Deposit[0]; !ok || 0 > cpy.SystemConfig.Deposit[0].Cmp(big.NewInt(0)) {
-		cpy.SystemConfig.Deposit[0] = new(big.Int).Set(minCndPledgeBalance)
+		cpy.SystemConfig.Deposit[0] = new(big.Int).Set(economics(s.config).MinCndPledgeBalance)
 	}
 	if _, ok := cpy.SystemConfig.Deposit[sscEnumSignerReward]; !ok || 0 > cpy.SystemConfig.Deposit[sscEnumSignerReward].Cmp(big.NewInt(0)) {
 		cpy.SystemConfig.Deposit[sscEnumSignerReward] = new(big.Int).Set(minSignerLockBalance)
@@ -707,45 +744,33 @@ func (s *Snapshot) copy() *Snapshot {
 		cpy.SystemConfig.Deposit[sscEnumBandwidthReward] = new(big.Int).Set(minBandwidthLockBalance)
 	}
 	if _, ok := cpy.SystemConfig.LockParameters[sscEnumCndLock]; !ok {
-		cpy.SystemConfig.LockParameters[sscEnumCndLock] = &LockParameter{
-			LockPeriod: uint32(180 * 24 * 60 * 60 / cpy.Period),
-			RlsPeriod:  0,
-			Interval:   0,
-		}
+		cpy.SystemConfig.LockParameters[sscEnumCndLock] = newLockParameter(economics(s.config).SignerPledgeLock, cpy.Period)
 	}
 	if _, ok := cpy.SystemConfig.LockParameters[sscEnumFlwLock]; !ok {
-		cpy.SystemConfig.LockParameters[sscEnumFlwLock] = &LockParameter{
-			LockPeriod: uint32(180 * 24 * 60 * 60 / cpy.Period),
-			RlsPeriod:  0,
-			Interval:   0,
-		}
+		cpy.SystemConfig.LockParameters[sscEnumFlwLock] = newLockParameter(economics(s.config).FlowPledgeLock, cpy.Period)
 	}
 	if _, ok := cpy.SystemConfig.LockParameters[sscEnumRwdLock]; !ok {
-		cpy.SystemConfig.LockParameters[sscEnumRwdLock] = &LockParameter{
-			LockPeriod: uint32(30 * 24 * 60 * 60 / cpy.Period),
-			RlsPeriod:  uint32(180 * 24 * 60 * 60 / cpy.Period),
-			Interval:   uint32(24 * 60 * 60 / cpy.Period),
-		}
+		cpy.SystemConfig.LockParameters[sscEnumRwdLock] = newLockParameter(economics(s.config).RewardLock, cpy.Period)
 	}
 	if _, ok := cpy.SystemConfig.ManagerAddress[sscEnumExchRate]; !ok {
-		cpy.SystemConfig.ManagerAddress[sscEnumExchRate] = managerAddressExchRate
+		cpy.SystemConfig.ManagerAddress[sscEnumExchRate] = genesisManagerAddress(s.config, managerAddressExchRate)
 	}
 	if _, ok := cpy.SystemConfig.ManagerAddress[sscEnumSystem]; !ok {
-		cpy.SystemConfig.ManagerAddress[sscEnumSystem] = managerAddressSystem
+		cpy.SystemConfig.ManagerAddress[sscEnumSystem] = genesisManagerAddress(s.config, managerAddressSystem)
 	}
 	if _, ok := cpy.SystemConfig.ManagerAddress[sscEnumWdthPnsh]; !ok {
-		cpy.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = managerAddressWdthPnsh
+		cpy.SystemConfig.ManagerAddress[sscEnumWdthPnsh] = genesisManagerAddress(s.config, managerAddressWdthPnsh)
 	}
 	if _, ok := cpy.SystemConfig.ManagerAddress[sscEnumFlowReport]; !ok {
-		cpy.SystemConfig.ManagerAddress[sscEnumFlowReport] = managerAddressFlowReport
+		cpy.SystemConfig.ManagerAddress[sscEnumFlowReport] = genesisManagerAddress(s.config, managerAddressFlowReport)
 	}
 
 	return cpy
 }
 
 // apply creates a new authorization snapshot by applying the given headers to
-// the original one.
-func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot, error) {
+// the original one. The vote changes are passed to votes unless it is nil.
+func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database, votes *voteHistory) (*Snapshot, error) {
 	// Allow passing in no headers for cleaner code
 	if len(headers) == 0 {
 		return s, nil
@@ -761,6 +786,8 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 	}
 	// Iterate through the headers and create a new snapshot
 	snap := s.copy()
+	snap.votes = votes
+	defer func() { snap.votes = nil }()
 
 	for _, header := range headers {
 		// Resolve the authorization key and check against signers
@@ -768,7 +795,7 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 		if err != nil {
 			return nil, err
 		}
-		if coinbase.String() != header.Coinbase.String() && header.Number.Cmp(big.NewInt(bugFixBlockNumber)) != 0 {
+		if coinbase.String() != header.Coinbase.String() && !isForkBlock(s.config.IsBugFix, header.Number.Uint64()) {
 			return nil, errUnauthorized
 		}
 
@@ -796,12 +823,16 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 
 		// deal the new vote from voter
 		snap.updateSnapshotByVotes(headerExtra.CurrentBlockVotes, header.Number)
+		snap.updateSnapshotByUnvotes(headerExtra.CurrentBlockUnvotes, header.Number)
+		snap.updateSnapshotByRedelegates(headerExtra.CurrentBlockRedelegates, header.Number)
 
 		// deal the voter which balance modified
 		snap.updateSnapshotByMPVotes(headerExtra.ModifyPredecessorVotes)
 
 		// deal the snap related with punished
 		snap.updateSnapshotForPunish(headerExtra.SignerMissing, header.Number, header.Coinbase)
+		snap.updateJailed(headerExtra.SignerMissing, header.Number.Uint64())
+		snap.updateRandomBeacon(header.Coinbase, header.Number, headerExtra)
 
 		// deal proposals
 		snap.updateSnapshotByProposals(headerExtra.CurrentBlockProposals, header.Number)
@@ -809,6 +840,11 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 		// deal declares
 		snap.updateSnapshotByDeclares(headerExtra.CurrentBlockDeclares, header.Number)
 
+		// deal multi-signature proposals
+		snap.updateMultiSigProposals(headerExtra.MultiSigProposals, header.Number)
+		snap.updateMultiSigApprovals(headerExtra.MultiSigApprovals)
+		snap.updateMultiSigExpired(header.Number)
+
 		// deal trantor upgrade
 		if snap.Period == 0 {
 			snap.Period = snap.config.Period
@@ -848,14 +884,18 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 		snap.updateFlowMiner(header,db)
 		snap.updateMinerStack(headerExtra.MinerStake)
 		snap.updateGrantProfit(headerExtra.GrantProfit, db,header.Hash(),header.Number.Uint64())
-		if header.Number.Uint64() == lockMergeNumber  {
-			snap.FlowRevenue.updateMergeLockData(db,snap.Period,snap.Hash)
+		if isForkBlock(snap.config.IsLockMerge, header.Number.Uint64()) {
+			snap.FlowRevenue.updateMergeLockData(db,snap.getBlockPreDay(),snap.Hash)
 		}
 		snap.updateFlowRevenueRls(headerExtra.LockReward, header.Number)
 		snap.updateExchangeNFC(headerExtra.ExchangeNFC, header.Number.Uint64())
+		snap.updateFulTransfers(headerExtra.FulTransfers, header.Number)
+		snap.updateFulAllowances(headerExtra.FulAllowances)
 		snap.updateDeviceBind(headerExtra.DeviceBind)
 		snap.updateCandidatePledge(headerExtra.CandidatePledge)
 		snap.updateCandidatePunish(headerExtra.CandidatePunish)
+		snap.updateDoubleSignSlash(headerExtra.DoubleSignSlash)
+		snap.updateCandidateUnjail(headerExtra.CandidateUnjail, header.Number)
 		snap.updateCandidateExit(headerExtra.CandidateExit, header.Number)
 		snap.updateClaimedBandwidth(headerExtra.ClaimedBandwidth)
 		snap.updateFlowMinerExit(headerExtra.FlowMinerExit, header.Number)
@@ -868,15 +908,13 @@ func (s *Snapshot) apply(headers []*types.Header, db ethdb.Database) (*Snapshot,
 		snap.updateConfigISPQOS(headerExtra.ConfigISPQOS)
 		snap.updateManagerAddress(headerExtra.ManagerAddress)
 		snap.updateLockParameters(headerExtra.LockParameters)
-		if header.Number.Uint64()%(snap.config.MaxSignerCount*snap.LCRS) == 0 && header.Number.Uint64()>= signFixBlockNumber {
+		if header.Number.Uint64()%(snap.config.MaxSignerCount*snap.LCRS) == 0 && snap.config.IsSignFix(header.Number) {
 			snap.updateSignerNumber(headerExtra.SignerQueue)
 		}
-		if header.Number.Uint64() ==(FulTrieNumber-1) {
-			snap.Ful,err = NewFUL(common.Hash{},db)
-			if err!=nil{
-				return snap,nil
+		if isForkBlock(snap.config.IsFulTrie, header.Number.Uint64()+1) {
+			if err = snap.initFulTrie(db); err != nil {
+				return snap, nil
 			}
-			snap.initFulBalance()
 		}
 	}
 	snap.Number += uint64(len(headers))
@@ -955,10 +993,10 @@ func (snap *Snapshot) updateConfigExchRate(configExchRate uint32) {
 }
 
 func (snap *Snapshot) getFlowRewardBlock() uint64 {
-	return accumulateFlowRewardInterval / snap.config.Period
+	return economics(snap.config).AccumulateFlowRewardInterval / snap.config.Period
 }
 func (snap *Snapshot) getBlockPreDay() uint64 {
-	return secondsPerDay / snap.config.Period
+	return economics(snap.config).RewardCycle / snap.config.Period
 }
 
 func (snap *Snapshot) updateFlowReport(flowReport []MinerFlowReportRecord, headerNumber *big.Int) {
@@ -967,7 +1005,7 @@ func (snap *Snapshot) updateFlowReport(flowReport []MinerFlowReportRecord, heade
 }
 
 func (snap *Snapshot) updateFULBalanceCostTotal(flowReport []MinerFlowReportRecord, headerNumber *big.Int) {
-	if isGeFulTrieNumber(headerNumber.Uint64()){
+	if isGeFulTrieNumber(snap.config, headerNumber.Uint64()){
 		snap.updateFULBalanceCost(flowReport,headerNumber)
 		return
 	}
@@ -1083,6 +1121,18 @@ func (snap *Snapshot) updateCandidatePunish(candidatePunish []CandidatePunishRec
 	}
 }
 
+func (snap *Snapshot) updateDoubleSignSlash(doubleSignSlash []DoubleSignRecord) {
+	for _, item := range doubleSignSlash {
+		snap.slashDoubleSign(item.Target, item.Number)
+	}
+}
+
+func (snap *Snapshot) updateCandidateUnjail(candidateUnjail []common.Address, headerNumber *big.Int) {
+	for _, miner := range candidateUnjail {
+		snap.unjail(miner, headerNumber.Uint64())
+	}
+}
+
 func (snap *Snapshot) updateCandidatePledge(candidatePledge []CandidatePledgeRecord) {
 	for _, item := range candidatePledge {
 		if _, ok := snap.CandidatePledge[item.Target]; ok {
@@ -1126,7 +1176,7 @@ func (snap *Snapshot) updateDeviceBind(deviceBind []DeviceBindRecord) {
 }
 
 func (snap *Snapshot) updateExchangeNFC(exchangeNFC []ExchangeNFCRecord, number uint64) {
-    if isGeFulTrieNumber(number){
+    if isGeFulTrieNumber(snap.config, number){
 		if snap.Ful!=nil{
 			for _, item := range exchangeNFC {
 				snap.Ful.Add(item.Target,item.Amount)
@@ -1166,7 +1216,7 @@ func (snap *Snapshot) updateGrantProfit(grantProfit []consensus.GrantProfitRecor
 			}
 		}
 	}
-	snap.FlowRevenue.updateGrantProfit(grantProfit, db,headerHash,number)
+	snap.FlowRevenue.updateGrantProfit(snap.config, grantProfit, db,headerHash,number)
 }
 
 func (snap *Snapshot) updateMinerStack(minerStake []MinerStakeRecord) {
@@ -1605,22 +1655,9 @@ func (s *Snapshot) calculateProposalResult(headerNumber *big.Int) {
 				s.ProposalRefund[headerNumber.Uint64()][proposal.Proposer].Add(s.ProposalRefund[headerNumber.Uint64()][proposal.Proposer], proposal.CurrentDeposit)
 			}
 
-			// calculate the current stake of this proposal
-			judegmentStake := big.NewInt(0)
-			for _, tally := range s.Tally {
-				judegmentStake.Add(judegmentStake, tally)
-			}
-			judegmentStake.Mul(judegmentStake, big.NewInt(2))
-			judegmentStake.Div(judegmentStake, big.NewInt(3))
-			// calculate declare stake
-			yesDeclareStake := big.NewInt(0)
-			for _, declare := range proposal.Declares {
-				if declare.Decision {
-					if _, ok := s.Tally[declare.Declarer]; ok {
-						yesDeclareStake.Add(yesDeclareStake, s.Tally[declare.Declarer])
-					}
-				}
-			}
+			// calculate the current stake and the declare stake of this proposal
+			yesDeclareStake, noDeclareStake, judegmentStake := s.proposalStakes(proposal)
+			s.recordProposalResult(proposal, headerNumber.Uint64(), yesDeclareStake, noDeclareStake, judegmentStake)
 			if yesDeclareStake.Cmp(judegmentStake) > 0 {
 				// process add candidate
 				switch proposal.ProposalType {
@@ -1729,6 +1766,7 @@ func (s *Snapshot) updateSnapshotForExpired(headerNumber *big.Int) {
 			}
 			delete(s.Votes, expiredVote.Voter)
 			delete(s.Voters, expiredVote.Voter)
+			s.recordVote(expiredVote.Voter, &VoteRecord{Number: headerNumber.Uint64(), Action: voteActionExpire, Candidate: expiredVote.Candidate, Stake: new(big.Int).Set(expiredVote.Stake)})
 		}
 	}
 
@@ -1789,6 +1827,19 @@ func (s *Snapshot) updateSnapshotByVotes(votes []Vote, headerNumber *big.Int) {
 
 		s.Votes[vote.Voter] = &Vote{vote.Voter, vote.Candidate, new(big.Int).Set(vote.Stake)}
 		s.Voters[vote.Voter] = headerNumber
+		s.recordVote(vote.Voter, &VoteRecord{Number: headerNumber.Uint64(), Action: voteActionVote, Candidate: vote.Candidate, Stake: new(big.Int).Set(vote.Stake)})
+	}
+}
+
+func (s *Snapshot) updateSnapshotByUnvotes(unvotes []common.Address, headerNumber *big.Int) {
+	for _, voter := range unvotes {
+		s.unvote(voter, headerNumber.Uint64())
+	}
+}
+
+func (s *Snapshot) updateSnapshotByRedelegates(redelegates []Vote, headerNumber *big.Int) {
+	for _, vote := range redelegates {
+		s.redelegate(vote.Voter, vote.Candidate, headerNumber.Uint64())
 	}
 }
 
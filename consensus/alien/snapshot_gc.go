@@ -0,0 +1,409 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+	"github.com/seaskycheng/sdvn/trie"
+)
+
+// The garbage collection keeps every snapshot and diff layer newer than the
+// window below the head, and the canonical ones down to the last checkpoint
+// before the window. The lock reward caches, flow miner caches and FUL trie
+// nodes are kept as long as one of the kept snapshots references them, or
+// they were written within the window.
+const DefaultSnapshotGCWindow = 10 * checkpointInterval
+
+var flowMinerCachePrefix = []byte("flow-") // flowMinerCachePrefix + number -> flow miner reports
+
+// snapshotEntry is a stored snapshot or diff layer seen by the collector.
+type snapshotEntry struct {
+	number uint64
+	parent common.Hash // Parent of a diff layer, empty for a base
+	layer  bool
+	refs   snapshotRefs
+}
+
+// snapshotRefs are the database entries referenced by a snapshot, besides the
+// snapshot blob itself.
+type snapshotRefs struct {
+	ful    []common.Hash
+	lockL1 []common.Hash
+	lockL2 []common.Hash
+	flow   []string
+}
+
+func (r *snapshotRefs) addSnapshot(s *Snapshot) {
+	if s.FulHash != (common.Hash{}) {
+		r.ful = append(r.ful, s.FulHash)
+	}
+	if s.FlowRevenue != nil {
+		for _, lock := range []*LockData{s.FlowRevenue.RewardLock, s.FlowRevenue.FlowLock, s.FlowRevenue.BandwidthLock} {
+			if lock != nil {
+				r.lockL1 = append(r.lockL1, lock.CacheL1...)
+				r.lockL2 = append(r.lockL2, lock.CacheL2)
+			}
+		}
+	}
+	if s.FlowMiner != nil {
+		r.flow = append(r.flow, s.FlowMiner.FlowMinerCache...)
+		r.flow = append(r.flow, s.FlowMiner.FlowMinerPrevCache...)
+	}
+}
+
+// addLayer collects the references changed by a diff layer. The unchanged ones
+// are those of the parent.
+func (r *snapshotRefs) addLayer(items []snapshotLayerItem) {
+	for _, item := range items {
+		if item.Deleted {
+			continue
+		}
+		var err error
+		switch {
+		case item.Path == "/FulHash":
+			var hash common.Hash
+			if err = rlp.DecodeBytes(item.Value, &hash); err == nil && hash != (common.Hash{}) {
+				r.ful = append(r.ful, hash)
+			}
+		case strings.HasPrefix(item.Path, "/FlowRevenue/") && strings.HasSuffix(item.Path, "/CacheL1"):
+			var hashes []common.Hash
+			if err = rlp.DecodeBytes(item.Value, &hashes); err == nil {
+				r.lockL1 = append(r.lockL1, hashes...)
+			}
+		case strings.HasPrefix(item.Path, "/FlowRevenue/") && strings.HasSuffix(item.Path, "/CacheL2"):
+			var hash common.Hash
+			if err = rlp.DecodeBytes(item.Value, &hash); err == nil {
+				r.lockL2 = append(r.lockL2, hash)
+			}
+		case item.Path == "/FlowMiner/FlowMinerCache" || item.Path == "/FlowMiner/FlowMinerPrevCache":
+			var keys []string
+			if err = rlp.DecodeBytes(item.Value, &keys); err == nil {
+				r.flow = append(r.flow, keys...)
+			}
+		}
+		if err != nil {
+			log.Warn("Failed to decode snapshot layer reference", "path", item.Path, "err", err)
+		}
+	}
+}
+
+// snapshotRefSet is the union of the references of the kept snapshots.
+type snapshotRefSet struct {
+	ful    map[common.Hash]struct{}
+	lockL1 map[common.Hash]struct{}
+	lockL2 map[common.Hash]struct{}
+	flow   map[string]struct{}
+}
+
+func newSnapshotRefSet() *snapshotRefSet {
+	return &snapshotRefSet{
+		ful:    make(map[common.Hash]struct{}),
+		lockL1: make(map[common.Hash]struct{}),
+		lockL2: make(map[common.Hash]struct{}),
+		flow:   make(map[string]struct{}),
+	}
+}
+
+func (set *snapshotRefSet) add(refs *snapshotRefs) {
+	for _, hash := range refs.ful {
+		set.ful[hash] = struct{}{}
+	}
+	for _, hash := range refs.lockL1 {
+		set.lockL1[hash] = struct{}{}
+	}
+	for _, hash := range refs.lockL2 {
+		set.lockL2[hash] = struct{}{}
+	}
+	for _, key := range refs.flow {
+		set.flow[key] = struct{}{}
+	}
+}
+
+// PruneSnapshots deletes the snapshots, diff layers, lock reward caches, flow
+// miner caches and FUL trie nodes which are not reachable from the canonical
+// chain within window blocks below head. It must only be run on a database
+// not in use by a running node.
+func PruneSnapshots(db ethdb.Database, head uint64, window uint64) error {
+	return pruneSnapshots(db, head, window, nil, true, nil)
+}
+
+// pruneSnapshots implements PruneSnapshots. The extra references are those of
+// the snapshots held in memory by a running engine. FUL trie nodes are only
+// swept if sweepFul is set, as a running engine may commit a node identical to
+// a garbage one while it is collected.
+func pruneSnapshots(db ethdb.Database, head uint64, window uint64, extra *snapshotRefs, sweepFul bool, quit chan struct{}) error {
+	if head <= window {
+		return nil
+	}
+	var (
+		start   = time.Now()
+		keepTop = head - window                        // Everything above is kept
+		keepCan = keepTop - keepTop%checkpointInterval // Canonical entries from here are kept
+		entries = make(map[common.Hash]*snapshotEntry) // Snapshots and diff layers found
+		caches  [][]byte                               // Lock reward cache keys found
+		kept    = make(map[common.Hash]struct{})       // Snapshots and diff layers to keep
+		refs    = newSnapshotRefSet()                  // References of the kept entries
+		garbage = make(map[common.Hash]struct{})       // FUL roots of the deleted entries
+		batch   = db.NewBatch()
+		deleted = make(map[string]int)
+	)
+	stopped := func() bool {
+		select {
+		case <-quit:
+			return true
+		default:
+			return false
+		}
+	}
+	// Collect the snapshots, diff layers and lock reward caches
+	it := db.NewIterator([]byte("alien-"), nil)
+	for it.Next() {
+		if stopped() {
+			it.Release()
+			return nil
+		}
+		key := it.Key()
+		switch {
+		case bytes.HasPrefix(key, evidencePrefix):
+			// Double sign evidence is never collected
+		case bytes.HasPrefix(key, snapshotLayerPrefix) && len(key) == len(snapshotLayerPrefix)+common.HashLength:
+			layer := new(snapshotLayer)
+			if err := rlp.DecodeBytes(it.Value(), layer); err != nil {
+				log.Warn("Skipping undecodable snapshot layer", "key", common.Bytes2Hex(key), "err", err)
+				continue
+			}
+			entry := &snapshotEntry{number: layer.Number, parent: layer.Parent, layer: true}
+			entry.refs.addLayer(layer.Items)
+			entries[common.BytesToHash(key[len(snapshotLayerPrefix):])] = entry
+		case len(key) == len("alien-")+common.HashLength:
+			snap, err := decodeSnapshot(it.Value())
+			if err != nil {
+				log.Warn("Skipping undecodable snapshot", "key", common.Bytes2Hex(key), "err", err)
+				continue
+			}
+			entry := &snapshotEntry{number: snap.Number}
+			entry.refs.addSnapshot(snap)
+			entries[common.BytesToHash(key[len("alien-"):])] = entry
+		case len(key) > common.HashLength && (bytes.Contains(key, []byte("-l1-")) || bytes.Contains(key, []byte("-l2-"))):
+			caches = append(caches, common.CopyBytes(key))
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+	// Keep the recent entries and the canonical ones within the window, along
+	// with the layers they are built on
+	for hash, entry := range entries {
+		if entry.number > keepTop || entry.number == 0 || (entry.number >= keepCan && rawdb.ReadCanonicalHash(db, entry.number) == hash) {
+			for {
+				if _, ok := kept[hash]; ok {
+					break
+				}
+				kept[hash] = struct{}{}
+				if !entry.layer {
+					break
+				}
+				parent := entry.parent
+				if entry = entries[parent]; entry == nil {
+					break
+				}
+				hash = parent
+			}
+		}
+	}
+	for hash := range kept {
+		refs.add(&entries[hash].refs)
+	}
+	if extra != nil {
+		refs.add(extra)
+	}
+	// Delete the snapshots and layers which aren't kept
+	for hash, entry := range entries {
+		if _, ok := kept[hash]; ok {
+			continue
+		}
+		if entry.layer {
+			batch.Delete(snapshotLayerKey(hash))
+			deleted["layers"]++
+		} else {
+			batch.Delete(snapshotKey(hash))
+			deleted["snapshots"]++
+		}
+		for _, root := range entry.refs.ful {
+			if _, ok := refs.ful[root]; !ok {
+				garbage[root] = struct{}{}
+			}
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	// Delete the unreferenced lock reward caches written before the window
+	for _, key := range caches {
+		hash := common.BytesToHash(key[len(key)-common.HashLength:])
+		level := refs.lockL1
+		if bytes.Contains(key, []byte("-l2-")) {
+			level = refs.lockL2
+		}
+		if _, ok := level[hash]; ok {
+			continue
+		}
+		if number := rawdb.ReadHeaderNumber(db, hash); number == nil || *number > keepTop {
+			continue
+		}
+		batch.Delete(key)
+		deleted["lock caches"]++
+	}
+	// Delete the unreferenced flow miner caches written before the window
+	it = db.NewIterator(flowMinerCachePrefix, nil)
+	for it.Next() {
+		key := string(it.Key())
+		number, err := strconv.ParseUint(key[len(flowMinerCachePrefix):], 10, 64)
+		if err != nil || number > keepTop {
+			continue
+		}
+		if _, ok := refs.flow[key]; ok {
+			continue
+		}
+		batch.Delete(it.Key())
+		deleted["flow caches"]++
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+
+	if sweepFul && len(garbage) > 0 {
+		nodes, err := sweepFulTries(db, refs.ful, garbage, quit)
+		if err != nil {
+			return err
+		}
+		deleted["ful nodes"] = nodes
+	}
+	log.Info("Pruned alien snapshots", "head", head, "window", window, "kept", len(kept),
+		"snapshots", deleted["snapshots"], "layers", deleted["layers"], "lockcaches", deleted["lock caches"],
+		"flowcaches", deleted["flow caches"], "fulnodes", deleted["ful nodes"], "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweepFulTries deletes the nodes of the garbage FUL tries which aren't part
+// of a live one, and returns the number of nodes deleted.
+func sweepFulTries(db ethdb.Database, live map[common.Hash]struct{}, garbage map[common.Hash]struct{}, quit chan struct{}) (int, error) {
+	var (
+		triedb = trie.NewDatabase(db)
+		marked = make(map[common.Hash]struct{})
+		swept  = make(map[common.Hash]struct{})
+	)
+	// walk iterates the nodes of a trie, skipping the subtries already seen
+	walk := func(root common.Hash, seen map[common.Hash]struct{}, skip map[common.Hash]struct{}) error {
+		tr, err := trie.NewSecure(root, triedb)
+		if err != nil {
+			return err
+		}
+		it := tr.NodeIterator(nil)
+		for descend := true; it.Next(descend); {
+			descend = true
+			hash := it.Hash()
+			if hash == (common.Hash{}) {
+				continue
+			}
+			if _, ok := seen[hash]; ok {
+				descend = false
+				continue
+			}
+			if _, ok := skip[hash]; ok {
+				descend = false
+				continue
+			}
+			seen[hash] = struct{}{}
+		}
+		return it.Error()
+	}
+	for root := range live {
+		if err := walk(root, marked, nil); err != nil {
+			log.Warn("Failed to mark live FUL trie, skipping the sweep", "root", root, "err", err)
+			return 0, nil
+		}
+	}
+	for root := range garbage {
+		select {
+		case <-quit:
+			return 0, nil
+		default:
+		}
+		if err := walk(root, swept, marked); err != nil {
+			log.Debug("Incomplete garbage FUL trie", "root", root, "err", err)
+		}
+	}
+	batch := db.NewBatch()
+	for hash := range swept {
+		rawdb.DeleteTrieNode(batch, hash)
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return 0, err
+			}
+			batch.Reset()
+		}
+	}
+	return len(swept), batch.Write()
+}
+
+// SetSnapshotGC enables the online garbage collection of the stored snapshots,
+// keeping window blocks below the head. FUL trie nodes are only pruned offline.
+func (a *Alien) SetSnapshotGC(window uint64) {
+	a.gcWindow = window
+}
+
+// gcSnapshots starts the online garbage collection after the checkpoint at
+// head was stored, unless one is running already. The references of the
+// in-memory snapshots are collected before, as they may be changed later on.
+func (a *Alien) gcSnapshots(head uint64) {
+	if !atomic.CompareAndSwapInt32(&a.gcRunning, 0, 1) {
+		return
+	}
+	recents := new(snapshotRefs)
+	for _, hash := range a.recents.Keys() {
+		if snap, ok := a.recents.Peek(hash); ok {
+			recents.addSnapshot(snap.(*Snapshot))
+		}
+	}
+	go func() {
+		defer atomic.StoreInt32(&a.gcRunning, 0)
+
+		if err := pruneSnapshots(a.db, head, a.gcWindow, recents, false, a.quit); err != nil {
+			log.Warn("Failed to prune voting snapshots", "head", head, "err", err)
+		}
+	}()
+}
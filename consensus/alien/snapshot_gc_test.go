@@ -0,0 +1,132 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	hashOf := func(number uint64, canonical bool) common.Hash {
+		hash := common.BytesToHash([]byte(fmt.Sprintf("block-%d-%v", number, canonical)))
+		rawdb.WriteHeaderNumber(db, hash, number)
+		if canonical {
+			rawdb.WriteCanonicalHash(db, hash, number)
+		}
+		return hash
+	}
+	putSnapshot := func(number uint64, hash common.Hash, ful common.Hash, l1 []common.Hash, flow []string) {
+		snap := testEncodingSnapshot()
+		snap.Number, snap.Hash, snap.FulHash = number, hash, ful
+		snap.FlowRevenue.RewardLock.CacheL1 = l1
+		snap.FlowMiner.FlowMinerCache = flow
+		blob, _ := encodeSnapshot(snap)
+		db.Put(snapshotKey(hash), blob)
+	}
+	putLayer := func(number uint64, hash common.Hash, parent common.Hash) {
+		enc, _ := rlp.EncodeToBytes(&snapshotLayer{Parent: parent, Number: number, Depth: 1})
+		db.Put(snapshotLayerKey(hash), enc)
+	}
+	// Two FUL tries sharing some nodes, only the first one stays referenced
+	live, _ := NewFulTrie(common.Hash{}, db)
+	for i := int64(1); i <= 20; i++ {
+		live.Set(common.BigToAddress(big.NewInt(i)), big.NewInt(i))
+	}
+	liveRoot, _ := live.Save(db)
+	dead, _ := NewFulTrie(liveRoot, db)
+	dead.Set(common.BigToAddress(big.NewInt(1)), big.NewInt(100))
+	deadRoot, _ := dead.Save(db)
+	if len(rawdb.ReadTrieNode(db, deadRoot)) == 0 {
+		t.Fatalf("FUL trie not committed")
+	}
+
+	var (
+		genesis = hashOf(0, true)
+		old     = hashOf(360, true)
+		base    = hashOf(720, true)
+		child   = hashOf(721, true)
+		forkA   = hashOf(799, false)
+		forkB   = hashOf(800, false)
+		recentA = hashOf(899, false)
+		recentB = hashOf(950, false)
+		unknown = common.HexToHash("0xdead")
+	)
+	putSnapshot(0, genesis, common.Hash{}, nil, nil)
+	putSnapshot(360, old, deadRoot, nil, nil)
+	putSnapshot(720, base, liveRoot, []common.Hash{old}, []string{"flow-360"})
+	putLayer(721, child, base)
+	putLayer(799, forkA, base)
+	putLayer(800, forkB, forkA)
+	putLayer(899, recentA, base)
+	putLayer(950, recentB, recentA)
+
+	caches := map[string]bool{
+		"alien-reward-l1-" + string(old[:]):     true,
+		"alien-flow-l2-" + string(old[:]):       false,
+		"alien-reward-l1-" + string(unknown[:]): true,
+		"flow-360":                              true,
+		"flow-100":                              false,
+		"flow-950":                              true,
+	}
+	for key := range caches {
+		db.Put([]byte(key), []byte{0x01})
+	}
+	if err := PruneSnapshots(db, 1000, 100); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	has := func(db ethdb.KeyValueReader, key []byte) bool {
+		ok, _ := db.Has(key)
+		return ok
+	}
+	for hash, want := range map[common.Hash]bool{genesis: true, old: false, base: true} {
+		if have := has(db, snapshotKey(hash)); have != want {
+			t.Errorf("snapshot %x: kept %v, want %v", hash, have, want)
+		}
+	}
+	for hash, want := range map[common.Hash]bool{child: true, forkA: false, forkB: false, recentA: true, recentB: true} {
+		if have := has(db, snapshotLayerKey(hash)); have != want {
+			t.Errorf("layer %x: kept %v, want %v", hash, have, want)
+		}
+	}
+	for key, want := range caches {
+		if have := has(db, []byte(key)); have != want {
+			t.Errorf("cache %q: kept %v, want %v", key, have, want)
+		}
+	}
+	if len(rawdb.ReadTrieNode(db, deadRoot)) != 0 {
+		t.Errorf("garbage FUL root not swept")
+	}
+	snap, _, err := readSnapshot(db, child)
+	if err != nil {
+		t.Fatalf("kept layer unreadable: %v", err)
+	}
+	ful, err := NewFulTrie(snap.FulHash, db)
+	if err != nil {
+		t.Fatalf("live FUL trie unreadable: %v", err)
+	}
+	if balances := ful.GetAll(); len(balances) != 20 || balances[common.BigToAddress(big.NewInt(1))].Int64() != 1 {
+		t.Errorf("live FUL trie damaged: %v", balances)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// Between two checkpoints a snapshot is stored as a diff layer on top of the
+// snapshot it was applied on, holding only the entries which changed. A full
+// base is written at every checkpoint, or once maxSnapshotLayers diff layers
+// are stacked, so rebuilding any snapshot reads a bounded number of layers.
+const (
+	maxSnapshotLayers = checkpointInterval // Diff layers stacked before flattening into a base
+	inMemoryLayers    = 4                  // Number of recent flattened snapshots to diff against
+)
+
+var (
+	snapshotLayerPrefix = []byte("alien-layer-") // snapshotLayerPrefix + hash -> snapshot diff layer
+
+	errSnapshotLayerDepth = errors.New("snapshot layer depth exceeded")
+	bigIntType            = reflect.TypeOf(big.Int{})
+)
+
+func snapshotKey(hash common.Hash) []byte {
+	return append([]byte("alien-"), hash[:]...)
+}
+
+func snapshotLayerKey(hash common.Hash) []byte {
+	return append(common.CopyBytes(snapshotLayerPrefix), hash[:]...)
+}
+
+// snapshotFlat is the encoded snapshot split by field path and entry key. Every
+// keyed list of snapshotRLP is split into its entries, every other field is
+// stored whole under the empty key.
+type snapshotFlat map[string]map[string][]byte
+
+// snapshotLayerItem is a changed entry of a diff layer.
+type snapshotLayerItem struct {
+	Path    string
+	Key     []byte
+	Value   []byte
+	Deleted bool
+}
+
+// snapshotLayer is the diff of a snapshot against its parent snapshot.
+type snapshotLayer struct {
+	Parent common.Hash
+	Number uint64
+	Depth  uint64
+	Items  []snapshotLayerItem
+}
+
+// isSnapshotEntries reports whether the field is a list of map entries, keyed
+// by their first field.
+func isSnapshotEntries(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Struct
+}
+
+// isSnapshotStruct reports whether the field is a nested struct of the
+// encoding, flattened field by field.
+func isSnapshotStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && typ != bigIntType
+}
+
+func flattenSnapshot(s *Snapshot) (snapshotFlat, error) {
+	flat := make(snapshotFlat)
+	if err := flattenValue(reflect.ValueOf(newSnapshotRLP(s)).Elem(), "", flat); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+func flattenValue(v reflect.Value, path string, flat snapshotFlat) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field, value := typ.Field(i), v.Field(i)
+		fieldPath := path + "/" + field.Name
+
+		switch {
+		case isSnapshotEntries(field.Type):
+			entries := make(map[string][]byte, value.Len())
+			for j := 0; j < value.Len(); j++ {
+				key, err := rlp.EncodeToBytes(value.Index(j).Field(0).Interface())
+				if err != nil {
+					return err
+				}
+				entry, err := rlp.EncodeToBytes(value.Index(j).Interface())
+				if err != nil {
+					return err
+				}
+				entries[string(key)] = entry
+			}
+			flat[fieldPath] = entries
+		case isSnapshotStruct(field.Type):
+			if field.Type.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			// The empty entry marks the presence of an optional struct
+			flat[fieldPath] = map[string][]byte{"": {}}
+			if err := flattenValue(value, fieldPath, flat); err != nil {
+				return err
+			}
+		default:
+			enc, err := rlp.EncodeToBytes(value.Interface())
+			if err != nil {
+				return err
+			}
+			flat[fieldPath] = map[string][]byte{"": enc}
+		}
+	}
+	return nil
+}
+
+func (flat snapshotFlat) snapshot() (*Snapshot, error) {
+	var enc snapshotRLP
+	if err := flat.unflattenValue(reflect.ValueOf(&enc).Elem(), ""); err != nil {
+		return nil, err
+	}
+	return enc.snapshot(), nil
+}
+
+func (flat snapshotFlat) unflattenValue(v reflect.Value, path string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field, value := typ.Field(i), v.Field(i)
+		fieldPath := path + "/" + field.Name
+
+		switch {
+		case isSnapshotEntries(field.Type):
+			entries := flat[fieldPath]
+			keys := make([]string, 0, len(entries))
+			for key := range entries {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			list := reflect.MakeSlice(field.Type, 0, len(keys))
+			for _, key := range keys {
+				entry := reflect.New(field.Type.Elem())
+				if err := rlp.DecodeBytes(entries[key], entry.Interface()); err != nil {
+					return err
+				}
+				list = reflect.Append(list, entry.Elem())
+			}
+			value.Set(list)
+		case isSnapshotStruct(field.Type):
+			if _, ok := flat[fieldPath]; !ok {
+				continue
+			}
+			if field.Type.Kind() == reflect.Ptr {
+				value.Set(reflect.New(field.Type.Elem()))
+				value = value.Elem()
+			}
+			if err := flat.unflattenValue(value, fieldPath); err != nil {
+				return err
+			}
+		default:
+			if enc, ok := flat[fieldPath][""]; ok {
+				if err := rlp.DecodeBytes(enc, value.Addr().Interface()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// diff returns the items changing flat into child, sorted by path and key.
+func (flat snapshotFlat) diff(child snapshotFlat) []snapshotLayerItem {
+	var items []snapshotLayerItem
+	for path, entries := range child {
+		for key, value := range entries {
+			if old, ok := flat[path][key]; !ok || !bytes.Equal(old, value) {
+				items = append(items, snapshotLayerItem{Path: path, Key: []byte(key), Value: value})
+			}
+		}
+	}
+	for path, entries := range flat {
+		for key := range entries {
+			if _, ok := child[path][key]; !ok {
+				items = append(items, snapshotLayerItem{Path: path, Key: []byte(key), Deleted: true})
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Path != items[j].Path {
+			return items[i].Path < items[j].Path
+		}
+		return bytes.Compare(items[i].Key, items[j].Key) < 0
+	})
+	return items
+}
+
+// apply applies the items of a diff layer in place.
+func (flat snapshotFlat) apply(items []snapshotLayerItem) {
+	for _, item := range items {
+		if item.Deleted {
+			delete(flat[item.Path], string(item.Key))
+			if len(flat[item.Path]) == 0 {
+				delete(flat, item.Path)
+			}
+			continue
+		}
+		if _, ok := flat[item.Path]; !ok {
+			flat[item.Path] = make(map[string][]byte)
+		}
+		flat[item.Path][string(item.Key)] = item.Value
+	}
+}
+
+// readSnapshot reads the snapshot with the given hash, either stored whole or
+// rebuilt from its nearest base and the diff layers on top of it. It returns
+// the number of diff layers applied too.
+func readSnapshot(db ethdb.Database, hash common.Hash) (*Snapshot, uint64, error) {
+	blob, err := db.Get(snapshotKey(hash))
+	if err == nil {
+		snap, err := decodeSnapshot(blob)
+		return snap, 0, err
+	}
+	// Collect the diff layers down to the nearest base
+	var layers []*snapshotLayer
+	for {
+		enc, err := db.Get(snapshotLayerKey(hash))
+		if err != nil {
+			return nil, 0, err
+		}
+		layer := new(snapshotLayer)
+		if err := rlp.DecodeBytes(enc, layer); err != nil {
+			return nil, 0, err
+		}
+		layers = append(layers, layer)
+		if len(layers) > maxSnapshotLayers {
+			return nil, 0, errSnapshotLayerDepth
+		}
+		if blob, err = db.Get(snapshotKey(layer.Parent)); err == nil {
+			break
+		}
+		hash = layer.Parent
+	}
+	base, err := decodeSnapshot(blob)
+	if err != nil {
+		return nil, 0, err
+	}
+	flat, err := flattenSnapshot(base)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		flat.apply(layers[i].Items)
+	}
+	snap, err := flat.snapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	return snap, uint64(len(layers)), nil
+}
+
+// storeLayer stores snap as a diff layer on top of parent, or as a new base if
+// too many layers are stacked already. It doesn't have the side effects of
+// Snapshot.store, the caches of the flow reports and the lock rewards are
+// only flushed at checkpoints.
+func (a *Alien) storeLayer(parent *Snapshot, snap *Snapshot) error {
+	flat, err := flattenSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	if parent.layers+1 >= maxSnapshotLayers {
+		blob, err := encodeSnapshot(snap)
+		if err != nil {
+			return err
+		}
+		if err := a.db.Put(snapshotKey(snap.Hash), blob); err != nil {
+			return err
+		}
+		snap.layers = 0
+		a.layers.Add(snap.Hash, flat)
+		log.Trace("Flattened voting snapshot layers", "number", snap.Number, "hash", snap.Hash)
+		return nil
+	}
+	var parentFlat snapshotFlat
+	if cached, ok := a.layers.Get(parent.Hash); ok {
+		parentFlat = cached.(snapshotFlat)
+	} else if parentFlat, err = flattenSnapshot(parent); err != nil {
+		return err
+	}
+	layer := &snapshotLayer{
+		Parent: parent.Hash,
+		Number: snap.Number,
+		Depth:  parent.layers + 1,
+		Items:  parentFlat.diff(flat),
+	}
+	enc, err := rlp.EncodeToBytes(layer)
+	if err != nil {
+		return err
+	}
+	if err := a.db.Put(snapshotLayerKey(snap.Hash), enc); err != nil {
+		return err
+	}
+	snap.layers = layer.Depth
+	a.layers.Add(snap.Hash, flat)
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/params"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+func TestSnapshotLayers(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	alien := New(&params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int)}, db)
+	defer alien.Close()
+
+	base := testEncodingSnapshot()
+	blob, _ := encodeSnapshot(base)
+	db.Put(snapshotKey(base.Hash), blob)
+
+	// First layer changes, adds and deletes entries
+	addr := common.HexToAddress("0x0f0635247686493bbb2498103e24cf7dc548ac2a")
+	child := base.copy()
+	child.Number, child.Hash = base.Number+1, common.HexToHash("0x43")
+	child.HistoryHash = append(child.HistoryHash, child.Hash)
+	child.Tally[addr] = big.NewInt(12)
+	child.Punished = make(map[common.Address]uint64)
+	child.SystemConfig.QosConfig[1] = 90
+	child.FlowMiner.FlowMiner[addr] = map[common.Hash]*FlowMinerReport{child.Hash: {Target: addr}}
+	if err := alien.storeLayer(base, child); err != nil {
+		t.Fatalf("failed to store layer: %v", err)
+	}
+	// Second layer on top of the first one
+	grandchild := child.copy()
+	grandchild.Number, grandchild.Hash = child.Number+1, common.HexToHash("0x44")
+	delete(grandchild.Tally, addr)
+	grandchild.FlowTotal = big.NewInt(77)
+	if err := alien.storeLayer(child, grandchild); err != nil {
+		t.Fatalf("failed to store layer: %v", err)
+	}
+	if child.layers != 1 || grandchild.layers != 2 {
+		t.Fatalf("layer depth mismatch: have %d and %d, want 1 and 2", child.layers, grandchild.layers)
+	}
+	enc, _ := db.Get(snapshotLayerKey(child.Hash))
+	var layer snapshotLayer
+	if err := rlp.DecodeBytes(enc, &layer); err != nil {
+		t.Fatalf("failed to decode layer: %v", err)
+	}
+	if len(layer.Items) > 12 {
+		t.Errorf("layer holds %d items, more than the changed entries", len(layer.Items))
+	}
+	for _, want := range []*Snapshot{child, grandchild} {
+		snap, layers, err := readSnapshot(db, want.Hash)
+		if err != nil {
+			t.Fatalf("failed to read snapshot %d: %v", want.Number, err)
+		}
+		if layers != want.layers {
+			t.Errorf("snapshot %d: layers mismatch: have %d, want %d", want.Number, layers, want.layers)
+		}
+		have, _ := json.Marshal(snap)
+		if wantJSON, _ := json.Marshal(want); !bytes.Equal(have, wantJSON) {
+			t.Errorf("snapshot %d rebuilt wrong\nhave %s\nwant %s", want.Number, have, wantJSON)
+		}
+	}
+	// Too many stacked layers are flattened into a new base
+	grandchild.layers = maxSnapshotLayers - 1
+	last := grandchild.copy()
+	last.Number, last.Hash = grandchild.Number+1, common.HexToHash("0x45")
+	if err := alien.storeLayer(grandchild, last); err != nil {
+		t.Fatalf("failed to store layer: %v", err)
+	}
+	if ok, _ := db.Has(snapshotLayerKey(last.Hash)); ok || last.layers != 0 {
+		t.Errorf("layers not flattened, depth %d", last.layers)
+	}
+	if _, layers, err := readSnapshot(db, last.Hash); err != nil || layers != 0 {
+		t.Errorf("flattened snapshot not stored as base: layers %d, err %v", layers, err)
+	}
+}
@@ -0,0 +1,1090 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// Snapshots are stored under the "alien-"+hash key. The first byte of the blob
+// tells its format: the legacy JSON encoding always starts with '{', any other
+// value is the version of the RLP encoding which follows it. The JSON shape is
+// kept for the RPC output only.
+const (
+	snapshotFormatJSON  = byte('{')
+	snapshotFormatRLPv1 = byte(0x01)
+)
+
+var errUnknownSnapshotFormat = errors.New("unknown snapshot format")
+
+// encodeSnapshot encodes the snapshot in the current storage format.
+func encodeSnapshot(s *Snapshot) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(newSnapshotRLP(s))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{snapshotFormatRLPv1}, blob...), nil
+}
+
+// decodeSnapshot decodes a stored snapshot of any known format.
+func decodeSnapshot(blob []byte) (*Snapshot, error) {
+	if len(blob) == 0 {
+		return nil, errUnknownSnapshotFormat
+	}
+	switch blob[0] {
+	case snapshotFormatJSON:
+		snap := new(Snapshot)
+		if err := json.Unmarshal(blob, snap); err != nil {
+			return nil, err
+		}
+		return snap, nil
+	case snapshotFormatRLPv1:
+		var enc snapshotRLP
+		if err := rlp.DecodeBytes(blob[1:], &enc); err != nil {
+			return nil, err
+		}
+		return enc.snapshot(), nil
+	}
+	return nil, fmt.Errorf("%w: %#x", errUnknownSnapshotFormat, blob[0])
+}
+
+// migrateSnapshots rewrites the snapshots stored in the legacy JSON format in
+// the current format. It runs in the background and may be interrupted at any
+// time, the remaining snapshots are still loadable and migrated on next start.
+func migrateSnapshots(db ethdb.Database, quit chan struct{}) {
+	var (
+		start    = time.Now()
+		prefix   = []byte("alien-")
+		batch    = db.NewBatch()
+		migrated int
+	)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		select {
+		case <-quit:
+			log.Info("Alien snapshot migration interrupted", "migrated", migrated)
+			return
+		default:
+		}
+		// Only the snapshots themselves, not the lock caches sharing the prefix
+		key, blob := it.Key(), it.Value()
+		if len(key) != len(prefix)+common.HashLength || len(blob) == 0 || blob[0] != snapshotFormatJSON {
+			continue
+		}
+		snap, err := decodeSnapshot(blob)
+		if err != nil {
+			log.Warn("Failed to decode legacy alien snapshot", "key", common.Bytes2Hex(key), "err", err)
+			continue
+		}
+		enc, err := encodeSnapshot(snap)
+		if err != nil {
+			log.Warn("Failed to encode alien snapshot", "hash", snap.Hash, "err", err)
+			continue
+		}
+		if err := batch.Put(common.CopyBytes(key), enc); err != nil {
+			log.Warn("Failed to migrate alien snapshot", "hash", snap.Hash, "err", err)
+			return
+		}
+		migrated++
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Warn("Failed to migrate alien snapshots", "err", err)
+				return
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to migrate alien snapshots", "err", err)
+		return
+	}
+	if migrated > 0 {
+		log.Info("Migrated alien snapshots", "count", migrated, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
+// The maps of the snapshot are stored as lists of entries sorted by key, so
+// the same snapshot always gives the same blob.
+
+type addressBigEntry struct {
+	Key   common.Address
+	Value *big.Int
+}
+
+type addressUintEntry struct {
+	Key   common.Address
+	Value uint64
+}
+
+type jailEntry struct {
+	Key     common.Address
+	Records []*JailRecord
+}
+
+type randomEntry struct {
+	Key    common.Address
+	Commit *RandomCommit
+}
+
+type fulAllowanceEntry struct {
+	Key      common.Address
+	Spenders []addressBigEntry
+}
+
+type addressBoolEntry struct {
+	Key   common.Address
+	Value bool
+}
+
+type addressAddressEntry struct {
+	Key   common.Address
+	Value common.Address
+}
+
+type hashBigEntry struct {
+	Key   common.Hash
+	Value *big.Int
+}
+
+type uint32BigEntry struct {
+	Key   uint32
+	Value *big.Int
+}
+
+type voteEntry struct {
+	Key  common.Address
+	Vote *Vote
+}
+
+type confirmationEntry struct {
+	Number  uint64
+	Signers []common.Address
+}
+
+type proposalEntry struct {
+	Hash     common.Hash
+	Proposal *Proposal
+}
+
+type proposalRefundEntry struct {
+	Number  uint64
+	Refunds []addressBigEntry
+}
+
+type scCoinbaseEntry struct {
+	Hash     common.Hash
+	Coinbase []addressAddressEntry
+}
+
+type scConfirmationEntry struct {
+	Number        uint64
+	Confirmations []*SCConfirmation
+}
+
+type scRentInfoEntry struct {
+	Hash common.Hash
+	Rent *SCRentInfo
+}
+
+type scRecordRLP struct {
+	Record              []scConfirmationEntry
+	LastConfirmedNumber uint64
+	MaxHeaderNumber     uint64
+	CountPerPeriod      uint64
+	RewardPerPeriod     uint64
+	RentReward          []scRentInfoEntry
+}
+
+type scRecordEntry struct {
+	Hash   common.Hash
+	Record scRecordRLP
+}
+
+type scBlockRewardEntry struct {
+	Number uint64
+	Scores []addressUintEntry
+}
+
+type scRewardEntry struct {
+	Hash    common.Hash
+	Rewards []scBlockRewardEntry
+}
+
+type gasChargingEntry struct {
+	Hash     common.Hash
+	Charging GasCharging
+}
+
+type noticeCREntry struct {
+	Hash    common.Hash
+	NRecord []addressBoolEntry
+	Number  uint64
+	Type    uint64
+	Success bool
+}
+
+type ccNoticeRLP struct {
+	CurrentCharging []gasChargingEntry
+	ConfirmReceived []noticeCREntry
+}
+
+type scNoticeEntry struct {
+	Hash   common.Hash
+	Notice ccNoticeRLP
+}
+
+type fulBalanceEntry struct {
+	Key       common.Address
+	Balance   []*big.Int
+	CostTotal []hashBigEntry
+}
+
+type revenueEntry struct {
+	Key     common.Address
+	Revenue *RevenueParameter
+}
+
+type pledgeEntry struct {
+	Key    common.Address
+	Pledge *PledgeItem
+}
+
+type candidateStateEntry struct {
+	Key   common.Address
+	State *CandidateState
+}
+
+type bandwidthEntry struct {
+	Key       common.Address
+	Bandwidth *ClaimedBandwidth
+}
+
+type lockPledgeEntry struct {
+	Key    uint32
+	Pledge *PledgeItem
+}
+
+type lockBalanceEntry struct {
+	Number  uint64
+	Pledges []lockPledgeEntry
+}
+
+type lockRevenueEntry struct {
+	Key           common.Address
+	RewardBalance []uint32BigEntry
+	LockBalance   []lockBalanceEntry
+}
+
+type lockDataRLP struct {
+	FlowRevenue []lockRevenueEntry
+	CacheL1     []common.Hash
+	CacheL2     common.Hash
+	Locktype    string
+}
+
+type lockProfitRLP struct {
+	Number        uint64
+	Hash          common.Hash
+	RewardLock    *lockDataRLP `rlp:"nil"`
+	FlowLock      *lockDataRLP `rlp:"nil"`
+	BandwidthLock *lockDataRLP `rlp:"nil"`
+}
+
+type qosEntry struct {
+	Key   uint32
+	Value uint32
+}
+
+type managerEntry struct {
+	Key     uint32
+	Address common.Address
+}
+
+type lockParameterEntry struct {
+	Key       uint32
+	Parameter *LockParameter
+}
+
+type systemParameterRLP struct {
+	ExchRate       uint32
+	OffLine        uint32
+	Deposit        []uint32BigEntry
+	QosConfig      []qosEntry
+	ManagerAddress []managerEntry
+	LockParameters []lockParameterEntry
+}
+
+type flowReportEntry struct {
+	Hash   common.Hash
+	Report *FlowMinerReport
+}
+
+type flowMinerEntry struct {
+	Key     common.Address
+	Reports []flowReportEntry
+}
+
+type flowMinerRLP struct {
+	DayStartTime       uint64
+	FlowMinerPrevTotal uint64
+	FlowMiner          []flowMinerEntry
+	FlowMinerPrev      []flowMinerEntry
+	FlowMinerCache     []string
+	FlowMinerPrevCache []string
+}
+
+// snapshotRLP is the version 1 RLP encoding of Snapshot.
+type snapshotRLP struct {
+	LCRS            uint64
+	Period          uint64
+	Number          uint64
+	ConfirmedNumber uint64
+	Hash            common.Hash
+	HistoryHash     []common.Hash
+	Signers         []common.Address
+	Votes           []voteEntry
+	Tally           []addressBigEntry
+	Voters          []addressBigEntry
+	Candidates      []addressUintEntry
+	Punished        []addressUintEntry
+	Confirmations   []confirmationEntry
+	Proposals       []proposalEntry
+	HeaderTime      uint64
+	LoopStartTime   uint64
+	ProposalRefund  []proposalRefundEntry
+	SCCoinbase      []scCoinbaseEntry
+	SCRecordMap     []scRecordEntry
+	SCRewardMap     []scRewardEntry
+	SCNoticeMap     []scNoticeEntry
+	LocalNotice     *ccNoticeRLP `rlp:"nil"`
+	MinerReward     uint64
+	MinVB           []*big.Int
+	FULBalance      []fulBalanceEntry
+	RevenueNormal   []revenueEntry
+	RevenueFlow     []revenueEntry
+	CandidatePledge []pledgeEntry
+	TallyMiner      []candidateStateEntry
+	FlowPledge      []pledgeEntry
+	Bandwidth       []bandwidthEntry
+	FlowHarvest     []*big.Int
+	FlowRevenue     *lockProfitRLP `rlp:"nil"`
+	SystemConfig    systemParameterRLP
+	FlowMiner       *flowMinerRLP `rlp:"nil"`
+	FlowTotal       []*big.Int
+	SCMinerRevenue  []addressAddressEntry
+	SCFlowPledge    []addressBoolEntry
+	SCFULBalance    []addressBigEntry
+	SignerMissing   []common.Address
+	FulHash         common.Hash
+	DoubleSigned    []addressUintEntry  `rlp:"optional"`
+	Jails           []jailEntry         `rlp:"optional"`
+	RandomCommits   []randomEntry       `rlp:"optional"`
+	RandomBeacon    common.Hash         `rlp:"optional"`
+	ProposalResults []*ProposalResult   `rlp:"optional"`
+	MultiSigPending []*MultiSigProposal `rlp:"optional"`
+	FulAllowances   []fulAllowanceEntry `rlp:"optional"`
+}
+
+// encodeNilBig keeps a nil big.Int apart from zero, which RLP can't, by
+// storing it in a list of at most one item.
+func encodeNilBig(b *big.Int) []*big.Int {
+	if b == nil {
+		return nil
+	}
+	return []*big.Int{b}
+}
+
+func decodeNilBig(list []*big.Int) *big.Int {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[0]
+}
+
+func addressLess(a, b common.Address) bool { return bytes.Compare(a[:], b[:]) < 0 }
+func hashLess(a, b common.Hash) bool       { return bytes.Compare(a[:], b[:]) < 0 }
+
+func encodeAddressBig(m map[common.Address]*big.Int) []addressBigEntry {
+	entries := make([]addressBigEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, addressBigEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeAddressBig(entries []addressBigEntry) map[common.Address]*big.Int {
+	m := make(map[common.Address]*big.Int, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeAddressUint(m map[common.Address]uint64) []addressUintEntry {
+	entries := make([]addressUintEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, addressUintEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeAddressUint(entries []addressUintEntry) map[common.Address]uint64 {
+	m := make(map[common.Address]uint64, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeJails(m map[common.Address][]*JailRecord) []jailEntry {
+	entries := make([]jailEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, jailEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeJails(entries []jailEntry) map[common.Address][]*JailRecord {
+	m := make(map[common.Address][]*JailRecord, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Records
+	}
+	return m
+}
+
+func encodeRandomCommits(m map[common.Address]*RandomCommit) []randomEntry {
+	entries := make([]randomEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, randomEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeRandomCommits(entries []randomEntry) map[common.Address]*RandomCommit {
+	m := make(map[common.Address]*RandomCommit, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Commit
+	}
+	return m
+}
+
+func encodeMultiSigPending(m map[common.Hash]*MultiSigProposal) []*MultiSigProposal {
+	if m == nil {
+		return nil
+	}
+	proposals := make([]*MultiSigProposal, 0, len(m))
+	for _, proposal := range m {
+		proposals = append(proposals, proposal)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return hashLess(proposals[i].Hash, proposals[j].Hash) })
+	return proposals
+}
+
+func decodeMultiSigPending(proposals []*MultiSigProposal) map[common.Hash]*MultiSigProposal {
+	if len(proposals) == 0 {
+		return nil
+	}
+	m := make(map[common.Hash]*MultiSigProposal, len(proposals))
+	for _, proposal := range proposals {
+		m[proposal.Hash] = proposal
+	}
+	return m
+}
+
+func encodeFulAllowances(m map[common.Address]FulAllowance) []fulAllowanceEntry {
+	if m == nil {
+		return nil
+	}
+	entries := make([]fulAllowanceEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, fulAllowanceEntry{key, encodeAddressBig(value)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeFulAllowances(entries []fulAllowanceEntry) map[common.Address]FulAllowance {
+	if len(entries) == 0 {
+		return nil
+	}
+	m := make(map[common.Address]FulAllowance, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = decodeAddressBig(entry.Spenders)
+	}
+	return m
+}
+
+func encodeAddressBool(m map[common.Address]bool) []addressBoolEntry {
+	entries := make([]addressBoolEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, addressBoolEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeAddressBool(entries []addressBoolEntry) map[common.Address]bool {
+	m := make(map[common.Address]bool, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeAddressAddress(m map[common.Address]common.Address) []addressAddressEntry {
+	entries := make([]addressAddressEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, addressAddressEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeAddressAddress(entries []addressAddressEntry) map[common.Address]common.Address {
+	m := make(map[common.Address]common.Address, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeHashBig(m map[common.Hash]*big.Int) []hashBigEntry {
+	entries := make([]hashBigEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, hashBigEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return hashLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeHashBig(entries []hashBigEntry) map[common.Hash]*big.Int {
+	m := make(map[common.Hash]*big.Int, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeUint32Big(m map[uint32]*big.Int) []uint32BigEntry {
+	entries := make([]uint32BigEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, uint32BigEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+func decodeUint32Big(entries []uint32BigEntry) map[uint32]*big.Int {
+	m := make(map[uint32]*big.Int, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Value
+	}
+	return m
+}
+
+func encodeAddresses(addresses []*common.Address) []common.Address {
+	list := make([]common.Address, 0, len(addresses))
+	for _, address := range addresses {
+		list = append(list, *address)
+	}
+	return list
+}
+
+func decodeAddresses(list []common.Address) []*common.Address {
+	addresses := make([]*common.Address, 0, len(list))
+	for i := range list {
+		addresses = append(addresses, &list[i])
+	}
+	return addresses
+}
+
+func encodeRevenues(m map[common.Address]*RevenueParameter) []revenueEntry {
+	entries := make([]revenueEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, revenueEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeRevenues(entries []revenueEntry) map[common.Address]*RevenueParameter {
+	m := make(map[common.Address]*RevenueParameter, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Revenue
+	}
+	return m
+}
+
+func encodePledges(m map[common.Address]*PledgeItem) []pledgeEntry {
+	entries := make([]pledgeEntry, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, pledgeEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodePledges(entries []pledgeEntry) map[common.Address]*PledgeItem {
+	m := make(map[common.Address]*PledgeItem, len(entries))
+	for _, entry := range entries {
+		m[entry.Key] = entry.Pledge
+	}
+	return m
+}
+
+func encodeCCNotice(notice *CCNotice) ccNoticeRLP {
+	var enc ccNoticeRLP
+	for hash, charging := range notice.CurrentCharging {
+		enc.CurrentCharging = append(enc.CurrentCharging, gasChargingEntry{hash, charging})
+	}
+	sort.Slice(enc.CurrentCharging, func(i, j int) bool {
+		return hashLess(enc.CurrentCharging[i].Hash, enc.CurrentCharging[j].Hash)
+	})
+	for hash, received := range notice.ConfirmReceived {
+		enc.ConfirmReceived = append(enc.ConfirmReceived, noticeCREntry{
+			Hash:    hash,
+			NRecord: encodeAddressBool(received.NRecord),
+			Number:  received.Number,
+			Type:    received.Type,
+			Success: received.Success,
+		})
+	}
+	sort.Slice(enc.ConfirmReceived, func(i, j int) bool {
+		return hashLess(enc.ConfirmReceived[i].Hash, enc.ConfirmReceived[j].Hash)
+	})
+	return enc
+}
+
+func (enc *ccNoticeRLP) notice() *CCNotice {
+	notice := &CCNotice{
+		CurrentCharging: make(map[common.Hash]GasCharging, len(enc.CurrentCharging)),
+		ConfirmReceived: make(map[common.Hash]NoticeCR, len(enc.ConfirmReceived)),
+	}
+	for _, entry := range enc.CurrentCharging {
+		notice.CurrentCharging[entry.Hash] = entry.Charging
+	}
+	for _, entry := range enc.ConfirmReceived {
+		notice.ConfirmReceived[entry.Hash] = NoticeCR{
+			NRecord: decodeAddressBool(entry.NRecord),
+			Number:  entry.Number,
+			Type:    entry.Type,
+			Success: entry.Success,
+		}
+	}
+	return notice
+}
+
+func encodeLockData(data *LockData) *lockDataRLP {
+	if data == nil {
+		return nil
+	}
+	enc := &lockDataRLP{
+		FlowRevenue: make([]lockRevenueEntry, 0, len(data.FlowRevenue)),
+		CacheL1:     data.CacheL1,
+		CacheL2:     data.CacheL2,
+		Locktype:    data.Locktype,
+	}
+	for address, balance := range data.FlowRevenue {
+		entry := lockRevenueEntry{
+			Key:           address,
+			RewardBalance: encodeUint32Big(balance.RewardBalance),
+		}
+		for number, pledges := range balance.LockBalance {
+			item := lockBalanceEntry{Number: number}
+			for who, pledge := range pledges {
+				item.Pledges = append(item.Pledges, lockPledgeEntry{who, pledge})
+			}
+			sort.Slice(item.Pledges, func(i, j int) bool { return item.Pledges[i].Key < item.Pledges[j].Key })
+			entry.LockBalance = append(entry.LockBalance, item)
+		}
+		sort.Slice(entry.LockBalance, func(i, j int) bool { return entry.LockBalance[i].Number < entry.LockBalance[j].Number })
+		enc.FlowRevenue = append(enc.FlowRevenue, entry)
+	}
+	sort.Slice(enc.FlowRevenue, func(i, j int) bool { return addressLess(enc.FlowRevenue[i].Key, enc.FlowRevenue[j].Key) })
+	return enc
+}
+
+func (enc *lockDataRLP) lockData() *LockData {
+	if enc == nil {
+		return nil
+	}
+	data := &LockData{
+		FlowRevenue: make(map[common.Address]*LockBalanceData, len(enc.FlowRevenue)),
+		CacheL1:     enc.CacheL1,
+		CacheL2:     enc.CacheL2,
+		Locktype:    enc.Locktype,
+	}
+	for _, entry := range enc.FlowRevenue {
+		balance := &LockBalanceData{
+			RewardBalance: decodeUint32Big(entry.RewardBalance),
+			LockBalance:   make(map[uint64]map[uint32]*PledgeItem, len(entry.LockBalance)),
+		}
+		for _, item := range entry.LockBalance {
+			pledges := make(map[uint32]*PledgeItem, len(item.Pledges))
+			for _, pledge := range item.Pledges {
+				pledges[pledge.Key] = pledge.Pledge
+			}
+			balance.LockBalance[item.Number] = pledges
+		}
+		data.FlowRevenue[entry.Key] = balance
+	}
+	return data
+}
+
+func encodeFlowMiners(m map[common.Address]map[common.Hash]*FlowMinerReport) []flowMinerEntry {
+	entries := make([]flowMinerEntry, 0, len(m))
+	for address, reports := range m {
+		entry := flowMinerEntry{Key: address}
+		for hash, report := range reports {
+			entry.Reports = append(entry.Reports, flowReportEntry{hash, report})
+		}
+		sort.Slice(entry.Reports, func(i, j int) bool { return hashLess(entry.Reports[i].Hash, entry.Reports[j].Hash) })
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return addressLess(entries[i].Key, entries[j].Key) })
+	return entries
+}
+
+func decodeFlowMiners(entries []flowMinerEntry) map[common.Address]map[common.Hash]*FlowMinerReport {
+	m := make(map[common.Address]map[common.Hash]*FlowMinerReport, len(entries))
+	for _, entry := range entries {
+		reports := make(map[common.Hash]*FlowMinerReport, len(entry.Reports))
+		for _, report := range entry.Reports {
+			reports[report.Hash] = report.Report
+		}
+		m[entry.Key] = reports
+	}
+	return m
+}
+
+func newSnapshotRLP(s *Snapshot) *snapshotRLP {
+	enc := &snapshotRLP{
+		LCRS:            s.LCRS,
+		Period:          s.Period,
+		Number:          s.Number,
+		ConfirmedNumber: s.ConfirmedNumber,
+		Hash:            s.Hash,
+		HistoryHash:     s.HistoryHash,
+		Signers:         encodeAddresses(s.Signers),
+		Tally:           encodeAddressBig(s.Tally),
+		Voters:          encodeAddressBig(s.Voters),
+		Candidates:      encodeAddressUint(s.Candidates),
+		Punished:        encodeAddressUint(s.Punished),
+		HeaderTime:      s.HeaderTime,
+		LoopStartTime:   s.LoopStartTime,
+		MinerReward:     s.MinerReward,
+		MinVB:           encodeNilBig(s.MinVB),
+		RevenueNormal:   encodeRevenues(s.RevenueNormal),
+		RevenueFlow:     encodeRevenues(s.RevenueFlow),
+		CandidatePledge: encodePledges(s.CandidatePledge),
+		FlowPledge:      encodePledges(s.FlowPledge),
+		FlowHarvest:     encodeNilBig(s.FlowHarvest),
+		FlowTotal:       encodeNilBig(s.FlowTotal),
+		SCMinerRevenue:  encodeAddressAddress(s.SCMinerRevenue),
+		SCFlowPledge:    encodeAddressBool(s.SCFlowPledge),
+		SCFULBalance:    encodeAddressBig(s.SCFULBalance),
+		SignerMissing:   s.SignerMissing,
+		FulHash:         s.FulHash,
+		DoubleSigned:    encodeAddressUint(s.DoubleSigned),
+		Jails:           encodeJails(s.Jails),
+		RandomCommits:   encodeRandomCommits(s.RandomCommits),
+		RandomBeacon:    s.RandomBeacon,
+		ProposalResults: s.ProposalResults,
+		MultiSigPending: encodeMultiSigPending(s.MultiSigPending),
+		FulAllowances:   encodeFulAllowances(s.FulAllowances),
+	}
+	for voter, vote := range s.Votes {
+		enc.Votes = append(enc.Votes, voteEntry{voter, vote})
+	}
+	sort.Slice(enc.Votes, func(i, j int) bool { return addressLess(enc.Votes[i].Key, enc.Votes[j].Key) })
+
+	for number, signers := range s.Confirmations {
+		enc.Confirmations = append(enc.Confirmations, confirmationEntry{number, encodeAddresses(signers)})
+	}
+	sort.Slice(enc.Confirmations, func(i, j int) bool { return enc.Confirmations[i].Number < enc.Confirmations[j].Number })
+
+	for hash, proposal := range s.Proposals {
+		enc.Proposals = append(enc.Proposals, proposalEntry{hash, proposal})
+	}
+	sort.Slice(enc.Proposals, func(i, j int) bool { return hashLess(enc.Proposals[i].Hash, enc.Proposals[j].Hash) })
+
+	for number, refunds := range s.ProposalRefund {
+		enc.ProposalRefund = append(enc.ProposalRefund, proposalRefundEntry{number, encodeAddressBig(refunds)})
+	}
+	sort.Slice(enc.ProposalRefund, func(i, j int) bool { return enc.ProposalRefund[i].Number < enc.ProposalRefund[j].Number })
+
+	for hash, coinbase := range s.SCCoinbase {
+		enc.SCCoinbase = append(enc.SCCoinbase, scCoinbaseEntry{hash, encodeAddressAddress(coinbase)})
+	}
+	sort.Slice(enc.SCCoinbase, func(i, j int) bool { return hashLess(enc.SCCoinbase[i].Hash, enc.SCCoinbase[j].Hash) })
+
+	for hash, record := range s.SCRecordMap {
+		item := scRecordRLP{
+			LastConfirmedNumber: record.LastConfirmedNumber,
+			MaxHeaderNumber:     record.MaxHeaderNumber,
+			CountPerPeriod:      record.CountPerPeriod,
+			RewardPerPeriod:     record.RewardPerPeriod,
+		}
+		for number, confirmations := range record.Record {
+			item.Record = append(item.Record, scConfirmationEntry{number, confirmations})
+		}
+		sort.Slice(item.Record, func(i, j int) bool { return item.Record[i].Number < item.Record[j].Number })
+		for rentHash, rent := range record.RentReward {
+			item.RentReward = append(item.RentReward, scRentInfoEntry{rentHash, rent})
+		}
+		sort.Slice(item.RentReward, func(i, j int) bool { return hashLess(item.RentReward[i].Hash, item.RentReward[j].Hash) })
+		enc.SCRecordMap = append(enc.SCRecordMap, scRecordEntry{hash, item})
+	}
+	sort.Slice(enc.SCRecordMap, func(i, j int) bool { return hashLess(enc.SCRecordMap[i].Hash, enc.SCRecordMap[j].Hash) })
+
+	for hash, reward := range s.SCRewardMap {
+		entry := scRewardEntry{Hash: hash}
+		for number, blockReward := range reward.SCBlockRewardMap {
+			entry.Rewards = append(entry.Rewards, scBlockRewardEntry{number, encodeAddressUint(blockReward.RewardScoreMap)})
+		}
+		sort.Slice(entry.Rewards, func(i, j int) bool { return entry.Rewards[i].Number < entry.Rewards[j].Number })
+		enc.SCRewardMap = append(enc.SCRewardMap, entry)
+	}
+	sort.Slice(enc.SCRewardMap, func(i, j int) bool { return hashLess(enc.SCRewardMap[i].Hash, enc.SCRewardMap[j].Hash) })
+
+	for hash, notice := range s.SCNoticeMap {
+		enc.SCNoticeMap = append(enc.SCNoticeMap, scNoticeEntry{hash, encodeCCNotice(notice)})
+	}
+	sort.Slice(enc.SCNoticeMap, func(i, j int) bool { return hashLess(enc.SCNoticeMap[i].Hash, enc.SCNoticeMap[j].Hash) })
+
+	if s.LocalNotice != nil {
+		notice := encodeCCNotice(s.LocalNotice)
+		enc.LocalNotice = &notice
+	}
+	for address, balance := range s.FULBalance {
+		enc.FULBalance = append(enc.FULBalance, fulBalanceEntry{address, encodeNilBig(balance.Balance), encodeHashBig(balance.CostTotal)})
+	}
+	sort.Slice(enc.FULBalance, func(i, j int) bool { return addressLess(enc.FULBalance[i].Key, enc.FULBalance[j].Key) })
+
+	for address, state := range s.TallyMiner {
+		enc.TallyMiner = append(enc.TallyMiner, candidateStateEntry{address, state})
+	}
+	sort.Slice(enc.TallyMiner, func(i, j int) bool { return addressLess(enc.TallyMiner[i].Key, enc.TallyMiner[j].Key) })
+
+	for address, bandwidth := range s.Bandwidth {
+		enc.Bandwidth = append(enc.Bandwidth, bandwidthEntry{address, bandwidth})
+	}
+	sort.Slice(enc.Bandwidth, func(i, j int) bool { return addressLess(enc.Bandwidth[i].Key, enc.Bandwidth[j].Key) })
+
+	if s.FlowRevenue != nil {
+		enc.FlowRevenue = &lockProfitRLP{
+			Number:        s.FlowRevenue.Number,
+			Hash:          s.FlowRevenue.Hash,
+			RewardLock:    encodeLockData(s.FlowRevenue.RewardLock),
+			FlowLock:      encodeLockData(s.FlowRevenue.FlowLock),
+			BandwidthLock: encodeLockData(s.FlowRevenue.BandwidthLock),
+		}
+	}
+	enc.SystemConfig = systemParameterRLP{
+		ExchRate: s.SystemConfig.ExchRate,
+		OffLine:  s.SystemConfig.OffLine,
+		Deposit:  encodeUint32Big(s.SystemConfig.Deposit),
+	}
+	for who, qos := range s.SystemConfig.QosConfig {
+		enc.SystemConfig.QosConfig = append(enc.SystemConfig.QosConfig, qosEntry{who, qos})
+	}
+	sort.Slice(enc.SystemConfig.QosConfig, func(i, j int) bool {
+		return enc.SystemConfig.QosConfig[i].Key < enc.SystemConfig.QosConfig[j].Key
+	})
+	for who, address := range s.SystemConfig.ManagerAddress {
+		enc.SystemConfig.ManagerAddress = append(enc.SystemConfig.ManagerAddress, managerEntry{who, address})
+	}
+	sort.Slice(enc.SystemConfig.ManagerAddress, func(i, j int) bool {
+		return enc.SystemConfig.ManagerAddress[i].Key < enc.SystemConfig.ManagerAddress[j].Key
+	})
+	for who, parameter := range s.SystemConfig.LockParameters {
+		enc.SystemConfig.LockParameters = append(enc.SystemConfig.LockParameters, lockParameterEntry{who, parameter})
+	}
+	sort.Slice(enc.SystemConfig.LockParameters, func(i, j int) bool {
+		return enc.SystemConfig.LockParameters[i].Key < enc.SystemConfig.LockParameters[j].Key
+	})
+
+	if s.FlowMiner != nil {
+		enc.FlowMiner = &flowMinerRLP{
+			DayStartTime:       s.FlowMiner.DayStartTime,
+			FlowMinerPrevTotal: s.FlowMiner.FlowMinerPrevTotal,
+			FlowMiner:          encodeFlowMiners(s.FlowMiner.FlowMiner),
+			FlowMinerPrev:      encodeFlowMiners(s.FlowMiner.FlowMinerPrev),
+			FlowMinerCache:     s.FlowMiner.FlowMinerCache,
+			FlowMinerPrevCache: s.FlowMiner.FlowMinerPrevCache,
+		}
+	}
+	return enc
+}
+
+// snapshot rebuilds the snapshot. Every map is allocated, even if empty.
+func (enc *snapshotRLP) snapshot() *Snapshot {
+	s := &Snapshot{
+		LCRS:            enc.LCRS,
+		Period:          enc.Period,
+		Number:          enc.Number,
+		ConfirmedNumber: enc.ConfirmedNumber,
+		Hash:            enc.Hash,
+		HistoryHash:     enc.HistoryHash,
+		Signers:         decodeAddresses(enc.Signers),
+		Votes:           make(map[common.Address]*Vote, len(enc.Votes)),
+		Tally:           decodeAddressBig(enc.Tally),
+		Voters:          decodeAddressBig(enc.Voters),
+		Candidates:      decodeAddressUint(enc.Candidates),
+		Punished:        decodeAddressUint(enc.Punished),
+		Confirmations:   make(map[uint64][]*common.Address, len(enc.Confirmations)),
+		Proposals:       make(map[common.Hash]*Proposal, len(enc.Proposals)),
+		HeaderTime:      enc.HeaderTime,
+		LoopStartTime:   enc.LoopStartTime,
+		ProposalRefund:  make(map[uint64]map[common.Address]*big.Int, len(enc.ProposalRefund)),
+		SCCoinbase:      make(map[common.Hash]map[common.Address]common.Address, len(enc.SCCoinbase)),
+		SCRecordMap:     make(map[common.Hash]*SCRecord, len(enc.SCRecordMap)),
+		SCRewardMap:     make(map[common.Hash]*SCReward, len(enc.SCRewardMap)),
+		SCNoticeMap:     make(map[common.Hash]*CCNotice, len(enc.SCNoticeMap)),
+		MinerReward:     enc.MinerReward,
+		MinVB:           decodeNilBig(enc.MinVB),
+		FULBalance:      make(map[common.Address]*FULLBalanceData, len(enc.FULBalance)),
+		RevenueNormal:   decodeRevenues(enc.RevenueNormal),
+		RevenueFlow:     decodeRevenues(enc.RevenueFlow),
+		CandidatePledge: decodePledges(enc.CandidatePledge),
+		TallyMiner:      make(map[common.Address]*CandidateState, len(enc.TallyMiner)),
+		FlowPledge:      decodePledges(enc.FlowPledge),
+		Bandwidth:       make(map[common.Address]*ClaimedBandwidth, len(enc.Bandwidth)),
+		FlowHarvest:     decodeNilBig(enc.FlowHarvest),
+		SystemConfig: SystemParameter{
+			ExchRate:       enc.SystemConfig.ExchRate,
+			OffLine:        enc.SystemConfig.OffLine,
+			Deposit:        decodeUint32Big(enc.SystemConfig.Deposit),
+			QosConfig:      make(map[uint32]uint32, len(enc.SystemConfig.QosConfig)),
+			ManagerAddress: make(map[uint32]common.Address, len(enc.SystemConfig.ManagerAddress)),
+			LockParameters: make(map[uint32]*LockParameter, len(enc.SystemConfig.LockParameters)),
+		},
+		FlowTotal:       decodeNilBig(enc.FlowTotal),
+		SCMinerRevenue:  decodeAddressAddress(enc.SCMinerRevenue),
+		SCFlowPledge:    decodeAddressBool(enc.SCFlowPledge),
+		SCFULBalance:    decodeAddressBig(enc.SCFULBalance),
+		SignerMissing:   enc.SignerMissing,
+		FulHash:         enc.FulHash,
+		DoubleSigned:    decodeAddressUint(enc.DoubleSigned),
+		Jails:           decodeJails(enc.Jails),
+		RandomCommits:   decodeRandomCommits(enc.RandomCommits),
+		RandomBeacon:    enc.RandomBeacon,
+		ProposalResults: enc.ProposalResults,
+		MultiSigPending: decodeMultiSigPending(enc.MultiSigPending),
+		FulAllowances:   decodeFulAllowances(enc.FulAllowances),
+	}
+	for _, entry := range enc.Votes {
+		s.Votes[entry.Key] = entry.Vote
+	}
+	for _, entry := range enc.Confirmations {
+		s.Confirmations[entry.Number] = decodeAddresses(entry.Signers)
+	}
+	for _, entry := range enc.Proposals {
+		s.Proposals[entry.Hash] = entry.Proposal
+	}
+	for _, entry := range enc.ProposalRefund {
+		s.ProposalRefund[entry.Number] = decodeAddressBig(entry.Refunds)
+	}
+	for _, entry := range enc.SCCoinbase {
+		s.SCCoinbase[entry.Hash] = decodeAddressAddress(entry.Coinbase)
+	}
+	for _, entry := range enc.SCRecordMap {
+		record := &SCRecord{
+			Record:              make(map[uint64][]*SCConfirmation, len(entry.Record.Record)),
+			LastConfirmedNumber: entry.Record.LastConfirmedNumber,
+			MaxHeaderNumber:     entry.Record.MaxHeaderNumber,
+			CountPerPeriod:      entry.Record.CountPerPeriod,
+			RewardPerPeriod:     entry.Record.RewardPerPeriod,
+			RentReward:          make(map[common.Hash]*SCRentInfo, len(entry.Record.RentReward)),
+		}
+		for _, item := range entry.Record.Record {
+			record.Record[item.Number] = item.Confirmations
+		}
+		for _, item := range entry.Record.RentReward {
+			record.RentReward[item.Hash] = item.Rent
+		}
+		s.SCRecordMap[entry.Hash] = record
+	}
+	for _, entry := range enc.SCRewardMap {
+		reward := &SCReward{SCBlockRewardMap: make(map[uint64]*SCBlockReward, len(entry.Rewards))}
+		for _, item := range entry.Rewards {
+			reward.SCBlockRewardMap[item.Number] = &SCBlockReward{RewardScoreMap: decodeAddressUint(item.Scores)}
+		}
+		s.SCRewardMap[entry.Hash] = reward
+	}
+	for _, entry := range enc.SCNoticeMap {
+		s.SCNoticeMap[entry.Hash] = entry.Notice.notice()
+	}
+	if enc.LocalNotice != nil {
+		s.LocalNotice = enc.LocalNotice.notice()
+	}
+	for _, entry := range enc.FULBalance {
+		s.FULBalance[entry.Key] = &FULLBalanceData{Balance: decodeNilBig(entry.Balance), CostTotal: decodeHashBig(entry.CostTotal)}
+	}
+	for _, entry := range enc.TallyMiner {
+		s.TallyMiner[entry.Key] = entry.State
+	}
+	for _, entry := range enc.Bandwidth {
+		s.Bandwidth[entry.Key] = entry.Bandwidth
+	}
+	if enc.FlowRevenue != nil {
+		s.FlowRevenue = &LockProfitSnap{
+			Number:        enc.FlowRevenue.Number,
+			Hash:          enc.FlowRevenue.Hash,
+			RewardLock:    enc.FlowRevenue.RewardLock.lockData(),
+			FlowLock:      enc.FlowRevenue.FlowLock.lockData(),
+			BandwidthLock: enc.FlowRevenue.BandwidthLock.lockData(),
+		}
+	}
+	for _, entry := range enc.SystemConfig.QosConfig {
+		s.SystemConfig.QosConfig[entry.Key] = entry.Value
+	}
+	for _, entry := range enc.SystemConfig.ManagerAddress {
+		s.SystemConfig.ManagerAddress[entry.Key] = entry.Address
+	}
+	for _, entry := range enc.SystemConfig.LockParameters {
+		s.SystemConfig.LockParameters[entry.Key] = entry.Parameter
+	}
+	if enc.FlowMiner != nil {
+		s.FlowMiner = &FlowMinerSnap{
+			DayStartTime:       enc.FlowMiner.DayStartTime,
+			FlowMinerPrevTotal: enc.FlowMiner.FlowMinerPrevTotal,
+			FlowMiner:          decodeFlowMiners(enc.FlowMiner.FlowMiner),
+			FlowMinerPrev:      decodeFlowMiners(enc.FlowMiner.FlowMinerPrev),
+			FlowMinerCache:     enc.FlowMiner.FlowMinerCache,
+			FlowMinerPrevCache: enc.FlowMiner.FlowMinerPrevCache,
+		}
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+func testEncodingSnapshot() *Snapshot {
+	var (
+		addr1 = common.HexToAddress("0xbec92229b1bd96919c8ffc993171fa6504121dc6")
+		addr2 = common.HexToAddress("0xa63b29ebe0a141b87a87e39de17f17346e11e1b7")
+		hash1 = common.HexToHash("0x01")
+		hash2 = common.HexToHash("0x02")
+	)
+	config := &params.AlienConfig{Period: 3, MaxSignerCount: 2, SelfVoteSigners: []common.UnprefixedAddress{common.UnprefixedAddress(addr1)}}
+	snap := newSnapshot(config, nil, hash1, []*Vote{{Voter: addr1, Candidate: addr1, Stake: big.NewInt(100)}}, 0)
+	snap.Number = 42
+	snap.Punished[addr2] = 3
+	snap.RandomCommits[addr1] = &RandomCommit{Number: 41, Hash: hash2}
+	snap.RandomBeacon = hash1
+	snap.Jails[addr2] = []*JailRecord{{Number: 30, Credit: 60, Release: 40, Unjailed: 41}, {Number: 42, Credit: 60, Release: 52}}
+	snap.Confirmations[41] = []*common.Address{&addr1, &addr2}
+	snap.Proposals[hash2] = &Proposal{Hash: hash2, ReceivedNumber: big.NewInt(40), CurrentDeposit: big.NewInt(0), Declares: []*Declare{{ProposalHash: hash2, Declarer: addr1, Decision: true}}}
+	snap.ProposalResults = []*ProposalResult{{Proposal: &Proposal{Hash: hash1, ReceivedNumber: big.NewInt(20), CurrentDeposit: big.NewInt(0), Declares: []*Declare{}}, Number: 30, Passed: true, YesStake: big.NewInt(100), NoStake: big.NewInt(0), RequiredStake: big.NewInt(66)}}
+	snap.MultiSigPending = map[common.Hash]*MultiSigProposal{hash2: {Hash: hash2, MultiSig: addr1, Proposer: addr2, To: addr1, Amount: big.NewInt(5), Action: []byte("NFC:1:Exit:x"), Number: 40, Approvals: []common.Address{addr2}}}
+	snap.FulAllowances = map[common.Address]FulAllowance{addr1: {addr2: big.NewInt(8)}}
+	snap.ProposalRefund[40] = map[common.Address]*big.Int{addr2: big.NewInt(7)}
+	snap.SCCoinbase[hash2] = map[common.Address]common.Address{addr1: addr2}
+	snap.SCRecordMap[hash2] = &SCRecord{
+		Record:          map[uint64][]*SCConfirmation{9: {{Hash: hash2, Coinbase: addr2, Number: 9, LoopInfo: []string{"a", "b"}}}},
+		MaxHeaderNumber: 9,
+		RentReward:      map[common.Hash]*SCRentInfo{hash1: {RentPerPeriod: big.NewInt(1), MaxRewardNumber: big.NewInt(2)}},
+	}
+	snap.SCRewardMap[hash2] = &SCReward{SCBlockRewardMap: map[uint64]*SCBlockReward{9: {RewardScoreMap: map[common.Address]uint64{addr2: 100}}}}
+	snap.SCNoticeMap[hash2] = &CCNotice{
+		CurrentCharging: map[common.Hash]GasCharging{hash1: {Target: addr1, Volume: 5, Hash: hash1}},
+		ConfirmReceived: map[common.Hash]NoticeCR{hash1: {NRecord: map[common.Address]bool{addr2: true}, Number: 8}},
+	}
+	snap.FULBalance[addr1] = &FULLBalanceData{Balance: big.NewInt(11), CostTotal: map[common.Hash]*big.Int{hash2: big.NewInt(3)}}
+	snap.RevenueNormal[addr1] = &RevenueParameter{RevenueAddress: addr2}
+	snap.CandidatePledge[addr1] = &PledgeItem{Amount: big.NewInt(1e18), Playment: big.NewInt(0), TargetAddress: addr1}
+	snap.FlowPledge[addr2] = &PledgeItem{Amount: big.NewInt(5), Playment: big.NewInt(1), StartHigh: 12}
+	snap.TallyMiner[addr2] = &CandidateState{SignerNumber: 1, Stake: big.NewInt(9)}
+	snap.Bandwidth[addr2] = &ClaimedBandwidth{ISPQosID: 1, BandwidthClaimed: 100}
+	snap.FlowRevenue.FlowLock.FlowRevenue[addr2] = &LockBalanceData{
+		RewardBalance: map[uint32]*big.Int{sscEnumFlwLock: big.NewInt(4)},
+		LockBalance:   map[uint64]map[uint32]*PledgeItem{30: {sscEnumFlwLock: {Amount: big.NewInt(2), Playment: big.NewInt(0)}}},
+	}
+	snap.FlowRevenue.RewardLock.CacheL1 = []common.Hash{hash1}
+	snap.SystemConfig.Deposit[0] = big.NewInt(6)
+	snap.SystemConfig.QosConfig[1] = 80
+	snap.SystemConfig.ManagerAddress[sscEnumSystem] = addr1
+	snap.SystemConfig.LockParameters[sscEnumCndLock] = &LockParameter{LockPeriod: 1, RlsPeriod: 2, Interval: 3}
+	snap.FlowMiner.FlowMiner[addr2] = map[common.Hash]*FlowMinerReport{hash1: {Target: addr2, Hash: hash1, ReportNumber: 1, FlowValue1: 2}}
+	snap.FlowMiner.FlowMinerCache = []string{"flow-40"}
+	snap.SCFlowPledge[addr2] = true
+	snap.SCFULBalance[addr1] = big.NewInt(8)
+	snap.SignerMissing = []common.Address{addr2}
+	snap.FulHash = hash2
+	return snap
+}
+
+func TestSnapshotEncoding(t *testing.T) {
+	snap := testEncodingSnapshot()
+	want, _ := json.Marshal(snap)
+
+	blob, err := encodeSnapshot(snap)
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	if blob[0] != snapshotFormatRLPv1 {
+		t.Fatalf("unexpected format tag %#x", blob[0])
+	}
+	if again, _ := encodeSnapshot(snap); !bytes.Equal(again, blob) {
+		t.Errorf("snapshot encoding not deterministic")
+	}
+	for _, stored := range [][]byte{blob, want} {
+		dec, err := decodeSnapshot(stored)
+		if err != nil {
+			t.Fatalf("failed to decode snapshot with tag %#x: %v", stored[0], err)
+		}
+		if have, _ := json.Marshal(dec); !bytes.Equal(have, want) {
+			t.Errorf("snapshot with tag %#x changed\nhave %s\nwant %s", stored[0], have, want)
+		}
+	}
+	snap.MinVB, snap.FlowHarvest = nil, big.NewInt(0)
+	blob, _ = encodeSnapshot(snap)
+	dec, err := decodeSnapshot(blob)
+	if err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if dec.MinVB != nil || dec.FlowHarvest == nil || dec.FlowHarvest.Sign() != 0 {
+		t.Errorf("nil and zero values mixed up: MinVB %v, FlowHarvest %v", dec.MinVB, dec.FlowHarvest)
+	}
+	if _, err := decodeSnapshot([]byte{0x7f}); err == nil {
+		t.Errorf("unknown format decoded")
+	}
+}
+
+func TestMigrateSnapshots(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	snap := testEncodingSnapshot()
+	legacy, _ := json.Marshal(snap)
+	key := append([]byte("alien-"), snap.Hash[:]...)
+	cacheKey := append([]byte("alien-flow-l1-"), snap.Hash[:]...)
+	db.Put(key, legacy)
+	db.Put(cacheKey, legacy)
+
+	migrateSnapshots(db, make(chan struct{}))
+
+	blob, _ := db.Get(key)
+	if want, _ := encodeSnapshot(snap); !bytes.Equal(blob, want) {
+		t.Errorf("snapshot not migrated, format tag %#x", blob[0])
+	}
+	if blob, _ := db.Get(cacheKey); !bytes.Equal(blob, legacy) {
+		t.Errorf("lock cache modified by migration")
+	}
+}
@@ -0,0 +1,421 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/state"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/ethdb/memorydb"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+var errVerifyAborted = errors.New("snapshot verification aborted")
+
+// SnapshotMismatch is the first difference found between a stored snapshot and
+// the one replayed from genesis.
+type SnapshotMismatch struct {
+	Number   uint64      `json:"number"`
+	Hash     common.Hash `json:"hash"`
+	Field    string      `json:"field"`
+	Stored   string      `json:"stored"`
+	Replayed string      `json:"replayed"`
+}
+
+// SnapshotVerification is the result of a snapshot consistency check.
+type SnapshotVerification struct {
+	Head        uint64            `json:"head"`        // Last block replayed
+	Checkpoints uint64            `json:"checkpoints"` // Number of stored snapshots compared
+	Mismatch    *SnapshotMismatch `json:"mismatch"`    // First difference, nil if consistent
+}
+
+// VerifySnapshots rebuilds the voting snapshots from genesis over the canonical
+// headers up to number, and compares them with every stored checkpoint and
+// with the FulDataRoot of every header. The database isn't modified.
+func VerifySnapshots(config *params.AlienConfig, db ethdb.Database, number uint64) (*SnapshotVerification, error) {
+	conf := configWithDefaults(config)
+	return verifySnapshots(&conf, db, number, nil)
+}
+
+// VerifySnapshots is VerifySnapshots on the database of the engine. It stops
+// early if quit is closed.
+func (a *Alien) VerifySnapshots(number uint64, quit <-chan struct{}) (*SnapshotVerification, error) {
+	conf := *a.config
+	return verifySnapshots(&conf, a.db, number, quit)
+}
+
+func verifySnapshots(config *params.AlienConfig, db ethdb.Database, number uint64, quit <-chan struct{}) (*SnapshotVerification, error) {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		overlay = newOverlayDatabase(db)
+		result  = new(SnapshotVerification)
+	)
+	genesis := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, 0), 0)
+	if genesis == nil {
+		return nil, errors.New("genesis header not found")
+	}
+	votes, err := verifyGenesisVotes(config, db, genesis.Root)
+	if err != nil {
+		return nil, err
+	}
+	snap := newSnapshot(config, nil, genesis.Hash(), votes, defaultLoopCntRecalculateSigners)
+	if isGeFulTrieNumber(config, 1) {
+		if err := snap.initFulTrie(overlay); err != nil {
+			return nil, err
+		}
+	}
+	if err := snap.store(overlay); err != nil {
+		return nil, err
+	}
+	for {
+		// Compare the replayed snapshot with the stored one, if any
+		if blob, err := db.Get(snapshotKey(snap.Hash)); err == nil {
+			stored, err := decodeSnapshot(blob)
+			if err != nil {
+				return nil, fmt.Errorf("stored snapshot %d undecodable: %v", snap.Number, err)
+			}
+			result.Checkpoints++
+			if mismatch, err := diffSnapshots(stored, snap); err != nil || mismatch != nil {
+				if mismatch != nil {
+					mismatch.Number, mismatch.Hash = snap.Number, snap.Hash
+				}
+				result.Mismatch = mismatch
+				return result, err
+			}
+		}
+		if snap.Number >= number {
+			break
+		}
+		select {
+		case <-quit:
+			return result, errVerifyAborted
+		default:
+		}
+		hash := rawdb.ReadCanonicalHash(db, snap.Number+1)
+		header := rawdb.ReadHeader(db, hash, snap.Number+1)
+		if header == nil {
+			break
+		}
+		// Mirror the lock data moved into the caches by GrantProfit, then apply
+		if err := snap.FlowRevenue.replayPayProfit(overlay, config, header.Number.Uint64()); err != nil {
+			return nil, err
+		}
+		next, err := snap.apply([]*types.Header{header}, overlay, nil)
+		if err != nil {
+			var rootErr *fulRootError
+			if errors.As(err, &rootErr) {
+				result.Head = header.Number.Uint64()
+				result.Mismatch = &SnapshotMismatch{
+					Number:   header.Number.Uint64(),
+					Hash:     header.Hash(),
+					Field:    "FulDataRoot",
+					Stored:   rootErr.header.Hex(),
+					Replayed: rootErr.calculated.Hex(),
+				}
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to apply block %d: %v", header.Number, err)
+		}
+		snap = next
+		result.Head = snap.Number
+		if snap.Number%checkpointInterval == 0 {
+			if err := snap.store(overlay); err != nil {
+				return nil, err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying voting snapshots", "number", snap.Number, "checkpoints", result.Checkpoints, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Verified voting snapshots", "head", result.Head, "checkpoints", result.Checkpoints, "elapsed", common.PrettyDuration(time.Since(start)))
+	return result, nil
+}
+
+// verifyGenesisVotes returns the votes of the self voting signers at genesis,
+// staking their genesis balance.
+func verifyGenesisVotes(config *params.AlienConfig, db ethdb.Database, root common.Hash) ([]*Vote, error) {
+	var balanceOf func(addr common.Address) *big.Int
+	if config.LightConfig != nil {
+		balanceOf = func(addr common.Address) *big.Int {
+			stake := new(big.Int)
+			if account, ok := config.LightConfig.Alloc[common.UnprefixedAddress(addr)]; ok {
+				stake.UnmarshalText([]byte(account.Balance))
+			}
+			return stake
+		}
+	} else {
+		statedb, err := state.New(root, state.NewDatabase(db), nil)
+		if err != nil {
+			return nil, fmt.Errorf("genesis state unavailable: %v", err)
+		}
+		balanceOf = statedb.GetBalance
+	}
+	var (
+		votes        []*Vote
+		alreadyVoted = make(map[common.Address]struct{})
+	)
+	for _, unPrefixVoter := range config.SelfVoteSigners {
+		voter := common.Address(unPrefixVoter)
+		if _, ok := alreadyVoted[voter]; !ok {
+			votes = append(votes, &Vote{Voter: voter, Candidate: voter, Stake: balanceOf(voter)})
+			alreadyVoted[voter] = struct{}{}
+		}
+	}
+	return votes, nil
+}
+
+// replayPayProfit mirrors the snapshot changes GrantProfit makes when paying
+// the locked rewards in the block with the given number.
+func (s *LockProfitSnap) replayPayProfit(db ethdb.Database, config *params.AlienConfig, number uint64) error {
+	switch {
+	case number == 0:
+		return nil
+	case isPaySignerRewards(number, config):
+		return s.RewardLock.saveCacheL1(db, s.Hash)
+	case isPayFlowRewards(number, config):
+		return s.FlowLock.saveCacheL1(db, s.Hash)
+	case isPayBandWidthRewards(number, config):
+		return s.BandwidthLock.saveCacheL1(db, s.Hash)
+	}
+	return nil
+}
+
+// diffSnapshots compares the JSON encodings of two snapshots and returns the
+// first differing field, nil if they are equal.
+func diffSnapshots(stored, replayed *Snapshot) (*SnapshotMismatch, error) {
+	storedJSON, err := snapshotJSONValue(stored)
+	if err != nil {
+		return nil, err
+	}
+	replayedJSON, err := snapshotJSONValue(replayed)
+	if err != nil {
+		return nil, err
+	}
+	return diffJSONValues("", storedJSON, replayedJSON), nil
+}
+
+func snapshotJSONValue(s *Snapshot) (interface{}, error) {
+	enc, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(enc))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// diffJSONValues walks two decoded JSON values in a deterministic order and
+// returns the first difference.
+func diffJSONValues(path string, stored, replayed interface{}) *SnapshotMismatch {
+	switch s := stored.(type) {
+	case map[string]interface{}:
+		if r, ok := replayed.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(s)+len(r))
+			for key := range s {
+				keys = append(keys, key)
+			}
+			for key := range r {
+				if _, ok := s[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if mismatch := diffJSONValues(path+"."+key, s[key], r[key]); mismatch != nil {
+					return mismatch
+				}
+			}
+			return nil
+		}
+	case []interface{}:
+		if r, ok := replayed.([]interface{}); ok {
+			for i := 0; i < len(s) || i < len(r); i++ {
+				var storedItem, replayedItem interface{}
+				if i < len(s) {
+					storedItem = s[i]
+				}
+				if i < len(r) {
+					replayedItem = r[i]
+				}
+				if i >= len(s) || i >= len(r) {
+					return newJSONMismatch(path+"["+strconv.Itoa(i)+"]", storedItem, replayedItem)
+				}
+				if mismatch := diffJSONValues(path+"["+strconv.Itoa(i)+"]", storedItem, replayedItem); mismatch != nil {
+					return mismatch
+				}
+			}
+			return nil
+		}
+	default:
+		storedEnc, _ := json.Marshal(stored)
+		replayedEnc, _ := json.Marshal(replayed)
+		if bytes.Equal(storedEnc, replayedEnc) {
+			return nil
+		}
+	}
+	return newJSONMismatch(path, stored, replayed)
+}
+
+func newJSONMismatch(path string, stored, replayed interface{}) *SnapshotMismatch {
+	storedEnc, _ := json.Marshal(stored)
+	replayedEnc, _ := json.Marshal(replayed)
+	if len(path) > 0 && path[0] == '.' {
+		path = path[1:]
+	}
+	return &SnapshotMismatch{Field: path, Stored: string(storedEnc), Replayed: string(replayedEnc)}
+}
+
+// overlayDatabase is a database whose writes are kept in memory, on top of a
+// database only read from. Iterators only see the underlying database.
+type overlayDatabase struct {
+	ethdb.Database
+
+	overlay *memorydb.Database
+	deleted map[string]struct{}
+	lock    sync.RWMutex
+}
+
+func newOverlayDatabase(db ethdb.Database) *overlayDatabase {
+	return &overlayDatabase{
+		Database: db,
+		overlay:  memorydb.New(),
+		deleted:  make(map[string]struct{}),
+	}
+}
+
+func (db *overlayDatabase) Has(key []byte) (bool, error) {
+	if ok, _ := db.overlay.Has(key); ok {
+		return true, nil
+	}
+	db.lock.RLock()
+	_, deleted := db.deleted[string(key)]
+	db.lock.RUnlock()
+	if deleted {
+		return false, nil
+	}
+	return db.Database.Has(key)
+}
+
+func (db *overlayDatabase) Get(key []byte) ([]byte, error) {
+	if value, err := db.overlay.Get(key); err == nil {
+		return value, nil
+	}
+	db.lock.RLock()
+	_, deleted := db.deleted[string(key)]
+	db.lock.RUnlock()
+	if deleted {
+		return nil, errors.New("not found")
+	}
+	return db.Database.Get(key)
+}
+
+func (db *overlayDatabase) Put(key []byte, value []byte) error {
+	db.lock.Lock()
+	delete(db.deleted, string(key))
+	db.lock.Unlock()
+	return db.overlay.Put(key, value)
+}
+
+func (db *overlayDatabase) Delete(key []byte) error {
+	db.lock.Lock()
+	db.deleted[string(key)] = struct{}{}
+	db.lock.Unlock()
+	return db.overlay.Delete(key)
+}
+
+func (db *overlayDatabase) NewBatch() ethdb.Batch {
+	return &overlayBatch{db: db}
+}
+
+// overlayBatch is a write batch of an overlayDatabase.
+type overlayBatch struct {
+	db     *overlayDatabase
+	writes []overlayWrite
+	size   int
+}
+
+type overlayWrite struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+func (b *overlayBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, overlayWrite{key: common.CopyBytes(key), value: common.CopyBytes(value)})
+	b.size += len(value)
+	return nil
+}
+
+func (b *overlayBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, overlayWrite{key: common.CopyBytes(key), delete: true})
+	b.size += len(key)
+	return nil
+}
+
+func (b *overlayBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *overlayBatch) Write() error {
+	for _, write := range b.writes {
+		if write.delete {
+			b.db.Delete(write.key)
+		} else {
+			b.db.Put(write.key, write.value)
+		}
+	}
+	return nil
+}
+
+func (b *overlayBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+func (b *overlayBatch) Replay(w ethdb.KeyValueWriter) error {
+	for _, write := range b.writes {
+		if write.delete {
+			if err := w.Delete(write.key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.Put(write.key, write.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	stored := testEncodingSnapshot()
+	blob, _ := encodeSnapshot(stored)
+	clone := func() *Snapshot {
+		snap, _ := decodeSnapshot(blob)
+		return snap
+	}
+	if mismatch, err := diffSnapshots(stored, clone()); err != nil || mismatch != nil {
+		t.Fatalf("equal snapshots differ: %+v, %v", mismatch, err)
+	}
+	addr := common.HexToAddress("0xa63b29ebe0a141b87a87e39de17f17346e11e1b7")
+	tests := []struct {
+		change   func(s *Snapshot)
+		field    string
+		stored   string
+		replayed string
+	}{
+		{
+			change:   func(s *Snapshot) { s.Punished[addr] = 4 },
+			field:    "punished.0xa63b29ebe0a141b87a87e39de17f17346e11e1b7",
+			stored:   "3",
+			replayed: "4",
+		},
+		{
+			change:   func(s *Snapshot) { s.Tally[addr] = big.NewInt(1) },
+			field:    "tally.0xa63b29ebe0a141b87a87e39de17f17346e11e1b7",
+			stored:   "null",
+			replayed: "1",
+		},
+		{
+			change:   func(s *Snapshot) { s.SignerMissing = nil },
+			field:    "signermissing",
+			stored:   `["0xa63b29ebe0a141b87a87e39de17f17346e11e1b7"]`,
+			replayed: "null",
+		},
+		{
+			change:   func(s *Snapshot) { s.HistoryHash = append(s.HistoryHash, common.Hash{}) },
+			field:    "historyHash[1]",
+			stored:   "null",
+			replayed: `"0x0000000000000000000000000000000000000000000000000000000000000000"`,
+		},
+	}
+	for i, tt := range tests {
+		replayed := clone()
+		tt.change(replayed)
+		mismatch, err := diffSnapshots(stored, replayed)
+		if err != nil {
+			t.Fatalf("test %d: failed to diff: %v", i, err)
+		}
+		if mismatch == nil {
+			t.Errorf("test %d: difference not found", i)
+			continue
+		}
+		if mismatch.Field != tt.field || mismatch.Stored != tt.stored || mismatch.Replayed != tt.replayed {
+			t.Errorf("test %d: mismatch %s %s/%s, want %s %s/%s", i, mismatch.Field, mismatch.Stored, mismatch.Replayed, tt.field, tt.stored, tt.replayed)
+		}
+	}
+}
+
+func TestOverlayDatabase(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	db.Put([]byte("a"), []byte{1})
+	db.Put([]byte("b"), []byte{2})
+
+	overlay := newOverlayDatabase(db)
+	overlay.Put([]byte("a"), []byte{3})
+	overlay.Delete([]byte("b"))
+	batch := overlay.NewBatch()
+	batch.Put([]byte("c"), []byte{4})
+	batch.Write()
+
+	if value, _ := overlay.Get([]byte("a")); len(value) != 1 || value[0] != 3 {
+		t.Errorf("overlay value not read: %v", value)
+	}
+	if ok, _ := overlay.Has([]byte("b")); ok {
+		t.Errorf("deleted value still visible")
+	}
+	if value, _ := overlay.Get([]byte("c")); len(value) != 1 || value[0] != 4 {
+		t.Errorf("batch value not read: %v", value)
+	}
+	if value, _ := db.Get([]byte("a")); value[0] != 1 {
+		t.Errorf("underlying database modified")
+	}
+	if ok, _ := db.Has([]byte("b")); !ok {
+		t.Errorf("underlying value deleted")
+	}
+	if ok, _ := db.Has([]byte("c")); ok {
+		t.Errorf("batch written to underlying database")
+	}
+}
@@ -1,6 +1,7 @@
 package alien
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/seaskycheng/sdvn/common"
@@ -26,6 +27,12 @@ const (
 	gp_s="GrantProfit"
 	fr_s="FlowReport"
 	mfrt_s="MinerFlowReportItem"
+	ds_s="DoubleSignSlash"
+	rd_s="CurrentBlockRedelegates"
+	msp_s="MultiSigProposals"
+	msa_s="MultiSigApprovals"
+	ft_s="FulTransfers"
+	fa_s="FulAllowances"
 )
 func verifyHeaderExtern(currentExtra *HeaderExtra, verifyExtra *HeaderExtra) error {
 
@@ -136,6 +143,54 @@ func verifyHeaderExtern(currentExtra *HeaderExtra, verifyExtra *HeaderExtra) err
 	if err != nil {
 		return err
 	}
+
+	//DoubleSignSlash           []DoubleSignRecord
+	err = verifyDoubleSignSlash(currentExtra.DoubleSignSlash, verifyExtra.DoubleSignSlash)
+	if err != nil {
+		return err
+	}
+
+	//CandidateUnjail           []common.Address
+	err = verifyExit(currentExtra.CandidateUnjail, verifyExtra.CandidateUnjail,"CandidateUnjail")
+	if err != nil {
+		return err
+	}
+
+	//CurrentBlockUnvotes       []common.Address
+	err = verifyExit(currentExtra.CurrentBlockUnvotes, verifyExtra.CurrentBlockUnvotes,"CurrentBlockUnvotes")
+	if err != nil {
+		return err
+	}
+
+	//CurrentBlockRedelegates   []Vote
+	err = verifyRedelegates(currentExtra.CurrentBlockRedelegates, verifyExtra.CurrentBlockRedelegates)
+	if err != nil {
+		return err
+	}
+
+	//MultiSigProposals         []MultiSigProposeRecord
+	err = verifyMultiSigProposals(currentExtra.MultiSigProposals, verifyExtra.MultiSigProposals)
+	if err != nil {
+		return err
+	}
+
+	//MultiSigApprovals         []MultiSigApproveRecord
+	err = verifyMultiSigApprovals(currentExtra.MultiSigApprovals, verifyExtra.MultiSigApprovals)
+	if err != nil {
+		return err
+	}
+
+	//FulTransfers              []FulTransferRecord
+	err = verifyFulTransfers(currentExtra.FulTransfers, verifyExtra.FulTransfers)
+	if err != nil {
+		return err
+	}
+
+	//FulAllowances             []FulAllowanceRecord
+	err = verifyFulAllowances(currentExtra.FulAllowances, verifyExtra.FulAllowances)
+	if err != nil {
+		return err
+	}
 	return nil
 
 	//FulDataRoot
@@ -355,6 +410,226 @@ func compareCandidatePunish(a []CandidatePunishRecord, b []CandidatePunishRecord
 	return nil
 }
 
+func verifyDoubleSignSlash(current []DoubleSignRecord, verify []DoubleSignRecord) error {
+	arrLen, err := verifyArrayBasic(ds_s, current, verify)
+	if err != nil {
+		return err
+	}
+	if arrLen == 0 {
+		return nil
+	}
+	err=compareDoubleSignSlash(current,verify)
+	if err!=nil{
+		return err
+	}
+	err=compareDoubleSignSlash(verify,current)
+	if err!=nil{
+		return err
+	}
+	return nil
+}
+
+func compareDoubleSignSlash(a []DoubleSignRecord, b []DoubleSignRecord) error{
+	b2:= make([]DoubleSignRecord, len(b))
+	copy(b2,b)
+	for _, c := range a {
+		find := false
+		for i, v := range b2 {
+			if c.Target == v.Target  && c.Number==v.Number  && c.Amount.Cmp(v.Amount)==0{
+				find = true
+				b2=append(b2[:i],b2[i+1:]...)
+				break
+			}
+		}
+		if !find {
+			return errorsMsg4(ds_s,c)
+		}
+	}
+	return nil
+}
+
+func verifyRedelegates(current []Vote, verify []Vote) error {
+	arrLen, err := verifyArrayBasic(rd_s, current, verify)
+	if err != nil {
+		return err
+	}
+	if arrLen == 0 {
+		return nil
+	}
+	err=compareRedelegates(current,verify)
+	if err!=nil{
+		return err
+	}
+	err=compareRedelegates(verify,current)
+	if err!=nil{
+		return err
+	}
+	return nil
+}
+
+func compareRedelegates(a []Vote, b []Vote) error{
+	b2:= make([]Vote, len(b))
+	copy(b2,b)
+	for _, c := range a {
+		find := false
+		for i, v := range b2 {
+			if c.Voter == v.Voter  && c.Candidate==v.Candidate  && c.Stake.Cmp(v.Stake)==0{
+				find = true
+				b2=append(b2[:i],b2[i+1:]...)
+				break
+			}
+		}
+		if !find {
+			return errorsMsg4(rd_s,c)
+		}
+	}
+	return nil
+}
+
+func verifyMultiSigProposals(current []MultiSigProposeRecord, verify []MultiSigProposeRecord) error {
+	arrLen, err := verifyArrayBasic(msp_s, current, verify)
+	if err != nil {
+		return err
+	}
+	if arrLen == 0 {
+		return nil
+	}
+	err=compareMultiSigProposals(current,verify)
+	if err!=nil{
+		return err
+	}
+	err=compareMultiSigProposals(verify,current)
+	if err!=nil{
+		return err
+	}
+	return nil
+}
+
+func compareMultiSigProposals(a []MultiSigProposeRecord, b []MultiSigProposeRecord) error{
+	b2:= make([]MultiSigProposeRecord, len(b))
+	copy(b2,b)
+	for _, c := range a {
+		find := false
+		for i, v := range b2 {
+			cp, vp := c.Proposal, v.Proposal
+			if cp.Hash == vp.Hash && cp.MultiSig == vp.MultiSig && cp.Proposer == vp.Proposer && cp.To == vp.To && cp.Amount.Cmp(vp.Amount)==0 &&
+				bytes.Equal(cp.Action, vp.Action) && cp.Number == vp.Number && equalAddresses(cp.Approvals, vp.Approvals) && c.Executed == v.Executed {
+				find = true
+				b2=append(b2[:i],b2[i+1:]...)
+				break
+			}
+		}
+		if !find {
+			return errorsMsg4(msp_s,c)
+		}
+	}
+	return nil
+}
+
+func verifyMultiSigApprovals(current []MultiSigApproveRecord, verify []MultiSigApproveRecord) error {
+	arrLen, err := verifyArrayBasic(msa_s, current, verify)
+	if err != nil {
+		return err
+	}
+	if arrLen == 0 {
+		return nil
+	}
+	err=compareMultiSigApprovals(current,verify)
+	if err!=nil{
+		return err
+	}
+	err=compareMultiSigApprovals(verify,current)
+	if err!=nil{
+		return err
+	}
+	return nil
+}
+
+func compareMultiSigApprovals(a []MultiSigApproveRecord, b []MultiSigApproveRecord) error{
+	b2:= make([]MultiSigApproveRecord, len(b))
+	copy(b2,b)
+	for _, c := range a {
+		find := false
+		for i, v := range b2 {
+			if c.Hash == v.Hash && equalAddresses(c.Approvals, v.Approvals) && c.Executed == v.Executed {
+				find = true
+				b2=append(b2[:i],b2[i+1:]...)
+				break
+			}
+		}
+		if !find {
+			return errorsMsg4(msa_s,c)
+		}
+	}
+	return nil
+}
+
+func verifyFulTransfers(current []FulTransferRecord, verify []FulTransferRecord) error {
+	arrLen, err := verifyArrayBasic(ft_s, current, verify)
+	if err != nil {
+		return err
+	}
+	if arrLen == 0 {
+		return nil
+	}
+	err=compareFulTransfers(current,verify)
+	if err!=nil{
+		return err
+	}
+	err=compareFulTransfers(verify,current)
+	if err!=nil{
+		return err
+	}
+	return nil
+}
+
+func compareFulTransfers(a []FulTransferRecord, b []FulTransferRecord) error{
+	b2:= make([]FulTransferRecord, len(b))
+	copy(b2,b)
+	for _, c := range a {
+		find := false
+		for i, v := range b2 {
+			if c.From == v.From && c.To == v.To && c.Spender == v.Spender && c.Amount.Cmp(v.Amount)==0 {
+				find = true
+				b2=append(b2[:i],b2[i+1:]...)
+				break
+			}
+		}
+		if !find {
+			return errorsMsg4(ft_s,c)
+		}
+	}
+	return nil
+}
+
+// verifyFulAllowances compares the allowance records in order, as a later
+// record of a spender replaces the earlier ones.
+func verifyFulAllowances(current []FulAllowanceRecord, verify []FulAllowanceRecord) error {
+	arrLen, err := verifyArrayBasic(fa_s, current, verify)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < arrLen; i++ {
+		c, v := current[i], verify[i]
+		if c.Owner != v.Owner || c.Spender != v.Spender || c.Amount.Cmp(v.Amount) != 0 {
+			return errorsMsg4(fa_s,c)
+		}
+	}
+	return nil
+}
+
+func equalAddresses(a []common.Address, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func verifyMinerStake(current []MinerStakeRecord, verify []MinerStakeRecord) error {
 	arrLen, err := verifyArrayBasic(ms_s, current, verify)
 	if err != nil {
@@ -0,0 +1,107 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+
+	"github.com/seaskycheng/sdvn/common"
+)
+
+// Besides voting, a voter may withdraw its vote with an unvote custom
+// transaction or move its stake to another candidate with a redelegate one.
+// Both are refused during the VoteCooldown of the economics following the last
+// vote change.
+
+// Actions of the vote records.
+const (
+	voteActionVote       = "vote"
+	voteActionUnvote     = "unvote"
+	voteActionRedelegate = "redelegate"
+	voteActionExpire     = "expire"
+)
+
+// VoteRecord is one change of the vote of a voter.
+type VoteRecord struct {
+	Number    uint64         `json:"number"`            // Block the vote changed at
+	BlockHash common.Hash    `json:"blockHash" rlp:"-"` // Hash of the block the vote changed at
+	Action    string         `json:"action"`            // One of vote, unvote, redelegate and expire
+	Candidate common.Address `json:"candidate"`         // Candidate voted for after the change, the left one for unvote and expire
+	Previous  common.Address `json:"previous"`          // Candidate voted for before a redelegate
+	Stake     *big.Int       `json:"stake"`             // Stake of the vote
+}
+
+// recordVote passes a change of the vote of voter to the vote history, if the
+// snapshot applies headers for the vote history index.
+func (s *Snapshot) recordVote(voter common.Address, record *VoteRecord) {
+	s.votes.add(voter, record)
+}
+
+// inVoteCooldown returns whether the vote of voter changed too recently to be
+// withdrawn or redelegated at number.
+func (s *Snapshot) inVoteCooldown(voter common.Address, number uint64) bool {
+	cooldown := economics(s.config).VoteCooldown / s.config.Period
+	last, ok := s.Voters[voter]
+	if cooldown == 0 || !ok {
+		return false
+	}
+	return number < last.Uint64()+cooldown
+}
+
+// unvote withdraws the vote of voter at number.
+func (s *Snapshot) unvote(voter common.Address, number uint64) {
+	vote, ok := s.Votes[voter]
+	if !ok {
+		return
+	}
+	if tally, ok := s.Tally[vote.Candidate]; ok {
+		tally.Sub(tally, vote.Stake)
+		if tally.Sign() <= 0 {
+			delete(s.Tally, vote.Candidate)
+		}
+	}
+	delete(s.Votes, voter)
+	delete(s.Voters, voter)
+	s.recordVote(voter, &VoteRecord{Number: number, Action: voteActionUnvote, Candidate: vote.Candidate, Stake: new(big.Int).Set(vote.Stake)})
+}
+
+// redelegate moves the stake of the vote of voter to candidate at number. As a
+// new vote, it restarts the expiration of the vote.
+func (s *Snapshot) redelegate(voter common.Address, candidate common.Address, number uint64) {
+	vote, ok := s.Votes[voter]
+	if !ok || vote.Candidate == candidate {
+		return
+	}
+	previous := vote.Candidate
+	if tally, ok := s.Tally[previous]; ok {
+		tally.Sub(tally, vote.Stake)
+		if tally.Sign() <= 0 {
+			delete(s.Tally, previous)
+		}
+	}
+	if tally, ok := s.Tally[candidate]; ok {
+		tally.Add(tally, vote.Stake)
+	} else {
+		s.Tally[candidate] = new(big.Int).Set(vote.Stake)
+		if !candidateNeedPD {
+			s.Candidates[candidate] = candidateStateNormal
+		}
+	}
+	s.Votes[voter] = &Vote{Voter: voter, Candidate: candidate, Stake: vote.Stake}
+	s.Voters[voter] = new(big.Int).SetUint64(number)
+	s.recordVote(voter, &VoteRecord{Number: number, Action: voteActionRedelegate, Candidate: candidate, Previous: previous, Stake: new(big.Int).Set(vote.Stake)})
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/ethdb"
+	"github.com/seaskycheng/sdvn/rlp"
+)
+
+// The vote history is an optional index of every change of the vote of a
+// voter. It is filled while the snapshots apply the headers, where expired
+// votes are known as well, and keyed by the hash of the header, so a header
+// applied again overwrites its records. Only the records of the canonical
+// block of a height are served.
+var (
+	voteHistoryPrefix = []byte("alien-votehistory-") // voteHistoryPrefix + voter + number (uint64 big endian) + block hash + index (uint32 big endian) -> VoteRecord
+
+	// errVoteHistoryDisabled is returned if the vote history is requested
+	// from a node which doesn't index it.
+	errVoteHistoryDisabled = errors.New("vote history not indexed, enable it with --alien.votehistory")
+)
+
+// voteHistoryEntry is a record of the vote history of voter.
+type voteHistoryEntry struct {
+	voter  common.Address
+	record *VoteRecord
+}
+
+// voteHistory collects the vote changes of the headers applied to a snapshot.
+type voteHistory struct {
+	entries []voteHistoryEntry
+}
+
+// add records a change of the vote of voter.
+func (h *voteHistory) add(voter common.Address, record *VoteRecord) {
+	if h == nil {
+		return
+	}
+	h.entries = append(h.entries, voteHistoryEntry{voter: voter, record: record})
+}
+
+// voteHistoryIndex stores the vote history in the database of the engine.
+type voteHistoryIndex struct {
+	db   ethdb.Database
+	lock sync.Mutex // Serializes the headers indexed by concurrent snapshots
+}
+
+// EnableVoteHistory enables the index of the vote changes of every voter from
+// the next header applied to a snapshot on.
+func (a *Alien) EnableVoteHistory() {
+	a.voteHistory = &voteHistoryIndex{db: a.db}
+}
+
+func voteHistoryKey(voter common.Address, number uint64, hash common.Hash, index uint32) []byte {
+	key := append(append([]byte{}, voteHistoryPrefix...), voter.Bytes()...)
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	key = append(append(key, enc...), hash.Bytes()...)
+	binary.BigEndian.PutUint32(enc, index)
+	return append(key, enc[:4]...)
+}
+
+// store writes the vote history collected while applying headers.
+func (h *voteHistoryIndex) store(headers []*types.Header, history *voteHistory) error {
+	if len(headers) == 0 || len(history.entries) == 0 {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var (
+		batch   = h.db.NewBatch()
+		first   = headers[0].Number.Uint64()
+		indexes = make(map[common.Address]uint32)
+		number  uint64
+	)
+	for _, entry := range history.entries {
+		if entry.record.Number != number {
+			number, indexes = entry.record.Number, make(map[common.Address]uint32)
+		}
+		if number < first || number-first >= uint64(len(headers)) {
+			continue
+		}
+		blob, err := rlp.EncodeToBytes(entry.record)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(voteHistoryKey(entry.voter, number, headers[number-first].Hash(), indexes[entry.voter]), blob); err != nil {
+			return err
+		}
+		indexes[entry.voter]++
+	}
+	return batch.Write()
+}
+
+// history returns the vote changes of voter up to block to, skipping the
+// records of blocks which aren't canonical. canonical returns the hash of the
+// canonical block of a height.
+func (h *voteHistoryIndex) history(voter common.Address, to uint64, canonical func(number uint64) common.Hash) ([]*VoteRecord, error) {
+	prefix := append(append([]byte{}, voteHistoryPrefix...), voter.Bytes()...)
+	it := h.db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var (
+		records = []*VoteRecord{}
+		number  uint64
+		started bool
+		hash    common.Hash
+	)
+	for it.Next() {
+		key := it.Key()[len(prefix):]
+		if len(key) != 8+common.HashLength+4 {
+			continue
+		}
+		if n := binary.BigEndian.Uint64(key); !started || n != number {
+			if n > to {
+				break
+			}
+			number, started = n, true
+			hash = canonical(n)
+		}
+		if common.BytesToHash(key[8:8+common.HashLength]) != hash {
+			continue
+		}
+		record := new(VoteRecord)
+		if err := rlp.DecodeBytes(it.Value(), record); err != nil {
+			return nil, err
+		}
+		record.BlockHash = hash
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
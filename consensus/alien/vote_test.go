@@ -0,0 +1,156 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien/customtx"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/params"
+)
+
+// newVoteTestConfig returns a config with a vote cooldown of 10 blocks.
+func newVoteTestConfig() *params.AlienConfig {
+	economics := params.DefaultAlienEconomics
+	economics.VoteCooldown = 30
+	return &params.AlienConfig{Period: 3, MinVoterBalance: new(big.Int), FulTrieBlock: big.NewInt(0), UnvoteBlock: big.NewInt(0), Economics: &economics}
+}
+
+func TestVoteHistory(t *testing.T) {
+	voter, candidate1, candidate2 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	snap := newSnapshot(newVoteTestConfig(), nil, common.Hash{}, nil, 0)
+	snap.votes = new(voteHistory)
+
+	snap.updateSnapshotByVotes([]Vote{{Voter: voter, Candidate: candidate1, Stake: big.NewInt(100)}}, big.NewInt(10))
+	if !snap.inVoteCooldown(voter, 19) || snap.inVoteCooldown(voter, 20) {
+		t.Errorf("unexpected cooldown of the vote at block 10")
+	}
+	cpy := snap.copy()
+	snap.updateSnapshotByRedelegates([]Vote{{Voter: voter, Candidate: candidate2}}, big.NewInt(20))
+	if snap.Votes[voter].Candidate != candidate2 || snap.Tally[candidate2].Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("stake not redelegated: %+v", snap.Votes[voter])
+	}
+	if _, ok := snap.Tally[candidate1]; ok {
+		t.Errorf("stake left on the previous candidate: %v", snap.Tally[candidate1])
+	}
+	if snap.Voters[voter].Uint64() != 20 {
+		t.Errorf("redelegate didn't restart the vote: %v", snap.Voters[voter])
+	}
+	if cpy.Votes[voter].Candidate != candidate1 || cpy.votes != nil {
+		t.Errorf("redelegate changed the copied snapshot")
+	}
+	snap.updateSnapshotByUnvotes([]common.Address{voter}, big.NewInt(30))
+	if _, ok := snap.Votes[voter]; ok {
+		t.Errorf("vote not withdrawn")
+	}
+	if _, ok := snap.Tally[candidate2]; ok {
+		t.Errorf("stake left on the candidate: %v", snap.Tally[candidate2])
+	}
+	want := []VoteRecord{
+		{Number: 10, Action: voteActionVote, Candidate: candidate1, Stake: big.NewInt(100)},
+		{Number: 20, Action: voteActionRedelegate, Candidate: candidate2, Previous: candidate1, Stake: big.NewInt(100)},
+		{Number: 30, Action: voteActionUnvote, Candidate: candidate2, Stake: big.NewInt(100)},
+	}
+	if len(snap.votes.entries) != len(want) {
+		t.Fatalf("history has %d records, want %d", len(snap.votes.entries), len(want))
+	}
+
+	// The index only serves the records of canonical blocks
+	var headers []*types.Header
+	for number := int64(10); number <= 30; number++ {
+		headers = append(headers, &types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(1), Initial: new(big.Int)})
+	}
+	index := &voteHistoryIndex{db: rawdb.NewMemoryDatabase()}
+	if err := index.store(headers, snap.votes); err != nil {
+		t.Fatalf("failed to store vote history: %v", err)
+	}
+	side := []*types.Header{{Number: big.NewInt(20), Difficulty: big.NewInt(2), Initial: new(big.Int)}}
+	if err := index.store(side, &voteHistory{entries: snap.votes.entries[1:2]}); err != nil {
+		t.Fatalf("failed to store vote history: %v", err)
+	}
+	canonical := func(number uint64) common.Hash { return headers[number-10].Hash() }
+	history, err := index.history(voter, 30, canonical)
+	if err != nil {
+		t.Fatalf("failed to read vote history: %v", err)
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d records, want %d", len(history), len(want))
+	}
+	for i, record := range history {
+		if record.Number != want[i].Number || record.Action != want[i].Action || record.Candidate != want[i].Candidate || record.Previous != want[i].Previous || record.Stake.Cmp(want[i].Stake) != 0 {
+			t.Errorf("record %d: have %+v, want %+v", i, record, want[i])
+		}
+		if record.BlockHash != canonical(record.Number) {
+			t.Errorf("record %d: block hash %x, want %x", i, record.BlockHash, canonical(record.Number))
+		}
+	}
+	if history, _ := index.history(voter, 25, canonical); len(history) != 2 {
+		t.Errorf("history up to block 25 has %d records, want 2", len(history))
+	}
+}
+
+func TestProcessUnvote(t *testing.T) {
+	voter, candidate1, candidate2 := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	config := newVoteTestConfig()
+	alien := New(config, rawdb.NewMemoryDatabase())
+	defer alien.Close()
+
+	snap := newSnapshot(config, nil, common.Hash{}, nil, 0)
+	snap.Candidates[candidate1] = candidateStateNormal
+	snap.Candidates[candidate2] = candidateStateNormal
+	snap.updateSnapshotByVotes([]Vote{{Voter: voter, Candidate: candidate1, Stake: big.NewInt(100)}}, big.NewInt(10))
+
+	redelegate := types.NewTransaction(0, candidate2, big.NewInt(0), 0, big.NewInt(0), (&customtx.Redelegate{}).Encode())
+	unvote := types.NewTransaction(1, candidate2, big.NewInt(0), 0, big.NewInt(0), (&customtx.Unvote{}).Encode())
+	receipts := []*types.Receipt{
+		{TxHash: redelegate.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(20)},
+		{TxHash: unvote.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(20)},
+	}
+	if _, reject := alien.processEventRedelegate(nil, redelegate.Data(), candidate1, redelegate, receipts, 20, snap); reject != RejectNotVoted {
+		t.Errorf("redelegated without a vote: %v", reject)
+	}
+	if _, reject := alien.processEventRedelegate(nil, redelegate.Data(), voter, redelegate, receipts, 19, snap); reject != RejectVoteCooldown {
+		t.Errorf("redelegated within the cooldown: %v", reject)
+	}
+	redelegates, reject := alien.processEventRedelegate(nil, redelegate.Data(), voter, redelegate, receipts, 20, snap)
+	if reject != RejectNone {
+		t.Fatalf("redelegate rejected: %v", reject)
+	}
+	if len(redelegates) != 1 || redelegates[0].Candidate != candidate2 || redelegates[0].Stake.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("unexpected redelegates %v", redelegates)
+	}
+	if _, reject := alien.processEventRedelegate(redelegates, redelegate.Data(), voter, redelegate, receipts, 30, snap); reject != RejectSameCandidate {
+		t.Errorf("redelegated to the same candidate: %v", reject)
+	}
+	if _, reject := alien.processEventUnvote(nil, unvote.Data(), voter, unvote, receipts, 29, snap); reject != RejectVoteCooldown {
+		t.Errorf("unvoted within the cooldown of the redelegate: %v", reject)
+	}
+	unvotes, reject := alien.processEventUnvote(nil, unvote.Data(), voter, unvote, receipts, 30, snap)
+	if reject != RejectNone || len(unvotes) != 1 || unvotes[0] != voter {
+		t.Fatalf("unvote rejected: %v %v", unvotes, reject)
+	}
+	if len(receipts[0].Logs) != 1 || len(receipts[1].Logs) != 1 {
+		t.Errorf("vote changes not logged")
+	}
+	if _, reject := alien.processEventUnvote(unvotes, unvote.Data(), voter, unvote, receipts, 30, snap); reject != RejectNotVoted {
+		t.Errorf("unvoted twice: %v", reject)
+	}
+}
@@ -139,6 +139,26 @@ type Engine interface {
 	VerifyHeaderExtra(chain ChainHeaderReader, header *types.Header, verifyExtra []byte) error
 }
 
+// BFT is a consensus engine running a finality gadget, which makes the chain
+// irreversible up to its last finalized block.
+type BFT interface {
+	Engine
+
+	// Finalized returns the number and hash of the last finalized block, or zero
+	// values if no block is finalized yet.
+	Finalized() (uint64, common.Hash)
+}
+
+// Confirmer is a consensus engine which knows the blocks it won't revert anymore,
+// backing the finalized and safe block tags.
+type Confirmer interface {
+	Engine
+
+	// Confirmed returns the number of the last block confirmed on the chain with
+	// the given head.
+	Confirmed(chain ChainHeaderReader, head *types.Header) (uint64, error)
+}
+
 // PoW is a consensus engine based on proof-of-work.
 type PoW interface {
 	Engine
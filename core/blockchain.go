@@ -2191,6 +2191,12 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("invalid new chain")
 		}
 	}
+	// Never revert the blocks finalized by the consensus engine
+	if bft, ok := bc.engine.(consensus.BFT); ok {
+		if finalized, _ := bft.Finalized(); len(oldChain) > 0 && commonBlock.NumberU64() < finalized {
+			return fmt.Errorf("%w: common ancestor %d, finalized %d", ErrReorgFinalized, commonBlock.NumberU64(), finalized)
+		}
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
@@ -31,6 +31,10 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrReorgFinalized is returned if a chain reorganisation would revert a
+	// block finalized by the consensus engine.
+	ErrReorgFinalized = errors.New("reorg below finalized block")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/seaskycheng/sdvn/common"
 	"github.com/seaskycheng/sdvn/common/hexutil"
@@ -228,6 +229,9 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
+	if err := storedcfg.CheckGenesisCompatible(newcfg, *height); err != nil {
+		return newcfg, stored, err
+	}
 	compatErr := storedcfg.CheckCompatible(newcfg, *height)
 	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
 		return newcfg, stored, compatErr
@@ -445,6 +449,60 @@ func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 	}
 }
 
+// DeveloperAlienGenesisBlock returns the 'sdvn --dev --dev.engine=alien'
+// genesis block. The faucet is the only signer and manages all system configs.
+// A 0 period seals blocks on demand, economics may shorten the reward schedule.
+func DeveloperAlienGenesisBlock(period uint64, faucet common.Address, economics *params.AlienEconomics) *Genesis {
+	timestamp := uint64(time.Now().Unix()) - 1
+
+	// London stays disabled as on mainnet, alien seals don't cover the base fee
+	config := *params.AllAlienProtocolChanges
+	config.LondonBlock = nil
+	alien := *config.Alien
+	alien.Period = period
+	alien.SealOnDemand = period == 0
+	alien.MaxSignerCount = 1
+	alien.GenesisTimestamp = timestamp
+	alien.SelfVoteSigners = []common.UnprefixedAddress{common.UnprefixedAddress(faucet)}
+	alien.ManagerAddress = &faucet
+	alien.Economics = economics
+	alien.TrantorBlock = big.NewInt(0)
+	alien.RejectLogBlock = big.NewInt(0)
+	alien.SignFixBlock = big.NewInt(0)
+	alien.GrantProfitOneTimeBlock = big.NewInt(0)
+	alien.LockMergeBlock = big.NewInt(0)
+	alien.LockSimplifyBlock = big.NewInt(0)
+	alien.FulTrieBlock = big.NewInt(0)
+	alien.BugFixBlock = big.NewInt(0)
+	alien.RandomBeaconBlock = big.NewInt(0)
+	alien.DoubleSignBlock = big.NewInt(0)
+	alien.UnvoteBlock = big.NewInt(0)
+	alien.MultiSigBlock = big.NewInt(0)
+	alien.FulTransferBlock = big.NewInt(0)
+	config.Alien = &alien
+
+	// Assemble and return the genesis with the precompiles and faucet pre-funded
+	return &Genesis{
+		Config:     &config,
+		Timestamp:  timestamp,
+		ExtraData:  make([]byte, 32+crypto.SignatureLength),
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1),
+		Alloc: map[common.Address]GenesisAccount{
+			common.BytesToAddress([]byte{1}): {Balance: big.NewInt(1)}, // ECRecover
+			common.BytesToAddress([]byte{2}): {Balance: big.NewInt(1)}, // SHA256
+			common.BytesToAddress([]byte{3}): {Balance: big.NewInt(1)}, // RIPEMD
+			common.BytesToAddress([]byte{4}): {Balance: big.NewInt(1)}, // Identity
+			common.BytesToAddress([]byte{5}): {Balance: big.NewInt(1)}, // ModExp
+			common.BytesToAddress([]byte{6}): {Balance: big.NewInt(1)}, // ECAdd
+			common.BytesToAddress([]byte{7}): {Balance: big.NewInt(1)}, // ECScalarMul
+			common.BytesToAddress([]byte{8}): {Balance: big.NewInt(1)}, // ECPairing
+			common.BytesToAddress([]byte{9}): {Balance: big.NewInt(1)}, // BLAKE2b
+			faucet:                           {Balance: new(big.Int).Mul(big.NewInt(1e9), big.NewInt(params.Ether))},
+		},
+	}
+}
+
 func decodePrealloc(data string) GenesisAlloc {
 	var p []struct{ Addr, Balance *big.Int }
 	if err := rlp.NewStream(strings.NewReader(data), 0).Decode(&p); err != nil {
@@ -140,6 +140,17 @@ type blockChain interface {
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
 
+// TxValidator is an additional admission check of the transactions entering
+// the pool, allowing the consensus engine to refuse transactions it would
+// certainly ignore.
+type TxValidator interface {
+	// Reset is called with the pool lock held whenever the pool moves to a
+	// new head, before any transaction is validated on top of it.
+	Reset(head *types.Header)
+
+	ValidateTx(tx *types.Transaction, from common.Address) error
+}
+
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
 	Locals    []common.Address // Addresses that should be treated by default as local
@@ -225,6 +236,7 @@ type TxPool struct {
 	config      TxPoolConfig
 	chainconfig *params.ChainConfig
 	chain       blockChain
+	validator   TxValidator
 	gasPrice    *big.Int
 	txFeed      event.Feed
 	scope       event.SubscriptionScope
@@ -424,6 +436,18 @@ func (pool *TxPool) GasPrice() *big.Int {
 	return new(big.Int).Set(pool.gasPrice)
 }
 
+// SetValidator installs an additional validator of the transactions entering
+// the pool. It doesn't affect the transactions already in the pool.
+func (pool *TxPool) SetValidator(validator TxValidator) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.validator = validator
+	if validator != nil {
+		validator.Reset(pool.chain.CurrentBlock().Header())
+	}
+}
+
 // SetGasPrice updates the minimum price required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
@@ -613,6 +637,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
+	// Let the installed validator refuse transactions the chain would ignore
+	if pool.validator != nil {
+		if err := pool.validator.ValidateTx(tx, from); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1246,6 +1276,9 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	if pool.validator != nil {
+		pool.validator.Reset(newHead)
+	}
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
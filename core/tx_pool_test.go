@@ -311,6 +311,45 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+type testTxValidator struct {
+	refused common.Address
+	err     error
+}
+
+func (v *testTxValidator) Reset(head *types.Header) {}
+
+func (v *testTxValidator) ValidateTx(tx *types.Transaction, from common.Address) error {
+	if from == v.refused {
+		return v.err
+	}
+	return nil
+}
+
+// Tests that the installed validator is consulted after the basic checks and
+// its error is returned to the submitter.
+func TestValidatorTransactions(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	errRefused := errors.New("refused")
+	pool.SetValidator(&testTxValidator{refused: from, err: errRefused})
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != errRefused {
+		t.Error("expected", errRefused, "got", err)
+	}
+	if err := pool.AddRemote(transaction(0, 100, key)); !errors.Is(err, ErrIntrinsicGas) {
+		t.Error("expected", ErrIntrinsicGas, "got", err)
+	}
+	pool.SetValidator(&testTxValidator{err: errRefused})
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Error("expected", nil, "got", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,159 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/core"
+	"github.com/seaskycheng/sdvn/core/rawdb"
+	"github.com/seaskycheng/sdvn/core/types"
+	"github.com/seaskycheng/sdvn/core/vm"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/rpc"
+)
+
+// alienExplainReexec is the number of blocks re-executed at most to rebuild
+// a missing state when explaining a custom transaction.
+const alienExplainReexec = 128
+
+// PrivateAlienAPI provides the alien engine RPCs which need to re-execute
+// blocks, which the engine itself can't do.
+type PrivateAlienAPI struct {
+	eth *Ethereum
+}
+
+// NewPrivateAlienAPI creates a new alien API for full nodes.
+func NewPrivateAlienAPI(eth *Ethereum) *PrivateAlienAPI {
+	return &PrivateAlienAPI{eth: eth}
+}
+
+// ExplainCustomTx re-executes the block of the given custom transaction and
+// returns whether the engine accepted it, or the reason it was ignored.
+func (api *PrivateAlienAPI) ExplainCustomTx(hash common.Hash) (*alien.CustomTxExplanation, error) {
+	engine, ok := api.eth.engine.(*alien.Alien)
+	if !ok {
+		return nil, errors.New("alien engine not running")
+	}
+	_, blockHash, number, _ := rawdb.ReadTransaction(api.eth.ChainDb(), hash)
+	if blockHash == (common.Hash{}) {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	block := api.eth.blockchain.GetBlock(blockHash, number)
+	if block == nil {
+		return nil, fmt.Errorf("block %#x not found", blockHash)
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), number-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, err := api.eth.stateAtBlock(parent, alienExplainReexec, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		config   = api.eth.blockchain.Config()
+		header   = block.Header()
+		gp       = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas  = new(uint64)
+		receipts []*types.Receipt
+	)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		receipt, _, err := core.ApplyTransaction(config, api.eth.blockchain, nil, gp, statedb, header, tx, usedGas, vm.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	return engine.ExplainCustomTx(api.eth.blockchain, header, statedb, block.Transactions(), receipts, hash)
+}
+
+// VerifyAlienSnapshots rebuilds the alien voting snapshots from genesis up to
+// the given block and compares them with the stored checkpoints and the FUL
+// roots of the headers. It returns the first difference found, if any.
+func (api *PrivateDebugAPI) VerifyAlienSnapshots(ctx context.Context, number rpc.BlockNumber) (*alien.SnapshotVerification, error) {
+	engine, ok := api.eth.engine.(*alien.Alien)
+	if !ok {
+		return nil, errors.New("alien engine not running")
+	}
+	head := api.eth.blockchain.CurrentBlock().NumberU64()
+	if number >= 0 && uint64(number) < head {
+		head = uint64(number)
+	}
+	return engine.VerifySnapshots(head, ctx.Done())
+}
+
+// alienProposalsChanSize is the size of the channel listening to the chain
+// events of a proposal subscription.
+const alienProposalsChanSize = 10
+
+// PublicAlienAPI provides the alien engine subscriptions of the eth namespace.
+type PublicAlienAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicAlienAPI creates a new alien subscription API.
+func NewPublicAlienAPI(eth *Ethereum) *PublicAlienAPI {
+	return &PublicAlienAPI{eth: eth}
+}
+
+// AlienProposals sends a notification each time a proposal is created,
+// declared, passed or rejected in a block appended to the chain.
+func (api *PublicAlienAPI) AlienProposals(ctx context.Context) (*rpc.Subscription, error) {
+	engine, ok := api.eth.engine.(*alien.Alien)
+	if !ok {
+		return nil, errors.New("alien engine not running")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		chainEvents := make(chan core.ChainEvent, alienProposalsChanSize)
+		chainSub := api.eth.blockchain.SubscribeChainEvent(chainEvents)
+		defer chainSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-chainEvents:
+				events, err := engine.ProposalEvents(api.eth.blockchain, ev.Block.Header())
+				if err != nil {
+					log.Warn("Failed to collect alien proposal events", "number", ev.Block.Number(), "err", err)
+					continue
+				}
+				for _, event := range events {
+					notifier.Notify(rpcSub.ID, event)
+				}
+			case <-chainSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -71,9 +71,26 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock().Header(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		confirmed, err := b.confirmedNumber()
+		if err != nil {
+			return nil, err
+		}
+		return b.eth.blockchain.GetHeaderByNumber(confirmed), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
+// confirmedNumber resolves the finalized and safe block tags through the
+// consensus engine.
+func (b *EthAPIBackend) confirmedNumber() (uint64, error) {
+	confirmer, ok := b.eth.engine.(consensus.Confirmer)
+	if !ok {
+		return 0, errors.New("finalized and safe blocks not supported by the consensus engine")
+	}
+	return confirmer.Confirmed(b.eth.blockchain, b.eth.blockchain.CurrentHeader())
+}
+
 func (b *EthAPIBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.HeaderByNumber(ctx, blockNr)
@@ -105,6 +122,13 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		confirmed, err := b.confirmedNumber()
+		if err != nil {
+			return nil, err
+		}
+		return b.eth.blockchain.GetBlockByNumber(confirmed), nil
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
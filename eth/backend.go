@@ -43,6 +43,7 @@ import (
 	"github.com/seaskycheng/sdvn/eth/ethconfig"
 	"github.com/seaskycheng/sdvn/eth/filters"
 	"github.com/seaskycheng/sdvn/eth/gasprice"
+	"github.com/seaskycheng/sdvn/eth/protocols/bft"
 	"github.com/seaskycheng/sdvn/eth/protocols/eth"
 	"github.com/seaskycheng/sdvn/eth/protocols/snap"
 	"github.com/seaskycheng/sdvn/ethdb"
@@ -214,6 +215,18 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
+	if alienEngine, ok := eth.engine.(*alien.Alien); ok {
+		eth.txPool.SetValidator(alienEngine.NewTxPoolValidator(eth.blockchain))
+		if config.AlienGCWindow > 0 {
+			alienEngine.SetSnapshotGC(config.AlienGCWindow)
+		}
+		if config.AlienFulHistory {
+			alienEngine.EnableFulHistory()
+		}
+		if config.AlienVoteHistory {
+			alienEngine.EnableVoteHistory()
+		}
+	}
 
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
@@ -306,6 +319,18 @@ func (s *Ethereum) APIs() []rpc.API {
 
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
+	if _, ok := s.engine.(*alien.Alien); ok {
+		apis = append(apis, rpc.API{
+			Namespace: "alien",
+			Version:   "1.0",
+			Service:   NewPrivateAlienAPI(s),
+		}, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicAlienAPI(s),
+			Public:    true,
+		})
+	}
 
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
@@ -556,6 +581,9 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
+	if s.handler.bft != nil {
+		protos = append(protos, bft.MakeProtocols(s.handler.bft)...)
+	}
 	return protos
 }
 
@@ -132,7 +132,10 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
-	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	TxLookupLimit    uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	AlienGCWindow    uint64 `toml:",omitempty"` // The number of blocks from head whose alien snapshots are reserved, 0 disables the collection.
+	AlienFulHistory  bool   `toml:",omitempty"` // Whether to index the FUL debits and credits of every address.
+	AlienVoteHistory bool   `toml:",omitempty"` // Whether to index the vote changes of every voter.
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
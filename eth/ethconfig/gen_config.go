@@ -26,6 +26,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NoPruning               bool
 		NoPrefetch              bool
 		TxLookupLimit           uint64                 `toml:",omitempty"`
+		AlienGCWindow           uint64                 `toml:",omitempty"`
+		AlienFulHistory         bool                   `toml:",omitempty"`
+		AlienVoteHistory        bool                   `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
 		LightIngress            int                    `toml:",omitempty"`
@@ -70,6 +73,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.AlienGCWindow = c.AlienGCWindow
+	enc.AlienFulHistory = c.AlienFulHistory
+	enc.AlienVoteHistory = c.AlienVoteHistory
 	enc.Whitelist = c.Whitelist
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -118,6 +124,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NoPruning               *bool
 		NoPrefetch              *bool
 		TxLookupLimit           *uint64                `toml:",omitempty"`
+		AlienGCWindow           *uint64                `toml:",omitempty"`
+		AlienFulHistory         *bool                  `toml:",omitempty"`
+		AlienVoteHistory        *bool                  `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
 		LightIngress            *int                   `toml:",omitempty"`
@@ -181,6 +190,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.AlienGCWindow != nil {
+		c.AlienGCWindow = *dec.AlienGCWindow
+	}
+	if dec.AlienFulHistory != nil {
+		c.AlienFulHistory = *dec.AlienFulHistory
+	}
+	if dec.AlienVoteHistory != nil {
+		c.AlienVoteHistory = *dec.AlienVoteHistory
+	}
 	if dec.Whitelist != nil {
 		c.Whitelist = dec.Whitelist
 	}
@@ -108,6 +108,7 @@ type handler struct {
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
+	bft          *bftHandler // Relays the alien finality votes, nil for other engines
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
@@ -142,6 +143,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		whitelist:  config.Whitelist,
 		txsyncCh:   make(chan *txsync),
 		quitSync:   make(chan struct{}),
+		bft:        newBFTHandler(config.Chain),
 	}
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the fast
@@ -411,11 +413,19 @@ func (h *handler) Start(maxPeers int) {
 	h.wg.Add(2)
 	go h.chainSync.loop()
 	go h.txsyncLoop64() // TODO(karalabe): Legacy initial tx echange, drop with eth/64.
+
+	// vote on the finality of the new heads
+	if h.bft != nil {
+		h.bft.start()
+	}
 }
 
 func (h *handler) Stop() {
 	h.txsSub.Unsubscribe()        // quits txBroadcastLoop
 	h.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	if h.bft != nil {
+		h.bft.stop() // quits the finality voting loop
+	}
 
 	// Quit chainSync and txsync64.
 	// After this is done, no new peers will be accepted.
@@ -0,0 +1,153 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/core"
+	"github.com/seaskycheng/sdvn/eth/protocols/bft"
+	"github.com/seaskycheng/sdvn/event"
+	"github.com/seaskycheng/sdvn/p2p/enode"
+)
+
+// bftHandler implements the bft.Backend interface, relaying the votes of the
+// alien finality gadget between the peers.
+type bftHandler struct {
+	chain  *core.BlockChain
+	engine *alien.Alien
+
+	peers map[string]*bft.Peer
+	lock  sync.RWMutex
+
+	headCh  chan core.ChainHeadEvent
+	headSub event.Subscription
+	wg      sync.WaitGroup
+}
+
+// bftPeerInfo represents a short summary of the `bft` protocol metadata known
+// about a connected peer.
+type bftPeerInfo struct {
+	Version uint `json:"version"` // BFT protocol version negotiated
+}
+
+// newBFTHandler creates the handler of the `bft` protocol, or returns nil if
+// the chain isn't run by the alien engine.
+func newBFTHandler(chain *core.BlockChain) *bftHandler {
+	engine, ok := chain.Engine().(*alien.Alien)
+	if !ok {
+		return nil
+	}
+	return &bftHandler{
+		chain:  chain,
+		engine: engine,
+		peers:  make(map[string]*bft.Peer),
+	}
+}
+
+// start begins voting on the new chain heads.
+func (h *bftHandler) start() {
+	h.headCh = make(chan core.ChainHeadEvent, 16)
+	h.headSub = h.chain.SubscribeChainHeadEvent(h.headCh)
+
+	h.wg.Add(1)
+	go h.loop()
+}
+
+// stop terminates the voting loop.
+func (h *bftHandler) stop() {
+	h.headSub.Unsubscribe()
+	h.wg.Wait()
+}
+
+// loop casts and broadcasts the votes for every new chain head.
+func (h *bftHandler) loop() {
+	defer h.wg.Done()
+	for {
+		select {
+		case ev := <-h.headCh:
+			h.broadcast(h.engine.NewFinalityHead(h.chain, ev.Block.Header()))
+		case <-h.headSub.Err():
+			return
+		}
+	}
+}
+
+// RunPeer is invoked when a peer joins on the `bft` protocol.
+func (h *bftHandler) RunPeer(peer *bft.Peer, hand bft.Handler) error {
+	h.lock.Lock()
+	h.peers[peer.ID()] = peer
+	h.lock.Unlock()
+
+	defer func() {
+		h.lock.Lock()
+		delete(h.peers, peer.ID())
+		h.lock.Unlock()
+	}()
+	// Bring the peer up to date with the last finalized block
+	if votes := h.engine.FinalityJustification(); len(votes) > 0 {
+		if err := peer.SendVotes(votes); err != nil {
+			return err
+		}
+	}
+	return hand(peer)
+}
+
+// PeerInfo retrieves all known `bft` information about a peer.
+func (h *bftHandler) PeerInfo(id enode.ID) interface{} {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if peer, ok := h.peers[id.String()]; ok {
+		return &bftPeerInfo{Version: peer.Version()}
+	}
+	return nil
+}
+
+// HandleVotes is invoked from a peer's message handler when it receives new
+// finality votes, relaying the ones accepted by the engine.
+func (h *bftHandler) HandleVotes(peer *bft.Peer, votes []*alien.FinalityVote) error {
+	relay, err := h.engine.HandleFinalityVotes(h.chain, votes)
+	h.broadcast(relay)
+	return err
+}
+
+// broadcast sends the votes to every peer not knowing them yet.
+func (h *bftHandler) broadcast(votes []*alien.FinalityVote) {
+	if len(votes) == 0 {
+		return
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, peer := range h.peers {
+		var unknown []*alien.FinalityVote
+		for _, vote := range votes {
+			if !peer.KnownVote(vote.ID()) {
+				unknown = append(unknown, vote)
+			}
+		}
+		if len(unknown) > 0 {
+			go func(peer *bft.Peer, votes []*alien.FinalityVote) {
+				if err := peer.SendVotes(votes); err != nil {
+					peer.Log().Debug("Failed to send finality votes", "err", err)
+				}
+			}(peer, unknown)
+		}
+	}
+}
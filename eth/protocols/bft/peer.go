@@ -0,0 +1,93 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	mapset "github.com/deckarep/golang-set"
+	"github.com/seaskycheng/sdvn/common"
+	"github.com/seaskycheng/sdvn/consensus/alien"
+	"github.com/seaskycheng/sdvn/log"
+	"github.com/seaskycheng/sdvn/p2p"
+)
+
+// maxKnownVotes is the maximum vote hashes to keep in the known list before
+// starting to randomly evict them.
+const maxKnownVotes = 8192
+
+// Peer is a collection of relevant information we have about a `bft` peer.
+type Peer struct {
+	id string // Unique ID for the peer, cached
+
+	*p2p.Peer                   // The embedded P2P package peer
+	rw        p2p.MsgReadWriter // Input/output streams for bft
+	version   uint              // Protocol version negotiated
+
+	knownVotes mapset.Set // Set of vote hashes known to be known by this peer
+	logger     log.Logger // Contextual logger with the peer id injected
+}
+
+// newPeer create a wrapper for a network connection and negotiated protocol
+// version.
+func newPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
+	id := p.ID().String()
+	return &Peer{
+		id:         id,
+		Peer:       p,
+		rw:         rw,
+		version:    version,
+		knownVotes: mapset.NewSet(),
+		logger:     log.New("peer", id[:8]),
+	}
+}
+
+// ID retrieves the peer's unique identifier.
+func (p *Peer) ID() string {
+	return p.id
+}
+
+// Version retrieves the peer's negoatiated `bft` protocol version.
+func (p *Peer) Version() uint {
+	return p.version
+}
+
+// Log overrides the P2P logget with the higher level one containing only the id.
+func (p *Peer) Log() log.Logger {
+	return p.logger
+}
+
+// KnownVote returns whether the peer is known to already have the vote.
+func (p *Peer) KnownVote(hash common.Hash) bool {
+	return p.knownVotes.Contains(hash)
+}
+
+// markVotes marks the votes as known for the peer, ensuring that they will
+// never be propagated to this particular peer.
+func (p *Peer) markVotes(votes []*alien.FinalityVote) {
+	for p.knownVotes.Cardinality() > maxKnownVotes-len(votes) && p.knownVotes.Cardinality() > 0 {
+		p.knownVotes.Pop()
+	}
+	for _, vote := range votes {
+		p.knownVotes.Add(vote.ID())
+	}
+}
+
+// SendVotes sends the finality votes to the peer and includes their hashes in
+// its vote hash set for future reference.
+func (p *Peer) SendVotes(votes []*alien.FinalityVote) error {
+	p.markVotes(votes)
+	return p2p.Send(p.rw, VotesMsg, VotesPacket(votes))
+}
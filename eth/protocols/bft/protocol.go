@@ -0,0 +1,58 @@
+// Copyright 2021 The sdvn Authors
+// This file is part of the sdvn library.
+//
+// The sdvn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The sdvn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the sdvn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements the `bft` protocol, over which the alien signers
+// exchange the votes of the finality gadget.
+package bft
+
+import (
+	"errors"
+
+	"github.com/seaskycheng/sdvn/consensus/alien"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	bft1 = 1
+)
+
+// ProtocolName is the official short name of the `bft` protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "bft"
+
+// ProtocolVersions are the supported versions of the `bft` protocol (first
+// is primary).
+var ProtocolVersions = []uint{bft1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{bft1: 1}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 1024 * 1024
+
+const (
+	VotesMsg = 0x00
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+)
+
+// VotesPacket is the network packet for propagating finality votes.
+type VotesPacket []*alien.FinalityVote
@@ -292,6 +292,12 @@ func toBlockNumArg(number *big.Int) string {
 	if number.Cmp(pending) == 0 {
 		return "pending"
 	}
+	if number.Cmp(big.NewInt(int64(rpc.FinalizedBlockNumber))) == 0 {
+		return "finalized"
+	}
+	if number.Cmp(big.NewInt(int64(rpc.SafeBlockNumber))) == 0 {
+		return "safe"
+	}
 	return hexutil.EncodeBig(number)
 }
 
@@ -133,6 +133,77 @@ web3._extend({
 			call: 'alien_getFulBalanceAtNumber',
 			params: 2
 		}),
+        new web3._extend.Method({
+			name: 'getDoubleSignEvidence',
+			call: 'alien_getDoubleSignEvidence',
+			params: 1
+		}),
+        new web3._extend.Method({
+			name: 'getJailState',
+			call: 'alien_getJailState',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getJailedSigners',
+			call: 'alien_getJailedSigners',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'previewSignerQueue',
+			call: 'alien_previewSignerQueue',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'explainElection',
+			call: 'alien_explainElection',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getVoteHistory',
+			call: 'alien_getVoteHistory',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getProposals',
+			call: 'alien_getProposals',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getProposal',
+			call: 'alien_getProposal',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getMultiSigProposals',
+			call: 'alien_getMultiSigProposals',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getMultiSigProposal',
+			call: 'alien_getMultiSigProposal',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getFulProof',
+			call: 'alien_getFulProof',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+        new web3._extend.Method({
+			name: 'getFulHistory',
+			call: 'alien_getFulHistory',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	]
 });
 `
@@ -552,6 +623,12 @@ web3._extend({
 			call: 'debug_freezeClient',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'verifyAlienSnapshots',
+			call: 'debug_verifyAlienSnapshots',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter],
+		}),
 	],
 	properties: []
 });
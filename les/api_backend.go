@@ -63,6 +63,17 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		confirmer, ok := b.eth.engine.(consensus.Confirmer)
+		if !ok {
+			return nil, errors.New("finalized and safe blocks not supported by the consensus engine")
+		}
+		confirmed, err := confirmer.Confirmed(b.eth.blockchain, b.eth.blockchain.CurrentHeader())
+		if err != nil {
+			return nil, err
+		}
+		return b.eth.blockchain.GetHeaderByNumberOdr(ctx, confirmed)
+	}
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(number))
 }
 
@@ -18,7 +18,6 @@ package miner
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"math/big"
 	"sync"
 	"sync/atomic"
@@ -748,44 +747,6 @@ func (w *worker) updateSnapshot() {
 	w.snapshotState = w.current.state.Copy()
 }
 
-// For PBFT of alien consensus, the follow code may move later
-// After confirm a new block, the miner send a custom transaction to self, which value is 0
-// and data like "ufo:1:event:confirm:123" (ufo is prefix, 1 is version, 123 is block number
-// to let the next signer now this signer already confirm this block
-func (w *worker) sendConfirmTx(blockNumber *big.Int) error {
-	wallets := w.eth.AccountManager().Wallets()
-	// wallets check
-	if len(wallets) == 0 {
-		return nil
-	}
-	for _, wallet := range wallets {
-		if len(wallet.Accounts()) == 0 {
-			continue
-		} else {
-			for _, account := range wallet.Accounts() {
-				if account.Address == w.coinbase {
-					// coinbase account found
-					// send custom tx
-					nonce := w.snapshotState.GetNonce(account.Address)
-					tmpTx := types.NewTransaction(nonce, account.Address, big.NewInt(0), uint64(100000), big.NewInt(10000), []byte(fmt.Sprintf("ufo:1:event:confirm:%d", blockNumber)))
-					signedTx, err := wallet.SignTx(account, tmpTx, w.eth.BlockChain().Config().ChainID)
-					if err != nil {
-						return err
-					} else {
-						err = w.eth.TxPool().AddLocal(signedTx)
-						if err != nil {
-							return err
-						}
-					}
-					return nil
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
 func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Address) ([]*types.Log, error) {
 	snap := w.current.state.Snapshot()
 
@@ -1065,7 +1026,6 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 	// Deep copy receipts here to avoid interaction between different tasks.
 	receipts := copyReceipts(w.current.receipts)
 	s := w.current.state.Copy()
-	parent := w.chain.CurrentBlock()
 	if w.chainConfig.Alien != nil {
 /*
 		var payProfit []consensus.GrantProfitRecord
@@ -1139,13 +1099,6 @@ log.Warn("worker commit", "number", w.current.header.Number, "GasReward", w.curr
 			log.Info("Worker has exited")
 		}
 	}
-	// todo: add params into sdvn, to decide if or not send this tx
-	if w.chainConfig.Alien != nil && w.chainConfig.Alien.PBFTEnable {
-		err := w.sendConfirmTx(parent.Number())
-		if err != nil {
-			log.Info("Fail to Sign the transaction by coinbase", "err", err)
-		}
-	}
 	if update {
 		w.updateSnapshot()
 	}
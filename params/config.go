@@ -18,6 +18,8 @@ package params
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -343,13 +345,160 @@ type AlienConfig struct {
 	SelfVoteSigners  []common.UnprefixedAddress `json:"signers"`          // Signers vote by themselves to seal the block, make sure the signer accounts are pre-funded
 	SideChain        bool                       `json:"sideChain"`        // If side chain or not
 	MCRPCClient      *rpc.Client                // Main chain rpc client for side chain
-	PBFTEnable       bool                       `json:"pbft"` //
+	PBFTEnable       bool                       `json:"pbft"` // Whether the miner signs BFT finality votes
+	SealOnDemand     bool                       `json:"sealOnDemand,omitempty"` // Whether a 0-period developer chain seals blocks only for transactions
+
+	TrantorBlock   *big.Int          `json:"trantorBlock,omitempty"`   // Trantor switch block (nil = no fork)
+	TerminusBlock  *big.Int          `json:"terminusBlock,omitempty"`  // Terminus switch block (nil = no fork)
+	RejectLogBlock *big.Int          `json:"rejectLogBlock,omitempty"` // Custom tx rejection log switch block (nil = no fork)
+	LightConfig    *AlienLightConfig `json:"lightConfig,omitempty"`
+	Economics      *AlienEconomics   `json:"economics,omitempty"`      // Reward and lock economics (nil = mainnet economics)
+	ManagerAddress *common.Address   `json:"managerAddress,omitempty"` // Initial manager of all system configs (nil = mainnet managers)
+
+	SignFixBlock            *big.Int `json:"signFixBlock,omitempty"`            // Signer number recalculation switch block (nil = mainnet default)
+	GrantProfitOneTimeBlock *big.Int `json:"grantProfitOneTimeBlock,omitempty"` // Grant profits are paid in one go after this block (nil = mainnet default)
+	LockMergeBlock          *big.Int `json:"lockMergeBlock,omitempty"`          // Reward lock data merge block (nil = mainnet default)
+	LockSimplifyBlock       *big.Int `json:"lockSimplifyBlock,omitempty"`       // Simplified reward locking switch block (nil = mainnet default)
+	FulTrieBlock            *big.Int `json:"fulTrieBlock,omitempty"`            // FUL balance trie switch block (nil = mainnet default)
+	BugFixBlock             *big.Int `json:"bugFixBlock,omitempty"`             // Block exempt from the coinbase check, enforced when sealing after it (nil = mainnet default)
+	RandomBeaconBlock       *big.Int `json:"randomBeaconBlock,omitempty"`       // Random beacon signer ordering switch block (nil = no fork)
+	DoubleSignBlock         *big.Int `json:"doubleSignBlock,omitempty"`         // Double sign slashing and unjailing switch block (nil = no fork)
+	UnvoteBlock             *big.Int `json:"unvoteBlock,omitempty"`             // Unvote and redelegate switch block (nil = no fork)
+	MultiSigBlock           *big.Int `json:"multiSigBlock,omitempty"`           // Multi-signature proposal and owner change switch block (nil = no fork)
+	FulTransferBlock        *big.Int `json:"fulTransferBlock,omitempty"`        // FUL transfer and allowance switch block (nil = no fork)
+}
+
+// AlienLockConfig is the lock period, release period and release interval of
+// a pledge or reward lock, in seconds.
+type AlienLockConfig struct {
+	Period    uint64 `json:"period"`
+	RlsPeriod uint64 `json:"rlsPeriod"`
+	Interval  uint64 `json:"interval"`
+}
+
+// AlienEconomics is the reward and lock economics of an alien network. The
+// reward intervals are offsets into the reward cycle, all durations are given
+// in seconds. The economics are frozen at genesis, as every block sealed
+// depends on them, so they can't be changed once the chain grew beyond it.
+type AlienEconomics struct {
+	RewardCycle                       uint64 `json:"rewardCycle"`                       // Length of a reward cycle, one day on mainnet
+	AccumulateFlowRewardInterval      uint64 `json:"accumulateFlowRewardInterval"`      // Flow rewards are accumulated at this offset
+	AccumulateBandwidthRewardInterval uint64 `json:"accumulateBandwidthRewardInterval"` // Bandwidth rewards are accumulated at this offset
+	PaySignerRewardInterval           uint64 `json:"paySignerRewardInterval"`           // Locked signer rewards are paid at this offset
+	PayFlowRewardInterval             uint64 `json:"payFlowRewardInterval"`             // Locked flow rewards are paid at this offset
+	PayBandwidthRewardInterval        uint64 `json:"payBandwidthRewardInterval"`        // Locked bandwidth rewards are paid at this offset
+
+	SignerPledgeLock AlienLockConfig `json:"signerPledgeLock"` // Lock of candidate pledges
+	FlowPledgeLock   AlienLockConfig `json:"flowPledgeLock"`   // Lock of flow miner pledges
+	RewardLock       AlienLockConfig `json:"rewardLock"`       // Lock of signer rewards
+
+	CalFlowToFULRatio          uint64   `json:"calFlowToFULRatio"`          // FUL wei charged per reported flow unit
+	MinCndPledgeBalance        *big.Int `json:"minCndPledgeBalance"`        // Candidate pledge unless set by a deposit config tx
+	MaxCandidateMiner          uint64   `json:"maxCandidateMiner"`          // Max count of candidates taking part in an election
+	ElectionPartitionThreshold uint64   `json:"electionPartitionThreshold"` // Candidate count up to which elections are not partitioned
+	JailCredit                 uint64   `json:"jailCredit"`                 // Punished credit at which a signer is jailed, zero to never jail
+	JailPeriod                 uint64   `json:"jailPeriod"`                 // Time a jailed signer stays out of the signer queue before it may unjail
+	VoteCooldown               uint64   `json:"voteCooldown"`               // Time after a vote change before the voter may unvote or redelegate, zero for none
+}
+
+// DefaultAlienEconomics is the economics of the main network. A genesis
+// economics section only needs to list the values it changes.
+var DefaultAlienEconomics = AlienEconomics{
+	RewardCycle:                       24 * 60 * 60,
+	AccumulateFlowRewardInterval:      2 * 60 * 60,
+	AccumulateBandwidthRewardInterval: 1 * 60 * 60,
+	PaySignerRewardInterval:           0,
+	PayFlowRewardInterval:             2*60*60 + 30*60,
+	PayBandwidthRewardInterval:        1*60*60 + 30*60,
+	SignerPledgeLock:                  AlienLockConfig{Period: 180 * 24 * 60 * 60},
+	FlowPledgeLock:                    AlienLockConfig{Period: 180 * 24 * 60 * 60},
+	RewardLock:                        AlienLockConfig{Period: 30 * 24 * 60 * 60, RlsPeriod: 180 * 24 * 60 * 60, Interval: 24 * 60 * 60},
+	CalFlowToFULRatio:                 13671875000000, // 0.014 FUL/GB
+	MinCndPledgeBalance:               new(big.Int).Mul(big.NewInt(1e+18), big.NewInt(36)),
+	MaxCandidateMiner:                 500,
+	ElectionPartitionThreshold:        36,
+	JailCredit:                        0,
+	JailPeriod:                        24 * 60 * 60,
+	VoteCooldown:                      0,
+}
+
+// DeveloperAlienEconomics is a reward schedule of minutes instead of days, so
+// rewards and locks can be exercised on a developer chain.
+var DeveloperAlienEconomics = AlienEconomics{
+	RewardCycle:                       10 * 60,
+	AccumulateFlowRewardInterval:      2 * 60,
+	AccumulateBandwidthRewardInterval: 1 * 60,
+	PaySignerRewardInterval:           0,
+	PayFlowRewardInterval:             2*60 + 30,
+	PayBandwidthRewardInterval:        1*60 + 30,
+	SignerPledgeLock:                  AlienLockConfig{Period: 30 * 60},
+	FlowPledgeLock:                    AlienLockConfig{Period: 30 * 60},
+	RewardLock:                        AlienLockConfig{Period: 10 * 60, RlsPeriod: 30 * 60, Interval: 10 * 60},
+	CalFlowToFULRatio:                 DefaultAlienEconomics.CalFlowToFULRatio,
+	MinCndPledgeBalance:               DefaultAlienEconomics.MinCndPledgeBalance,
+	MaxCandidateMiner:                 DefaultAlienEconomics.MaxCandidateMiner,
+	ElectionPartitionThreshold:        DefaultAlienEconomics.ElectionPartitionThreshold,
+	JailCredit:                        DefaultAlienEconomics.JailCredit,
+	JailPeriod:                        10 * 60,
+	VoteCooldown:                      DefaultAlienEconomics.VoteCooldown,
+}
+
+// UnmarshalJSON decodes an economics section, keeping the mainnet value of
+// every field the section leaves out.
+func (e *AlienEconomics) UnmarshalJSON(input []byte) error {
+	type economics AlienEconomics
+	dec := economics(DefaultAlienEconomics)
+	dec.MinCndPledgeBalance = new(big.Int).Set(DefaultAlienEconomics.MinCndPledgeBalance)
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*e = AlienEconomics(dec)
+	return nil
+}
 
-	TrantorBlock  *big.Int          `json:"trantorBlock,omitempty"`  // Trantor switch block (nil = no fork)
-	TerminusBlock *big.Int          `json:"terminusBlock,omitempty"` // Terminus switch block (nil = no fork)
-	LightConfig   *AlienLightConfig `json:"lightConfig,omitempty"`
+// Validate checks that the economics can be run by a chain sealing a block
+// every period seconds with queues of maxSignerCount signers.
+func (e *AlienEconomics) Validate(period uint64, maxSignerCount uint64) error {
+	if e.RewardCycle == 0 || e.RewardCycle < period {
+		return fmt.Errorf("invalid alien economics: reward cycle %d shorter than the block period %d", e.RewardCycle, period)
+	}
+	for _, interval := range []struct {
+		name  string
+		value uint64
+	}{
+		{"accumulateFlowRewardInterval", e.AccumulateFlowRewardInterval},
+		{"accumulateBandwidthRewardInterval", e.AccumulateBandwidthRewardInterval},
+		{"paySignerRewardInterval", e.PaySignerRewardInterval},
+		{"payFlowRewardInterval", e.PayFlowRewardInterval},
+		{"payBandwidthRewardInterval", e.PayBandwidthRewardInterval},
+	} {
+		if interval.value >= e.RewardCycle {
+			return fmt.Errorf("invalid alien economics: %v %d not within the reward cycle %d", interval.name, interval.value, e.RewardCycle)
+		}
+	}
+	if e.MinCndPledgeBalance == nil || e.MinCndPledgeBalance.Sign() < 0 {
+		return errors.New("invalid alien economics: missing or negative minCndPledgeBalance")
+	}
+	if e.MaxCandidateMiner == 0 {
+		return errors.New("invalid alien economics: maxCandidateMiner is zero")
+	}
+	if e.MaxCandidateMiner < maxSignerCount {
+		return fmt.Errorf("invalid alien economics: maxCandidateMiner %d below the max signer count %d", e.MaxCandidateMiner, maxSignerCount)
+	}
+	return nil
 }
 
+// Alien fork blocks of the main network, used for every AlienConfig leaving
+// the corresponding field unset.
+var (
+	AlienSignFixBlock            = big.NewInt(397520)
+	AlienGrantProfitOneTimeBlock = big.NewInt(397545)
+	AlienLockMergeBlock          = big.NewInt(397570)
+	AlienLockSimplifyBlock       = big.NewInt(397595)
+	AlienFulTrieBlock            = big.NewInt(2342357)
+	AlienBugFixBlock             = big.NewInt(14456164)
+)
+
 // String implements the stringer interface, returning the consensus engine details.
 func (a *AlienConfig) String() string {
 	return "alien"
@@ -365,6 +514,197 @@ func (a *AlienConfig) IsTerminus(num *big.Int) bool {
 	return isForked(a.TerminusBlock, num)
 }
 
+// IsRejectLog returns whether num is either equal to the RejectLog block or greater.
+func (a *AlienConfig) IsRejectLog(num *big.Int) bool {
+	return isForked(a.RejectLogBlock, num)
+}
+
+// IsRandomBeacon returns whether num is either equal to the RandomBeacon block or greater.
+func (a *AlienConfig) IsRandomBeacon(num *big.Int) bool {
+	return isForked(a.RandomBeaconBlock, num)
+}
+
+// IsDoubleSign returns whether num is either equal to the DoubleSign block or greater.
+func (a *AlienConfig) IsDoubleSign(num *big.Int) bool {
+	return isForked(a.DoubleSignBlock, num)
+}
+
+// IsUnvote returns whether num is either equal to the Unvote block or greater.
+func (a *AlienConfig) IsUnvote(num *big.Int) bool {
+	return isForked(a.UnvoteBlock, num)
+}
+
+// IsMultiSig returns whether num is either equal to the MultiSig block or greater.
+func (a *AlienConfig) IsMultiSig(num *big.Int) bool {
+	return isForked(a.MultiSigBlock, num)
+}
+
+// IsFulTransfer returns whether num is either equal to the FulTransfer block or greater.
+func (a *AlienConfig) IsFulTransfer(num *big.Int) bool {
+	return isForked(a.FulTransferBlock, num)
+}
+
+// IsSignFix returns whether num is either equal to the SignFix block or greater.
+func (a *AlienConfig) IsSignFix(num *big.Int) bool {
+	return isForked(alienForkBlock(a.SignFixBlock, AlienSignFixBlock), num)
+}
+
+// IsGrantProfitOneTime returns whether num is either equal to the GrantProfitOneTime block or greater.
+func (a *AlienConfig) IsGrantProfitOneTime(num *big.Int) bool {
+	return isForked(alienForkBlock(a.GrantProfitOneTimeBlock, AlienGrantProfitOneTimeBlock), num)
+}
+
+// IsLockMerge returns whether num is either equal to the LockMerge block or greater.
+func (a *AlienConfig) IsLockMerge(num *big.Int) bool {
+	return isForked(alienForkBlock(a.LockMergeBlock, AlienLockMergeBlock), num)
+}
+
+// IsLockSimplify returns whether num is either equal to the LockSimplify block or greater.
+func (a *AlienConfig) IsLockSimplify(num *big.Int) bool {
+	return isForked(alienForkBlock(a.LockSimplifyBlock, AlienLockSimplifyBlock), num)
+}
+
+// IsFulTrie returns whether num is either equal to the FulTrie block or greater.
+func (a *AlienConfig) IsFulTrie(num *big.Int) bool {
+	return isForked(alienForkBlock(a.FulTrieBlock, AlienFulTrieBlock), num)
+}
+
+// IsBugFix returns whether num is either equal to the BugFix block or greater.
+func (a *AlienConfig) IsBugFix(num *big.Int) bool {
+	return isForked(alienForkBlock(a.BugFixBlock, AlienBugFixBlock), num)
+}
+
+// CheckForkOrder checks that the alien fork blocks are valid and that the
+// reward lock data is merged before the simplified locking takes over.
+func (a *AlienConfig) CheckForkOrder() error {
+	forks := []struct {
+		name  string
+		block *big.Int
+	}{
+		{"signFixBlock", alienForkBlock(a.SignFixBlock, AlienSignFixBlock)},
+		{"grantProfitOneTimeBlock", alienForkBlock(a.GrantProfitOneTimeBlock, AlienGrantProfitOneTimeBlock)},
+		{"lockMergeBlock", alienForkBlock(a.LockMergeBlock, AlienLockMergeBlock)},
+		{"lockSimplifyBlock", alienForkBlock(a.LockSimplifyBlock, AlienLockSimplifyBlock)},
+		{"fulTrieBlock", alienForkBlock(a.FulTrieBlock, AlienFulTrieBlock)},
+		{"bugFixBlock", alienForkBlock(a.BugFixBlock, AlienBugFixBlock)},
+	}
+	for _, fork := range forks {
+		if fork.block.Sign() < 0 {
+			return fmt.Errorf("invalid alien fork block: %v set to %v", fork.name, fork.block)
+		}
+	}
+	merge, simplify := forks[2], forks[3]
+	if merge.block.Cmp(simplify.block) > 0 {
+		return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
+			merge.name, merge.block, simplify.name, simplify.block)
+	}
+	// These forks are carried in the header extra introduced by the FUL trie
+	fulTrie := forks[4]
+	for _, fork := range []struct {
+		name  string
+		block *big.Int
+	}{
+		{"randomBeaconBlock", a.RandomBeaconBlock},
+		{"doubleSignBlock", a.DoubleSignBlock},
+		{"unvoteBlock", a.UnvoteBlock},
+		{"multiSigBlock", a.MultiSigBlock},
+		{"fulTransferBlock", a.FulTransferBlock},
+	} {
+		if fork.block != nil && fork.block.Cmp(fulTrie.block) < 0 {
+			return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
+				fork.name, fork.block, fulTrie.name, fulTrie.block)
+		}
+	}
+	return nil
+}
+
+// checkCompatible checks whether the alien forks of newcfg can be applied to
+// a chain whose head is at the given block.
+func (a *AlienConfig) checkCompatible(newcfg *AlienConfig, head *big.Int) *ConfigCompatError {
+	for _, fork := range []struct {
+		what         string
+		stored, next *big.Int
+	}{
+		{"Alien RejectLog fork block", a.RejectLogBlock, newcfg.RejectLogBlock},
+		{"Alien SignFix fork block", alienForkBlock(a.SignFixBlock, AlienSignFixBlock), alienForkBlock(newcfg.SignFixBlock, AlienSignFixBlock)},
+		{"Alien GrantProfitOneTime fork block", alienForkBlock(a.GrantProfitOneTimeBlock, AlienGrantProfitOneTimeBlock), alienForkBlock(newcfg.GrantProfitOneTimeBlock, AlienGrantProfitOneTimeBlock)},
+		{"Alien LockMerge fork block", alienForkBlock(a.LockMergeBlock, AlienLockMergeBlock), alienForkBlock(newcfg.LockMergeBlock, AlienLockMergeBlock)},
+		{"Alien LockSimplify fork block", alienForkBlock(a.LockSimplifyBlock, AlienLockSimplifyBlock), alienForkBlock(newcfg.LockSimplifyBlock, AlienLockSimplifyBlock)},
+		{"Alien FulTrie fork block", alienForkBlock(a.FulTrieBlock, AlienFulTrieBlock), alienForkBlock(newcfg.FulTrieBlock, AlienFulTrieBlock)},
+		{"Alien BugFix fork block", alienForkBlock(a.BugFixBlock, AlienBugFixBlock), alienForkBlock(newcfg.BugFixBlock, AlienBugFixBlock)},
+		{"Alien RandomBeacon fork block", a.RandomBeaconBlock, newcfg.RandomBeaconBlock},
+		{"Alien DoubleSign fork block", a.DoubleSignBlock, newcfg.DoubleSignBlock},
+		{"Alien Unvote fork block", a.UnvoteBlock, newcfg.UnvoteBlock},
+		{"Alien MultiSig fork block", a.MultiSigBlock, newcfg.MultiSigBlock},
+		{"Alien FulTransfer fork block", a.FulTransferBlock, newcfg.FulTransferBlock},
+	} {
+		if isForkIncompatible(fork.stored, fork.next, head) {
+			return newCompatError(fork.what, fork.stored, fork.next)
+		}
+	}
+	// The fields frozen at genesis apply from the first block on
+	if head.Sign() > 0 {
+		if what := a.genesisChange(newcfg); what != "" {
+			return &ConfigCompatError{What: what}
+		}
+	}
+	return nil
+}
+
+// ErrAlienGenesisChanged is returned if the alien config frozen at genesis of
+// a chain is changed after genesis.
+var ErrAlienGenesisChanged = errors.New("alien config frozen at genesis changed")
+
+// CheckGenesisCompatible returns an error if newcfg changes the alien config
+// frozen at genesis of a chain whose head is beyond genesis. CheckCompatible
+// reports these changes as well, but as they rewind to genesis they are
+// otherwise skipped when the genesis is set up.
+func (c *ChainConfig) CheckGenesisCompatible(newcfg *ChainConfig, height uint64) error {
+	if c.Alien == nil || newcfg.Alien == nil || height == 0 {
+		return nil
+	}
+	if what := c.Alien.genesisChange(newcfg.Alien); what != "" {
+		return fmt.Errorf("%w: %s", ErrAlienGenesisChanged, what)
+	}
+	return nil
+}
+
+// genesisChange returns the name of the first field frozen at genesis which
+// newcfg changes, an empty string if there is none.
+func (a *AlienConfig) genesisChange(newcfg *AlienConfig) string {
+	if (a.ManagerAddress == nil) != (newcfg.ManagerAddress == nil) || (a.ManagerAddress != nil && *a.ManagerAddress != *newcfg.ManagerAddress) {
+		return "Alien manager address"
+	}
+	if a.SealOnDemand != newcfg.SealOnDemand {
+		return "Alien on-demand sealing"
+	}
+	// The big.Int of the pledge balance is compared by value through JSON
+	stored, _ := json.Marshal(a.economics())
+	next, _ := json.Marshal(newcfg.economics())
+	if string(stored) != string(next) {
+		return "Alien economics"
+	}
+	return ""
+}
+
+// economics returns the configured economics, or the mainnet economics if the
+// genesis left them unset.
+func (a *AlienConfig) economics() *AlienEconomics {
+	if a.Economics != nil {
+		return a.Economics
+	}
+	return &DefaultAlienEconomics
+}
+
+// alienForkBlock returns the configured fork block, or the mainnet default if
+// the genesis left it unset.
+func alienForkBlock(block, def *big.Int) *big.Int {
+	if block != nil {
+		return block
+	}
+	return def
+}
+
 // CliqueConfig is the consensus engine configs for proof-of-authority based sealing.
 type CliqueConfig struct {
 	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
@@ -539,6 +879,14 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	if c.Alien != nil {
+		if err := c.Alien.CheckForkOrder(); err != nil {
+			return err
+		}
+		if c.Alien.Economics != nil {
+			return c.Alien.Economics.Validate(c.Alien.Period, c.Alien.MaxSignerCount)
+		}
+	}
 	return nil
 }
 
@@ -592,6 +940,11 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if c.Alien != nil && newcfg.Alien != nil {
+		if err := c.Alien.checkCompatible(newcfg.Alien, head); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -17,9 +17,13 @@
 package params
 
 import (
+	"encoding/json"
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/seaskycheng/sdvn/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -87,6 +91,46 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{Alien: &AlienConfig{}},
+			new:     &ChainConfig{Alien: &AlienConfig{FulTrieBlock: new(big.Int).Set(AlienFulTrieBlock)}},
+			head:    AlienFulTrieBlock.Uint64(),
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Alien: &AlienConfig{}},
+			new:    &ChainConfig{Alien: &AlienConfig{FulTrieBlock: big.NewInt(100)}},
+			head:   200,
+			wantErr: &ConfigCompatError{
+				What:         "Alien FulTrie fork block",
+				StoredConfig: AlienFulTrieBlock,
+				NewConfig:    big.NewInt(100),
+				RewindTo:     99,
+			},
+		},
+		{
+			stored:  &ChainConfig{Alien: &AlienConfig{ManagerAddress: &common.Address{0x01}}},
+			new:     &ChainConfig{Alien: &AlienConfig{}},
+			head:    0,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{Alien: &AlienConfig{ManagerAddress: &common.Address{0x01}}},
+			new:     &ChainConfig{Alien: &AlienConfig{ManagerAddress: &common.Address{0x02}}},
+			head:    10,
+			wantErr: &ConfigCompatError{What: "Alien manager address"},
+		},
+		{
+			stored: &ChainConfig{Alien: &AlienConfig{RejectLogBlock: big.NewInt(50)}},
+			new:    &ChainConfig{Alien: &AlienConfig{}},
+			head:   200,
+			wantErr: &ConfigCompatError{
+				What:         "Alien RejectLog fork block",
+				StoredConfig: big.NewInt(50),
+				NewConfig:    nil,
+				RewindTo:     49,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +140,100 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestAlienForks(t *testing.T) {
+	var config AlienConfig
+	if err := json.Unmarshal([]byte(`{"period": 3, "fulTrieBlock": 10, "bugFixBlock": 0}`), &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	tests := []struct {
+		isForked func(*big.Int) bool
+		fork     *big.Int
+	}{
+		{config.IsSignFix, AlienSignFixBlock},
+		{config.IsGrantProfitOneTime, AlienGrantProfitOneTimeBlock},
+		{config.IsLockMerge, AlienLockMergeBlock},
+		{config.IsLockSimplify, AlienLockSimplifyBlock},
+		{config.IsFulTrie, big.NewInt(10)},
+		{config.IsBugFix, big.NewInt(0)},
+	}
+	for i, tt := range tests {
+		if tt.fork.Sign() > 0 && tt.isForked(new(big.Int).Sub(tt.fork, big.NewInt(1))) {
+			t.Errorf("test %d: forked before block %v", i, tt.fork)
+		}
+		if !tt.isForked(tt.fork) {
+			t.Errorf("test %d: not forked at block %v", i, tt.fork)
+		}
+	}
+	if err := (&ChainConfig{Alien: &config}).CheckConfigForkOrder(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+	for i, invalid := range []*AlienConfig{
+		{FulTrieBlock: big.NewInt(-1)},
+		{LockMergeBlock: big.NewInt(20), LockSimplifyBlock: big.NewInt(10)},
+		{FulTrieBlock: big.NewInt(10), DoubleSignBlock: big.NewInt(5)},
+		{FulTrieBlock: big.NewInt(10), UnvoteBlock: big.NewInt(5)},
+		{FulTrieBlock: big.NewInt(10), MultiSigBlock: big.NewInt(5)},
+		{FulTrieBlock: big.NewInt(10), FulTransferBlock: big.NewInt(5)},
+	} {
+		if err := (&ChainConfig{Alien: invalid}).CheckConfigForkOrder(); err == nil {
+			t.Errorf("test %d: invalid config accepted", i)
+		}
+	}
+}
+
+func TestAlienEconomics(t *testing.T) {
+	var config AlienConfig
+	input := `{"period": 1, "economics": {"rewardCycle": 600, "payFlowRewardInterval": 300, "rewardLock": {"period": 60}}}`
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	economics := config.Economics
+	if economics.RewardCycle != 600 || economics.PayFlowRewardInterval != 300 || economics.RewardLock.Period != 60 {
+		t.Errorf("configured values not decoded: %+v", economics)
+	}
+	if economics.AccumulateFlowRewardInterval != DefaultAlienEconomics.AccumulateFlowRewardInterval {
+		t.Errorf("default value lost: have %d, want %d", economics.AccumulateFlowRewardInterval, DefaultAlienEconomics.AccumulateFlowRewardInterval)
+	}
+	if economics.RewardLock.RlsPeriod != DefaultAlienEconomics.RewardLock.RlsPeriod {
+		t.Errorf("default lock value lost: have %d, want %d", economics.RewardLock.RlsPeriod, DefaultAlienEconomics.RewardLock.RlsPeriod)
+	}
+	if economics.MinCndPledgeBalance == DefaultAlienEconomics.MinCndPledgeBalance {
+		t.Errorf("default pledge balance shared")
+	}
+	// The default accumulation intervals lie beyond the configured cycle
+	if err := (&ChainConfig{Alien: &config}).CheckConfigForkOrder(); err == nil {
+		t.Errorf("intervals outside the reward cycle accepted")
+	}
+	economics.AccumulateFlowRewardInterval, economics.AccumulateBandwidthRewardInterval, economics.PayBandwidthRewardInterval = 100, 100, 200
+	if err := (&ChainConfig{Alien: &config}).CheckConfigForkOrder(); err != nil {
+		t.Errorf("valid economics rejected: %v", err)
+	}
+	config.MaxSignerCount = economics.MaxCandidateMiner + 1
+	if err := (&ChainConfig{Alien: &config}).CheckConfigForkOrder(); err == nil {
+		t.Errorf("fewer candidates than signers accepted")
+	}
+	// The economics can't change once the chain grew beyond genesis
+	changed := *economics
+	changed.RewardCycle = 1200
+	stored, next := &ChainConfig{Alien: &config}, &ChainConfig{Alien: &AlienConfig{Economics: &changed}}
+	if err := stored.CheckGenesisCompatible(next, 0); err != nil {
+		t.Errorf("economics change refused at genesis: %v", err)
+	}
+	if err := stored.CheckGenesisCompatible(next, 1); !errors.Is(err, ErrAlienGenesisChanged) {
+		t.Errorf("economics change accepted beyond genesis: %v", err)
+	}
+	same := *economics
+	same.MinCndPledgeBalance = new(big.Int).Set(economics.MinCndPledgeBalance)
+	if err := stored.CheckGenesisCompatible(&ChainConfig{Alien: &AlienConfig{Economics: &same}}, 1); err != nil {
+		t.Errorf("unchanged economics refused: %v", err)
+	}
+	if err := (&ChainConfig{Alien: &AlienConfig{}}).CheckGenesisCompatible(&ChainConfig{Alien: &AlienConfig{Economics: &DefaultAlienEconomics}}, 1); err != nil {
+		t.Errorf("mainnet economics refused: %v", err)
+	}
+	config.MaxSignerCount = 0
+	config.Period = 700
+	if err := (&ChainConfig{Alien: &config}).CheckConfigForkOrder(); err == nil {
+		t.Errorf("reward cycle shorter than the period accepted")
+	}
+}